@@ -0,0 +1,93 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package anomaly defines stable codes for the conditions that the client detects
+// during a test and that affect how its results should be read. Unlike the warnings
+// (which are meant for people and may be reworded), the codes never change, so that
+// programs can act on them.
+package anomaly
+
+import "sort"
+
+const (
+	// The client could not keep up with its own schedule (e.g., it missed the
+	// deadline for sampling the throughput) or used (about) all the CPU, so it may
+	// have limited the results.
+	ClientCPUBound = "client-cpu-bound"
+	// A direction did not reach saturation before the time to do so ran out.
+	Unsaturated = "unsaturated"
+	// The data about the load-generating connections had to be collected before
+	// the test was complete.
+	ProvisionalData = "provisional-data"
+	// A probe was answered by a cache or the server permits caching of a
+	// measurement resource.
+	CacheDetected = "cache-detected"
+	// The wall clock jumped during the test.
+	ClockJump = "clock-jump"
+	// The system was suspended during the test.
+	Suspended = "suspended"
+	// Some probes failed.
+	ProbeErrors = "probe-errors"
+	// No download load could be generated.
+	DownloadFailed = "download-failed"
+	// No upload load could be generated.
+	UploadFailed = "upload-failed"
+	// The small download resource is too large for its serialization delay to be
+	// negligible.
+	LargeSmallObject = "large-small-object"
+	// Downloaded data did not match the server's digest or the expected pattern.
+	IntegrityMismatch = "integrity-mismatch"
+	// Load-generating connections stopped making progress (and were replaced).
+	StalledFlows = "stalled-flows"
+	// The server interrupted load-generating connections (with a GOAWAY or a
+	// RST_STREAM).
+	ServerInterruptions = "server-interruptions"
+	// Extended statistics were requested but are not available on this platform.
+	ExtendedStatsUnavailable = "extended-stats-unavailable"
+	// The network (the addresses of the interfaces or the route to the test server)
+	// changed during the test.
+	NetworkChanged = "network-changed"
+	// Something between the client and the test server (a captive portal or an
+	// intercepting proxy) seems to answer in the server's place.
+	MiddleboxSuspected = "middlebox-suspected"
+)
+
+// A Set collects the codes of the anomalies that were detected (each one once).
+type Set struct {
+	codes map[string]bool
+}
+
+// Add records that the anomaly with the given code was detected.
+func (s *Set) Add(code string) {
+	if s.codes == nil {
+		s.codes = make(map[string]bool)
+	}
+	s.codes[code] = true
+}
+
+// Has returns whether the anomaly with the given code was detected.
+func (s *Set) Has(code string) bool {
+	return s.codes[code]
+}
+
+// Codes returns the codes of the detected anomalies in alphabetical order (an empty
+// slice, rather than nil, when there are none).
+func (s *Set) Codes() []string {
+	codes := make([]string, 0, len(s.codes))
+	for code := range s.codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
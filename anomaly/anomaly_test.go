@@ -0,0 +1,36 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package anomaly
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	set := Set{}
+	if codes := set.Codes(); codes == nil || len(codes) != 0 {
+		t.Fatalf("An empty set has codes %v", codes)
+	}
+	set.Add(Unsaturated)
+	set.Add(CacheDetected)
+	set.Add(Unsaturated)
+	if !set.Has(Unsaturated) || set.Has(ClockJump) {
+		t.Fatalf("The set does not have the right codes: %v", set.Codes())
+	}
+	if codes := set.Codes(); !reflect.DeepEqual(codes, []string{CacheDetected, Unsaturated}) {
+		t.Fatalf("The set has codes %v", codes)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package auth authenticates the client to test servers that are only open to some
+// (e.g., internal servers of an organization): every request to the test server (for
+// the configuration, the load and the probes) carries the same Authorization header.
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/redirect"
+)
+
+// The value of the Authorization header ("" when there is none).
+var authorization = ""
+
+// SetToken authenticates all requests with the bearer token _token_.
+func SetToken(token string) {
+	authorization = "Bearer " + token
+}
+
+// SetBasic authenticates all requests with _credentials_ (user:password) through basic
+// authentication.
+func SetBasic(credentials string) error {
+	if !strings.Contains(credentials, ":") {
+		return fmt.Errorf("The credentials for basic authentication must be user:password")
+	}
+	authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	return nil
+}
+
+// Apply sets the Authorization header of _request_ (if there is one to set and
+// _request_ is not for a host to which the test server redirected from another; see
+// redirect.MarkForeign).
+func Apply(request *http.Request) {
+	if authorization != "" && !redirect.IsForeign(redirect.Address(request.URL)) {
+		request.Header.Set("Authorization", authorization)
+	}
+}
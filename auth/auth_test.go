@@ -0,0 +1,61 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/redirect"
+)
+
+func TestApply(t *testing.T) {
+	defer func() { authorization = "" }()
+
+	request, _ := http.NewRequest("GET", "https://example.com/config", nil)
+	Apply(request)
+	if request.Header.Get("Authorization") != "" {
+		t.Fatalf("Authenticated without credentials")
+	}
+
+	if err := SetBasic("user"); err == nil {
+		t.Fatalf("Accepted credentials without a password")
+	}
+	if err := SetBasic("user:pass"); err != nil {
+		t.Fatalf("Could not set the credentials: %v", err)
+	}
+	Apply(request)
+	if user, pass, ok := request.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Fatalf("Expected basic authentication as user:pass but got %v", request.Header)
+	}
+
+	SetToken("secret")
+	Apply(request)
+	if value := request.Header.Get("Authorization"); value != "Bearer secret" {
+		t.Fatalf("Expected the bearer token but got %s", value)
+	}
+}
+
+func TestApplyToForeignHost(t *testing.T) {
+	defer func() { authorization = "" }()
+
+	SetToken("secret")
+	request, _ := http.NewRequest("GET", "https://elsewhere.example.com/large", nil)
+	redirect.MarkForeign(request.URL)
+	Apply(request)
+	if value := request.Header.Get("Authorization"); value != "" {
+		t.Fatalf("Sent the credentials to a host that the test server redirected to: %s", value)
+	}
+}
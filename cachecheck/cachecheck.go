@@ -0,0 +1,92 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cachecheck determines whether a test server permits caches to store the
+// resources that are used for measuring. When they do, a cache between the client and
+// the server can answer probes and make the network look more responsive than it is.
+package cachecheck
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+type Report struct {
+	URL          string `json:"url"`
+	CacheControl string `json:"cache_control"`
+	Age          string `json:"age"`
+	Cacheable    bool   `json:"cacheable"`
+	Reason       string `json:"reason"`
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%s (Cache-Control: %q, Age: %q): %s", r.URL, r.CacheControl, r.Age, r.Reason)
+}
+
+// Cacheable determines, from the headers of a response, whether a shared cache may
+// store the response (and why).
+func Cacheable(header http.Header) (bool, string) {
+	if header.Get("Age") != "" {
+		return true, "the response came from a cache"
+	}
+	cacheControl := header.Values("Cache-Control")
+	if len(cacheControl) == 0 {
+		return true, "there is no Cache-Control header to forbid caching"
+	}
+	for _, directive := range strings.Split(strings.Join(cacheControl, ","), ",") {
+		switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(directive), " ", "")) {
+		case "no-store":
+			return false, "no-store forbids caching"
+		case "no-cache":
+			return false, "no-cache requires revalidation"
+		case "private":
+			return false, "private forbids shared caches"
+		case "max-age=0", "s-maxage=0":
+			return false, "the response is immediately stale"
+		}
+	}
+	return true, "Cache-Control does not forbid caching"
+}
+
+// Check requests _url_ (with _method_) and reports whether the response may be cached.
+func Check(method string, url string) (Report, error) {
+	request, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return Report{}, err
+	}
+	config.Prepare(request)
+	client := config.NewClient(request.URL.Scheme)
+	client.Timeout = constants.CacheCheckTimeout
+	defer client.CloseIdleConnections()
+	response, err := client.Do(request)
+	if err != nil {
+		return Report{}, fmt.Errorf("Could not check the caching headers of %s: %v", url, err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	cacheable, reason := Cacheable(response.Header)
+	return Report{
+		URL:          url,
+		CacheControl: strings.Join(response.Header.Values("Cache-Control"), ", "),
+		Age:          response.Header.Get("Age"),
+		Cacheable:    cacheable,
+		Reason:       reason,
+	}, nil
+}
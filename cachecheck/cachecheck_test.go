@@ -0,0 +1,40 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cachecheck
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCacheable(t *testing.T) {
+	cases := []struct {
+		header    http.Header
+		cacheable bool
+	}{
+		{http.Header{}, true},
+		{http.Header{"Cache-Control": []string{"public, max-age=3600"}}, true},
+		{http.Header{"Cache-Control": []string{"no-store"}}, false},
+		{http.Header{"Cache-Control": []string{"public", "No-Cache"}}, false},
+		{http.Header{"Cache-Control": []string{"private, max-age=60"}}, false},
+		{http.Header{"Cache-Control": []string{"s-maxage=0"}}, false},
+		{http.Header{"Cache-Control": []string{"no-store"}, "Age": []string{"3"}}, true},
+	}
+	for _, c := range cases {
+		if cacheable, reason := Cacheable(c.header); cacheable != c.cacheable {
+			t.Fatalf("Expected %v to be cacheable: %v (%s).", c.header, c.cacheable, reason)
+		}
+	}
+}
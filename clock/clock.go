@@ -0,0 +1,216 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package clock abstracts how the test keeps time, so that tests and simulations can
+// drive the probers, the data collection and the timeouts with a clock of their own
+// (see Fake) instead of waiting for the wall clock.
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Clock tells the time and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	// After waits for _d_ to pass and then sends the time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer is a time.Timer of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the clock of the system.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+// Fake is a clock whose time only passes when it is advanced (see Advance).
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake creates a fake clock that starts at _now_.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{fake: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time of the clock forward by _d_ and fires the timers that are due
+// (in the order of their deadlines).
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool { return f.timers[i].when.Before(f.timers[j].when) })
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.when.After(f.now) {
+			pending = append(pending, t)
+			continue
+		}
+		select {
+		case t.c <- f.now:
+		default:
+		}
+	}
+	f.timers = pending
+}
+
+// Waiters returns the number of timers that have not fired yet (e.g., so that a test
+// knows when whatever it drives is waiting for the time to pass).
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// Drive advances the clock, until _ctx_ is done, whenever whatever keeps time by it
+// has settled: once the timers that wait for it (see Waiters) stayed the same for
+// _pause_ of the wall clock, it advances the clock to the first of their deadlines (but
+// by no more than _step_). The clock then runs as fast as whatever keeps time by it
+// allows rather than as fast as the wall clock, and the same timers fire at the same
+// times from one run to the next.
+func (f *Fake) Drive(ctx context.Context, step time.Duration, pause time.Duration) {
+	waiting := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+		next, count := f.next()
+		if count == 0 || count != waiting {
+			waiting = count
+			continue
+		}
+		if next > step {
+			next = step
+		}
+		f.Advance(next)
+		waiting = -1
+	}
+}
+
+// next returns how long it is until the first deadline of the timers and how many of
+// them there are.
+func (f *Fake) next() (time.Duration, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.timers) == 0 {
+		return 0, 0
+	}
+	first := f.timers[0].when
+	for _, t := range f.timers[1:] {
+		if t.when.Before(first) {
+			first = t.when
+		}
+	}
+	return first.Sub(f.now), len(f.timers)
+}
+
+type fakeTimer struct {
+	fake *Fake
+	when time.Time
+	c    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	return t.remove()
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	active := t.remove()
+	t.when = t.fake.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- t.fake.now:
+		default:
+		}
+		return active
+	}
+	t.fake.timers = append(t.fake.timers, t)
+	return active
+}
+
+// remove removes the timer from the pending ones of its clock (which must be locked)
+// and returns whether it was pending.
+func (t *fakeTimer) remove() bool {
+	for i, pending := range t.fake.timers {
+		if pending == t {
+			t.fake.timers = append(t.fake.timers[:i], t.fake.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
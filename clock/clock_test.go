@@ -0,0 +1,79 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	later := fake.After(2 * time.Second)
+	sooner := fake.NewTimer(time.Second)
+	stopped := fake.NewTimer(time.Second)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatalf("Expected a pending timer to stop (once).")
+	}
+	if fake.Waiters() != 2 {
+		t.Fatalf("Expected 2 waiters but got %d.", fake.Waiters())
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case now := <-sooner.C():
+		if !now.Equal(start.Add(time.Second)) {
+			t.Fatalf("Expected the timer to fire at %v but it fired at %v.", start.Add(time.Second), now)
+		}
+	default:
+		t.Fatalf("Expected the timer to fire after a second.")
+	}
+	select {
+	case <-later:
+		t.Fatalf("Expected After(2s) not to fire after a second.")
+	case <-stopped.C():
+		t.Fatalf("Expected a stopped timer not to fire.")
+	default:
+	}
+
+	if sooner.Reset(time.Second) {
+		t.Fatalf("Expected a timer that fired not to be pending.")
+	}
+	fake.Advance(time.Second)
+	<-later
+	<-sooner.C()
+	if fake.Waiters() != 0 || !fake.Now().Equal(start.Add(2*time.Second)) {
+		t.Fatalf("Expected no waiters at %v but got %d at %v.", start.Add(2*time.Second), fake.Waiters(), fake.Now())
+	}
+}
+
+func TestDrive(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go fake.Drive(ctx, time.Second, time.Millisecond)
+
+	// The clock goes straight to a deadline that is closer than a step ...
+	if now := <-fake.After(300 * time.Millisecond); !now.Equal(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("Expected the timer to fire at %v but it fired at %v.", start.Add(300*time.Millisecond), now)
+	}
+	// ... and takes a step at a time toward one that is farther.
+	if now := <-fake.After(2500 * time.Millisecond); !now.Equal(start.Add(2800 * time.Millisecond)) {
+		t.Fatalf("Expected the timer to fire at %v but it fired at %v.", start.Add(2800*time.Millisecond), now)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package clockwatch detects jumps of the wall clock (e.g., an NTP step or the system
+// being suspended and resumed) during a test by comparing how much the wall clock and
+// the monotonic clock advance.
+package clockwatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// Jump is a jump of the wall clock that happened sometime between Start and End.
+type Jump struct {
+	Start time.Time
+	End   time.Time
+	Size  time.Duration
+}
+
+func (j Jump) String() string {
+	return fmt.Sprintf("%v between %v and %v", j.Size, j.Start.Format(time.StampMilli), j.End.Format(time.StampMilli))
+}
+
+// IsSuspend is true when the jump looks like the system was suspended and resumed
+// rather than like the wall clock being adjusted.
+func (j Jump) IsSuspend() bool {
+	return j.Size >= constants.SuspendThreshold
+}
+
+// Overlaps is true when the interval that begins at _start_ and lasts for _duration_
+// overlaps the time during which the jump happened.
+func (j Jump) Overlaps(start time.Time, duration time.Duration) bool {
+	return start.Before(j.End) && start.Add(duration).After(j.Start)
+}
+
+type Watcher struct {
+	lock      sync.Mutex
+	start     time.Time
+	lastCheck time.Time
+	offset    time.Duration
+	jumps     []Jump
+}
+
+func NewWatcher(start time.Time) *Watcher {
+	return &Watcher{start: start, lastCheck: start}
+}
+
+// offset is how far the wall clock has advanced beyond the monotonic clock since _start_.
+func (w *Watcher) offsetAt(now time.Time) time.Duration {
+	// Round(0) strips the monotonic clock reading, leaving only the wall clock.
+	return now.Round(0).Sub(w.start.Round(0)) - now.Sub(w.start)
+}
+
+// Check looks for a jump since the last check and returns it (or nil).
+func (w *Watcher) Check(now time.Time) *Jump {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	offset := w.offsetAt(now)
+	change := offset - w.offset
+	var jump *Jump = nil
+	if change > constants.ClockJumpThreshold || change < -constants.ClockJumpThreshold {
+		jump = &Jump{Start: w.lastCheck, End: now, Size: change}
+		w.jumps = append(w.jumps, *jump)
+	}
+	w.offset = offset
+	w.lastCheck = now
+	return jump
+}
+
+// Run checks for jumps every _interval_ until _ctx_ is canceled.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check(time.Now())
+		}
+	}
+}
+
+// Suspended is true when the system was (apparently) suspended at some point.
+func (w *Watcher) Suspended() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, jump := range w.jumps {
+		if jump.IsSuspend() {
+			return true
+		}
+	}
+	return false
+}
+
+// Jumps returns all the jumps that were detected.
+func (w *Watcher) Jumps() []Jump {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]Jump(nil), w.jumps...)
+}
@@ -22,15 +22,26 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/network-quality/goresponsiveness/auth"
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/hostoverride"
+	"github.com/network-quality/goresponsiveness/redirect"
+	"github.com/network-quality/goresponsiveness/useragent"
 	"github.com/network-quality/goresponsiveness/utilities"
 	"golang.org/x/net/http2"
 )
 
+// How long each attempt to fetch the configuration may take.
+var timeout = constants.ConfigTimeout
+
 type ConfigUrls struct {
 	SmallUrl  string `json:"small_https_download_url"`
 	LargeUrl  string `json:"large_https_download_url"`
 	UploadUrl string `json:"https_upload_url"`
+	// Optional: where the server describes itself (see package servermeta).
+	MetadataUrl string `json:"metadata_url,omitempty"`
 }
 
 type Config struct {
@@ -38,28 +49,75 @@ type Config struct {
 	Urls          ConfigUrls `json:"urls"`
 	Source        string
 	Test_Endpoint string
+	// The redirects that led to the configuration.
+	Redirects []redirect.Hop `json:"-"`
 }
 
 func (c *Config) Get(configHost string, configPath string) error {
-	configTransport := http2.Transport{}
-	configTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	configClient := &http.Client{Transport: &configTransport}
+	return c.GetUrl(Url(configHost, configPath))
+}
+
+// Url returns the URL of the configuration at _configPath_ on _configHost_
+// (host[:port]).
+func Url(configHost string, configPath string) string {
 	// Extraneous /s in URLs is normally okay, but the Apple CDN does not
 	// like them. Make sure that we put exactly one (1) / between the host
 	// and the path.
 	if !strings.HasPrefix(configPath, "/") {
 		configPath = "/" + configPath
 	}
-	c.Source = fmt.Sprintf("https://%s%s", configHost, configPath)
-	resp, err := configClient.Get(c.Source)
+	return fmt.Sprintf("https://%s%s", configHost, configPath)
+}
+
+// NewClient returns a client for the test server's URLs with _scheme_.
+func NewClient(scheme string) *http.Client {
+	client := &http.Client{}
+	if scheme == "https" {
+		client.Transport = &hostoverride.Transport{New: func() *http2.Transport {
+			return &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		}}
+	}
+	return client
+}
+
+// Prepare prepares a request to the test server like all the others (see packages
+// useragent, auth and hostoverride).
+func Prepare(request *http.Request) {
+	useragent.Apply(request)
+	auth.Apply(request)
+	hostoverride.Apply(request)
+}
+
+// GetUrl gets the configuration from _configUrl_. For labs, that may be a plain-HTTP
+// URL. It gives up on any of its requests (with the response) that takes longer than
+// constants.ConfigTimeout.
+func (c *Config) GetUrl(configUrl string) error {
+	parsed, err := url.Parse(configUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("Error: %s is not an https (or http) URL\n", configUrl)
+	}
+	c.Source = configUrl
+	client := NewClient(parsed.Scheme)
+	client.Timeout = timeout
+	resp, redirects, err := redirect.Follow(client, "GET", c.Source, Prepare)
+	c.Redirects = redirects
 	if err != nil {
 		return fmt.Errorf(
 			"Error: Could not connect to configuration host %s: %v\n",
-			configHost,
+			parsed.Host,
 			err,
 		)
 	}
 
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf(
+			"Error: Fetching the configuration from %s failed: %s\n",
+			c.Source,
+			resp.Status,
+		)
+	}
+
 	jsonConfig, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf(
@@ -99,6 +157,105 @@ func (c *Config) Get(configHost string, configPath string) error {
 	return nil
 }
 
+// GetWithRetry gets the configuration (see GetUrl) from the first of _configUrls_ that
+// serves it: the first is the primary one and the others are fallbacks. When none of
+// them does, it tries all of them again (up to _attempts_ rounds in all), waiting
+// _backoff_ before the second round and twice as long before every round after that.
+// _failed_ (if not nil) learns about every failed attempt. The error is that of the
+// last attempt.
+func (c *Config) GetWithRetry(
+	configUrls []string,
+	attempts int,
+	backoff time.Duration,
+	failed func(configUrl string, attempt int, err error),
+) (err error) {
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		for _, configUrl := range configUrls {
+			// Nothing of a configuration that failed to parse may linger.
+			*c = Config{}
+			if err = c.GetUrl(configUrl); err == nil {
+				return nil
+			}
+			if failed != nil {
+				failed(configUrl, attempt, err)
+			}
+		}
+	}
+	return err
+}
+
+// ResolveRedirects replaces each of the URLs of the small, large and upload resources
+// that redirects with the URL where the redirects end (so that the test does not
+// measure them) and returns the redirects. To find them, it makes one request of each
+// resource, whether or not it redirects: a GET of the small and of the large resource
+// (of which it reads only the headers) and an empty POST to the upload URL. The servers
+// of the test URLs as configured are the test server (see redirect.MarkOrigin); any
+// other that the redirects lead to is foreign (see redirect.Follow) so that neither
+// the resolution nor the test sends it the credentials or the overrides of the Host
+// header and the server name.
+func (c *Config) ResolveRedirects() ([]redirect.Hop, error) {
+	redirects := make([]redirect.Hop, 0)
+	resources := []struct {
+		method string
+		url    *string
+	}{
+		{"GET", &c.Urls.SmallUrl},
+		{"GET", &c.Urls.LargeUrl},
+		{"POST", &c.Urls.UploadUrl},
+	}
+	for _, resource := range resources {
+		parsed, err := url.Parse(*resource.url)
+		if err != nil {
+			return redirects, fmt.Errorf("Could not parse %s: %v", *resource.url, err)
+		}
+		redirect.MarkOrigin(parsed)
+	}
+	for _, resource := range resources {
+		parsed, _ := url.Parse(*resource.url)
+		// The body of the large resource is not needed (and only its headers are read).
+		response, hops, err := redirect.Follow(NewClient(parsed.Scheme), resource.method, *resource.url, Prepare)
+		redirects = append(redirects, hops...)
+		if err != nil {
+			return redirects, fmt.Errorf("Could not follow the redirects of %s: %v", *resource.url, err)
+		}
+		response.Body.Close()
+		if len(hops) > 0 {
+			*resource.url = hops[len(hops)-1].Location
+		}
+	}
+	return redirects, nil
+}
+
+// Shard returns the URLs for each of _endpoints_ (host[:port]): they are the configured
+// URLs with their hosts replaced. The configured URLs themselves come first.
+func (c *Config) Shard(endpoints []string) ([]ConfigUrls, error) {
+	shards := []ConfigUrls{c.Urls}
+	for _, endpoint := range endpoints {
+		shard := ConfigUrls{}
+		for _, pair := range []struct {
+			from string
+			to   *string
+		}{
+			{c.Urls.SmallUrl, &shard.SmallUrl},
+			{c.Urls.LargeUrl, &shard.LargeUrl},
+			{c.Urls.UploadUrl, &shard.UploadUrl},
+		} {
+			parsed, err := url.Parse(pair.from)
+			if err != nil {
+				return nil, fmt.Errorf("Could not shard %s: %v", pair.from, err)
+			}
+			parsed.Host = endpoint
+			*pair.to = parsed.String()
+		}
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+
 func (c *Config) String() string {
 	return fmt.Sprintf(
 		"Version: %d\nSmall URL: %s\nLarge URL: %s\nUpload URL: %s\nEndpoint: %s\n",
@@ -0,0 +1,202 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/hostoverride"
+)
+
+// A server records the server names of its handshakes and the Host headers of its
+// requests.
+type server struct {
+	*httptest.Server
+	lock        sync.Mutex
+	serverNames []string
+	hosts       []string
+}
+
+func newServer(handler http.HandlerFunc) *server {
+	s := &server{}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.hosts = append(s.hosts, r.Host)
+		s.lock.Unlock()
+		handler(w, r)
+	}))
+	s.EnableHTTP2 = true
+	s.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		s.lock.Lock()
+		s.serverNames = append(s.serverNames, hello.ServerName)
+		s.lock.Unlock()
+		return nil, nil
+	}}
+	s.StartTLS()
+	return s
+}
+
+func TestOverridesStayOffForeignServers(t *testing.T) {
+	defer func() {
+		hostoverride.SetServerName("")
+		hostoverride.SetHost("")
+	}()
+	hostoverride.SetServerName("test.example")
+	hostoverride.SetHost("test.example")
+
+	foreign := newServer(func(w http.ResponseWriter, r *http.Request) {})
+	defer foreign.Close()
+	origin := newServer(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, foreign.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	})
+	defer origin.Close()
+
+	c := Config{Urls: ConfigUrls{
+		SmallUrl:  origin.URL + "/small",
+		LargeUrl:  origin.URL + "/large",
+		UploadUrl: origin.URL + "/upload",
+	}}
+	if _, err := c.ResolveRedirects(); err != nil {
+		t.Fatalf("Could not resolve the redirects: %v", err)
+	}
+	if c.Urls.LargeUrl != foreign.URL+"/large" {
+		t.Fatalf("Expected the large URL to resolve to the other server but got %s", c.Urls.LargeUrl)
+	}
+
+	request, _ := http.NewRequest("GET", c.Urls.LargeUrl, nil)
+	Prepare(request)
+	response, err := NewClient("https").Do(request)
+	if err != nil {
+		t.Fatalf("Could not get the resolved URL: %v", err)
+	}
+	response.Body.Close()
+
+	for _, name := range origin.serverNames {
+		if name != "test.example" {
+			t.Fatalf("Expected the overridden server name at the test server but got %q", name)
+		}
+	}
+	for _, name := range foreign.serverNames {
+		if name == "test.example" {
+			t.Fatalf("Sent the overridden server name to the server that the test server redirected to")
+		}
+	}
+	for _, host := range foreign.hosts {
+		if host == "test.example" {
+			t.Fatalf("Sent the overridden Host header to the server that the test server redirected to")
+		}
+	}
+	if len(origin.serverNames) == 0 || len(foreign.serverNames) == 0 {
+		t.Fatalf("Expected handshakes with both servers")
+	}
+}
+
+func TestGetWithRetry(t *testing.T) {
+	defer func(original time.Duration) { timeout = original }(timeout)
+	timeout = 100 * time.Millisecond
+
+	var lock sync.Mutex
+	requested := make([]string, 0)
+	record := func(name string) {
+		lock.Lock()
+		defer lock.Unlock()
+		requested = append(requested, name)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	hanging := newServer(func(w http.ResponseWriter, r *http.Request) {
+		record("hanging")
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	})
+	defer hanging.Close()
+	// What parses of its configuration must not linger after it fails.
+	invalid := newServer(func(w http.ResponseWriter, r *http.Request) {
+		record("invalid")
+		fmt.Fprint(w, `{"version": 7, "test_endpoint": "stale.example", "urls": 5}`)
+	})
+	defer invalid.Close()
+	var valid *server
+	failures := 1
+	valid = newServer(func(w http.ResponseWriter, r *http.Request) {
+		record("valid")
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"urls": {"small_https_download_url": "%s/small"}}`, valid.URL)
+	})
+	defer valid.Close()
+
+	failed := make([]string, 0)
+	c := Config{}
+	err := c.GetWithRetry(
+		[]string{hanging.URL + "/config", invalid.URL + "/config", valid.URL + "/config"},
+		3,
+		time.Millisecond,
+		func(configUrl string, attempt int, err error) {
+			failed = append(failed, fmt.Sprintf("%s %d", configUrl, attempt))
+		},
+	)
+	if err != nil {
+		t.Fatalf("Could not get the configuration: %v", err)
+	}
+
+	expected := []string{"hanging", "invalid", "valid", "hanging", "invalid", "valid"}
+	lock.Lock()
+	if fmt.Sprint(requested) != fmt.Sprint(expected) {
+		t.Fatalf("Expected the requests %v but got %v", expected, requested)
+	}
+	lock.Unlock()
+	expected = []string{
+		hanging.URL + "/config 1", invalid.URL + "/config 1", valid.URL + "/config 1",
+		hanging.URL + "/config 2", invalid.URL + "/config 2",
+	}
+	if fmt.Sprint(failed) != fmt.Sprint(expected) {
+		t.Fatalf("Expected the failures %v but got %v", expected, failed)
+	}
+	if c.Version != 0 || c.Test_Endpoint != "" || c.Source != valid.URL+"/config" ||
+		c.Urls.SmallUrl != valid.URL+"/small" {
+		t.Fatalf("Expected only the configuration of %s but got %v", valid.URL, c)
+	}
+}
+
+func TestGetWithRetryGivesUp(t *testing.T) {
+	requests := 0
+	unavailable := newServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer unavailable.Close()
+
+	c := Config{}
+	if err := c.GetWithRetry([]string{unavailable.URL + "/config"}, 3, time.Millisecond, nil); err == nil {
+		t.Fatalf("Got a configuration from a server that did not serve one")
+	}
+	if requests != 3 {
+		t.Fatalf("Expected 3 attempts but got %d", requests)
+	}
+}
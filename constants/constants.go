@@ -28,6 +28,12 @@ var (
 	AdditiveNumberOfLoadGeneratingConnections uint64 = 4
 	// The cutoff of the percent difference that defines instability.
 	InstabilityDelta float64 = 5
+	// How far (in percent) below its target the throughput may be to count as at the
+	// target (see -target-rate-mbps).
+	TargetRateTolerance float64 = 5
+	// The number of consecutive intervals without any progress after which a
+	// load-generating connection counts as stalled (and is replaced).
+	StallIntervalCount int = 3
 
 	// The amount of time that the client will cooldown if it is in debug mode.
 	CooldownPeriod time.Duration = 4 * time.Second
@@ -44,4 +50,178 @@ var (
 	DefaultDebug bool = false
 	// The default URL for the config host.
 	DefaultConfigHost string = "networkquality.example.com"
+	// The default number of rounds in which to try to fetch the configuration (from the
+	// config host and its fallbacks).
+	DefaultConfigAttempts int = 3
+	// How long to wait before the second round of trying to fetch the configuration
+	// (the wait doubles for every round after that).
+	ConfigRetryBackoff time.Duration = 1 * time.Second
+	// The maximum amount of time to spend on each attempt to fetch the configuration.
+	ConfigTimeout time.Duration = 10 * time.Second
+	// The default number of redirects that a request to the test server may take.
+	DefaultMaxRedirects int = 10
+
+	// The maximum amount of time to spend establishing (TCP + TLS) a connection.
+	DialTimeout time.Duration = 10 * time.Second
+	// The amount of time a connection may go without receiving a frame before
+	// it is health-checked with a PING.
+	ConnectionReadIdleTimeout time.Duration = 5 * time.Second
+	// The amount of time to wait for the response to a health-check PING before
+	// considering a connection dead.
+	ConnectionPingTimeout time.Duration = 5 * time.Second
+	// The amount of time a connection may be unable to make progress writing
+	// before it is considered dead.
+	ConnectionWriteTimeout time.Duration = 10 * time.Second
+	// The number of times that a load-generating connection reconnects after the server
+	// interrupted it (with a GOAWAY or a RST_STREAM) before it gives up.
+	MaximumInterruptions uint64 = 10
+
+	// The maximum amount of time to spend pushing results to a metrics collector.
+	PushTimeout time.Duration = 10 * time.Second
+	// The maximum amount of time to spend notifying a webhook about results.
+	WebhookTimeout time.Duration = 10 * time.Second
+	// The maximum amount of time to spend submitting results to a collection service.
+	SubmitTimeout time.Duration = 10 * time.Second
+
+	// The shortest time that a single round trip of a probe can plausibly take. Probes
+	// that complete faster than that must have been answered by something other than
+	// the server (e.g., a cache).
+	MinimumProbeRoundTripDuration time.Duration = 10 * time.Microsecond
+
+	// The amount of time that probes still in flight when their phase ends are given
+	// to complete before they are canceled.
+	LateProbeGracePeriod time.Duration = 2 * time.Second
+
+	// The RPM from which the responsiveness counts as medium (below, it is low).
+	ResponsivenessMediumRPM float64 = 300
+	// The RPM from which the responsiveness counts as high.
+	ResponsivenessHighRPM float64 = 1000
+
+	// The largest small download resource whose serialization delay is negligible.
+	MaximumSmallObjectSize uint64 = 1024
+
+	// The MTU that is assumed when estimating the bytes on the wire (see package wire).
+	WireMTU int = 1500
+
+	// The maximum amount of time to spend checking the caching headers of a resource.
+	CacheCheckTimeout time.Duration = 5 * time.Second
+	// The maximum amount of time to spend fetching the server's metadata.
+	ServerMetadataTimeout time.Duration = 5 * time.Second
+	// The maximum amount of time to spend checking each resource before the test.
+	PreflightTimeout time.Duration = 5 * time.Second
+	// The maximum amount of time to spend on each request that checks for middleboxes.
+	MiddleboxCheckTimeout time.Duration = 5 * time.Second
+	// How much of a response to read when checking for middleboxes.
+	MiddleboxBodyLimit int64 = 64 * 1024
+
+	// The maximum amount of time to spend on each of the checks of a server's compliance.
+	ServerCheckTimeout time.Duration = 10 * time.Second
+	// The amount of data that must be available from a server's large download URL.
+	ServerCheckDownloadSize int64 = 8 * 1024 * 1024
+	// The amount of data to send to a server's upload URL when checking it.
+	ServerCheckUploadSize int64 = 1024 * 1024
+
+	// The amount of data that is read from the upload source (and then sent repeatedly).
+	UploadPayloadSize int = 4 * 1024 * 1024
+	// The size of the (pooled) buffers through which the load-generating downloads are
+	// read.
+	DownloadCopyBufferSize int = 64 * 1024
+
+	// The longest that a data logger keeps records in its buffer before writing them out.
+	DataLoggerFlushInterval time.Duration = 1 * time.Second
+
+	// How often to compare the network with what it was (see package netwatch).
+	NetworkCheckInterval time.Duration = 1 * time.Second
+
+	// How often to sample the CPU usage (see package cpuload).
+	CPUSampleInterval time.Duration = 1 * time.Second
+	// The CPU usage (in percent of all the CPUs) of the client or of the system at
+	// which a sample counts as CPU-bound.
+	CPUBoundPercent float64 = 90
+	// How many CPU-bound samples make the client count as CPU-bound.
+	CPUBoundSamples int = 3
+
+	// The default length of the windows over which the RPM is calculated during the
+	// test (see -rpm-window).
+	DefaultRPMSeriesWindow time.Duration = 5 * time.Second
+	// How far apart the windows of the RPM series end.
+	RPMSeriesStep time.Duration = 1 * time.Second
+
+	// How often to compare the wall clock with the monotonic clock.
+	ClockCheckInterval time.Duration = 1 * time.Second
+	// How much more (or less) the wall clock must advance than the monotonic clock
+	// between checks to count as a jump.
+	ClockJumpThreshold time.Duration = 500 * time.Millisecond
+	// How far the wall clock must jump ahead for the jump to be taken as the system
+	// having been suspended (the monotonic clock stops while suspended).
+	SuspendThreshold time.Duration = 5 * time.Second
+
+	// The interval between the UDP probes (see package udpprobe).
+	UDPProbeInterval time.Duration = 100 * time.Millisecond
+	// How long to wait for the echo of a UDP probe before counting it as lost.
+	UDPProbeTimeout time.Duration = 1 * time.Second
+	// The interval between the ICMP echo requests (see package icmpprobe).
+	ICMPProbeInterval time.Duration = 100 * time.Millisecond
+	// How long to wait for the reply to an ICMP echo request before counting it as lost.
+	ICMPProbeTimeout time.Duration = 1 * time.Second
+	// The most hops that a traceroute (see package traceroute) follows.
+	TracerouteMaxHops int = 30
+	// How many probes a traceroute sends to every hop.
+	TracerouteRounds int = 3
+	// How long a traceroute waits for the answers to a round of probes.
+	TracerouteTimeout time.Duration = 1 * time.Second
+	// How much of every packet a capture (see package pcap) keeps: enough for the
+	// headers (the payload is encrypted anyway).
+	PcapSnapLength int = 256
+	// How long to measure the latency of the idle network before the test.
+	IdleBaselineDuration time.Duration = 3 * time.Second
+	// How long to probe the idle network with -latency-only by default.
+	DefaultLatencyOnlyDuration time.Duration = 5 * time.Second
+	// The address on which the udp-echo subcommand listens by default.
+	DefaultUDPEchoAddress = ":4444"
+	// The number of load-generating connections in each direction of the load
+	// subcommand by default.
+	DefaultLoadFlows = 4
+	// The load subcommand downloads the large resource in ranges of this many bytes
+	// (over and over), so that the downloads never run out.
+	LoadRangeSize int64 = 64 * 1024 * 1024
+	// The interval between the reports of the rates of the load subcommand.
+	LoadReportInterval time.Duration = time.Second
+
+	// The shortest delay that a lost packet adds in a simulation (see package simulate):
+	// that of a TCP retransmission timeout.
+	SimulatedRetransmissionTimeout time.Duration = 200 * time.Millisecond
+	// A simulation advances its clock by this much at a time at most (see
+	// clock.Fake.Drive) ...
+	SimulatedClockStep time.Duration = 10 * time.Millisecond
+	// ... once whatever keeps time by it has left its timers alone for this long.
+	SimulatedClockPause time.Duration = time.Millisecond
+
+	// How long (in seconds) the test of the selftest subcommand may take to saturate
+	// the built-in server and then to collect the results (see -sattimeout and
+	// -rpmtimeout).
+	SelfTestSaturationTimeout int = 4
+	SelfTestRPMTimeout        int = 2
+
+	// The interval between reports of the test's estimated progress.
+	ProgressInterval time.Duration = 500 * time.Millisecond
+	// With plain output, progress is only reported in steps of this many percent.
+	PlainProgressStep float64 = 10
+	// The interval between reports of the test's state while it runs.
+	LiveInterval time.Duration = 1 * time.Second
+)
+
+// The default weight of the self probes in the RPM (the foreign probes weigh the rest).
+const DefaultSelfProbeWeight float64 = 0.5
+
+// The default number of (scaled) median absolute deviations from the median beyond
+// which a round-trip time is an outlier (see package outlier).
+const DefaultOutlierK float64 = 3
+
+// The number of previous probes (of the same prober) whose median a probe's round-trip
+// time is compared with to detect latency spikes (see package spike), and how many of
+// them there must be at least.
+const (
+	SpikeMedianWindow   int = 20
+	SpikeMinimumHistory int = 5
 )
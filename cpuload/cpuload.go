@@ -0,0 +1,177 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cpuload samples the CPU usage of the client and of the whole system during a
+// test. On weak hardware (e.g., OpenWrt-class routers), the client can run out of CPU
+// before the network runs out of capacity, so that the "throughput" that it measures is
+// the limit of the CPU rather than that of the link.
+package cpuload
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/resourceusage"
+)
+
+// Sample is the CPU usage (in percent of all the CPUs) of the client and of the whole
+// system over one interval. System is -1 where the platform cannot report it.
+type Sample struct {
+	Process float64
+	System  float64
+}
+
+// Bound returns whether the client or the system used (about) all the CPU.
+func (s Sample) Bound() bool {
+	return s.Process >= constants.CPUBoundPercent || s.System >= constants.CPUBoundPercent
+}
+
+// Summary describes the samples of a test. The system's figures are -1 where the
+// platform cannot report them.
+type Summary struct {
+	ProcessPeakPercent float64 `json:"process_peak_percent"`
+	ProcessMeanPercent float64 `json:"process_mean_percent"`
+	SystemPeakPercent  float64 `json:"system_peak_percent"`
+	SystemMeanPercent  float64 `json:"system_mean_percent"`
+	// The number of samples (intervals) in which the CPU was (about) all used.
+	BoundSamples int `json:"bound_samples"`
+	// Whether there were enough of those for the client to count as CPU-bound.
+	Bound bool `json:"bound"`
+}
+
+// Summarize describes _samples_.
+func Summarize(samples []Sample) Summary {
+	summary := Summary{SystemPeakPercent: -1, SystemMeanPercent: -1}
+	if len(samples) == 0 {
+		return summary
+	}
+	systemTotal, systemCount := 0.0, 0
+	for _, sample := range samples {
+		if sample.Process > summary.ProcessPeakPercent {
+			summary.ProcessPeakPercent = sample.Process
+		}
+		summary.ProcessMeanPercent += sample.Process / float64(len(samples))
+		if sample.System >= 0 {
+			if sample.System > summary.SystemPeakPercent {
+				summary.SystemPeakPercent = sample.System
+			}
+			systemTotal += sample.System
+			systemCount++
+		}
+		if sample.Bound() {
+			summary.BoundSamples++
+		}
+	}
+	if systemCount > 0 {
+		summary.SystemMeanPercent = systemTotal / float64(systemCount)
+	}
+	summary.Bound = summary.BoundSamples >= constants.CPUBoundSamples
+	return summary
+}
+
+// systemTimes are the CPU times of the whole system (in ticks): those that the CPUs were
+// busy and all of them.
+type systemTimes struct {
+	busy  float64
+	total float64
+}
+
+// parseStat parses the times of all the CPUs from the first line of _stat_ (the
+// contents of /proc/stat on Linux).
+func parseStat(stat string) (systemTimes, error) {
+	line, _, _ := strings.Cut(stat, "\n")
+	fields := strings.Fields(line)
+	// user nice system idle iowait irq softirq steal (guest time is part of user).
+	if len(fields) < 9 || fields[0] != "cpu" {
+		return systemTimes{}, fmt.Errorf("Unexpected CPU times: %q", line)
+	}
+	times := systemTimes{}
+	for i, field := range fields[1:9] {
+		ticks, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return systemTimes{}, fmt.Errorf("Unexpected CPU times: %q", line)
+		}
+		times.total += ticks
+		// Idle and waiting for I/O.
+		if i != 3 && i != 4 {
+			times.busy += ticks
+		}
+	}
+	return times, nil
+}
+
+// Sampler samples the CPU usage.
+type Sampler struct {
+	lock        sync.Mutex
+	cpus        float64
+	lastTime    time.Time
+	lastProcess float64
+	lastSystem  systemTimes
+	haveSystem  bool
+	samples     []Sample
+}
+
+// NewSampler starts to measure the CPU usage at _now_.
+func NewSampler(now time.Time) *Sampler {
+	s := &Sampler{cpus: float64(runtime.NumCPU()), lastTime: now}
+	s.lastProcess, _ = resourceusage.ProcessCPUSeconds()
+	s.lastSystem, s.haveSystem = readSystemTimes()
+	return s
+}
+
+// Sample records the CPU usage since the previous sample (or the start) at _now_.
+func (s *Sampler) Sample(now time.Time) Sample {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sample := Sample{System: -1}
+	if elapsed := now.Sub(s.lastTime).Seconds(); elapsed > 0 {
+		process, _ := resourceusage.ProcessCPUSeconds()
+		sample.Process = 100 * (process - s.lastProcess) / (elapsed * s.cpus)
+		s.lastProcess = process
+	}
+	if system, ok := readSystemTimes(); ok && s.haveSystem && system.total > s.lastSystem.total {
+		sample.System = 100 * (system.busy - s.lastSystem.busy) / (system.total - s.lastSystem.total)
+		s.lastSystem = system
+	}
+	s.lastTime = now
+	s.samples = append(s.samples, sample)
+	return sample
+}
+
+// Run samples the CPU usage every _interval_ until _ctx_ is canceled.
+func (s *Sampler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Sample(now)
+		}
+	}
+}
+
+// Samples returns all the samples so far.
+func (s *Sampler) Samples() []Sample {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Sample(nil), s.samples...)
+}
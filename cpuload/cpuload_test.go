@@ -0,0 +1,45 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cpuload
+
+import "testing"
+
+func TestParseStat(t *testing.T) {
+	times, err := parseStat("cpu  100 10 50 800 20 5 5 10 0 0\ncpu0 100 10 50 800 20 5 5 10 0 0\n")
+	if err != nil {
+		t.Fatalf("Could not parse the CPU times: %v", err)
+	}
+	if times.total != 1000 || times.busy != 180 {
+		t.Fatalf("The CPU times are %+v (expected 180 busy of 1000).", times)
+	}
+	if _, err := parseStat("intr 12345"); err == nil {
+		t.Fatalf("Parsed CPU times from a line without them.")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]Sample{{20, -1}, {95, -1}, {50, -1}, {92, -1}, {99, -1}})
+	if !summary.Bound || summary.BoundSamples != 3 {
+		t.Fatalf("Three CPU-bound samples gave %+v.", summary)
+	}
+	if summary.ProcessPeakPercent != 99 || summary.SystemPeakPercent != -1 {
+		t.Fatalf("The peaks are wrong: %+v.", summary)
+	}
+
+	summary = Summarize([]Sample{{10, 95}, {10, 40}, {10, 30}})
+	if summary.Bound || summary.BoundSamples != 1 || summary.SystemMeanPercent != 55 {
+		t.Fatalf("One busy system sample gave %+v.", summary)
+	}
+}
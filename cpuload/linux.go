@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cpuload
+
+import "os"
+
+func readSystemTimes() (systemTimes, bool) {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return systemTimes{}, false
+	}
+	times, err := parseStat(string(stat))
+	return times, err == nil
+}
@@ -0,0 +1,69 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// JSONLDataLogger writes every record as a JSON object on a line of its own (JSON lines)
+// which, unlike CSV, keeps the types of the values.
+type JSONLDataLogger[T any] struct {
+	*stream
+}
+
+func newJSONLDataLogger[T any](destination io.WriteCloser, name string) *JSONLDataLogger[T] {
+	logger := &JSONLDataLogger[T]{newStream(destination, name)}
+	if metadata != nil {
+		if encoded, err := json.Marshal(labeledMetadata{name, metadata}); err == nil {
+			logger.write(append(encoded, '\n'))
+		}
+	}
+	return logger
+}
+
+// labeledMetadata is what is written for the metadata (see SetMetadata).
+type labeledMetadata struct {
+	Logger   string          `json:"logger,omitempty"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// labeledRecord is what is written for a record when the logger has a name.
+type labeledRecord[T any] struct {
+	Logger string `json:"logger"`
+	Record T      `json:"record"`
+}
+
+func CreateJSONLDataLogger[T any](filename string) (DataLogger[T], error) {
+	destination, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newJSONLDataLogger[T](destination, ""), nil
+}
+
+func (logger *JSONLDataLogger[T]) LogRecord(record T) {
+	logger.mut.Lock()
+	defer logger.mut.Unlock()
+	var toEncode interface{} = record
+	if logger.name != "" {
+		toEncode = labeledRecord[T]{logger.name, record}
+	}
+	if encoded, err := json.Marshal(toEncode); err == nil {
+		logger.write(append(encoded, '\n'))
+	}
+}
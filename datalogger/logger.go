@@ -15,45 +15,252 @@
 package datalogger
 
 import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"sync"
+	"time"
 
+	"github.com/network-quality/goresponsiveness/constants"
 	"github.com/network-quality/goresponsiveness/utilities"
 )
 
 type DataLogger[T any] interface {
 	LogRecord(record T)
+	// Export makes sure that all the records logged so far are written out. Records are
+	// written as they are logged (and flushed periodically) so calling Export is only
+	// necessary to flush the last of them.
 	Export() bool
 	Close() bool
 }
 
-type CSVDataLogger[T any] struct {
+// The metadata (as JSON) that every data logger writes before its records (see
+// SetMetadata).
+var metadata json.RawMessage = nil
+
+// SetMetadata sets the metadata about the run (anything that can be marshaled as JSON)
+// that every data logger created afterwards writes before its records, so that its
+// records can be joined back to the run. CSV data loggers write it (as JSON) on a
+// comment line that starts with #; JSON lines data loggers write it as an object whose
+// only member (besides the logger's name) is "metadata".
+func SetMetadata(m any) error {
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("Could not format the metadata for the data loggers: %v", err)
+	}
+	metadata = encoded
+	return nil
+}
+
+// stream is where a data logger writes its records. The records are buffered and the
+// buffer is flushed (at least) every constants.DataLoggerFlushInterval so that a test
+// that does not end cleanly loses (almost) no data and memory use stays bounded.
+// When a stream has a name, every record is labeled with it (so that several loggers can
+// share a destination).
+type stream struct {
 	mut         *sync.Mutex
-	recordCount int
-	data        []T
 	isOpen      bool
+	name        string
 	destination io.WriteCloser
+	buffered    *bufio.Writer
+	lastFlush   time.Time
+}
+
+func newStream(destination io.WriteCloser, name string) *stream {
+	return &stream{
+		mut:         &sync.Mutex{},
+		isOpen:      true,
+		name:        name,
+		destination: destination,
+		buffered:    bufio.NewWriter(destination),
+		lastFlush:   time.Now(),
+	}
+}
+
+// flusher is implemented by destinations that buffer, too (e.g., gzip writers).
+type flusher interface {
+	Flush() error
+}
+
+// flush must be called with the lock held.
+func (s *stream) flush() bool {
+	if !s.isOpen {
+		return false
+	}
+	if err := s.buffered.Flush(); err != nil {
+		return false
+	}
+	if f, ok := s.destination.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			return false
+		}
+	}
+	s.lastFlush = time.Now()
+	return true
+}
+
+// write must be called with the lock held. Unless it is too big for the buffer, a record
+// that is written in a single call is never split across writes to the destination.
+func (s *stream) write(data []byte) {
+	if !s.isOpen {
+		return
+	}
+	if len(data) > s.buffered.Available() {
+		s.flush()
+	}
+	s.buffered.Write(data)
+	if time.Since(s.lastFlush) > constants.DataLoggerFlushInterval {
+		s.flush()
+	}
+}
+
+func (s *stream) Export() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.flush()
+}
+
+func (s *stream) Close() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if !s.isOpen {
+		return false
+	}
+	s.flush()
+	s.destination.Close()
+	s.isOpen = false
+	return true
+}
+
+type CSVDataLogger[T any] struct {
+	*stream
+	recordCount   int
+	visibleFields []reflect.StructField
+}
+
+func newCSVDataLogger[T any](destination io.WriteCloser, name string) *CSVDataLogger[T] {
+	logger := &CSVDataLogger[T]{
+		stream:        newStream(destination, name),
+		recordCount:   0,
+		visibleFields: reflect.VisibleFields(reflect.TypeOf((*T)(nil)).Elem()),
+	}
+	if metadata != nil {
+		comment := "# "
+		if name != "" {
+			comment += name + ", "
+		}
+		logger.write([]byte(comment + string(metadata) + "\n"))
+	}
+	header := ""
+	if name != "" {
+		header = "Logger, "
+	}
+	for _, v := range logger.visibleFields {
+		description, success := v.Tag.Lookup("Description")
+		columnName := fmt.Sprintf("%s", v.Name)
+		if success {
+			columnName = fmt.Sprintf("%s", description)
+		}
+		header += fmt.Sprintf("%s, ", columnName)
+	}
+	logger.write([]byte(header + "\n"))
+	return logger
 }
 
 func CreateCSVDataLogger[T any](filename string) (DataLogger[T], error) {
-	data := make([]T, 0)
 	destination, err := os.Create(filename)
 	if err != nil {
-		return &CSVDataLogger[T]{&sync.Mutex{}, 0, data, true, destination}, err
+		return nil, err
 	}
+	return newCSVDataLogger[T](destination, ""), nil
+}
 
-	result := CSVDataLogger[T]{&sync.Mutex{}, 0, data, true, destination}
-	return &result, nil
+// gzipFile compresses everything that is written to it into a file. Closing it flushes
+// the compressed data and closes the file.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	gzipErr := g.Writer.Close()
+	if err := g.file.Close(); err != nil {
+		return err
+	}
+	return gzipErr
+}
+
+func createDestination(filename string, compress bool) (io.WriteCloser, error) {
+	file, err := os.Create(filename)
+	if err != nil || !compress {
+		return file, err
+	}
+	return &gzipFile{gzip.NewWriter(file), file}, nil
+}
+
+// CreateDataLogger creates a data logger that writes in _format_ (csv or jsonl) and,
+// when _compress_ is true, compresses what it writes with gzip.
+func CreateDataLogger[T any](format string, filename string, compress bool) (DataLogger[T], error) {
+	destination, err := createDestination(filename, compress)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := createDataLogger[T](format, destination, "")
+	if err != nil {
+		destination.Close()
+	}
+	return logger, err
+}
+
+func createDataLogger[T any](format string, destination io.WriteCloser, name string) (DataLogger[T], error) {
+	switch format {
+	case "csv":
+		return newCSVDataLogger[T](destination, name), nil
+	case "jsonl":
+		return newJSONLDataLogger[T](destination, name), nil
+	}
+	return nil, fmt.Errorf("Unknown data logger format: %s", format)
+}
+
+type unclosable struct {
+	io.Writer
+}
+
+func (unclosable) Close() error {
+	return nil
+}
+
+// CreateDataLoggerForWriter creates a data logger that writes in _format_ to
+// _destination_ (which it never closes). Several loggers can share a destination (as
+// long as it is safe for concurrent use) when each has a _name_ with which its records
+// are labeled.
+func CreateDataLoggerForWriter[T any](format string, destination io.Writer, name string) (DataLogger[T], error) {
+	return createDataLogger[T](format, unclosable{destination}, name)
 }
 
 func (logger *CSVDataLogger[T]) LogRecord(record T) {
 	logger.mut.Lock()
 	defer logger.mut.Unlock()
 	logger.recordCount += 1
-	logger.data = append(logger.data, record)
+
+	line := ""
+	if logger.name != "" {
+		line = logger.name + ", "
+	}
+	data := reflect.ValueOf(record)
+	for _, v := range logger.visibleFields {
+		toWrite := data.FieldByIndex(v.Index)
+		if formattedToWrite, err := doCustomFormatting(toWrite, v.Tag); err == nil {
+			line += fmt.Sprintf("%s,", formattedToWrite)
+		} else {
+			line += fmt.Sprintf("%v, ", toWrite)
+		}
+	}
+	logger.write([]byte(line + "\n"))
 }
 
 func doCustomFormatting(value reflect.Value, tag reflect.StructTag) (string, error) {
@@ -90,47 +297,3 @@ func doCustomFormatting(value reflect.Value, tag reflect.StructTag) (string, err
 	}
 	return "", fmt.Errorf("Too many results returned by the format method's invocation.")
 }
-
-func (logger *CSVDataLogger[T]) Export() bool {
-	logger.mut.Lock()
-	defer logger.mut.Unlock()
-	if !logger.isOpen {
-		return false
-	}
-
-	visibleFields := reflect.VisibleFields(reflect.TypeOf((*T)(nil)).Elem())
-	for _, v := range visibleFields {
-		description, success := v.Tag.Lookup("Description")
-		columnName := fmt.Sprintf("%s", v.Name)
-		if success {
-			columnName = fmt.Sprintf("%s", description)
-		}
-		logger.destination.Write([]byte(fmt.Sprintf("%s, ", columnName)))
-	}
-	logger.destination.Write([]byte("\n"))
-
-	for _, d := range logger.data {
-		for _, v := range visibleFields {
-			data := reflect.ValueOf(d)
-			toWrite := data.FieldByIndex(v.Index)
-			if formattedToWrite, err := doCustomFormatting(toWrite, v.Tag); err == nil {
-				logger.destination.Write([]byte(fmt.Sprintf("%s,", formattedToWrite)))
-			} else {
-				logger.destination.Write([]byte(fmt.Sprintf("%v, ", toWrite)))
-			}
-		}
-		logger.destination.Write([]byte("\n"))
-	}
-	return true
-}
-
-func (logger *CSVDataLogger[T]) Close() bool {
-	logger.mut.Lock()
-	defer logger.mut.Unlock()
-	if !logger.isOpen {
-		return false
-	}
-	logger.destination.Close()
-	logger.isOpen = false
-	return true
-}
@@ -0,0 +1,103 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package datalogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testRecord struct {
+	Count uint64  `Description:"The count."`
+	Value float64 `Description:"The value."`
+}
+
+func TestCSVDataLogger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.csv")
+	logger, err := CreateDataLogger[testRecord]("csv", filename, false)
+	if err != nil {
+		t.Fatalf("Could not create the logger: %v", err)
+	}
+	logger.LogRecord(testRecord{1, 0.5})
+	logger.LogRecord(testRecord{2, 1.5})
+	logger.Export()
+
+	// The records are in the file before the logger is closed.
+	contents, _ := os.ReadFile(filename)
+	if expected := "The count., The value., \n1, 0.5, \n2, 1.5, \n"; string(contents) != expected {
+		t.Fatalf("Expected %q but the log contains %q.", expected, string(contents))
+	}
+	logger.Close()
+}
+
+func TestCompressedJSONLDataLogger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.jsonl.gz")
+	logger, err := CreateDataLogger[testRecord]("jsonl", filename, true)
+	if err != nil {
+		t.Fatalf("Could not create the logger: %v", err)
+	}
+	logger.LogRecord(testRecord{1, 0.5})
+	logger.Close()
+
+	file, _ := os.Open(filename)
+	defer file.Close()
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("The log is not compressed: %v", err)
+	}
+	contents, _ := io.ReadAll(decompressor)
+	if expected := "{\"Count\":1,\"Value\":0.5}\n"; string(contents) != expected {
+		t.Fatalf("Expected %q but the log contains %q.", expected, string(contents))
+	}
+}
+
+func TestSharedWriterDataLoggers(t *testing.T) {
+	destination := &bytes.Buffer{}
+	csv, _ := CreateDataLoggerForWriter[testRecord]("csv", destination, "first")
+	jsonl, _ := CreateDataLoggerForWriter[testRecord]("jsonl", destination, "second")
+	csv.LogRecord(testRecord{1, 0.5})
+	csv.Close()
+	jsonl.LogRecord(testRecord{2, 1.5})
+	jsonl.Close()
+
+	expected := "Logger, The count., The value., \nfirst, 1, 0.5, \n" +
+		"{\"logger\":\"second\",\"record\":{\"Count\":2,\"Value\":1.5}}\n"
+	if destination.String() != expected {
+		t.Fatalf("Expected %q but the log contains %q.", expected, destination.String())
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	if err := SetMetadata(map[string]string{"run_id": "42"}); err != nil {
+		t.Fatalf("Could not set the metadata: %v", err)
+	}
+	defer func() { metadata = nil }()
+
+	destination := &bytes.Buffer{}
+	csv, _ := CreateDataLoggerForWriter[testRecord]("csv", destination, "")
+	csv.Close()
+	jsonl, _ := CreateDataLoggerForWriter[testRecord]("jsonl", destination, "second")
+	jsonl.Close()
+
+	expected := "# {\"run_id\":\"42\"}\nThe count., The value., \n" +
+		"{\"logger\":\"second\",\"metadata\":{\"run_id\":\"42\"}}\n"
+	if destination.String() != expected {
+		t.Fatalf("Expected %q but the log contains %q.", expected, destination.String())
+	}
+}
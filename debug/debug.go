@@ -14,15 +14,46 @@
 
 package debug
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
 type DebugLevel int8
 
 const (
 	NoDebug DebugLevel = iota
+	Trace
 	Debug
 	Warn
 	Error
 )
 
+// Verbosity is how much the client prints.
+type Verbosity int8
+
+const (
+	OnlyRPM Verbosity = iota
+	Quiet
+	Normal
+	Verbose
+	VeryVerbose
+)
+
+// Level returns the debug level that goes with the verbosity.
+func (v Verbosity) Level() DebugLevel {
+	switch v {
+	case VeryVerbose:
+		return Trace
+	case Verbose:
+		return Debug
+	}
+	return Error
+}
+
 type DebugWithPrefix struct {
 	Level  DebugLevel
 	Prefix string
@@ -36,6 +67,10 @@ func (d *DebugWithPrefix) String() string {
 	return d.Prefix
 }
 
+func IsTrace(level DebugLevel) bool {
+	return level <= Trace
+}
+
 func IsDebug(level DebugLevel) bool {
 	return level <= Debug
 }
@@ -47,3 +82,111 @@ func IsWarn(level DebugLevel) bool {
 func IsError(level DebugLevel) bool {
 	return level <= Error
 }
+
+// The formats in which the debugging output can be logged (see SetFormat).
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// The targets to which the debugging output can be logged (see SetTarget).
+const (
+	TargetStdout = "stdout"
+	TargetSyslog = "syslog"
+)
+
+// The slog level of the trace output (slog has none of its own).
+const levelTrace = slog.LevelDebug - 4
+
+// The debugging output is logged as structured records: the message of each is the
+// stable name of an event (e.g., "lgc.tcp_done") and its details are attributes, so
+// that the output of long runs can be searched and parsed.
+var (
+	format = FormatText
+	target = TargetStdout
+	logger = slog.New(newHandler(os.Stdout, format, true))
+)
+
+func newHandler(w io.Writer, format string, withTime bool) slog.Handler {
+	options := &slog.HandlerOptions{
+		Level: levelTrace,
+		ReplaceAttr: func(groups []string, attribute slog.Attr) slog.Attr {
+			if attribute.Key == slog.TimeKey && !withTime {
+				return slog.Attr{}
+			}
+			if attribute.Key == slog.LevelKey && attribute.Value.Any() == levelTrace {
+				attribute.Value = slog.StringValue("TRACE")
+			}
+			return attribute
+		},
+	}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+func configure(newFormat, newTarget string) error {
+	handler := newHandler(os.Stdout, newFormat, true)
+	if newTarget == TargetSyslog {
+		// The system log has a time of its own.
+		var err error
+		if handler, err = newSyslogHandler(func(w io.Writer) slog.Handler {
+			return newHandler(w, newFormat, false)
+		}); err != nil {
+			return err
+		}
+	}
+	format, target, logger = newFormat, newTarget, slog.New(handler)
+	return nil
+}
+
+// SetFormat sets the format (FormatText or FormatJSON) of the debugging output.
+func SetFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("Invalid log format %q (must be %s or %s)", format, FormatText, FormatJSON)
+	}
+	return configure(format, target)
+}
+
+// SetTarget sets where the debugging output goes: to the standard output
+// (TargetStdout) or to the system log (TargetSyslog), where systemd's journal picks it
+// up, too.
+func SetTarget(target string) error {
+	if target != TargetStdout && target != TargetSyslog {
+		return fmt.Errorf("Invalid log target %q (must be %s or %s)", target, TargetStdout, TargetSyslog)
+	}
+	return configure(format, target)
+}
+
+// Info logs _event_ with _args_ (alternating keys and values) whatever the debug
+// level.
+func Info(event string, args ...any) {
+	logger.Info(event, args...)
+}
+
+// Log logs _event_ with _args_ (alternating keys and values) when _level_ includes
+// the debugging output.
+func Log(level DebugLevel, event string, args ...any) {
+	if IsDebug(level) {
+		logger.Log(context.Background(), slog.LevelDebug, event, args...)
+	}
+}
+
+// LogTrace logs _event_ with _args_ (alternating keys and values) when _level_
+// includes the trace output.
+func LogTrace(level DebugLevel, event string, args ...any) {
+	if IsTrace(level) {
+		logger.Log(context.Background(), levelTrace, event, args...)
+	}
+}
+
+// Log logs _event_ like the package-level Log does, with the prefix as its source.
+func (d *DebugWithPrefix) Log(event string, args ...any) {
+	Log(d.Level, event, append([]any{"source", d.Prefix}, args...)...)
+}
+
+// Trace logs _event_ like LogTrace does, with the prefix as its source.
+func (d *DebugWithPrefix) Trace(event string, args ...any) {
+	LogTrace(d.Level, event, append([]any{"source", d.Prefix}, args...)...)
+}
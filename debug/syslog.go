@@ -0,0 +1,82 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package debug
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+// The tag of the records in the system log.
+const syslogTag = "networkQuality"
+
+// syslogHandler formats the records with another handler and sends each to the
+// system log with the priority that goes with its level.
+type syslogHandler struct {
+	slog.Handler
+	writer *syslog.Writer
+	buffer *bytes.Buffer
+	lock   *sync.Mutex
+}
+
+func newSyslogHandler(newHandler func(io.Writer) slog.Handler) (slog.Handler, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to the system log: %v", err)
+	}
+	buffer := &bytes.Buffer{}
+	return &syslogHandler{
+		Handler: newHandler(buffer),
+		writer:  writer,
+		buffer:  buffer,
+		lock:    &sync.Mutex{},
+	}, nil
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.buffer.Reset()
+	if err := h.Handler.Handle(ctx, record); err != nil {
+		return err
+	}
+	message := strings.TrimSuffix(h.buffer.String(), "\n")
+	switch {
+	case record.Level >= slog.LevelError:
+		return h.writer.Err(message)
+	case record.Level >= slog.LevelWarn:
+		return h.writer.Warning(message)
+	case record.Level >= slog.LevelInfo:
+		return h.writer.Info(message)
+	}
+	return h.writer.Debug(message)
+}
+
+func (h *syslogHandler) WithAttrs(attributes []slog.Attr) slog.Handler {
+	return &syslogHandler{h.Handler.WithAttrs(attributes), h.writer, h.buffer, h.lock}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{h.Handler.WithGroup(name), h.writer, h.buffer, h.lock}
+}
@@ -0,0 +1,28 @@
+//go:build windows || plan9
+// +build windows plan9
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package debug
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+func newSyslogHandler(newHandler func(io.Writer) slog.Handler) (slog.Handler, error) {
+	return nil, fmt.Errorf("Logging to the system log is not supported on this platform")
+}
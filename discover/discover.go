@@ -0,0 +1,81 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package discover finds the configuration servers that the operator of a domain (an
+// ISP, say) advertises in the DNS, so that clients test against the nearest (on-net)
+// server without knowing its name. The servers are the targets of the SRV records of
+// _nq._tcp.<domain> and the path to their configuration can be in a TXT record of the
+// same name (path=/config).
+package discover
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/config"
+)
+
+// The service (and protocol) of the SRV records.
+const (
+	Service  = "nq"
+	Protocol = "tcp"
+)
+
+// The key of the path in the TXT record.
+const pathKey = "path="
+
+// Discover returns the URLs of the configurations of the servers that _domain_
+// advertises, in the order in which to try them (by priority and then randomly by
+// weight). The path of the configurations is _defaultPath_ unless _domain_ advertises
+// one.
+func Discover(domain string, defaultPath string) (configUrls []string, err error) {
+	_, records, err := net.LookupSRV(Service, Protocol, domain)
+	if err != nil {
+		return nil, fmt.Errorf("Could not discover a configuration server for %s: %v", domain, err)
+	}
+	// The TXT record is optional.
+	texts, _ := net.LookupTXT(fmt.Sprintf("_%s._%s.%s", Service, Protocol, domain))
+	path := Path(texts)
+	if path == "" {
+		path = defaultPath
+	}
+	for _, record := range records {
+		// A target of "." means that the domain explicitly offers no such service.
+		host := strings.TrimSuffix(record.Target, ".")
+		if host == "" {
+			continue
+		}
+		configUrls = append(
+			configUrls,
+			config.Url(net.JoinHostPort(host, strconv.Itoa(int(record.Port))), path),
+		)
+	}
+	if len(configUrls) == 0 {
+		return nil, fmt.Errorf("%s does not advertise a configuration server", domain)
+	}
+	return configUrls, nil
+}
+
+// Path returns the path of the configuration from the strings of a TXT record ("" when
+// none of them has one).
+func Path(texts []string) string {
+	for _, text := range texts {
+		if strings.HasPrefix(text, pathKey) {
+			return strings.TrimPrefix(text, pathKey)
+		}
+	}
+	return ""
+}
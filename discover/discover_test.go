@@ -0,0 +1,33 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package discover
+
+import "testing"
+
+func TestPath(t *testing.T) {
+	for _, test := range []struct {
+		texts []string
+		path  string
+	}{
+		{nil, ""},
+		{[]string{"v=1"}, ""},
+		{[]string{"v=1", "path=/config"}, "/config"},
+		{[]string{"path=/a", "path=/b"}, "/a"},
+	} {
+		if path := Path(test.texts); path != test.path {
+			t.Fatalf("Path(%v) is %q and not %q", test.texts, path, test.path)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package events lets embedders (and frontends) follow a test while it runs rather than
+// waiting for its final results.
+package events
+
+import (
+	"time"
+)
+
+// The sources of events that are not about the test as a whole.
+const (
+	Download = "download"
+	Upload   = "upload"
+	Foreign  = "foreign"
+)
+
+type ThroughputSample struct {
+	Time time.Time
+	// The moving average of the instantaneous aggregate goodput.
+	BytesPerSecond float64
+	Connections    int
+}
+
+type ProbeResult struct {
+	// The time at which the probe was sent.
+	Time           time.Time
+	Foreign        bool
+	Duration       time.Duration
+	RoundTripCount uint64
+	PhaseCrossing  bool
+	Cached         bool
+}
+
+// Handler receives events as a test runs. Its methods are called from the goroutines that
+// do the measuring, so they must be safe for concurrent use and must not block.
+type Handler interface {
+	OnConnectionAdded(source string, connectionId uint64)
+	OnThroughputSample(source string, sample ThroughputSample)
+	OnProbeResult(source string, result ProbeResult)
+	OnPhaseChange(phase string, progressPercent float64)
+}
+
+// Nop is a Handler that ignores every event. Embed it to implement only some of the
+// methods of Handler.
+type Nop struct{}
+
+func (Nop) OnConnectionAdded(string, uint64)            {}
+func (Nop) OnThroughputSample(string, ThroughputSample) {}
+func (Nop) OnProbeResult(string, ProbeResult)           {}
+func (Nop) OnPhaseChange(string, float64)               {}
+
+// Multi is a Handler that hands every event to each of its handlers, in order.
+type Multi []Handler
+
+func (m Multi) OnConnectionAdded(source string, connectionId uint64) {
+	for _, h := range m {
+		h.OnConnectionAdded(source, connectionId)
+	}
+}
+
+func (m Multi) OnThroughputSample(source string, sample ThroughputSample) {
+	for _, h := range m {
+		h.OnThroughputSample(source, sample)
+	}
+}
+
+func (m Multi) OnProbeResult(source string, result ProbeResult) {
+	for _, h := range m {
+		h.OnProbeResult(source, result)
+	}
+}
+
+func (m Multi) OnPhaseChange(phase string, progressPercent float64) {
+	for _, h := range m {
+		h.OnPhaseChange(phase, progressPercent)
+	}
+}
+
+// Emitter sends events from a single source to a Handler. A nil Emitter (or one
+// without a Handler) emits nothing.
+type Emitter struct {
+	source  string
+	handler Handler
+}
+
+func NewEmitter(handler Handler, source string) *Emitter {
+	return &Emitter{source: source, handler: handler}
+}
+
+// Source returns the name of what the emitter reports on ("" for a nil emitter).
+func (e *Emitter) Source() string {
+	if e == nil {
+		return ""
+	}
+	return e.source
+}
+
+func (e *Emitter) enabled() bool {
+	return e != nil && e.handler != nil
+}
+
+func (e *Emitter) ConnectionAdded(connectionId uint64) {
+	if e.enabled() {
+		e.handler.OnConnectionAdded(e.source, connectionId)
+	}
+}
+
+func (e *Emitter) ThroughputSample(sample ThroughputSample) {
+	if e.enabled() {
+		e.handler.OnThroughputSample(e.source, sample)
+	}
+}
+
+func (e *Emitter) ProbeResult(result ProbeResult) {
+	if e.enabled() {
+		e.handler.OnProbeResult(e.source, result)
+	}
+}
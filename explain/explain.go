@@ -0,0 +1,84 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package explain tells the story of how the test brought the network to its working
+// conditions (see -explain): when connections were added and why, and when the network
+// was declared saturated or the test ran out of time. Runs that give very different
+// numbers usually differ in this story.
+package explain
+
+import (
+	"fmt"
+
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/summary"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func connections(count uint64) string {
+	return fmt.Sprintf("%d %s", count, utilities.Conditional(count == 1, "connection", "connections"))
+}
+
+// observation describes what the ramp algorithm observed at _step_.
+func observation(step summary.RampStep) string {
+	text := fmt.Sprintf(
+		"the moving average of the throughput was %.3f Mbps (%+.1f%% in a second) and %s",
+		utilities.ToMbps(step.RateBps),
+		step.MovingAverageDeltaPercent,
+		utilities.Conditional(step.Stable, "stable", "not yet stable"),
+	)
+	if step.LatencySeconds > 0 {
+		text += fmt.Sprintf(", with self probes at a P90 of %.3fs", step.LatencySeconds)
+	}
+	return text
+}
+
+// Narrate tells the story of the ramp of _direction_ from its _steps_ (see
+// summary.RampStep), one line per step. _saturated_ tells whether the direction
+// saturated before the test ran out of time.
+func Narrate(direction string, steps []summary.RampStep, saturated bool) []string {
+	lines := make([]string, 0, len(steps)+1)
+	for _, step := range steps {
+		var line string
+		switch step.Reason {
+		case rpm.RampInitial:
+			line = fmt.Sprintf("started with %s", connections(step.Added))
+		case rpm.RampAdd:
+			line = fmt.Sprintf(
+				"added %s (making %d): %s",
+				connections(step.Added),
+				step.Connections+int(step.Added),
+				observation(step),
+			)
+		case rpm.RampStall:
+			line = fmt.Sprintf("replaced %s that stalled", connections(step.Added))
+		case rpm.RampSaturated:
+			line = fmt.Sprintf(
+				"declared the network saturated with %s: %s",
+				connections(uint64(step.Connections)),
+				observation(step),
+			)
+		default:
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%6.1fs  The %s %s.", step.Seconds, direction, line))
+	}
+	if !saturated {
+		lines = append(
+			lines,
+			fmt.Sprintf("        The %s never saturated: the test ran out of time (or the load failed) first, so its results are provisional.", direction),
+		)
+	}
+	return lines
+}
@@ -0,0 +1,44 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package explain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+func TestNarrate(t *testing.T) {
+	steps := []summary.RampStep{
+		{Seconds: 0, Reason: rpm.RampInitial, Added: 4},
+		{Seconds: 4, Reason: rpm.RampAdd, Connections: 4, Added: 4, RateBps: 1e6, MovingAverageDeltaPercent: 12},
+		{Seconds: 8, Reason: rpm.RampSaturated, Connections: 8, RateBps: 1.1e6, Stable: true},
+	}
+	lines := Narrate("download", steps, true)
+	if len(lines) != 3 {
+		t.Fatalf("Expected a line per step but got %v.", lines)
+	}
+	if !strings.Contains(lines[1], "added 4 connections (making 8)") || !strings.Contains(lines[1], "+12.0%") {
+		t.Fatalf("The addition of connections is not explained: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "saturated with 8 connections") {
+		t.Fatalf("The saturation is not explained: %s", lines[2])
+	}
+	if lines := Narrate("upload", steps[:2], false); !strings.Contains(lines[len(lines)-1], "never saturated") {
+		t.Fatalf("The missing saturation is not explained: %v", lines)
+	}
+}
@@ -98,13 +98,16 @@ func GetTCPInfo(basicConn net.Conn) (*TCPInfo, error) {
 
 	var rawInfo *unix.TCPConnectionInfo = nil
 	var tcpInfo *TCPInfo = nil
-	rawConn.Control(func(fd uintptr) {
+	// The connection may already be closed (e.g., when the test is aborted).
+	if controlErr := rawConn.Control(func(fd uintptr) {
 		rawInfo, err = unix.GetsockoptTCPConnectionInfo(
 			int(fd),
 			unix.IPPROTO_TCP,
 			unix.TCP_CONNECTION_INFO,
 		)
-	})
+	}); controlErr != nil {
+		return nil, controlErr
+	}
 	if rawInfo != nil && err == nil {
 		tcpInfo = &TCPInfo{}
 		tcpInfo.Rxoutoforderbytes = rawInfo.Rxoutoforderbytes
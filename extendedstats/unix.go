@@ -85,8 +85,11 @@ func GetTCPInfo(basicConn net.Conn) (*unix.TCPInfo, error) {
 		return nil, err
 	}
 	var info *unix.TCPInfo = nil
-	rawConn.Control(func(fd uintptr) {
+	// The connection may already be closed (e.g., when the test is aborted).
+	if controlErr := rawConn.Control(func(fd uintptr) {
 		info, err = unix.GetsockoptTCPInfo(int(fd), unix.SOL_TCP, unix.TCP_INFO)
-	})
+	}); controlErr != nil {
+		return nil, controlErr
+	}
 	return info, err
 }
@@ -1,6 +1,6 @@
 module github.com/network-quality/goresponsiveness
 
-go 1.18
+go 1.21
 
 require (
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
@@ -0,0 +1,64 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package grade turns the growth of the latency under load into a bufferbloat grade
+// from A+ to F (the way DSLReports' speed test grades it), which many find easier
+// to act on than an RPM.
+package grade
+
+import (
+	"sort"
+	"time"
+)
+
+// The grades and the inflations up to which they are given.
+var grades = []struct {
+	grade string
+	limit time.Duration
+}{
+	{"A+", 5 * time.Millisecond},
+	{"A", 30 * time.Millisecond},
+	{"B", 60 * time.Millisecond},
+	{"C", 200 * time.Millisecond},
+	{"D", 400 * time.Millisecond},
+}
+
+func median(latencies []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// Inflation is how much larger the median of the _loaded_ latencies is than that of
+// the _idle_ ones (and false when either is missing).
+func Inflation(idle []time.Duration, loaded []time.Duration) (time.Duration, bool) {
+	if len(idle) == 0 || len(loaded) == 0 {
+		return 0, false
+	}
+	inflation := median(loaded) - median(idle)
+	if inflation < 0 {
+		inflation = 0
+	}
+	return inflation, true
+}
+
+// Bufferbloat grades _inflation_.
+func Bufferbloat(inflation time.Duration) string {
+	for _, grade := range grades {
+		if inflation < grade.limit {
+			return grade.grade
+		}
+	}
+	return "F"
+}
@@ -0,0 +1,50 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package grade
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBufferbloat(t *testing.T) {
+	cases := []struct {
+		inflation time.Duration
+		grade     string
+	}{
+		{0, "A+"},
+		{5 * time.Millisecond, "A"},
+		{59 * time.Millisecond, "B"},
+		{100 * time.Millisecond, "C"},
+		{399 * time.Millisecond, "D"},
+		{time.Second, "F"},
+	}
+	for _, c := range cases {
+		if grade := Bufferbloat(c.inflation); grade != c.grade {
+			t.Fatalf("Expected %v to be graded %s but got %s.", c.inflation, c.grade, grade)
+		}
+	}
+}
+
+func TestInflation(t *testing.T) {
+	idle := []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 30 * time.Millisecond}
+	loaded := []time.Duration{90 * time.Millisecond, 70 * time.Millisecond}
+	if inflation, ok := Inflation(idle, loaded); !ok || inflation != 70*time.Millisecond {
+		t.Fatalf("Expected an inflation of 70ms but got %v (%v).", inflation, ok)
+	}
+	if _, ok := Inflation(nil, loaded); ok {
+		t.Fatalf("Expected no inflation without an idle baseline.")
+	}
+}
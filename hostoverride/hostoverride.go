@@ -0,0 +1,99 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package hostoverride lets the name of the test server in the TLS handshake (SNI) and
+// in the requests (the Host header) differ from the address to which the client
+// connects. That is necessary to test through IP literals, in split-horizon setups or
+// behind fronting (e.g., a CDN's address for another name).
+package hostoverride
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+
+	"github.com/network-quality/goresponsiveness/redirect"
+	"golang.org/x/net/http2"
+)
+
+// The overrides ("" when there is none).
+var (
+	serverName = ""
+	host       = ""
+)
+
+// SetServerName sets the server name (SNI) of all TLS handshakes to _name_.
+func SetServerName(name string) {
+	serverName = name
+}
+
+// SetHost sets the Host header of all requests to _name_.
+func SetHost(name string) {
+	host = name
+}
+
+// ConfigureTLS sets the server name of _config_ for the handshake with the server at
+// _addr_ (host:port) if it is overridden and the test server did not redirect to that
+// server from another (see redirect.MarkForeign).
+func ConfigureTLS(config *tls.Config, addr string) {
+	if serverName != "" && !redirect.IsForeign(addr) {
+		config.ServerName = serverName
+	}
+}
+
+// A Transport is an HTTP/2 transport that has a transport of its own for each server,
+// so that the handshakes with each have the server name of ConfigureTLS.
+type Transport struct {
+	// New creates the transport for a server (before its server name is set).
+	New        func() *http2.Transport
+	lock       sync.Mutex
+	transports map[string]*http2.Transport
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	addr := redirect.Address(request.URL)
+	t.lock.Lock()
+	if t.transports == nil {
+		t.transports = make(map[string]*http2.Transport)
+	}
+	transport := t.transports[addr]
+	if transport == nil {
+		transport = t.New()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		ConfigureTLS(transport.TLSClientConfig, addr)
+		t.transports[addr] = transport
+	}
+	t.lock.Unlock()
+	return transport.RoundTrip(request)
+}
+
+// CloseIdleConnections closes the idle connections of the transports of all servers.
+func (t *Transport) CloseIdleConnections() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// Apply sets the Host header of _request_ (if it is overridden and _request_ is not
+// for a host to which the test server redirected from another; see
+// redirect.MarkForeign).
+func Apply(request *http.Request) {
+	if host != "" && !redirect.IsForeign(redirect.Address(request.URL)) {
+		request.Host = host
+	}
+}
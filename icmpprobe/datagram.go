@@ -0,0 +1,47 @@
+//go:build darwin || linux
+// +build darwin linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package icmpprobe
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenDatagram opens a datagram ICMP socket, which does not need privileges.
+func listenDatagram(v6 bool) (net.PacketConn, error) {
+	family, protocol := unix.AF_INET, unix.IPPROTO_ICMP
+	var address unix.Sockaddr = &unix.SockaddrInet4{}
+	if v6 {
+		family, protocol = unix.AF_INET6, unix.IPPROTO_ICMPV6
+		address = &unix.SockaddrInet6{}
+	}
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM, protocol)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Bind(fd, address); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("icmp:%d", fd))
+	defer file.Close()
+	return net.FilePacketConn(file)
+}
@@ -0,0 +1,236 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package icmpprobe runs ICMP echo probes (pings) during a test so that their latency
+// under load can be compared with that of the HTTP probes.
+package icmpprobe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// The types of the ICMP (and ICMPv6) echo messages.
+const (
+	echoRequest   = 8
+	echoReply     = 0
+	echoRequestV6 = 128
+	echoReplyV6   = 129
+)
+
+// The payload of our echo requests.
+var payload = []byte("goresponsiveness")
+
+func checksum(message []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(message); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(message[i:]))
+	}
+	if len(message)%2 == 1 {
+		sum += uint32(message[len(message)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// marshalEcho builds an echo request. The checksum of ICMPv6 covers a pseudo header
+// with the addresses, so the kernel fills it in.
+func marshalEcho(request byte, id int, sequence int) []byte {
+	message := make([]byte, 8+len(payload))
+	message[0] = request
+	binary.BigEndian.PutUint16(message[4:], uint16(id))
+	binary.BigEndian.PutUint16(message[6:], uint16(sequence))
+	copy(message[8:], payload)
+	if request == echoRequest {
+		binary.BigEndian.PutUint16(message[2:], checksum(message))
+	}
+	return message
+}
+
+// parseEcho returns the identifier and sequence number of the echo reply in _packet_.
+// Some platforms include the IPv4 header, which is skipped.
+func parseEcho(reply byte, packet []byte) (id int, sequence int, ok bool) {
+	if len(packet) >= 20 && packet[0]>>4 == 4 {
+		packet = packet[int(packet[0]&0x0f)*4:]
+	}
+	if len(packet) < 8 || packet[0] != reply {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(packet[4:])), int(binary.BigEndian.Uint16(packet[6:])), true
+}
+
+// Sample is the round trip of the echo request that was sent at Time (or its loss).
+type Sample struct {
+	Time     time.Time
+	Duration time.Duration
+	Lost     bool
+}
+
+type Prober struct {
+	lock        sync.Mutex
+	conn        net.PacketConn
+	destination net.Addr
+	request     byte
+	reply       byte
+	// With a datagram socket, the kernel picks the identifier (and only passes the
+	// replies to our own requests back).
+	privileged bool
+	id         int
+	sequence   int
+	sent       map[int]time.Time
+	samples    []Sample
+	done       chan struct{}
+}
+
+// NewProber sets up the probes of _host_. It first tries a datagram ICMP socket
+// (which, on Linux, the net.ipv4.ping_group_range sysctl must allow) and then a raw
+// socket (which needs privileges).
+func NewProber(host string) (*Prober, error) {
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve %s for the ICMP probes: %v", host, err)
+	}
+	prober := &Prober{
+		request: echoRequest,
+		reply:   echoReply,
+		id:      os.Getpid() & 0xffff,
+		sent:    make(map[int]time.Time),
+		done:    make(chan struct{}),
+	}
+	v6, rawNetwork := false, "ip4:icmp"
+	if addr.IP.To4() == nil {
+		prober.request, prober.reply = echoRequestV6, echoReplyV6
+		v6, rawNetwork = true, "ip6:ipv6-icmp"
+	}
+
+	if prober.conn, err = listenDatagram(v6); err == nil {
+		prober.destination = &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+		return prober, nil
+	}
+	if prober.conn, err = net.ListenPacket(rawNetwork, ""); err == nil {
+		prober.privileged = true
+		prober.destination = addr
+		return prober, nil
+	}
+	return nil, fmt.Errorf("Could not open a socket for the ICMP probes of %s: %v", host, err)
+}
+
+func (p *Prober) send(now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// The sequence number has 16 bits (which last for hours at the usual intervals).
+	sequence := p.sequence & 0xffff
+	p.sequence++
+	p.sent[sequence] = now
+	// A request that could not be sent eventually counts as lost.
+	p.conn.WriteTo(marshalEcho(p.request, p.id, sequence), p.destination)
+}
+
+func (p *Prober) receive(packet []byte, now time.Time) {
+	id, sequence, ok := parseEcho(p.reply, packet)
+	if !ok || (p.privileged && id != p.id) {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// Late (already counted as lost) and duplicated replies are ignored.
+	if sentTime, ok := p.sent[sequence]; ok {
+		delete(p.sent, sequence)
+		p.samples = append(p.samples, Sample{Time: sentTime, Duration: now.Sub(sentTime)})
+	}
+}
+
+func (p *Prober) outstanding() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.sent)
+}
+
+// expire counts the requests that were sent more than _timeout_ before _now_ and
+// whose reply has not arrived as lost.
+func (p *Prober) expire(now time.Time, timeout time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for sequence, sentTime := range p.sent {
+		if now.Sub(sentTime) > timeout {
+			delete(p.sent, sequence)
+			p.samples = append(p.samples, Sample{Time: sentTime, Lost: true})
+		}
+	}
+}
+
+// Run sends an echo request every _interval_ until _ctx_ is canceled and then waits
+// for the replies to the requests still in flight. A request whose reply does not
+// arrive within _timeout_ counts as lost.
+func (p *Prober) Run(ctx context.Context, interval time.Duration, timeout time.Duration) {
+	defer close(p.done)
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		packet := make([]byte, 1500)
+		for {
+			n, _, err := p.conn.ReadFrom(packet)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			p.receive(packet[:n], time.Now())
+			if ctx.Err() != nil && p.outstanding() == 0 {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.Now()
+			if p.outstanding() > 0 {
+				deadline = deadline.Add(timeout)
+			}
+			p.conn.SetReadDeadline(deadline)
+			<-received
+			p.conn.Close()
+			p.expire(time.Now(), 0)
+			return
+		case now := <-ticker.C:
+			p.expire(now, timeout)
+			p.send(now)
+		}
+	}
+}
+
+// Wait waits for Run to finish.
+func (p *Prober) Wait() {
+	<-p.done
+}
+
+// Samples returns the round trips (and losses) so far. Requests that are still in
+// flight are not included.
+func (p *Prober) Samples() []Sample {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]Sample(nil), p.samples...)
+}
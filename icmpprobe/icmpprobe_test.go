@@ -0,0 +1,35 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package icmpprobe
+
+import "testing"
+
+func TestEcho(t *testing.T) {
+	request := marshalEcho(echoRequest, 0x1234, 7)
+	if checksum(request) != 0 {
+		t.Fatalf("The checksum of %v is wrong.", request)
+	}
+
+	// A reply with an IPv4 header (of 20 bytes) in front of it.
+	reply := append(make([]byte, 20), request...)
+	reply[0] = 0x45
+	reply[20] = echoReply
+	if id, sequence, ok := parseEcho(echoReply, reply); !ok || id != 0x1234 || sequence != 7 {
+		t.Fatalf("Expected 0x1234 and 7 but got %x and %d (%v).", id, sequence, ok)
+	}
+	if _, _, ok := parseEcho(echoReply, request); ok {
+		t.Fatalf("An echo request was taken for a reply.")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package icmpprobe
+
+import (
+	"fmt"
+	"net"
+)
+
+func listenDatagram(v6 bool) (net.PacketConn, error) {
+	return nil, fmt.Errorf("Datagram ICMP sockets are not available on this platform")
+}
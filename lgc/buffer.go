@@ -0,0 +1,42 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"io"
+	"sync"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// The buffers through which the downloads are read. They are shared by all the
+// load-generating connections (and reused by each of their requests) so that the
+// transfers do not keep the garbage collector busy (which would perturb the probes on
+// weak CPUs).
+var copyBuffers = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, constants.DownloadCopyBufferSize)
+		return &buffer
+	},
+}
+
+// copyPooled copies _src_ to _dst_ (like io.Copy) through one of the pooled buffers.
+func copyPooled(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buffer)
+	// io.CopyBuffer ignores the buffer when _dst_ is an io.ReaderFrom (e.g.,
+	// io.Discard, whose buffers are smaller) or _src_ an io.WriterTo.
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buffer)
+}
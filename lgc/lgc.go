@@ -16,18 +16,30 @@ package lgc
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	mathrand "math/rand"
+	"net"
 	"net/http"
 	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/auth"
+	"github.com/network-quality/goresponsiveness/constants"
 	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/hostoverride"
+	"github.com/network-quality/goresponsiveness/sink"
 	"github.com/network-quality/goresponsiveness/stats"
 	"github.com/network-quality/goresponsiveness/traceable"
+	"github.com/network-quality/goresponsiveness/useragent"
 	"github.com/network-quality/goresponsiveness/utilities"
 	"golang.org/x/net/http2"
 )
@@ -35,27 +47,239 @@ import (
 type LoadGeneratingConnection interface {
 	Start(context.Context, debug.DebugLevel) bool
 	TransferredInInterval() (uint64, time.Duration)
+	// Transferred returns the number of bytes that were transferred (not counting those
+	// while draining) and for how long load has been generated on the connection.
+	Transferred() (uint64, time.Duration)
 	Client() *http.Client
 	IsValid() bool
+	// Failure returns the reason why the connection became invalid (nil while it is
+	// valid).
+	Failure() error
 	ClientId() uint64
+	// Interruptions returns how many times the server sent a GOAWAY and how many
+	// times it reset the stream (after each of which the connection reconnected).
+	Interruptions() (goAways uint64, streamResets uint64)
 	Stats() *stats.TraceStats
+	// Ping sends an HTTP/2 PING on the connection and returns how long it took to be
+	// acknowledged (by the server's HTTP/2 stack, without involving its application).
+	Ping(context.Context) (time.Duration, error)
+	// Drain stops generating load on the connection, gives it up to the given amount
+	// of time to settle and then closes it. It returns the number of bytes that were
+	// transferred (and discarded) while draining.
+	Drain(time.Duration) uint64
+}
+
+// DrainAll drains (see LoadGeneratingConnection.Drain) all of _lgcs_ concurrently and
+// returns the total number of bytes that were discarded while doing so.
+func DrainAll(lgcs []LoadGeneratingConnection, timeout time.Duration) uint64 {
+	discarded := uint64(0)
+	wg := sync.WaitGroup{}
+	for _, lgc := range lgcs {
+		wg.Add(1)
+		go func(lgc LoadGeneratingConnection) {
+			defer wg.Done()
+			atomic.AddUint64(&discarded, lgc.Drain(timeout))
+		}(lgc)
+	}
+	wg.Wait()
+	return discarded
+}
+
+// drainRequest gives the request of a load-generating connection up to _timeout_
+// to finish on its own (_done_ is closed when it does), stops it if it does not and
+// then closes the (now idle) connection that carried it. Closing an idle connection
+// rather than one with data in flight avoids RSTs.
+func drainRequest(
+	done chan struct{},
+	stopRequest context.CancelFunc,
+	pool *pingPool,
+	timeout time.Duration,
+) {
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+	stopRequest()
+	<-done
+	pool.closeIdleConnections()
+}
+
+// SetTransportDeadlines configures _transport_ so that connections which stop
+// making progress (reading or writing) are detected and torn down rather than
+// lingering until the kernel times them out.
+func SetTransportDeadlines(transport *http2.Transport) {
+	transport.ReadIdleTimeout = constants.ConnectionReadIdleTimeout
+	transport.PingTimeout = constants.ConnectionPingTimeout
+	transport.WriteByteTimeout = constants.ConnectionWriteTimeout
+}
+
+// bindRequestToContext derives the context for the load-generating requests from _ctx_:
+// the connection that ends up carrying a request is closed as soon as _ctx_ is done.
+// Dialing happens with the request's context (so that the tracing hooks fire), which
+// is why this cannot be done in the transport.
+func bindRequestToContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	requestCtx, cancel := context.WithCancel(ctx)
+	// The context may carry several requests (see
+	// LoadGeneratingConnectionDownload.RangeSize), which usually share a connection, but
+	// after an interruption (see interruptions) they get a new one that must be bound, too.
+	lock := sync.Mutex{}
+	bound := make(map[net.Conn]bool)
+	return httptrace.WithClientTrace(requestCtx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			if bound[info.Conn] {
+				return
+			}
+			bound[info.Conn] = true
+			go func() {
+				<-ctx.Done()
+				info.Conn.Close()
+			}()
+		},
+	}), cancel
+}
+
+// withDialDeadline derives the context for a single load-generating request from _ctx_
+// (see bindRequestToContext): the request is abandoned if a connection cannot be
+// established for it within constants.DialTimeout. The returned function releases the
+// context once the request is done.
+func withDialDeadline(ctx context.Context) (context.Context, func()) {
+	requestCtx, cancel := context.WithCancel(ctx)
+	lock := sync.Mutex{}
+	var dialDeadline *time.Timer = nil
+	stopDialDeadline := func() {
+		if dialDeadline != nil {
+			dialDeadline.Stop()
+		}
+	}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			lock.Lock()
+			defer lock.Unlock()
+			stopDialDeadline()
+			dialDeadline = time.AfterFunc(constants.DialTimeout, cancel)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			stopDialDeadline()
+		},
+	}
+	release := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		stopDialDeadline()
+		cancel()
+	}
+	return httptrace.WithClientTrace(requestCtx, trace), release
+}
+
+// interruptions counts how many times the server interrupted a load-generating
+// connection, either by closing the connection (GOAWAY) or by resetting the stream
+// (RST_STREAM). Some servers recycle their connections during a test.
+type interruptions struct {
+	goAways      uint64
+	streamResets uint64
+}
+
+// count counts _err_ if it is an interruption and returns whether it is one that the
+// connection should recover from (by reconnecting).
+func (i *interruptions) count(err error) bool {
+	var goAway http2.GoAwayError
+	var streamError http2.StreamError
+	if errors.As(err, &goAway) {
+		atomic.AddUint64(&i.goAways, 1)
+	} else if errors.As(err, &streamError) {
+		atomic.AddUint64(&i.streamResets, 1)
+	} else {
+		return false
+	}
+	goAways, streamResets := i.Interruptions()
+	return goAways+streamResets <= constants.MaximumInterruptions
+}
+
+func (i *interruptions) Interruptions() (uint64, uint64) {
+	return atomic.LoadUint64(&i.goAways), atomic.LoadUint64(&i.streamResets)
+}
+
+// The transfers keep their start times as the nanoseconds since this (monotonic) epoch
+// so that they can be read and written atomically (0 is before the start).
+var epoch = time.Now()
+
+// sinceEpoch returns how many nanoseconds have passed since the epoch (at least 1).
+func sinceEpoch() int64 {
+	return int64(time.Since(epoch)) + 1
+}
+
+// validity is whether a load-generating connection is still valid and, once it is
+// not, why. The transfer sets it while the data collection reads it.
+type validity struct {
+	lock    sync.Mutex
+	valid   bool
+	failure error
+}
+
+func (v *validity) validate() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.valid = true
+}
+
+func (v *validity) invalidate(failure error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.failure = failure
+	v.valid = false
+}
+
+func (v *validity) IsValid() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.valid
+}
+
+func (v *validity) Failure() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.failure
 }
 
 // TODO: All 64-bit fields that are accessed atomically must
 // appear at the top of this struct.
 type LoadGeneratingConnectionDownload struct {
+	interruptions
 	downloaded        uint64
+	totalDownloaded   uint64
 	lastIntervalEnd   int64
+	downloadStartTime int64
+	drained           uint64
+	draining          uint32
+	integrityFailed   uint32
 	Path              string
-	downloadStartTime time.Time
 	lastDownloaded    uint64
 	client            *http.Client
+	pool              *pingPool
 	debug             debug.DebugLevel
-	valid             bool
 	KeyLogger         io.Writer
 	clientId          uint64
 	tracer            *httptrace.ClientTrace
 	stats             stats.TraceStats
+	stopRequest       context.CancelFunc
+	done              chan struct{}
+	validity
+	// Where the downloaded data goes. When nil, it is simply discarded (which is the
+	// fastest option).
+	Sink sink.Sink
+	// When positive, the resource is downloaded in ranges of this many bytes (see
+	// doDownload) rather than all at once.
+	RangeSize int64
+	// When positive, the download is kept at (or below) this many bytes per second.
+	RateLimit float64
+	// When not nil, the download shares this Pacer (e.g., with the other downloads, to
+	// limit their aggregate rate) rather than pacing itself by RateLimit.
+	SharedPacer *Pacer
+	pacer       *Pacer
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetDnsStartTimeInfo(
@@ -64,13 +288,7 @@ func (lgd *LoadGeneratingConnectionDownload) SetDnsStartTimeInfo(
 ) {
 	lgd.stats.DnsStartTime = now
 	lgd.stats.DnsStart = dnsStartInfo
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"DNS Start for %v: %v\n",
-			lgd.ClientId(),
-			dnsStartInfo,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.dns_start", "id", lgd.ClientId(), "info", dnsStartInfo)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetDnsDoneTimeInfo(
@@ -79,26 +297,14 @@ func (lgd *LoadGeneratingConnectionDownload) SetDnsDoneTimeInfo(
 ) {
 	lgd.stats.DnsDoneTime = now
 	lgd.stats.DnsDone = dnsDoneInfo
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"DNS Done for %v: %v\n",
-			lgd.ClientId(),
-			lgd.stats.DnsDone,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.dns_done", "id", lgd.ClientId(), "info", lgd.stats.DnsDone)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetConnectStartTime(
 	now time.Time,
 ) {
 	lgd.stats.ConnectStartTime = now
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"TCP Start for %v at %v\n",
-			lgd.ClientId(),
-			lgd.stats.ConnectStartTime,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.tcp_start", "id", lgd.ClientId())
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetConnectDoneTimeError(
@@ -107,25 +313,12 @@ func (lgd *LoadGeneratingConnectionDownload) SetConnectDoneTimeError(
 ) {
 	lgd.stats.ConnectDoneTime = now
 	lgd.stats.ConnectDoneError = err
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"TCP Done for %v (with error %v) @ %v\n",
-			lgd.ClientId(),
-			lgd.stats.ConnectDoneError,
-			lgd.stats.ConnectDoneTime,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.tcp_done", "id", lgd.ClientId(), "error", lgd.stats.ConnectDoneError)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetGetConnTime(now time.Time) {
 	lgd.stats.GetConnectionStartTime = now
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Started getting connection for %v @ %v\n",
-			lgd.ClientId(),
-			lgd.stats.GetConnectionStartTime,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.get_conn", "id", lgd.ClientId())
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetGotConnTimeInfo(
@@ -138,27 +331,14 @@ func (lgd *LoadGeneratingConnectionDownload) SetGotConnTimeInfo(
 	}
 	lgd.stats.GetConnectionDoneTime = now
 	lgd.stats.ConnInfo = gotConnInfo
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Got connection for %v at %v with info %v\n",
-			lgd.ClientId(),
-			lgd.stats.GetConnectionDoneTime,
-			lgd.stats.ConnInfo,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.got_conn", "id", lgd.ClientId(), "info", lgd.stats.ConnInfo)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetTLSHandshakeStartTime(
 	now time.Time,
 ) {
 	lgd.stats.TLSStartTime = utilities.Some(now)
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Started TLS Handshake for %v @ %v\n",
-			lgd.ClientId(),
-			lgd.stats.TLSStartTime,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.tls_start", "id", lgd.ClientId())
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetTLSHandshakeDoneTimeState(
@@ -167,14 +347,7 @@ func (lgd *LoadGeneratingConnectionDownload) SetTLSHandshakeDoneTimeState(
 ) {
 	lgd.stats.TLSDoneTime = utilities.Some(now)
 	lgd.stats.TLSConnInfo = connectionState
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Completed TLS handshake for %v at %v with info %v\n",
-			lgd.ClientId(),
-			lgd.stats.TLSDoneTime,
-			lgd.stats.TLSConnInfo,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.tls_done", "id", lgd.ClientId(), "info", lgd.stats.TLSConnInfo)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetHttpWroteRequestTimeInfo(
@@ -183,27 +356,14 @@ func (lgd *LoadGeneratingConnectionDownload) SetHttpWroteRequestTimeInfo(
 ) {
 	lgd.stats.HttpWroteRequestTime = now
 	lgd.stats.HttpInfo = info
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"(lgd) Http finished writing request for %v at %v with info %v\n",
-			lgd.ClientId(),
-			lgd.stats.HttpWroteRequestTime,
-			lgd.stats.HttpInfo,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.wrote_request", "id", lgd.ClientId(), "info", lgd.stats.HttpInfo)
 }
 
 func (lgd *LoadGeneratingConnectionDownload) SetHttpResponseReadyTime(
 	now time.Time,
 ) {
 	lgd.stats.HttpResponseReadyTime = now
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Got the first byte of HTTP response headers for %v at %v\n",
-			lgd.ClientId(),
-			lgd.stats.HttpResponseReadyTime,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.first_response_byte", "id", lgd.ClientId())
 }
 
 func (lgd *LoadGeneratingConnectionDownload) ClientId() uint64 {
@@ -212,23 +372,33 @@ func (lgd *LoadGeneratingConnectionDownload) ClientId() uint64 {
 
 func (lgd *LoadGeneratingConnectionDownload) TransferredInInterval() (uint64, time.Duration) {
 	transferred := atomic.SwapUint64(&lgd.downloaded, 0)
-	newIntervalEnd := (time.Now().Sub(lgd.downloadStartTime)).Nanoseconds()
+	newIntervalEnd := sinceEpoch() - atomic.LoadInt64(&lgd.downloadStartTime)
 	previousIntervalEnd := atomic.SwapInt64(&lgd.lastIntervalEnd, newIntervalEnd)
 	intervalLength := time.Duration(newIntervalEnd - previousIntervalEnd)
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf("download: Transferred: %v bytes in %v.\n", transferred, intervalLength)
-	}
+	debug.Log(lgd.debug, "lgc.transferred", "direction", "download", "bytes", transferred, "interval", intervalLength)
 	return transferred, intervalLength
 }
 
+func (lgd *LoadGeneratingConnectionDownload) Transferred() (uint64, time.Duration) {
+	start := atomic.LoadInt64(&lgd.downloadStartTime)
+	if start == 0 {
+		return 0, 0
+	}
+	return atomic.LoadUint64(&lgd.totalDownloaded), time.Duration(sinceEpoch() - start)
+}
+
 func (lgd *LoadGeneratingConnectionDownload) Client() *http.Client {
 	return lgd.client
 }
 
 type countingReader struct {
 	n        *uint64
+	total    *uint64
+	drained  *uint64
+	draining *uint32
 	ctx      context.Context
 	readable io.Reader
+	pacer    *Pacer
 }
 
 func (cr *countingReader) Read(p []byte) (n int, err error) {
@@ -236,7 +406,14 @@ func (cr *countingReader) Read(p []byte) (n int, err error) {
 		return 0, io.EOF
 	}
 	n, err = cr.readable.Read(p)
-	atomic.AddUint64(cr.n, uint64(n))
+	// Once we are draining, bytes are no longer part of the measurement.
+	if atomic.LoadUint32(cr.draining) != 0 {
+		atomic.AddUint64(cr.drained, uint64(n))
+	} else {
+		atomic.AddUint64(cr.n, uint64(n))
+		atomic.AddUint64(cr.total, uint64(n))
+	}
+	cr.pacer.wait(cr.ctx, n)
 	return
 }
 
@@ -250,11 +427,7 @@ func (lgd *LoadGeneratingConnectionDownload) Start(
 	transport.TLSClientConfig = &tls.Config{}
 
 	if !utilities.IsInterfaceNil(lgd.KeyLogger) {
-		if debug.IsDebug(lgd.debug) {
-			fmt.Printf(
-				"Using an SSL Key Logger for this load-generating download.\n",
-			)
-		}
+		debug.Log(lgd.debug, "lgc.key_logger", "direction", "download")
 
 		// The presence of a custom TLSClientConfig in a *generic* `transport`
 		// means that go will default to HTTP/1.1 and cowardly avoid HTTP/2:
@@ -266,84 +439,246 @@ func (lgd *LoadGeneratingConnectionDownload) Start(
 		transport.TLSClientConfig.KeyLogWriter = lgd.KeyLogger
 	}
 	transport.TLSClientConfig.InsecureSkipVerify = true
+	SetTransportDeadlines(&transport)
+	// Compression would make the measured goodput differ from what is on the wire.
+	transport.DisableCompression = true
 
+	lgd.pool = newPingPool(&transport)
+	transport.ConnPool = lgd.pool
 	lgd.client = &http.Client{Transport: &transport}
+	lgd.pacer = lgd.SharedPacer
+	if lgd.pacer == nil {
+		lgd.pacer = NewPacer(lgd.RateLimit)
+	}
 	lgd.debug = debugLevel
-	lgd.valid = true
+	lgd.validate()
 	lgd.tracer = traceable.GenerateHttpTimingTracer(lgd, lgd.debug)
 
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf(
-			"Started a load-generating download (id: %v).\n",
-			lgd.clientId,
-		)
-	}
+	debug.Log(lgd.debug, "lgc.started", "direction", "download", "id", lgd.clientId)
 
-	go lgd.doDownload(parentCtx)
+	requestCtx, requestCtxCancel := bindRequestToContext(parentCtx)
+	lgd.stopRequest = requestCtxCancel
+	lgd.done = make(chan struct{})
+
+	go lgd.doDownload(parentCtx, requestCtx)
 	return true
 }
 
-func (lgd *LoadGeneratingConnectionDownload) IsValid() bool {
-	return lgd.valid
+func (lgd *LoadGeneratingConnectionDownload) Drain(timeout time.Duration) uint64 {
+	// Keep reading (and discarding) whatever the server is still sending us so that
+	// the connection is quiet when we close it.
+	atomic.StoreUint32(&lgd.draining, 1)
+	drainRequest(lgd.done, lgd.stopRequest, lgd.pool, timeout)
+	drained := atomic.LoadUint64(&lgd.drained)
+	debug.Log(lgd.debug, "lgc.drained", "direction", "download", "bytes", drained, "id", lgd.clientId)
+	return drained
+}
+
+func (lgd *LoadGeneratingConnectionDownload) Ping(ctx context.Context) (time.Duration, error) {
+	return lgd.pool.ping(ctx)
+}
+
+// IntegrityFailed returns whether the Sink found a problem with the integrity of any of
+// the downloaded data.
+func (lgd *LoadGeneratingConnectionDownload) IntegrityFailed() bool {
+	return atomic.LoadUint32(&lgd.integrityFailed) != 0
 }
 
 func (lgd *LoadGeneratingConnectionDownload) Stats() *stats.TraceStats {
 	return &lgd.stats
 }
 
-func (lgd *LoadGeneratingConnectionDownload) doDownload(ctx context.Context) {
-	var request *http.Request = nil
-	var get *http.Response = nil
-	var err error = nil
-
-	if request, err = http.NewRequestWithContext(
-		httptrace.WithClientTrace(ctx, lgd.tracer),
-		"GET",
-		lgd.Path,
-		nil,
-	); err != nil {
-		lgd.valid = false
-		return
+// contentRangeTotal extracts the size of the whole resource from a Content-Range header
+// (e.g., "bytes 0-1023/8192"). It is -1 when the size is unknown.
+func contentRangeTotal(contentRange string) int64 {
+	slash := strings.LastIndex(contentRange, "/")
+	if slash < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil {
+		return -1
 	}
+	return total
+}
 
-	// Used to disable compression
-	request.Header.Set("Accept-Encoding", "identity")
+func (lgd *LoadGeneratingConnectionDownload) logInterruption(err error) {
+	debug.Log(lgd.debug, "lgc.reconnecting", "direction", "download", "id", lgd.clientId, "error", err)
+}
 
-	lgd.downloadStartTime = time.Now()
-	lgd.lastIntervalEnd = 0
+func (lgd *LoadGeneratingConnectionDownload) isDraining() bool {
+	return atomic.LoadUint32(&lgd.draining) != 0
+}
 
-	if get, err = lgd.client.Do(request); err != nil {
-		lgd.valid = false
-		return
-	}
+// doDownload downloads the resource at lgd.Path. With a RangeSize, it downloads one
+// range of the resource after the other (starting over at the beginning once it gets
+// to the end) until the test is over.
+func (lgd *LoadGeneratingConnectionDownload) doDownload(
+	ctx context.Context,
+	requestCtx context.Context,
+) {
+	defer close(lgd.done)
+	defer lgd.stopRequest()
+
+	offset := int64(0)
+	// Each request is released once the next one starts (or the download ends).
+	releaseRequest := func() {}
+	defer func() { releaseRequest() }()
+	for first := true; ; first = false {
+		releaseRequest()
+		// Only the first request establishes the connection, so only it is traced.
+		tracedCtx := requestCtx
+		if first {
+			tracedCtx = httptrace.WithClientTrace(requestCtx, lgd.tracer)
+		}
+		var dialCtx context.Context
+		dialCtx, releaseRequest = withDialDeadline(tracedCtx)
+		request, err := http.NewRequestWithContext(dialCtx, "GET", lgd.Path, nil)
+		if err != nil {
+			lgd.invalidate(err)
+			return
+		}
 
-	// Header.Get returns "" when not set
-	if get.Header.Get("Content-Encoding") != "" {
-		lgd.valid = false
-		fmt.Printf("Content-Encoding header was set (compression not allowed)")
-		return
-	}
-	cr := &countingReader{n: &lgd.downloaded, ctx: ctx, readable: get.Body}
-	_, _ = io.Copy(ioutil.Discard, cr)
-	get.Body.Close()
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf("Ending a load-generating download.\n")
+		// Used to disable compression
+		request.Header.Set("Accept-Encoding", "identity")
+		useragent.Apply(request)
+		auth.Apply(request)
+		hostoverride.Apply(request)
+		if lgd.RangeSize > 0 {
+			request.Header.Set(
+				"Range",
+				fmt.Sprintf("bytes=%d-%d", offset, offset+lgd.RangeSize-1),
+			)
+		}
+
+		if first {
+			atomic.StoreInt64(&lgd.lastIntervalEnd, 0)
+			atomic.StoreInt64(&lgd.downloadStartTime, sinceEpoch())
+		}
+
+		get, err := lgd.client.Do(request)
+		if err != nil {
+			// A request that fails because the test is over does not make the
+			// connection invalid.
+			if ctx.Err() != nil || lgd.isDraining() {
+				return
+			}
+			if lgd.count(err) {
+				lgd.logInterruption(err)
+				continue
+			}
+			lgd.invalidate(err)
+			return
+		}
+
+		if get.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			get.Body.Close()
+			if offset == 0 {
+				lgd.invalidate(fmt.Errorf("The server cannot satisfy a range request for %s", lgd.Path))
+				return
+			}
+			offset = 0
+			continue
+		}
+
+		// A server that refuses the download (e.g., because the URL is wrong) would
+		// otherwise make the connection look like one that is merely slow.
+		if get.StatusCode < 200 || get.StatusCode > 299 {
+			get.Body.Close()
+			lgd.invalidate(fmt.Errorf("The server responded to the download with %s", get.Status))
+			return
+		}
+
+		// Header.Get returns "" when not set
+		if get.Header.Get("Content-Encoding") != "" {
+			get.Body.Close()
+			lgd.invalidate(fmt.Errorf("Content-Encoding header was set (compression not allowed)"))
+			return
+		}
+		var body io.Reader = get.Body
+		if lgd.RangeSize > 0 {
+			// In case the server ignores the Range header.
+			body = io.LimitReader(body, lgd.RangeSize)
+		}
+		cr := &countingReader{
+			n:        &lgd.downloaded,
+			total:    &lgd.totalDownloaded,
+			drained:  &lgd.drained,
+			draining: &lgd.draining,
+			ctx:      ctx,
+			readable: body,
+			pacer:    lgd.pacer,
+		}
+		var copyErr error
+		if lgd.Sink == nil {
+			_, copyErr = copyPooled(io.Discard, cr)
+		} else {
+			lgd.Sink.Begin(get.Header)
+			var received int64
+			received, copyErr = copyPooled(lgd.Sink, cr)
+			complete := copyErr == nil && ctx.Err() == nil &&
+				(get.ContentLength < 0 || received == get.ContentLength)
+			if err := lgd.Sink.End(complete); err != nil {
+				atomic.StoreUint32(&lgd.integrityFailed, 1)
+				debug.Log(lgd.debug, "lgc.integrity_failed", "id", lgd.ClientId(), "error", err)
+			}
+		}
+		get.Body.Close()
+
+		// The server interrupted the download, so start it over: the same range again
+		// (or the whole resource when it is not downloaded in ranges), from its
+		// beginning, on a new connection when the server sent a GOAWAY. The data itself
+		// does not matter, only the load.
+		if copyErr != nil && ctx.Err() == nil && !lgd.isDraining() {
+			if lgd.count(copyErr) {
+				lgd.logInterruption(copyErr)
+				continue
+			}
+			lgd.invalidate(copyErr)
+			return
+		}
+
+		if lgd.RangeSize <= 0 || ctx.Err() != nil || lgd.isDraining() {
+			break
+		}
+		offset += lgd.RangeSize
+		total := contentRangeTotal(get.Header.Get("Content-Range"))
+		if get.StatusCode != http.StatusPartialContent || (total >= 0 && offset >= total) {
+			offset = 0
+		}
 	}
+	debug.Log(lgd.debug, "lgc.ended", "direction", "download")
 }
 
 // TODO: All 64-bit fields that are accessed atomically must
 // appear at the top of this struct.
 type LoadGeneratingConnectionUpload struct {
+	interruptions
 	uploaded        uint64
+	totalUploaded   uint64
 	lastIntervalEnd int64
+	uploadStartTime int64
+	draining        uint32
 	Path            string
-	uploadStartTime time.Time
-	lastUploaded    uint64
-	client          *http.Client
-	debug           debug.DebugLevel
-	valid           bool
-	KeyLogger       io.Writer
-	clientId        uint64
+	// The data to send (repeatedly); see LoadUploadPayload. When nil, zeros are sent.
+	Payload []byte
+	// The most data to hand to the transport at once (0 for as much as it asks for).
+	ChunkSize    int
+	lastUploaded uint64
+	client       *http.Client
+	pool         *pingPool
+	debug        debug.DebugLevel
+	KeyLogger    io.Writer
+	clientId     uint64
+	stopRequest  context.CancelFunc
+	done         chan struct{}
+	validity
+	// When positive, the upload is kept at (or below) this many bytes per second.
+	RateLimit float64
+	// When not nil, the upload shares this Pacer (e.g., with the other uploads, to
+	// limit their aggregate rate) rather than pacing itself by RateLimit.
+	SharedPacer *Pacer
+	pacer       *Pacer
 }
 
 func (lgu *LoadGeneratingConnectionUpload) ClientId() uint64 {
@@ -352,70 +687,173 @@ func (lgu *LoadGeneratingConnectionUpload) ClientId() uint64 {
 
 func (lgd *LoadGeneratingConnectionUpload) TransferredInInterval() (uint64, time.Duration) {
 	transferred := atomic.SwapUint64(&lgd.uploaded, 0)
-	newIntervalEnd := (time.Now().Sub(lgd.uploadStartTime)).Nanoseconds()
+	newIntervalEnd := sinceEpoch() - atomic.LoadInt64(&lgd.uploadStartTime)
 	previousIntervalEnd := atomic.SwapInt64(&lgd.lastIntervalEnd, newIntervalEnd)
 	intervalLength := time.Duration(newIntervalEnd - previousIntervalEnd)
-	if debug.IsDebug(lgd.debug) {
-		fmt.Printf("upload: Transferred: %v bytes in %v.\n", transferred, intervalLength)
-	}
+	debug.Log(lgd.debug, "lgc.transferred", "direction", "upload", "bytes", transferred, "interval", intervalLength)
 	return transferred, intervalLength
 }
 
+func (lgu *LoadGeneratingConnectionUpload) Transferred() (uint64, time.Duration) {
+	start := atomic.LoadInt64(&lgu.uploadStartTime)
+	if start == 0 {
+		return 0, 0
+	}
+	return atomic.LoadUint64(&lgu.totalUploaded), time.Duration(sinceEpoch() - start)
+}
+
 func (lgu *LoadGeneratingConnectionUpload) Client() *http.Client {
 	return lgu.client
 }
 
-func (lgu *LoadGeneratingConnectionUpload) IsValid() bool {
-	return lgu.valid
+func (lgu *LoadGeneratingConnectionUpload) Ping(ctx context.Context) (time.Duration, error) {
+	return lgu.pool.ping(ctx)
+}
+
+// LoadUploadPayload loads the data that load-generating uploads send (repeatedly) from
+// _source_: zeros (the result is nil), random (incompressible data), seeded:<seed>
+// (pseudo-random data that is the same for every run with the same seed) or the name of
+// a file (e.g., /dev/urandom or a file with realistic data). The payload is read up
+// front so that the speed of the source does not affect the measurement.
+func LoadUploadPayload(source string) ([]byte, error) {
+	if source == "zeros" {
+		return nil, nil
+	}
+	var reader io.Reader = rand.Reader
+	if strings.HasPrefix(source, "seeded:") {
+		seed, err := strconv.ParseInt(strings.TrimPrefix(source, "seeded:"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid seed for the upload source %s: %v", source, err)
+		}
+		reader = mathrand.New(mathrand.NewSource(seed))
+	} else if source != "random" {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("Could not open the upload source: %v", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+	payload := make([]byte, constants.UploadPayloadSize)
+	read, err := io.ReadFull(reader, payload)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("Could not read the upload source %s: %v", source, err)
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("The upload source %s is empty", source)
+	}
+	return payload[:read], nil
 }
 
 type syntheticCountingReader struct {
-	n   *uint64
-	ctx context.Context
+	n         *uint64
+	total     *uint64
+	draining  *uint32
+	ctx       context.Context
+	payload   []byte
+	offset    int
+	chunkSize int
+	pacer     *Pacer
 }
 
 func (s *syntheticCountingReader) Read(p []byte) (n int, err error) {
-	if s.ctx.Err() != nil {
+	// Ending the body when we start to drain half-closes the stream.
+	if s.ctx.Err() != nil || atomic.LoadUint32(s.draining) != 0 {
 		return 0, io.EOF
 	}
 	err = nil
+	if s.chunkSize > 0 && len(p) > s.chunkSize {
+		p = p[:s.chunkSize]
+	}
 	n = len(p)
+	if s.payload == nil {
+		for i := range p {
+			p[i] = 0
+		}
+	} else {
+		for filled := 0; filled < n; {
+			copied := copy(p[filled:], s.payload[s.offset:])
+			filled += copied
+			s.offset = (s.offset + copied) % len(s.payload)
+		}
+	}
 
 	atomic.AddUint64(s.n, uint64(n))
+	atomic.AddUint64(s.total, uint64(n))
+	s.pacer.wait(s.ctx, n)
 	return
 }
 
-func (lgu *LoadGeneratingConnectionUpload) doUpload(ctx context.Context) bool {
+func (lgu *LoadGeneratingConnectionUpload) doUpload(
+	ctx context.Context,
+	requestCtx context.Context,
+) bool {
 	lgu.uploaded = 0
-	s := &syntheticCountingReader{n: &lgu.uploaded, ctx: ctx}
 	var resp *http.Response = nil
 	var request *http.Request = nil
 	var err error
 
-	if request, err = http.NewRequest(
-		"POST",
-		lgu.Path,
-		s,
-	); err != nil {
-		lgu.valid = false
-		return false
-	}
+	defer close(lgu.done)
+	defer lgu.stopRequest()
+
+	// Each attempt is released once the next one starts (or the upload ends).
+	releaseRequest := func() {}
+	defer func() { releaseRequest() }()
+	for first := true; ; first = false {
+		releaseRequest()
+		var dialCtx context.Context
+		dialCtx, releaseRequest = withDialDeadline(requestCtx)
+		// Every attempt gets its own body: the transport may still be reading the
+		// body of an attempt that the server interrupted.
+		s := &syntheticCountingReader{
+			n:         &lgu.uploaded,
+			total:     &lgu.totalUploaded,
+			draining:  &lgu.draining,
+			ctx:       ctx,
+			payload:   lgu.Payload,
+			chunkSize: lgu.ChunkSize,
+			pacer:     lgu.pacer,
+		}
+		if request, err = http.NewRequestWithContext(
+			dialCtx,
+			"POST",
+			lgu.Path,
+			s,
+		); err != nil {
+			lgu.invalidate(err)
+			return false
+		}
 
-	// Used to disable compression
-	request.Header.Set("Accept-Encoding", "identity")
+		// Used to disable compression
+		request.Header.Set("Accept-Encoding", "identity")
+		useragent.Apply(request)
+		auth.Apply(request)
+		hostoverride.Apply(request)
 
-	lgu.uploadStartTime = time.Now()
-	lgu.lastIntervalEnd = 0
+		if first {
+			atomic.StoreInt64(&lgu.lastIntervalEnd, 0)
+			atomic.StoreInt64(&lgu.uploadStartTime, sinceEpoch())
+		}
 
-	if resp, err = lgu.client.Do(request); err != nil {
-		lgu.valid = false
-		return false
+		if resp, err = lgu.client.Do(request); err != nil {
+			// The server interrupted the upload, so start another one (on a new
+			// connection when the server sent a GOAWAY).
+			if ctx.Err() == nil && atomic.LoadUint32(&lgu.draining) == 0 && lgu.count(err) {
+				debug.Log(lgu.debug, "lgc.reconnecting", "direction", "upload", "id", lgu.clientId, "error", err)
+				continue
+			}
+			lgu.invalidate(err)
+			return false
+		}
+		break
 	}
 
 	resp.Body.Close()
-	if debug.IsDebug(lgu.debug) {
-		fmt.Printf("Ending a load-generating upload.\n")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		lgu.invalidate(fmt.Errorf("The server responded to the upload with %s", resp.Status))
+		return false
 	}
+	debug.Log(lgu.debug, "lgc.ended", "direction", "upload")
 	return true
 }
 
@@ -433,26 +871,41 @@ func (lgu *LoadGeneratingConnectionUpload) Start(
 	transport.TLSClientConfig = &tls.Config{}
 
 	if !utilities.IsInterfaceNil(lgu.KeyLogger) {
-		if debug.IsDebug(lgu.debug) {
-			fmt.Printf(
-				"Using an SSL Key Logger for this load-generating upload.\n",
-			)
-		}
+		debug.Log(lgu.debug, "lgc.key_logger", "direction", "upload")
 		transport.TLSClientConfig.KeyLogWriter = lgu.KeyLogger
 	}
 	transport.TLSClientConfig.InsecureSkipVerify = true
+	SetTransportDeadlines(&transport)
+	// Compression would make the measured goodput differ from what is on the wire.
+	transport.DisableCompression = true
 
+	lgu.pool = newPingPool(&transport)
+	transport.ConnPool = lgu.pool
 	lgu.client = &http.Client{Transport: &transport}
-	lgu.valid = true
-
-	if debug.IsDebug(lgu.debug) {
-		fmt.Printf("Started a load-generating upload (id: %v).\n", lgu.clientId)
+	lgu.pacer = lgu.SharedPacer
+	if lgu.pacer == nil {
+		lgu.pacer = NewPacer(lgu.RateLimit)
 	}
+	lgu.validate()
+
+	debug.Log(lgu.debug, "lgc.started", "direction", "upload", "id", lgu.clientId)
 
-	go lgu.doUpload(parentCtx)
+	requestCtx, requestCtxCancel := bindRequestToContext(parentCtx)
+	lgu.stopRequest = requestCtxCancel
+	lgu.done = make(chan struct{})
+
+	go lgu.doUpload(parentCtx, requestCtx)
 	return true
 }
 
+func (lgu *LoadGeneratingConnectionUpload) Drain(timeout time.Duration) uint64 {
+	// We stop sending as soon as we start draining, so nothing is discarded. All
+	// that is left is to wait for the server to respond to the (now complete) upload.
+	atomic.StoreUint32(&lgu.draining, 1)
+	drainRequest(lgu.done, lgu.stopRequest, lgu.pool, timeout)
+	return 0
+}
+
 func (lgu *LoadGeneratingConnectionUpload) Stats() *stats.TraceStats {
 	// Get all your stats from the download side of the LGC.
 	return nil
@@ -0,0 +1,281 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+	"golang.org/x/net/http2"
+)
+
+// newServer starts an HTTP/2 server with _handler_.
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+func TestRangedDownload(t *testing.T) {
+	resource := bytes.Repeat([]byte{'x'}, 3*1024)
+	lock := sync.Mutex{}
+	ranges := make([]string, 0)
+	remotes := map[string]bool{}
+	server := newServer(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		remotes[r.RemoteAddr] = true
+		lock.Unlock()
+		http.ServeContent(w, r, "large", time.Time{}, bytes.NewReader(resource))
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lgd := &LoadGeneratingConnectionDownload{Path: server.URL + "/large", RangeSize: 1024}
+	lgd.Start(ctx, debug.Error)
+	for {
+		lock.Lock()
+		requests := len(ranges)
+		lock.Unlock()
+		if requests >= 5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-lgd.done
+
+	lock.Lock()
+	defer lock.Unlock()
+	expected := []string{"bytes=0-1023", "bytes=1024-2047", "bytes=2048-3071", "bytes=0-1023", "bytes=1024-2047"}
+	for i, requested := range expected {
+		if ranges[i] != requested {
+			t.Fatalf("Expected the ranges to start with %v but got %v", expected, ranges)
+		}
+	}
+	if len(remotes) != 1 {
+		t.Fatalf("Expected the ranges on a single connection but got %d", len(remotes))
+	}
+	if !lgd.IsValid() {
+		t.Fatalf("Expected the download to be valid but it failed: %v", lgd.Failure())
+	}
+	if transferred, _ := lgd.Transferred(); transferred < 4*1024 {
+		t.Fatalf("Expected at least 4 ranges to be counted but got %d bytes", transferred)
+	}
+}
+
+func TestDownloadStartsOverAfterStreamReset(t *testing.T) {
+	resource := bytes.Repeat([]byte{'x'}, 64*1024)
+	lock := sync.Mutex{}
+	requests := 0
+	server := newServer(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		reset := requests == 1
+		lock.Unlock()
+		if reset {
+			// Send some of the resource and then reset the stream.
+			w.Write(resource[:1024])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Write(resource)
+	})
+	defer server.Close()
+
+	lgd := &LoadGeneratingConnectionDownload{Path: server.URL + "/large"}
+	lgd.Start(context.Background(), debug.Error)
+	<-lgd.done
+
+	if !lgd.IsValid() {
+		t.Fatalf("Expected the download to recover but it failed: %v", lgd.Failure())
+	}
+	if goAways, streamResets := lgd.Interruptions(); goAways != 0 || streamResets != 1 {
+		t.Fatalf("Expected a single stream reset but got %d GOAWAYs and %d resets", goAways, streamResets)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if requests != 2 {
+		t.Fatalf("Expected the download to start over once but it took %d requests", requests)
+	}
+	if transferred, _ := lgd.Transferred(); transferred < uint64(len(resource)) {
+		t.Fatalf("Expected the whole resource after the reset but got %d bytes", transferred)
+	}
+}
+
+func TestDownloadGivesUpAfterTooManyInterruptions(t *testing.T) {
+	server := newServer(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer server.Close()
+
+	lgd := &LoadGeneratingConnectionDownload{Path: server.URL + "/large"}
+	lgd.Start(context.Background(), debug.Error)
+	<-lgd.done
+
+	if lgd.IsValid() {
+		t.Fatalf("Expected the download to give up on a server that always resets it")
+	}
+	if _, streamResets := lgd.Interruptions(); streamResets != constants.MaximumInterruptions+1 {
+		t.Fatalf("Expected %d stream resets but got %d", constants.MaximumInterruptions+1, streamResets)
+	}
+}
+
+// newPoolClient returns a client whose transport dials through a pingPool.
+func newPoolClient() (*http.Client, *pingPool) {
+	transport := &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	pool := newPingPool(transport)
+	transport.ConnPool = pool
+	return &http.Client{Transport: transport}, pool
+}
+
+func TestPingPool(t *testing.T) {
+	lock := sync.Mutex{}
+	remotes := map[string]bool{}
+	server := newServer(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		remotes[r.RemoteAddr] = true
+		lock.Unlock()
+	})
+	defer server.Close()
+
+	client, pool := newPoolClient()
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL)
+			if err != nil {
+				errs <- err
+				return
+			}
+			response.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Could not make a request through the pool: %v", err)
+	}
+
+	lock.Lock()
+	connections := len(remotes)
+	lock.Unlock()
+	if connections != 1 || len(pool.conns) != 1 {
+		t.Fatalf("Expected a single connection to the server but got %d (%d in the pool)", connections, len(pool.conns))
+	}
+	if _, err := pool.ping(context.Background()); err != nil {
+		t.Fatalf("Could not ping the connection: %v", err)
+	}
+	pool.closeIdleConnections()
+	if _, err := pool.ping(context.Background()); err == nil {
+		t.Fatalf("Pinged a connection that was closed")
+	}
+}
+
+func TestPingPoolRequiresHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.StartTLS()
+	defer server.Close()
+	// Negotiate no protocol at all (rather than fail the handshake).
+	server.TLS.NextProtos = nil
+
+	client, _ := newPoolClient()
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "negotiated") {
+		t.Fatalf("Expected the pool to refuse a connection without HTTP/2 but got %v", err)
+	}
+}
+
+func TestPacer(t *testing.T) {
+	if NewPacer(0) != nil {
+		t.Fatalf("Expected no Pacer without a rate")
+	}
+	var unpaced *Pacer
+	unpaced.wait(context.Background(), 1<<30)
+
+	// Two transfers that share a Pacer of 10 kB/s take 400ms for the 4 kB between them
+	// (the Pacer delays every kB until it is due).
+	pacer := NewPacer(10 * 1000)
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2; j++ {
+				pacer.wait(context.Background(), 1000)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Expected 4 kB at 10 kB/s to take 400ms but they took %v", elapsed)
+	}
+
+	// Waiting ends with the transfer.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	NewPacer(1).wait(ctx, 1000)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the wait to end with the transfer but it took %v", elapsed)
+	}
+}
+
+// A writeRecorder records the largest write.
+type writeRecorder struct {
+	bytes.Buffer
+	largest int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestCopyPooled(t *testing.T) {
+	data := make([]byte, 3*constants.DownloadCopyBufferSize+5)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	// A bytes.Reader is an io.WriterTo, which io.CopyBuffer would use (rather than
+	// the buffer) if it could.
+	dst := &writeRecorder{}
+	copied, err := copyPooled(dst, bytes.NewReader(data))
+	if err != nil || copied != int64(len(data)) {
+		t.Fatalf("Expected to copy %d bytes but copied %d (%v)", len(data), copied, err)
+	}
+	if !bytes.Equal(dst.Bytes(), data) {
+		t.Fatalf("The copy differs from the original")
+	}
+	if dst.largest != constants.DownloadCopyBufferSize {
+		t.Fatalf("Expected the copy to go through the %d-byte buffer but the largest write was %d bytes", constants.DownloadCopyBufferSize, dst.largest)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pacer keeps a transfer at (or below) a rate by delaying it. A Pacer that several
+// connections share keeps their aggregate transfer at the rate.
+type Pacer struct {
+	lock  sync.Mutex
+	rate  float64
+	start time.Time
+	bytes float64
+}
+
+// NewPacer returns a Pacer for _rate_ bytes per second (or nil, which never delays,
+// when _rate_ is not positive).
+func NewPacer(rate float64) *Pacer {
+	if rate <= 0 {
+		return nil
+	}
+	return &Pacer{rate: rate}
+}
+
+// wait delays the transfer of another _n_ bytes for as long as the transfer would
+// otherwise exceed the rate (or until _ctx_ is done).
+func (p *Pacer) wait(ctx context.Context, n int) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	now := time.Now()
+	due := p.start.Add(time.Duration(p.bytes / p.rate * float64(time.Second)))
+	// A transfer that fell behind (e.g., while it reconnected) does not get to make
+	// up for it with a burst.
+	if p.start.IsZero() || now.Sub(due) > time.Second {
+		p.start, p.bytes = now, 0
+	}
+	p.bytes += float64(n)
+	due = p.start.Add(time.Duration(p.bytes / p.rate * float64(time.Second)))
+	p.lock.Unlock()
+
+	delay := time.Until(due)
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
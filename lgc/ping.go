@@ -0,0 +1,143 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package lgc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/hostoverride"
+	"golang.org/x/net/http2"
+)
+
+// pingPool is the pool of the HTTP/2 connections of a load-generating connection (one
+// per server; there is more than one only when self probes go to other servers). It
+// dials just like the transport's default pool but, unlike that one, it gives access to
+// the connection that carries the load so that we can send PINGs on it.
+type pingPool struct {
+	transport *http2.Transport
+	lock      sync.Mutex
+	conns     map[string]*http2.ClientConn
+	// The server of the first request, which is the one that carries the load.
+	loadAddr string
+}
+
+func newPingPool(transport *http2.Transport) *pingPool {
+	return &pingPool{transport: transport, conns: make(map[string]*http2.ClientConn)}
+}
+
+func (p *pingPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+	p.lock.Lock()
+	if p.loadAddr == "" {
+		p.loadAddr = addr
+	}
+	if conn := p.conns[addr]; conn != nil && conn.ReserveNewRequest() {
+		p.lock.Unlock()
+		return conn, nil
+	}
+	// The handshake takes a while; ping and MarkDead must not wait for it (the lock is
+	// taken again once the connection is ready, below).
+	p.lock.Unlock()
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{}
+	if p.transport.TLSClientConfig != nil {
+		config = p.transport.TLSClientConfig.Clone()
+	}
+	config.NextProtos = []string{http2.NextProtoTLS}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	hostoverride.ConfigureTLS(config, addr)
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if protocol := conn.(*tls.Conn).ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+		conn.Close()
+		return nil, fmt.Errorf("The server negotiated %q rather than %q", protocol, http2.NextProtoTLS)
+	}
+	clientConn, err := p.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !clientConn.ReserveNewRequest() {
+		clientConn.Close()
+		return nil, fmt.Errorf("The new connection to %s cannot take a request", addr)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// Another request may have dialed the server in the meantime; keep the connection
+	// that is already known so that there is one per server.
+	if conn := p.conns[addr]; conn != nil && conn.ReserveNewRequest() {
+		clientConn.Close()
+		return conn, nil
+	}
+	p.conns[addr] = clientConn
+	return clientConn, nil
+}
+
+func (p *pingPool) MarkDead(clientConn *http2.ClientConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for addr, conn := range p.conns {
+		if conn == clientConn {
+			delete(p.conns, addr)
+		}
+	}
+}
+
+// closeIdleConnections closes the connections that are not carrying a request (the
+// transport's CloseIdleConnections only works with its default pool).
+func (p *pingPool) closeIdleConnections() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for addr, conn := range p.conns {
+		if conn.State().StreamsActive == 0 {
+			conn.Close()
+			delete(p.conns, addr)
+		}
+	}
+}
+
+// ping sends an HTTP/2 PING on the connection that carries the load and returns how
+// long it took for the server to acknowledge it.
+func (p *pingPool) ping(ctx context.Context) (time.Duration, error) {
+	p.lock.Lock()
+	clientConn := p.conns[p.loadAddr]
+	p.lock.Unlock()
+	if clientConn == nil {
+		return 0, fmt.Errorf("There is no connection to ping")
+	}
+	start := time.Now()
+	if err := clientConn.Ping(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,161 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package live reports the state of a test (once a second, say) while it runs.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/events"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Interim is the state of a test at one point during its run.
+type Interim struct {
+	Elapsed         float64 `json:"elapsed_seconds"`
+	Phase           string  `json:"phase"`
+	DownloadRateBps float64 `json:"download_bytes_per_second"`
+	DownloadFlows   int     `json:"download_flows"`
+	UploadRateBps   float64 `json:"upload_bytes_per_second"`
+	UploadFlows     int     `json:"upload_flows"`
+	SelfProbeP90    float64 `json:"self_probe_p90_seconds"`
+	ForeignProbeP90 float64 `json:"foreign_probe_p90_seconds"`
+	RPM             float64 `json:"rpm"`
+}
+
+// Reporter is an events.Handler that keeps track of the state of a test.
+type Reporter struct {
+	lock     sync.Mutex
+	start    time.Time
+	phase    string
+	download events.ThroughputSample
+	upload   events.ThroughputSample
+	// The P90s of the round-trip times so far (estimated as the probes arrive so that
+	// a long test does not keep every probe).
+	selfP90    *utilities.StreamingPercentile
+	foreignP90 *utilities.StreamingPercentile
+	// The weight of the self probes in the RPM (see -self-weight).
+	SelfWeight float64
+}
+
+func NewReporter(start time.Time) *Reporter {
+	return &Reporter{
+		start:      start,
+		selfP90:    utilities.NewStreamingPercentile(90),
+		foreignP90: utilities.NewStreamingPercentile(90),
+		SelfWeight: constants.DefaultSelfProbeWeight,
+	}
+}
+
+func (r *Reporter) OnConnectionAdded(string, uint64) {}
+
+func (r *Reporter) OnThroughputSample(source string, sample events.ThroughputSample) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if source == events.Download {
+		r.download = sample
+	} else if source == events.Upload {
+		r.upload = sample
+	}
+}
+
+func (r *Reporter) OnProbeResult(source string, result events.ProbeResult) {
+	// Just like at the end of the test, responses from a cache do not count.
+	if result.Cached {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if result.Foreign {
+		r.foreignP90.Add(result.Duration.Seconds())
+	} else {
+		r.selfP90.Add(result.Duration.Seconds())
+	}
+}
+
+func (r *Reporter) OnPhaseChange(phase string, progressPercent float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.phase = phase
+}
+
+// Interim returns the state of the test at _now_. The P90s (and RPM) are calculated over
+// all the probes so far and are 0 until there are enough probes.
+func (r *Reporter) Interim(now time.Time) Interim {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	interim := Interim{
+		Elapsed:         now.Sub(r.start).Seconds(),
+		Phase:           r.phase,
+		DownloadRateBps: r.download.BytesPerSecond,
+		DownloadFlows:   r.download.Connections,
+		UploadRateBps:   r.upload.BytesPerSecond,
+		UploadFlows:     r.upload.Connections,
+		SelfProbeP90:    r.selfP90.Value(),
+		ForeignProbeP90: r.foreignP90.Value(),
+	}
+	if interim.SelfProbeP90 > 0 && interim.ForeignProbeP90 > 0 {
+		interim.RPM = 60.0 / (r.SelfWeight*interim.SelfProbeP90 + (1-r.SelfWeight)*interim.ForeignProbeP90)
+	}
+	return interim
+}
+
+func (i Interim) String() string {
+	return fmt.Sprintf(
+		"[%5.1fs] Download: %8.3f Mbps (%2d flows), Upload: %8.3f Mbps (%2d flows), P90 LG RTT: %6.3fs, P90 NC RTT: %6.3fs, RPM: %5.0f (%s)",
+		i.Elapsed,
+		utilities.ToMbps(i.DownloadRateBps),
+		i.DownloadFlows,
+		utilities.ToMbps(i.UploadRateBps),
+		i.UploadFlows,
+		i.SelfProbeP90,
+		i.ForeignProbeP90,
+		i.RPM,
+		i.Phase,
+	)
+}
+
+// Report writes the state of the test to _w_ every _interval_ until _ctx_ is canceled,
+// one line per report. When _jsonLines_ is true, each line is a JSON object (an Interim).
+func (r *Reporter) Report(
+	ctx context.Context,
+	interval time.Duration,
+	jsonLines bool,
+	w io.Writer,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			interim := r.Interim(now)
+			if jsonLines {
+				if line, err := json.Marshal(interim); err == nil {
+					fmt.Fprintln(w, string(line))
+				}
+			} else {
+				fmt.Fprintln(w, interim.String())
+			}
+		}
+	}
+}
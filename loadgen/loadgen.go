@@ -0,0 +1,90 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package loadgen generates sustained load on the network without measuring anything
+// (e.g., as controlled cross traffic while another client measures the
+// responsiveness).
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/lgc"
+)
+
+// Run keeps _flows_ load-generating connections (made by _generate_) busy until _ctx_
+// is done. Every _interval_, _report_ (if not nil) learns the rate (in bytes per
+// second) of all of them together. A connection that fails is replaced; Run only gives
+// up (and returns the failure) when all of them failed at once.
+func Run(
+	ctx context.Context,
+	generate func() lgc.LoadGeneratingConnection,
+	flows int,
+	interval time.Duration,
+	report func(bytesPerSecond float64),
+	debugLevel debug.DebugLevel,
+) error {
+	lgcs := make([]lgc.LoadGeneratingConnection, 0, flows)
+	for len(lgcs) < flows {
+		connection := generate()
+		connection.Start(ctx, debugLevel)
+		lgcs = append(lgcs, connection)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// The connections fail once _ctx_ is done, which is no reason to replace them.
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		rate, failed := float64(0), 0
+		var failure error = nil
+		for i := range lgcs {
+			if !lgcs[i].IsValid() {
+				failed++
+				failure = lgcs[i].Failure()
+				fmt.Fprintf(
+					os.Stderr,
+					"Warning: Load-generating connection %d failed (it is replaced): %v\n",
+					lgcs[i].ClientId(),
+					failure,
+				)
+				lgcs[i] = generate()
+				lgcs[i].Start(ctx, debugLevel)
+				continue
+			}
+			transferred, duration := lgcs[i].TransferredInInterval()
+			if duration > 0 {
+				rate += float64(transferred) / duration.Seconds()
+			}
+		}
+		if failed == len(lgcs) {
+			return fmt.Errorf("All load-generating connections failed: %v", failure)
+		}
+		if report != nil {
+			report(rate)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package ma
 
 import (
+	"math"
+
 	"github.com/network-quality/goresponsiveness/saturating"
 	"github.com/network-quality/goresponsiveness/utilities"
 )
@@ -74,3 +76,22 @@ func (ma *MovingAverage) AllSequentialIncreasesLessThan(limit float64) bool {
 	}
 	return true
 }
+
+// StandardDeviationWithin is true when the standard deviation of the measurements is
+// at most _limit_ percent of their average.
+func (ma *MovingAverage) StandardDeviationWithin(limit float64) bool {
+
+	// If we have not yet accumulated a complete set of intervals,
+	// this is false.
+	if ma.divisor.Value() != ma.intervals {
+		return false
+	}
+
+	average := ma.CalculateAverage()
+	variance := float64(0)
+	for i := 0; i < ma.intervals; i++ {
+		variance += (ma.instants[i] - average) * (ma.instants[i] - average)
+	}
+	variance /= float64(ma.intervals)
+	return math.Sqrt(variance) <= math.Abs(average)*limit/100
+}
@@ -0,0 +1,92 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package metadata describes a run of the client (its identifier, which client ran it
+// where and against which server), so that everything that the run reports (e.g., the
+// records of the data loggers) can be joined back to it.
+package metadata
+
+import (
+	"net"
+	"net/url"
+	"runtime"
+
+	"github.com/network-quality/goresponsiveness/version"
+)
+
+type Metadata struct {
+	// The random identifier of the run (see package runid).
+	RunId  string       `json:"run_id"`
+	Client version.Info `json:"client"`
+	// The operating system and architecture of the client (e.g., linux/amd64).
+	OS string `json:"os"`
+	// The name of the network interface through which the client reaches the
+	// configuration server (empty when it cannot tell).
+	Interface string `json:"interface,omitempty"`
+	// The host of the configuration server and the address that it resolved to.
+	ConfigServer   string `json:"config_server"`
+	ConfigServerIP string `json:"config_server_ip,omitempty"`
+}
+
+// Collect describes the run _runId_ whose configuration came from _configSource_ (a
+// URL).
+func Collect(runId string, configSource string) Metadata {
+	m := Metadata{
+		RunId:  runId,
+		Client: version.Get(),
+		OS:     runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	parsed, err := url.Parse(configSource)
+	if err != nil {
+		return m
+	}
+	m.ConfigServer = parsed.Hostname()
+	if ips, err := net.LookupIP(m.ConfigServer); err == nil && len(ips) > 0 {
+		m.ConfigServerIP = ips[0].String()
+		m.Interface = interfaceTo(ips[0])
+	}
+	return m
+}
+
+// interfaceTo returns the name of the interface through which the client reaches _ip_
+// (empty when it cannot tell).
+func interfaceTo(ip net.IP) string {
+	// Connecting a UDP socket sends nothing but picks the local address (and so the
+	// interface) from the routing table.
+	conn, err := net.Dial("udp", net.JoinHostPort(ip.String(), "443"))
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return ""
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range interfaces {
+		addresses, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			if network, ok := address.(*net.IPNet); ok && network.IP.Equal(local.IP) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
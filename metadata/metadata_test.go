@@ -0,0 +1,33 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package metadata
+
+import (
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	m := Collect("42", "https://127.0.0.1:4043/config")
+	if m.RunId != "42" || m.OS == "" || m.Client.Version == "" {
+		t.Fatalf("%+v does not describe the run and the client", m)
+	}
+	if m.ConfigServer != "127.0.0.1" || m.ConfigServerIP != "127.0.0.1" {
+		t.Fatalf("%+v does not describe the configuration server", m)
+	}
+	// The loopback interface is lo (Linux) or lo0 (macOS and BSDs).
+	if m.Interface == "" {
+		t.Fatalf("%+v does not name the interface to the configuration server", m)
+	}
+}
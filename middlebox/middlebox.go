@@ -0,0 +1,135 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package middlebox checks, before the test, whether something between the client and
+// the test server (a captive portal or an intercepting proxy) answers in the server's
+// place. Such middleboxes yield results that look plausible but measure the middlebox
+// (or its login page) rather than the network.
+package middlebox
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/hostoverride"
+	"github.com/network-quality/goresponsiveness/redirect"
+)
+
+// The headers that (only) proxies add to responses. Via is not among them: CDNs (which
+// test servers may well be behind) add it, too.
+var proxyHeaders = []string{"X-Squid-Error", "X-Bluecoat-Via", "Proxy-Agent"}
+
+// Check fetches the configuration at _configUrl_ and the small resource at _smallUrl_
+// (whose redirects must have been followed already) and returns what about the
+// responses suggests a middlebox (nothing when they look like they came from a test
+// server).
+func Check(configUrl string, smallUrl string) []string {
+	findings := make([]string, 0)
+	findings = append(findings, check("configuration", configUrl, true)...)
+	findings = append(findings, check("small resource", smallUrl, false)...)
+	return findings
+}
+
+func check(name string, rawUrl string, expectJson bool) []string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return []string{fmt.Sprintf("The URL of the %s is invalid: %v", name, err)}
+	}
+	client := config.NewClient(parsed.Scheme)
+	client.Timeout = constants.MiddleboxCheckTimeout
+	response, hops, err := redirect.Follow(client, "GET", rawUrl, config.Prepare)
+	if err != nil {
+		// Whether the server is reachable at all is up to the test (or -preflight).
+		return nil
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, constants.MiddleboxBodyLimit))
+
+	findings := Inspect(name, response, body, expectJson)
+	// The redirects of the configuration were followed when it was fetched, but those
+	// of the test URLs were resolved already.
+	if !expectJson && len(hops) > 0 {
+		findings = append(findings, fmt.Sprintf("The %s redirects to %s", name, hops[len(hops)-1].Location))
+	}
+	tlsConfig := &tls.Config{ServerName: parsed.Hostname()}
+	hostoverride.ConfigureTLS(tlsConfig, redirect.Address(parsed))
+	if err := Untrusted(response.TLS, tlsConfig.ServerName); err != nil {
+		findings = append(findings, fmt.Sprintf(
+			"The certificate presented for the %s is not trusted (%v), so something may be intercepting TLS", name, err,
+		))
+	}
+	return findings
+}
+
+// Inspect returns what about _response_ (with _body_, which is JSON when _expectJson_)
+// to the request for the resource called _name_ suggests a middlebox.
+func Inspect(name string, response *http.Response, body []byte, expectJson bool) []string {
+	findings := make([]string, 0)
+	if response.StatusCode == http.StatusNetworkAuthenticationRequired {
+		findings = append(findings, fmt.Sprintf("The request for the %s requires logging in to the network", name))
+	} else if response.StatusCode/100 != 2 {
+		findings = append(findings, fmt.Sprintf("The request for the %s was answered with %s", name, response.Status))
+	}
+	if IsHtml(response.Header.Get("Content-Type"), body) {
+		findings = append(findings, fmt.Sprintf("The %s is an HTML page", name))
+	} else if expectJson && response.StatusCode/100 == 2 && !json.Valid(body) {
+		findings = append(findings, fmt.Sprintf("The %s is not JSON", name))
+	}
+	for _, header := range proxyHeaders {
+		if value := response.Header.Get(header); value != "" {
+			findings = append(findings, fmt.Sprintf("The response with the %s came through a proxy (%s: %s)", name, header, value))
+		}
+	}
+	return findings
+}
+
+// IsHtml returns whether a response with _contentType_ and _body_ is an HTML page (a
+// test server never serves one).
+func IsHtml(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		(mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+		return true
+	}
+	start := bytes.ToLower(bytes.TrimSpace(body))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+// Untrusted returns why the certificate of the connection with _state_ is not trusted
+// for _serverName_ (nil when it is or when there was no TLS). Servers addressed by IP
+// literals or as localhost are labs, which are expected to have certificates of their own.
+func Untrusted(state *tls.ConnectionState, serverName string) error {
+	if state == nil || len(state.PeerCertificates) == 0 ||
+		serverName == "localhost" || net.ParseIP(serverName) != nil {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: intermediates,
+	})
+	return err
+}
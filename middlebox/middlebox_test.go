@@ -0,0 +1,79 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package middlebox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/config" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"version": 1}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, "1234")
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	if findings := Check(server.URL+"/config", server.URL+"/small"); len(findings) != 0 {
+		t.Fatalf("Expected no findings for a test server but got %v", findings)
+	}
+}
+
+func TestCheckPortal(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>Please log in.</body></html>")
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	findings := Check(server.URL+"/config", server.URL+"/small")
+	// Both are HTML pages and the small resource redirects, too.
+	if len(findings) != 3 {
+		t.Fatalf("Expected 3 findings for a captive portal but got %v", findings)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	response := &http.Response{
+		StatusCode: http.StatusNetworkAuthenticationRequired,
+		Status:     "511 Network Authentication Required",
+		Header:     http.Header{"X-Squid-Error": []string{"ERR_ACCESS_DENIED 0"}},
+	}
+	if findings := Inspect("small resource", response, []byte("\n<!DOCTYPE html>"), false); len(findings) != 3 {
+		t.Fatalf("Expected 3 findings but got %v", findings)
+	}
+	response = &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}}
+	if findings := Inspect("configuration", response, []byte("urls"), true); len(findings) != 1 {
+		t.Fatalf("Expected a configuration that is not JSON but got %v", findings)
+	}
+	if findings := Inspect("small resource", response, []byte("urls"), false); len(findings) != 0 {
+		t.Fatalf("Expected no findings but got %v", findings)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package netwatch detects changes of the network during a test (e.g., a laptop that
+// roams from Wi-Fi to Ethernet), after which the results mix two networks. It
+// periodically compares the addresses of the interfaces and the local address (and so
+// the route) through which the test server is reached.
+package netwatch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Change is a change of the network that was noticed at Time.
+type Change struct {
+	Time   time.Time
+	Before string
+	After  string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("at %v, from %s to %s", c.Time.Format(time.StampMilli), c.Before, c.After)
+}
+
+type Watcher struct {
+	lock     sync.Mutex
+	snapshot func() string
+	last     string
+	changes  []Change
+}
+
+// NewWatcher creates a watcher of the network through which _host_ is reached.
+func NewWatcher(host string) *Watcher {
+	// The host is resolved once: looking it up at every check would add DNS traffic to
+	// the test (and its delays to the checks).
+	address := net.JoinHostPort(host, "443")
+	if resolved, err := net.ResolveUDPAddr("udp", address); err == nil {
+		address = resolved.String()
+	}
+	w := &Watcher{snapshot: func() string { return snapshot(address) }}
+	w.last = w.snapshot()
+	return w
+}
+
+// snapshot describes the addresses of the interfaces that are up (other than loopback)
+// and the local address through which _address_ (host:port) is reached.
+func snapshot(address string) string {
+	addresses := make([]string, 0)
+	if interfaces, err := net.Interfaces(); err == nil {
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			ifaceAddresses, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+			for _, address := range ifaceAddresses {
+				addresses = append(addresses, iface.Name+" "+address.String())
+			}
+		}
+	}
+	sort.Strings(addresses)
+	route := "none"
+	// Connecting a UDP socket sends nothing but picks the local address from the
+	// routing table.
+	if conn, err := net.Dial("udp", address); err == nil {
+		route = conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+	}
+	return fmt.Sprintf("route via %s (addresses: %s)", route, strings.Join(addresses, ", "))
+}
+
+// Check compares the network with what it was at the last check and returns the change
+// (or nil).
+func (w *Watcher) Check(now time.Time) *Change {
+	current := w.snapshot()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if current == w.last {
+		return nil
+	}
+	change := &Change{Time: now, Before: w.last, After: current}
+	w.changes = append(w.changes, *change)
+	w.last = current
+	return change
+}
+
+// Run checks the network every _interval_ until _ctx_ is canceled and calls _onChange_
+// (when not nil) with every change.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, onChange func(Change)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if change := w.Check(time.Now()); change != nil && onChange != nil {
+				onChange(*change)
+			}
+		}
+	}
+}
+
+// Changes returns all the changes that were detected.
+func (w *Watcher) Changes() []Change {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]Change(nil), w.changes...)
+}
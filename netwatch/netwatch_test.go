@@ -0,0 +1,37 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package netwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	current := "route via 192.0.2.1"
+	w := &Watcher{snapshot: func() string { return current }}
+	w.last = w.snapshot()
+	if change := w.Check(time.Now()); change != nil {
+		t.Fatalf("Detected a change of a network that did not change: %v", change)
+	}
+	current = "route via 198.51.100.1"
+	change := w.Check(time.Now())
+	if change == nil || change.Before != "route via 192.0.2.1" || change.After != current {
+		t.Fatalf("Expected the change of the route but got %v.", change)
+	}
+	if len(w.Changes()) != 1 {
+		t.Fatalf("Expected one change but got %v.", w.Changes())
+	}
+}
@@ -16,30 +16,155 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/anomaly"
+	"github.com/network-quality/goresponsiveness/auth"
+	"github.com/network-quality/goresponsiveness/cachecheck"
 	"github.com/network-quality/goresponsiveness/ccw"
+	"github.com/network-quality/goresponsiveness/clock"
+	"github.com/network-quality/goresponsiveness/clockwatch"
 	"github.com/network-quality/goresponsiveness/config"
 	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/cpuload"
 	"github.com/network-quality/goresponsiveness/datalogger"
 	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/discover"
+	"github.com/network-quality/goresponsiveness/events"
+	"github.com/network-quality/goresponsiveness/explain"
 	"github.com/network-quality/goresponsiveness/extendedstats"
+	"github.com/network-quality/goresponsiveness/grade"
+	"github.com/network-quality/goresponsiveness/hostoverride"
+	"github.com/network-quality/goresponsiveness/icmpprobe"
 	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/live"
+	"github.com/network-quality/goresponsiveness/loadgen"
+	"github.com/network-quality/goresponsiveness/metadata"
+	"github.com/network-quality/goresponsiveness/middlebox"
+	"github.com/network-quality/goresponsiveness/netwatch"
+	"github.com/network-quality/goresponsiveness/otlp"
+	"github.com/network-quality/goresponsiveness/outlier"
+	"github.com/network-quality/goresponsiveness/pcap"
+	"github.com/network-quality/goresponsiveness/plotscript"
+	"github.com/network-quality/goresponsiveness/preflight"
+	"github.com/network-quality/goresponsiveness/preset"
+	"github.com/network-quality/goresponsiveness/progress"
+	"github.com/network-quality/goresponsiveness/pushgateway"
+	"github.com/network-quality/goresponsiveness/ramp"
+	"github.com/network-quality/goresponsiveness/redirect"
+	"github.com/network-quality/goresponsiveness/resourceusage"
 	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/rpmseries"
+	"github.com/network-quality/goresponsiveness/runid"
+	"github.com/network-quality/goresponsiveness/selftest"
+	"github.com/network-quality/goresponsiveness/servercheck"
+	"github.com/network-quality/goresponsiveness/servermeta"
+	"github.com/network-quality/goresponsiveness/simulate"
+	"github.com/network-quality/goresponsiveness/sink"
+	"github.com/network-quality/goresponsiveness/spec"
+	"github.com/network-quality/goresponsiveness/spike"
+	"github.com/network-quality/goresponsiveness/stopwatch"
+	"github.com/network-quality/goresponsiveness/submit"
+	"github.com/network-quality/goresponsiveness/summary"
 	"github.com/network-quality/goresponsiveness/timeoutat"
+	"github.com/network-quality/goresponsiveness/traceable"
+	"github.com/network-quality/goresponsiveness/traceroute"
+	"github.com/network-quality/goresponsiveness/tui"
+	"github.com/network-quality/goresponsiveness/udpprobe"
+	"github.com/network-quality/goresponsiveness/useragent"
 	"github.com/network-quality/goresponsiveness/utilities"
+	"github.com/network-quality/goresponsiveness/version"
+	"github.com/network-quality/goresponsiveness/webhook"
+	"github.com/network-quality/goresponsiveness/wire"
 )
 
 var (
 	// Variables to hold CLI arguments.
+	userAgent = flag.String(
+		"user-agent",
+		"",
+		"User-Agent of all requests (default: goresponsiveness/<version> and the URL of the project).",
+	)
+	authToken = flag.String(
+		"auth-token",
+		"",
+		"Bearer token with which to authenticate to the test server.",
+	)
+	authBasic = flag.String(
+		"auth-basic",
+		"",
+		"Credentials (user:password) with which to authenticate to the test server through basic authentication.",
+	)
+	serverName = flag.String(
+		"sni",
+		"",
+		"Server name (SNI) in the TLS handshakes with the test server (instead of the name in its URLs).",
+	)
+	hostHeader = flag.String(
+		"host-header",
+		"",
+		"Host header of the requests to the test server (instead of the host in its URLs).",
+	)
+	preflightCheck = flag.Bool(
+		"preflight",
+		false,
+		"Check the small, large and upload resources of the test server (and fail right away if one is unusable) before the test and whether it lets caches store them after it.",
+	)
+	maxRedirects = flag.Int(
+		"max-redirects",
+		constants.DefaultMaxRedirects,
+		"Maximum number of redirects that a request to the test server may take.",
+	)
+	showVersion = flag.Bool(
+		"version",
+		false,
+		"Print the version of the client (and how it was built) and exit.",
+	)
 	configHost = flag.String(
 		"config",
 		constants.DefaultConfigHost,
-		"name/IP of responsiveness configuration server.",
+		"name/IP of responsiveness configuration server (followed by comma-separated fallbacks).",
+	)
+	configUrl = flag.String(
+		"url",
+		"",
+		"URL of the configuration (instead of -config, -port and -path; may be http for labs). May also be given as the only argument.",
+	)
+	serverPreset = flag.String(
+		"server",
+		"",
+		"well-known public server to test against (instead of -config, -port and -path): "+strings.Join(preset.Names, ", ")+".",
+	)
+	discoverDomain = flag.String(
+		"discover",
+		"",
+		"domain whose DNS (SRV records of _nq._tcp.<domain>) advertises the configuration servers (instead of -config and -port).",
+	)
+	configAttempts = flag.Int(
+		"config-attempts",
+		constants.DefaultConfigAttempts,
+		"number of rounds in which to try to fetch the configuration (from the server and its fallbacks), with exponential backoff.",
 	)
 	configPort = flag.Int(
 		"port",
@@ -54,7 +179,37 @@ var (
 	debugCliFlag = flag.Bool(
 		"debug",
 		constants.DefaultDebug,
-		"Enable debugging.",
+		"Enable debugging (the same as -v).",
+	)
+	verbose = flag.Bool(
+		"v",
+		false,
+		"Print what the test does along the way.",
+	)
+	veryVerbose = flag.Bool(
+		"vv",
+		false,
+		"Print what the test does along the way, down to every step of every probe.",
+	)
+	quiet = flag.Bool(
+		"q",
+		false,
+		"Only print the final download rate, upload rate and RPM.",
+	)
+	onlyRpm = flag.Bool(
+		"qq",
+		false,
+		"Only print the RPM.",
+	)
+	logFormat = flag.String(
+		"log-format",
+		debug.FormatText,
+		"The format of the output of -v and -vv: text or json (one object per event).",
+	)
+	logTarget = flag.String(
+		"log-target",
+		debug.TargetStdout,
+		"Where the output of -v and -vv (and then the summary) goes: stdout or syslog.",
 	)
 	sattimeout = flag.Int(
 		"sattimeout",
@@ -66,6 +221,21 @@ var (
 		constants.RPMCalculationTime,
 		"Maximum time to spend calculating RPM.",
 	)
+	downloadDuration = flag.Duration(
+		"download-duration",
+		0,
+		"Maximum time to spend saturating the download (e.g., 20s; 0 for -sattimeout).",
+	)
+	uploadDuration = flag.Duration(
+		"upload-duration",
+		0,
+		"Maximum time to spend saturating the upload (e.g., 60s on links whose upload is slow to saturate; 0 for -sattimeout).",
+	)
+	stableDuration = flag.Duration(
+		"stable-duration",
+		0,
+		"How long to keep the network saturated (and probing it) once both directions are saturated, so that the responsiveness is measured over a longer stable phase. 0 (the default) ends the test right away.",
+	)
 	sslKeyFileName = flag.String(
 		"ssl-key-file",
 		"",
@@ -76,6 +246,41 @@ var (
 		"",
 		"Enable client runtime profiling and specify storage location. Disabled by default.",
 	)
+	memProfile = flag.String(
+		"memprofile",
+		"",
+		"Store a heap profile in this file at the end of the test. Disabled by default.",
+	)
+	goroutineProfile = flag.String(
+		"goroutineprofile",
+		"",
+		"Store a profile of the goroutines in this file at the end of the test. Disabled by default.",
+	)
+	blockProfile = flag.String(
+		"blockprofile",
+		"",
+		"Record every blocking event and store the profile in this file at the end of the test. Disabled by default.",
+	)
+	mutexProfile = flag.String(
+		"mutexprofile",
+		"",
+		"Record every contended mutex and store the profile in this file at the end of the test. Disabled by default.",
+	)
+	pprofListen = flag.String(
+		"pprof-listen",
+		"",
+		"Serve the runtime profiles over HTTP (see net/http/pprof) on this address (e.g., localhost:6060) while the test runs. Disabled by default.",
+	)
+	profileHttp = flag.String(
+		"profile-http",
+		"",
+		"The same as -pprof-listen.",
+	)
+	executionTrace = flag.String(
+		"trace",
+		"",
+		"Store an execution trace of the test (see runtime/trace and go tool trace) in this file. Disabled by default.",
+	)
 	calculateExtendedStats = flag.Bool(
 		"extended-stats",
 		false,
@@ -84,16 +289,1478 @@ var (
 	dataLoggerBaseFileName = flag.String(
 		"logger-filename",
 		"",
-		"Store granular information about tests results in files with this basename. Time and information type will be appended (before the first .) to create separate log files. Disabled by default.",
+		"Store granular information about tests results in files with this basename. Time and information type will be appended (before the first .) to create separate log files. Use - to write all of it (labeled by information type) to standard output. Disabled by default.",
+	)
+	dataLoggerFormat = flag.String(
+		"logger-format",
+		"csv",
+		"The format of the files from -logger-filename: csv or jsonl (one JSON object per line).",
+	)
+	dataLoggerCompress = flag.Bool(
+		"logger-compress",
+		false,
+		"Compress the files from -logger-filename with gzip (and add .gz to their names).",
+	)
+	plotScripts = flag.Bool(
+		"plot-scripts",
+		false,
+		"Along with the files from -logger-filename, write a gnuplot script that plots the throughput and the probe latency over time.",
+	)
+	maxSamples = flag.Int(
+		"max-samples",
+		0,
+		"Keep only the most recent this many probe and throughput data points of each kind in memory, so that long tests on devices with little memory do not run out of it (the older ones remain in the files from -logger-filename). The results are calculated from the data points that were kept. 0 (the default) keeps all of them.",
+	)
+	jsonOutput = flag.Bool(
+		"json",
+		false,
+		"Print the results of the test as JSON rather than as human-readable text.",
+	)
+	drainTimeout = flag.Int(
+		"drain-timeout",
+		0,
+		"Maximum time to spend draining the load-generating connections (to close them cleanly) after measuring. Disabled by default.",
+	)
+	otelProbeSpans = flag.Bool(
+		"otel-probe-spans",
+		false,
+		"When exporting to OpenTelemetry (configured with the standard OTEL_* environment variables), include a span for every probe.",
+	)
+	pushUrl = flag.String(
+		"push-url",
+		"",
+		"Push the summary metrics to this Prometheus Pushgateway (or OpenMetrics-accepting) URL after the test. Disabled by default.",
+	)
+	webhookUrl = flag.String(
+		"webhook-url",
+		"",
+		"POST the results of the test (as JSON) to this URL after the test. Disabled by default.",
+	)
+	webhookSecret = flag.String(
+		"webhook-secret",
+		"",
+		"Sign the webhook requests (HMAC-SHA256 in the "+webhook.SignatureHeader+" header) with this secret.",
+	)
+	submitUrl = flag.String(
+		"submit-url",
+		"",
+		"Opt in to uploading the anonymized results of the test (as JSON, with the random identifier of the run) to this collection service. Disabled by default.",
+	)
+	showProgress = flag.Bool(
+		"progress",
+		false,
+		"Periodically print the estimated progress of the test (as a percentage) to stderr.",
+	)
+	shardEndpoints = flag.String(
+		"shard-endpoints",
+		"",
+		"Comma-separated list of additional servers (host[:port]) that serve the same resources as the configured one. The load-generating connections are spread across all the servers and the foreign probes take turns probing each of them.",
+	)
+	tuiMode = flag.Bool(
+		"tui",
+		false,
+		"Draw live charts of the throughput, probe latency and flow counts (and the RPM so far) on the terminal while the test runs.",
+	)
+	plainOutput = flag.Bool(
+		"plain",
+		os.Getenv("TERM") == "dumb",
+		"Keep the output plain (e.g., for screen readers and dumb terminals): no terminal control codes or redrawing, -tui becomes -live and -progress only reports every 10%. The default when TERM is dumb.",
+	)
+	uploadSource = flag.String(
+		"upload-source",
+		"zeros",
+		"What to upload: zeros, random (incompressible) data, seeded:<seed> (reproducible pseudo-random data) or (the beginning of) a file, e.g., /dev/urandom or a file with realistic data.",
+	)
+	uploadChunkSize = flag.Int(
+		"upload-chunk-size",
+		0,
+		"The most bytes that a load-generating upload hands to the HTTP/2 transport at once. 0 lets the transport decide.",
+	)
+	downloadSize = flag.Int64(
+		"download-size",
+		0,
+		"When positive, load-generating downloads request the large download resource in ranges of this many bytes (one after the other) instead of all at once.",
+	)
+	downloadSink = flag.String(
+		"download-sink",
+		sink.Discard,
+		"What to do with the downloaded data: discard it (fastest), checksum it (and compare with the server's digest, when there is one) or check that it matches the expected pattern (strictest).",
+	)
+	probeMethod = flag.String(
+		"probe-method",
+		"GET",
+		"The method of the probes' requests: GET or HEAD.",
+	)
+	selfProbe = flag.String(
+		"self-probe",
+		rpm.SelfProbeRequest,
+		"How the self probes measure the latency on the load-generating connections: request (a request for the small object, as in the specification), ping (an HTTP/2 PING, without the server's application processing) or both (the RPM is calculated with the requests).",
+	)
+	probeSize = flag.Int64(
+		"probe-size",
+		0,
+		"When positive, the probes GET this many bytes of the large download resource (with a Range request) instead of the small one.",
+	)
+	probeTiming = flag.String(
+		"probe-timing",
+		"total",
+		"Which duration of the probes to calculate the RPM with: total (until the whole response arrived, as in the specification) or first-byte (without the time it takes to receive the body).",
+	)
+	rounding = flag.String(
+		"rounding",
+		utilities.RoundNearest,
+		"How to round the rates and the RPM in the text output: nearest, even (halves to the even neighbor), down or up. The JSON output is never rounded.",
+	)
+	ratePrecision = flag.Int(
+		"rate-precision",
+		3,
+		"The number of decimal places of the rates in the text output.",
+	)
+	rpmPrecision = flag.Int(
+		"rpm-precision",
+		0,
+		"The number of decimal places of the RPM in the text output.",
+	)
+	responsivenessMedium = flag.Float64(
+		"responsiveness-medium",
+		constants.ResponsivenessMediumRPM,
+		"The RPM from which the responsiveness is classified as Medium (below, it is Low).",
+	)
+	responsivenessHigh = flag.Float64(
+		"responsiveness-high",
+		constants.ResponsivenessHighRPM,
+		"The RPM from which the responsiveness is classified as High.",
+	)
+	selfWeight = flag.Float64(
+		"self-weight",
+		constants.DefaultSelfProbeWeight,
+		"How much the self probes weigh in the RPM, between 0 and 1 (the foreign probes weigh the rest).",
+	)
+	outlierFilter = flag.String(
+		"outliers",
+		outlier.None,
+		"What to do with the round-trip times of the probes that are more than -outlier-k MADs from their median before the percentiles are calculated: none, discard or winsorize.",
+	)
+	outlierK = flag.Float64(
+		"outlier-k",
+		constants.DefaultOutlierK,
+		"How many (scaled) median absolute deviations from the median a round-trip time must be to be an outlier (see -outliers).",
+	)
+	udpProbe = flag.String(
+		"udp-probe",
+		"",
+		"Also measure the latency of UDP during the test with packets to an echo server at this host:port (see the udp-echo subcommand).",
+	)
+	icmpProbe = flag.Bool(
+		"icmp",
+		false,
+		"Also ping (with ICMP echo requests) the test server (or the -icmp-host) during the test.",
+	)
+	icmpHost = flag.String(
+		"icmp-host",
+		"",
+		"The host to ping with -icmp instead of the test server.",
+	)
+	traceRoute = flag.Bool(
+		"traceroute",
+		false,
+		"Trace the path to the test server before the test and under load to find the hops whose latency grows (needs privileges).",
+	)
+	pcapCapture = flag.String(
+		"pcap",
+		"",
+		"Capture the packets of the test's connections on an interface into a pcap file (given as interface:file; Linux only and needs privileges).",
+	)
+	idleBaseline = flag.Bool(
+		"idle-baseline",
+		false,
+		"Measure the latency of the idle network before the test and grade (from A+ to F) how much it grows under load.",
+	)
+	spikeThreshold = flag.Duration(
+		"spike-threshold",
+		0,
+		"Report the probes that took longer than this as latency spikes (0 to disable).",
+	)
+	spikeFactor = flag.Float64(
+		"spike-factor",
+		0,
+		"Report the probes that took longer than this many times the median of the previous probes of their prober as latency spikes (0 to disable).",
+	)
+	abortOnNetworkChange = flag.Bool(
+		"abort-on-network-change",
+		false,
+		"Abort the test when the network changes (e.g., when the route to the test server moves from Wi-Fi to Ethernet) rather than flag its results.",
+	)
+	explainRamp = flag.Bool(
+		"explain",
+		false,
+		"At the end of the test, explain how it reached the working conditions: when connections were added and why, and when the network saturated (or the test ran out of time).",
+	)
+	throughputOnly = flag.Bool(
+		"throughput-only",
+		false,
+		"Only measure the download and upload rates: generate the load like the test does but send no probes (and calculate no RPM).",
+	)
+	simulateProfile = flag.String(
+		"simulate",
+		"",
+		"Test against a simulated network that behaves as the profile in this JSON file scripts (see the README) instead of against a server.",
+	)
+	latencyOnly = flag.Bool(
+		"latency-only",
+		false,
+		"Do not generate any load; only probe the idle network (for -latency-duration) and report its RPM.",
+	)
+	latencyDuration = flag.Duration(
+		"latency-duration",
+		constants.DefaultLatencyOnlyDuration,
+		"How long to probe the idle network with -latency-only.",
+	)
+	compensateSerialization = flag.Bool(
+		"compensate-serialization",
+		false,
+		"Also report the probe round-trip times (and the RPM) without the time it takes to receive the body of the probes' responses at the measured download rate.",
+	)
+	resumptionFraction = flag.Float64(
+		"resumption-fraction",
+		0,
+		"The fraction (between 0 and 1) of the foreign probes that resume the TLS session of an earlier foreign probe. Their latency is reported separately (and they do not count toward the RPM).",
+	)
+	specName = flag.String(
+		"spec",
+		spec.Default,
+		"The revision of the draft whose probe cadence, aggregation and stability rules to follow: "+strings.Join(spec.Names, ", ")+".",
+	)
+	rampAlgorithm = flag.String(
+		"ramp-algorithm",
+		ramp.Default,
+		"How to decide when to add load-generating connections and when the network is saturated: "+strings.Join(ramp.Names, ", ")+".",
+	)
+	movingAverageWindow = flag.Int(
+		"moving-average-window",
+		constants.MovingAverageIntervalCount,
+		"The number of (one-second) intervals over which the throughput is averaged to judge whether it is stable (longer windows suit slow or variable links).",
+	)
+	latencyBudget = flag.Duration(
+		"latency-budget",
+		0,
+		"Stop adding load-generating connections once the loaded latency (the P90 of the recent self probes) exceeds this budget (e.g., 100ms), so that the throughput is what is achievable while staying responsive. Disabled by default.",
+	)
+	maxRateMbps = flag.String(
+		"max-rate-mbps",
+		"",
+		"Pace the load-generating connections of each direction so that together they stay at (or below) this rate in Mbps (e.g., 80% of the capacity). Give download,upload (e.g., 400,40) for different ceilings. Disabled by default.",
+	)
+	targetRateMbps = flag.String(
+		"target-rate-mbps",
+		"",
+		"Rather than saturating the network, hold the load of each direction at this rate in Mbps (adding connections until it is reached and pacing them to it) and measure the responsiveness there. Give download,upload (e.g., 400,40) for different targets. Disabled by default.",
+	)
+	rpmWindow = flag.Duration(
+		"rpm-window",
+		constants.DefaultRPMSeriesWindow,
+		"The length of the sliding windows (one every second) over which the RPM is also calculated during the test, to show whether the responsiveness degrades over time (0 to disable).",
+	)
+	cacheBusting = flag.Bool(
+		"cache-busting",
+		true,
+		"Add a random query parameter to the URLs of every probe and load-generating connection so that caches cannot answer them. Use -cache-busting=false for strict compliance with the specification.",
+	)
+	liveOutput = flag.Bool(
+		"live",
+		false,
+		"Print the state of the test (rates, flows, P90s and RPM so far) every second while it runs. With -json, every line is a JSON object (and so is the final result).",
 	)
 )
 
-func main() {
-	flag.Parse()
+// writeProfile writes the runtime profile called _name_ (see pprof.Lookup) to the file
+// at _path_.
+func writeProfile(name string, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not create the %s profile %s: %v\n", name, path, err)
+		return
+	}
+	defer f.Close()
+	if name == "heap" {
+		// Make the profile reflect what is live at the end of the test.
+		runtime.GC()
+	}
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not write the %s profile %s: %v\n", name, path, err)
+	}
+}
+
+// usesIPv6 determines whether the load-generating connections run over IPv6.
+func usesIPv6(lgcs []lgc.LoadGeneratingConnection) bool {
+	for _, connection := range lgcs {
+		if conn := connection.Stats().ConnInfo.Conn; conn != nil {
+			if address, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+				return address.IP.To4() == nil
+			}
+		}
+	}
+	return false
+}
+
+// reportDroppedSamples warns that _dropped_ data points were dropped to stay within
+// -max-samples (if any were).
+func reportDroppedSamples(dropped int) {
+	if dropped > 0 {
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The %d oldest probe and throughput data points were dropped to stay within -max-samples; the results are calculated from the most recent ones.\n",
+			dropped,
+		)
+	}
+}
+
+// parseRateCeilings parses the value of _name_ (-max-rate-mbps or -target-rate-mbps: one
+// rate for both directions or download,upload) into the rates of the download and
+// upload in bytes per second. The rates are in the Mbps of the results (see
+// utilities.ToMbps), so that a capped run reports its cap.
+func parseRateCeilings(name string, value string) (float64, float64, error) {
+	rates := strings.Split(value, ",")
+	if len(rates) > 2 {
+		return 0, 0, fmt.Errorf("%s must be a rate or download,upload rates", name)
+	}
+	ceilings := make([]float64, 0, 2)
+	for _, rate := range rates {
+		mbps, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || mbps <= 0 {
+			return 0, 0, fmt.Errorf("%s must be positive rates in Mbps (not %s)", name, rate)
+		}
+		ceilings = append(ceilings, utilities.FromMbps(mbps))
+	}
+	return ceilings[0], ceilings[len(ceilings)-1], nil
+}
+
+// flowSummaries takes stock of each of the load-generating connections in _lgcs_.
+func flowSummaries(direction string, lgcs []lgc.LoadGeneratingConnection) []summary.Flow {
+	flows := make([]summary.Flow, 0, len(lgcs))
+	for _, connection := range lgcs {
+		transferred, duration := connection.Transferred()
+		rate := float64(0)
+		if transferred > 0 && duration > 0 {
+			rate = float64(transferred) / duration.Seconds()
+		}
+		flows = append(flows, summary.Flow{
+			Direction: direction,
+			Id:        connection.ClientId(),
+			Bytes:     transferred,
+			Seconds:   duration.Seconds(),
+			RateBps:   rate,
+		})
+	}
+	return flows
+}
+
+// componentRpms calculates the RPM of the self probes and that of the foreign probes on
+// their own (0 when there are no such probes) the way that the RPM is calculated from
+// both (which is their harmonic mean, weighted by -self-weight; see rpmRoundTripTimes).
+func componentRpms(selfRoundTripTimes, foreignRoundTripTimes []float64, profile spec.Profile) (float64, float64) {
+	self, foreign := rpmRoundTripTimes(selfRoundTripTimes, foreignRoundTripTimes, profile)
+	selfRpm, foreignRpm := float64(0), float64(0)
+	if len(selfRoundTripTimes) > 0 {
+		selfRpm = 60.0 / self
+	}
+	if len(foreignRoundTripTimes) > 0 {
+		foreignRpm = 60.0 / foreign
+	}
+	return selfRpm, foreignRpm
+}
+
+// printOutlierFilter reports how many of the _total_ round-trip times of the probes
+// the outlier filter (see -outliers) discarded or winsorized.
+func printOutlierFilter(filtered int, total int) {
+	switch *outlierFilter {
+	case outlier.Discard:
+		fmt.Printf("Outliers: discarded %d of %d round-trip times (more than %g MADs from the median).\n", filtered, total+filtered, *outlierK)
+	case outlier.Winsorize:
+		fmt.Printf("Outliers: winsorized %d of %d round-trip times (more than %g MADs from the median).\n", filtered, total, *outlierK)
+	}
+}
+
+// roundRate rounds a rate for the text output as requested (the JSON output never is).
+func roundRate(rate float64) float64 {
+	return utilities.Round(rate, *ratePrecision, *rounding)
+}
+
+// roundRpm rounds an RPM for the text output as requested (the JSON output never is).
+func roundRpm(rpm float64) float64 {
+	return utilities.Round(rpm, *rpmPrecision, *rounding)
+}
+
+// probeRoundTripTime is the round-trip time of a probe (see -probe-timing) in seconds.
+func probeRoundTripTime(dp rpm.ProbeDataPoint) float64 {
+	if *probeTiming == "first-byte" {
+		return dp.FirstByteDuration.Seconds()
+	}
+	return dp.Duration.Seconds()
+}
+
+// p90 is the P90 of _roundTripTimes_ (0 when there are none, e.g., when a direction
+// failed).
+func p90(roundTripTimes []float64) float64 {
+	if len(roundTripTimes) == 0 {
+		return 0
+	}
+	return utilities.CalculatePercentile(roundTripTimes, 90)
+}
+
+// rpmRoundTripTimes returns the round-trip time of the self probes and that of the
+// foreign probes from which the RPM is calculated (0 when there are no such probes):
+// their P90s or, with the TrimmedMean of _profile_, their trimmed means. That of the
+// foreign probes is per round trip that a foreign probe stands for (see
+// spec.Profile.ForeignRoundTrips).
+func rpmRoundTripTimes(selfRoundTripTimes, foreignRoundTripTimes []float64, profile spec.Profile) (float64, float64) {
+	self, foreign := p90(selfRoundTripTimes), p90(foreignRoundTripTimes)
+	if profile.TrimmedMean {
+		self, foreign = spec.TrimmedMean(selfRoundTripTimes, 95), spec.TrimmedMean(foreignRoundTripTimes, 95)
+	}
+	if profile.ForeignRoundTrips > 1 {
+		foreign /= float64(profile.ForeignRoundTrips)
+	}
+	return self, foreign
+}
+
+// combinedRpm is the RPM of the round-trip times of the self and the foreign probes (of
+// the whole test or of a window of it; see rpmRoundTripTimes).
+func combinedRpm(selfRoundTripTimes, foreignRoundTripTimes []float64, profile spec.Profile) float64 {
+	self, foreign := rpmRoundTripTimes(selfRoundTripTimes, foreignRoundTripTimes, profile)
+	return rpm.Combine(self, foreign, *selfWeight)
+}
+
+// countCachedProbes counts the probes (in all of _probes_) whose responses came from a
+// cache and warns about them (once) and notes them among the _anomalies_. They say
+// nothing about the responsiveness of the network so they are left out of the
+// calculations (but still logged).
+func countCachedProbes(anomalies *anomaly.Set, probes ...[]rpm.ProbeDataPoint) int {
+	cachedProbes, total := 0, 0
+	for _, dataPoints := range probes {
+		for _, dp := range dataPoints {
+			total++
+			if dp.Cached {
+				cachedProbes++
+			}
+		}
+	}
+	if cachedProbes > 0 {
+		anomalies.Add(anomaly.CacheDetected)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The responses to %d of %d probes appear to have come from a cache; they are ignored.\n",
+			cachedProbes,
+			total,
+		)
+	}
+	return cachedProbes
+}
+
+// checkClock warns about (and notes among the _anomalies_) the jumps of the wall clock
+// and the suspends that _watcher_ detected. It returns the jumps and whether the system
+// was suspended.
+func checkClock(watcher *clockwatch.Watcher, anomalies *anomaly.Set) ([]clockwatch.Jump, bool) {
+	clockJumps := watcher.Jumps()
+	for _, jump := range clockJumps {
+		anomalies.Add(anomaly.ClockJump)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The clock jumped by %v during the test; the probes in flight at the time are ignored.\n",
+			jump,
+		)
+	}
+	// A suspend also stalls the load-generating connections, so nothing measured in such
+	// a run means much.
+	suspended := watcher.Suspended()
+	if suspended {
+		anomalies.Add(anomaly.Suspended)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The system was suspended during the test; its results are not reliable.\n",
+		)
+	}
+	return clockJumps, suspended
+}
+
+// usableProbes returns whether a probe counts: not when it failed or was answered from a
+// cache, nor when it was in flight when the wall clock jumped (e.g., because the system
+// was suspended; those are counted in _clockJumpProbes_).
+func usableProbes(clockJumps []clockwatch.Jump, clockJumpProbes *int) func(rpm.ProbeDataPoint) bool {
+	return func(dp rpm.ProbeDataPoint) bool {
+		if dp.Error != "" || dp.Cached {
+			return false
+		}
+		for _, jump := range clockJumps {
+			if jump.Overlaps(dp.Time, dp.Duration) {
+				*clockJumpProbes++
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// addProbeSpans adds a span (under _parent_) to _trace_ for each of the probes in
+// _dataPoints_ (see -otel-probe-spans).
+func addProbeSpans(trace *otlp.Trace, parent *otlp.Span, probeType rpm.ProbeType, dataPoints []rpm.ProbeDataPoint) {
+	for _, dp := range dataPoints {
+		trace.AddSpan(
+			"probe",
+			otlp.SpanKindClient,
+			parent,
+			dp.Time,
+			dp.Time.Add(dp.Duration),
+			map[string]interface{}{
+				"probe.type":             probeType.Value(),
+				"probe.round_trip_count": dp.RoundTripCount,
+				"probe.phase_crossing":   dp.PhaseCrossing,
+				"probe.cached":           dp.Cached,
+				"probe.error":            dp.Error,
+			},
+		)
+	}
+}
+
+// countProbeErrors counts the probes (in all of _probes_) that failed by the reason for
+// which they failed and warns about them (once) and notes them among the _anomalies_.
+// They have no round-trip time to contribute.
+func countProbeErrors(anomalies *anomaly.Set, probes ...[]rpm.ProbeDataPoint) map[string]int {
+	probeErrors := make(map[string]int)
+	for _, dataPoints := range probes {
+		for _, dp := range dataPoints {
+			if dp.Error != "" {
+				probeErrors[dp.Error]++
+			}
+		}
+	}
+	if len(probeErrors) != 0 {
+		anomalies.Add(anomaly.ProbeErrors)
+		fmt.Fprintf(os.Stderr, "Warning: Some probes failed (by reason): %v\n", probeErrors)
+	}
+	return probeErrors
+}
+
+// startPacketProbers starts the UDP and the ICMP probers that were asked for (see
+// -udp-probe and -icmp-probe), which run until _ctx_ is done. The ICMP probes go to
+// _host_ unless -icmp-host says otherwise.
+func startPacketProbers(ctx context.Context, host string) (*udpprobe.Prober, *icmpprobe.Prober) {
+	var udpProber *udpprobe.Prober = nil
+	if *udpProbe != "" {
+		if prober, err := udpprobe.NewProber(*udpProbe); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			udpProber = prober
+			go udpProber.Run(ctx, constants.UDPProbeInterval, constants.UDPProbeTimeout)
+		}
+	}
+
+	var icmpProber *icmpprobe.Prober = nil
+	if *icmpProbe {
+		if *icmpHost != "" {
+			host = *icmpHost
+		}
+		if prober, err := icmpprobe.NewProber(host); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			icmpProber = prober
+			go icmpProber.Run(ctx, constants.ICMPProbeInterval, constants.ICMPProbeTimeout)
+		}
+	}
+	return udpProber, icmpProber
+}
+
+// packetSample is what the samples of the UDP and the ICMP probers (see udpprobe.Sample
+// and icmpprobe.Sample) have in common.
+type packetSample = struct {
+	Time     time.Time
+	Duration time.Duration
+	Lost     bool
+}
+
+// packetRoundTripTimes returns the round-trip times (in seconds) of the _samples_ that
+// were sent up to _until_ and how many of those were lost.
+func packetRoundTripTimes[S ~packetSample](samples []S, until time.Time) ([]float64, int) {
+	roundTripTimes, lost := make([]float64, 0), 0
+	for _, s := range samples {
+		sample := packetSample(s)
+		if sample.Time.After(until) {
+			continue
+		}
+		if sample.Lost {
+			lost++
+		} else {
+			roundTripTimes = append(roundTripTimes, sample.Duration.Seconds())
+		}
+	}
+	return roundTripTimes, lost
+}
+
+// collectPacketProbes waits for the UDP and the ICMP probers (either may be nil) and
+// adds their results to _testSummary_. Only the probes up to _until_ count (i.e., those
+// that were sent while the HTTP probes ran, under the same conditions).
+func collectPacketProbes(
+	testSummary *summary.Summary,
+	udpProber *udpprobe.Prober,
+	icmpProber *icmpprobe.Prober,
+	until time.Time,
+) {
+	if udpProber != nil {
+		udpProber.Wait()
+		roundTripTimes, lost := packetRoundTripTimes(udpProber.Samples(), until)
+		testSummary.UDPProbes = len(roundTripTimes) + lost
+		testSummary.UDPProbesLost = lost
+		testSummary.UDPProbeP90 = p90(roundTripTimes)
+	}
+	if icmpProber != nil {
+		icmpProber.Wait()
+		roundTripTimes, lost := packetRoundTripTimes(icmpProber.Samples(), until)
+		testSummary.ICMPProbes = len(roundTripTimes) + lost
+		testSummary.ICMPProbesLost = lost
+		testSummary.ICMPProbeP90 = p90(roundTripTimes)
+	}
+}
+
+// printPacketProbes prints the results of the HTTP/2 PINGs and of the UDP and the ICMP
+// probes (of those that there were).
+func printPacketProbes(testSummary *summary.Summary) {
+	if testSummary.PingProbes > 0 {
+		fmt.Printf(
+			"HTTP/2 PINGs on the load-generating connections: P90 %.3fs (%d PINGs).\n",
+			testSummary.PingProbeP90,
+			testSummary.PingProbes,
+		)
+	}
+	if testSummary.UDPProbes > 0 {
+		fmt.Printf(
+			"UDP probes: P90 %.3fs (foreign probes: P90 %.3fs) with %d of %d probes lost.\n",
+			testSummary.UDPProbeP90,
+			testSummary.ForeignProbeP90,
+			testSummary.UDPProbesLost,
+			testSummary.UDPProbes,
+		)
+	}
+	if testSummary.ICMPProbes > 0 {
+		fmt.Printf(
+			"ICMP probes: P90 %.3fs with %d of %d probes lost.\n",
+			testSummary.ICMPProbeP90,
+			testSummary.ICMPProbesLost,
+			testSummary.ICMPProbes,
+		)
+	}
+}
+
+// testPhase is a phase of a test (a span of its trace).
+type testPhase struct {
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+}
+
+// exportResults sends the results of a test (and, with an _otelExporter_, its _trace_)
+// wherever they were asked for and, where the standard output is not collected, logs
+// them, too.
+func exportResults(
+	testSummary *summary.Summary,
+	otelExporter *otlp.Exporter,
+	trace *otlp.Trace,
+	debugLevel debug.DebugLevel,
+) {
+	if otelExporter != nil {
+		if err := otelExporter.ExportTrace(trace); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if err := otelExporter.ExportSummary(testSummary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if *pushUrl != "" {
+		if err := pushgateway.Push(*pushUrl, testSummary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			debug.Log(debugLevel, "push.done", "url", *pushUrl)
+		}
+	}
+
+	if *webhookUrl != "" {
+		if err := webhook.Notify(*webhookUrl, *webhookSecret, testSummary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			debug.Log(debugLevel, "webhook.done", "url", *webhookUrl)
+		}
+	}
+
+	if *submitUrl != "" {
+		if err := submit.Submit(*submitUrl, testSummary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			debug.Log(debugLevel, "submit.done", "url", *submitUrl, "run_id", testSummary.Metadata.RunId)
+		}
+	}
+
+	if *logTarget != debug.TargetStdout {
+		debug.Info(
+			"test.summary",
+			"download_bytes_per_second", testSummary.DownloadRateBps,
+			"upload_bytes_per_second", testSummary.UploadRateBps,
+			"download_flows", testSummary.DownloadFlows,
+			"upload_flows", testSummary.UploadFlows,
+			"rpm", testSummary.RPM,
+			"responsiveness", testSummary.Responsiveness,
+			"latency_only", testSummary.LatencyOnly,
+			"anomalies", testSummary.Anomalies,
+		)
+	}
+}
+
+// printResults prints the results of a test as JSON or, with -q and -qq, as the
+// few lines (or the one) that those ask for; the text output of each kind of test is
+// its own.
+func printResults(testSummary *summary.Summary, verbosity debug.Verbosity) {
+	if *jsonOutput {
+		marshal := func(v interface{}) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+		if *liveOutput {
+			// Keep the output valid JSON lines.
+			marshal = json.Marshal
+		}
+		if jsonSummary, err := marshal(testSummary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Could not format the results as JSON: %v\n", err)
+		} else {
+			fmt.Println(string(jsonSummary))
+		}
+	} else if verbosity == debug.Quiet {
+		if !testSummary.LatencyOnly {
+			fmt.Printf("Download: %.*f Mbps\n", *ratePrecision, roundRate(utilities.ToMbps(testSummary.DownloadRateBps)))
+			fmt.Printf("Upload: %.*f Mbps\n", *ratePrecision, roundRate(utilities.ToMbps(testSummary.UploadRateBps)))
+		}
+		if !testSummary.ThroughputOnly {
+			fmt.Printf("RPM: %.*f\n", *rpmPrecision, roundRpm(testSummary.RPM))
+		}
+	} else if verbosity == debug.OnlyRPM {
+		fmt.Printf("%.*f\n", *rpmPrecision, roundRpm(testSummary.RPM))
+	}
+}
+
+// closeDataLogger writes out the last records of the data logger of _name_ (if there is
+// one and it is still open) and closes it.
+func closeDataLogger(name string, logger interface {
+	Export() bool
+	Close() bool
+}, debugLevel debug.DebugLevel) {
+	if utilities.IsInterfaceNil(logger) {
+		return
+	}
+	logger.Export()
+	if logger.Close() {
+		debug.Log(debugLevel, "datalogger.close", "logger", name)
+	}
+}
+
+// rampSummaries turns the timeline of the ramp of a _direction_ (see rpm.RampStep) into
+// seconds since _start_.
+func rampSummaries(direction string, steps []rpm.RampStep, start time.Time) []summary.RampStep {
+	return utilities.Fmap(steps, func(step rpm.RampStep) summary.RampStep {
+		return summary.RampStep{
+			Direction:                 direction,
+			Seconds:                   step.Time.Sub(start).Seconds(),
+			Reason:                    step.Reason,
+			Connections:               step.Connections,
+			Added:                     step.Added,
+			RateBps:                   step.Throughput,
+			MovingAverageDeltaPercent: step.MovingAverageDelta,
+			Stable:                    step.Stable,
+			LatencySeconds:            step.Latency.Seconds(),
+		}
+	})
+}
+
+// latencyRow is a row of the latency table (see printLatencyTable).
+type latencyRow struct {
+	name           string
+	roundTripTimes []float64
+}
+
+// printLatencyTable prints the minimum, the median, the high percentiles and the maximum
+// of the round-trip times (in seconds) of each of the _rows_ that has any.
+func printLatencyTable(rows []latencyRow) {
+	percentiles := []int{0, 50, 90, 95, 99, 100}
+	fmt.Printf("%-16s %7s %7s %7s %7s %7s %7s %7s\n", "Latency (s)", "probes", "min", "median", "P90", "P95", "P99", "max")
+	for _, row := range rows {
+		if len(row.roundTripTimes) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%-16s %7d", row.name, len(row.roundTripTimes))
+		for _, percentile := range percentiles {
+			line += fmt.Sprintf(" %7.3f", utilities.CalculatePercentile(row.roundTripTimes, percentile))
+		}
+		fmt.Println(line)
+	}
+}
+
+// connectionSummaries takes stock of where the load-generating connections in _lgcs_
+// went and what they negotiated, counting the connections that have all of that in
+// common together (in the order in which they first appear).
+func connectionSummaries(lgcs []lgc.LoadGeneratingConnection) []summary.Connection {
+	connections := make([]summary.Connection, 0)
+	for _, connection := range lgcs {
+		// The upload connections keep no statistics of their own.
+		stats := connection.Stats()
+		if stats == nil || stats.ConnInfo.Conn == nil {
+			continue
+		}
+		conn := stats.ConnInfo.Conn
+		state := stats.TLSConnInfo
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			state = tlsConn.ConnectionState()
+		}
+		described := summary.Connection{Address: conn.RemoteAddr().String(), Count: 1}
+		if state.HandshakeComplete {
+			described.ALPN = state.NegotiatedProtocol
+			described.TLSVersion = tls.VersionName(state.Version)
+			described.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		}
+		known := false
+		for i := range connections {
+			counted := connections[i]
+			counted.Count = 1
+			if counted == described {
+				connections[i].Count += 1
+				known = true
+				break
+			}
+		}
+		if !known {
+			connections = append(connections, described)
+		}
+	}
+	return connections
+}
+
+// checkServer implements the check-server subcommand (see servercheck) and returns the
+// program's exit code.
+func checkServer(arguments []string) int {
+	flags := flag.NewFlagSet("check-server", flag.ExitOnError)
+	jsonReport := flags.Bool("json", false, "Print the report as JSON.")
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"Usage: %s check-server [-json] <configuration URL>\n\nCheck whether a server complies with the specification without running a test.\n",
+			os.Args[0],
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	report := servercheck.Check(flags.Arg(0))
+	if *jsonReport {
+		if jsonResult, err := json.MarshalIndent(report, "", "  "); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Could not format the report as JSON: %v\n", err)
+		} else {
+			fmt.Println(string(jsonResult))
+		}
+	} else {
+		fmt.Print(report)
+	}
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// serverEndpoints returns the addresses and ports of all the servers that the test
+// connects to.
+func serverEndpoints(shards []config.ConfigUrls) []netip.AddrPort {
+	endpoints := make([]netip.AddrPort, 0)
+	for _, shard := range shards {
+		for _, rawUrl := range []string{shard.SmallUrl, shard.LargeUrl, shard.UploadUrl} {
+			parsedUrl, err := url.Parse(rawUrl)
+			if err != nil {
+				continue
+			}
+			port, err := strconv.Atoi(parsedUrl.Port())
+			if err != nil {
+				port = 443
+				if parsedUrl.Scheme == "http" {
+					port = 80
+				}
+			}
+			ips, err := net.LookupIP(parsedUrl.Hostname())
+			if err != nil {
+				continue
+			}
+			for _, ip := range ips {
+				if addr, ok := netip.AddrFromSlice(ip); ok {
+					endpoints = append(endpoints, netip.AddrPortFrom(addr.Unmap(), uint16(port)))
+				}
+			}
+		}
+	}
+	return endpoints
+}
+
+// udpEcho implements the udp-echo subcommand (the server side of the UDP probes, see
+// udpprobe) and returns the program's exit code.
+func udpEcho(arguments []string) int {
+	flags := flag.NewFlagSet("udp-echo", flag.ExitOnError)
+	listen := flags.String("listen", constants.DefaultUDPEchoAddress, "The address on which to listen.")
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"Usage: %s udp-echo [-listen address]\n\nEcho the UDP probes of clients that run with -udp-probe.\n",
+			os.Args[0],
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	conn, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Could not listen on %s: %v\n", *listen, err)
+		return 1
+	}
+	defer conn.Close()
+	if err := udpprobe.Serve(conn); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// selfTest implements the selftest subcommand (see selftest) and returns the program's
+// exit code.
+func selfTest(arguments []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"Usage: %s selftest\n\nRun a short test against a built-in server on localhost to check that the client works end-to-end.\n",
+			os.Args[0],
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	fmt.Printf("Client: %s\n", version.Get())
+
+	server := selftest.NewServer()
+	defer server.Close()
+	logDirectory, err := os.MkdirTemp("", "networkQuality-selftest-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Could not create a directory for the data logs: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(logDirectory)
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Could not find the client to test: %v\n", err)
+		return 1
+	}
+
+	// The client is tested as it is run: as a program of its own.
+	test := exec.Command(
+		executable,
+		"-json",
+		"-sattimeout", strconv.Itoa(constants.SelfTestSaturationTimeout),
+		"-rpmtimeout", strconv.Itoa(constants.SelfTestRPMTimeout),
+		"-logger-filename", filepath.Join(logDirectory, "selftest.csv"),
+		server.URL+"/config",
+	)
+	testErrors := &strings.Builder{}
+	test.Stderr = testErrors
+	output, err := test.Output()
+	logs, _ := filepath.Glob(filepath.Join(logDirectory, "*"))
+	results, problems := selftest.Check(output, logs)
+	if err != nil {
+		problems = append([]string{fmt.Sprintf("The test failed: %v", err)}, problems...)
+	}
+	if len(problems) != 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s.\n", problem)
+		}
+		if testErrors.Len() != 0 {
+			fmt.Fprintf(os.Stderr, "The test reported:\n%s", testErrors.String())
+		}
+		return 1
+	}
+	fmt.Printf(
+		"Self-test passed: download %.3f Mbps, upload %.3f Mbps, RPM %.0f (%d self and %d foreign probes, %d data logs).\n",
+		utilities.ToMbps(results.DownloadRateBps),
+		utilities.ToMbps(results.UploadRateBps),
+		results.RPM,
+		results.SelfProbeRoundTrips,
+		results.ForeignProbeRoundTrips,
+		len(logs),
+	)
+	return 0
+}
+
+// The flags that shape every request to the test server (see applyRequestFlags), which
+// the subcommands that make such requests share with the test.
+var requestFlags = []string{"user-agent", "max-redirects", "sni", "host-header", "auth-token", "auth-basic"}
+
+// shareFlags registers the flags of the test with the given _names_ on the _flags_ of a
+// subcommand, where they set the same variables.
+func shareFlags(flags *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		shared := flag.CommandLine.Lookup(name)
+		flags.Var(shared.Value, shared.Name, shared.Usage)
+	}
+}
+
+// applyRequestFlags makes every request to the test server as the flags in requestFlags
+// ask.
+func applyRequestFlags() error {
+	if *userAgent != "" {
+		useragent.Set(*userAgent)
+	}
+	if *maxRedirects < 0 {
+		return fmt.Errorf("-max-redirects cannot be negative")
+	}
+	redirect.SetLimit(*maxRedirects)
+	if *serverName != "" {
+		hostoverride.SetServerName(*serverName)
+	}
+	if *hostHeader != "" {
+		hostoverride.SetHost(*hostHeader)
+	}
+	if *authToken != "" && *authBasic != "" {
+		return fmt.Errorf("-auth-token cannot be combined with -auth-basic")
+	}
+	if *authToken != "" {
+		auth.SetToken(*authToken)
+	}
+	if *authBasic != "" {
+		if err := auth.SetBasic(*authBasic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load implements the load subcommand (see loadgen) and returns the program's exit
+// code.
+func load(arguments []string) int {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	direction := flags.String("direction", "both", "The direction of the load: download, upload or both.")
+	flows := flags.Int("flows", constants.DefaultLoadFlows, "The number of load-generating connections in each direction.")
+	rate := flags.Float64(
+		"rate",
+		0,
+		"The target rate (in Mbps) in each direction, split evenly across the connections (0 for as fast as they go).",
+	)
+	duration := flags.Duration("duration", 0, "How long to generate the load (0 for until interrupted).")
+	shareFlags(flags, requestFlags...)
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"Usage: %s load [-direction download|upload|both] [-flows n] [-rate Mbps] [-duration d] [-auth-token token | -auth-basic user:password] [-sni name] [-host-header host] [-user-agent agent] [-max-redirects n] <configuration URL>\n\nGenerate load against the URLs of a configuration without measuring anything (e.g., as cross traffic for another test).\n",
+			os.Args[0],
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *direction != "download" && *direction != "upload" && *direction != "both" {
+		fmt.Fprintf(os.Stderr, "Error: The direction must be download, upload or both.\n")
+		return 2
+	}
+	if *flows < 1 || *rate < 0 || *duration < 0 {
+		fmt.Fprintf(os.Stderr, "Error: There must be at least one flow and neither the rate nor the duration can be negative.\n")
+		return 2
+	}
+	if err := applyRequestFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return 2
+	}
+
+	configuration := &config.Config{}
+	if err := configuration.GetUrl(flags.Arg(0)); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		return 1
+	}
+	if err := configuration.IsValid(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid configuration returned from %s: %v\n", configuration.Source, err)
+		return 1
+	}
+	if _, err := configuration.ResolveRedirects(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	// Once one direction fails, the other stops, too.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The rate of every connection is limited to its share of the target.
+	rateLimit := utilities.FromMbps(*rate) / float64(*flows)
+	directions := []struct {
+		name     string
+		label    string
+		generate func() lgc.LoadGeneratingConnection
+	}{
+		{"download", "Download", func() lgc.LoadGeneratingConnection {
+			return &lgc.LoadGeneratingConnectionDownload{
+				Path:      configuration.Urls.LargeUrl,
+				RangeSize: constants.LoadRangeSize,
+				RateLimit: rateLimit,
+			}
+		}},
+		{"upload", "Upload", func() lgc.LoadGeneratingConnection {
+			return &lgc.LoadGeneratingConnectionUpload{
+				Path:      configuration.Urls.UploadUrl,
+				RateLimit: rateLimit,
+			}
+		}},
+	}
+	fmt.Printf(
+		"Generating load (%s, %d connections in each direction) against %s.\n",
+		*direction,
+		*flows,
+		configuration.Source,
+	)
+	failures := make(chan error, len(directions))
+	running := 0
+	for _, loadDirection := range directions {
+		if *direction != loadDirection.name && *direction != "both" {
+			continue
+		}
+		running++
+		go func(label string, generate func() lgc.LoadGeneratingConnection) {
+			err := loadgen.Run(
+				ctx,
+				generate,
+				*flows,
+				constants.LoadReportInterval,
+				func(bytesPerSecond float64) {
+					fmt.Printf("%s: %.3f Mbps\n", label, utilities.ToMbps(bytesPerSecond))
+				},
+				debug.Error,
+			)
+			if err != nil {
+				err = fmt.Errorf("%s: %v", label, err)
+				cancel()
+			}
+			failures <- err
+		}(loadDirection.label, loadDirection.generate)
+	}
+	exitCode := 0
+	for ; running > 0; running-- {
+		if err := <-failures; err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "load" {
+		os.Exit(load(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check-server" {
+		os.Exit(checkServer(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "udp-echo" {
+		os.Exit(udpEcho(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		os.Exit(selfTest(os.Args[2:]))
+	}
+
+	flag.Parse()
+
+	// A test that fails once it is under way exits with this status, but only after the
+	// deferred cleanup (of the data loggers, the profiles and the capture) is done.
+	exitStatus := 0
+	defer func() {
+		if exitStatus != 0 {
+			os.Exit(exitStatus)
+		}
+	}()
+
+	if *showVersion {
+		fmt.Println(version.Get())
+		return
+	}
+	if err := applyRequestFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return
+	}
+
+	if _, err := sink.New(*downloadSink); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	if *selfProbe != rpm.SelfProbeRequest && *selfProbe != rpm.SelfProbePing &&
+		*selfProbe != rpm.SelfProbeBoth {
+		fmt.Fprintf(os.Stderr, "Error: Unknown self probe %s (use request, ping or both).\n", *selfProbe)
+		return
+	}
+	if *probeMethod != "GET" && *probeMethod != "HEAD" {
+		fmt.Fprintf(os.Stderr, "Error: Unknown probe method %s (use GET or HEAD).\n", *probeMethod)
+		return
+	}
+	if *probeTiming != "total" && *probeTiming != "first-byte" {
+		fmt.Fprintf(os.Stderr, "Error: Unknown probe timing %s (use total or first-byte).\n", *probeTiming)
+		return
+	}
+	if *rounding != utilities.RoundNearest && *rounding != utilities.RoundEven &&
+		*rounding != utilities.RoundDown && *rounding != utilities.RoundUp {
+		fmt.Fprintf(os.Stderr, "Error: Unknown rounding %s (use nearest, even, down or up).\n", *rounding)
+		return
+	}
+	if *ratePrecision < 0 || *rpmPrecision < 0 {
+		fmt.Fprintf(os.Stderr, "Error: The precision cannot be negative.\n")
+		return
+	}
+	if *responsivenessMedium < 0 || *responsivenessHigh < *responsivenessMedium {
+		fmt.Fprintf(
+			os.Stderr,
+			"Error: The responsiveness thresholds must not be negative and the one for High must not be below the one for Medium.\n",
+		)
+		return
+	}
+	if *resumptionFraction < 0 || *resumptionFraction > 1 {
+		fmt.Fprintf(os.Stderr, "Error: The resumption fraction must be between 0 and 1.\n")
+		return
+	}
+	if *probeSize < 0 || (*probeSize > 0 && *probeMethod != "GET") {
+		fmt.Fprintf(os.Stderr, "Error: The probe size must be positive and only works with GET probes.\n")
+		return
+	}
+
+	// Each direction gets its own instance of the ramp algorithm (they have state).
+	downloadRampAlgorithm, err := ramp.New(*rampAlgorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	uploadRampAlgorithm, _ := ramp.New(*rampAlgorithm)
+	testSpec, err := spec.Get(*specName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	if testSpec.DeviationStability {
+		downloadRampAlgorithm = ramp.WithDeviationStability(downloadRampAlgorithm)
+		uploadRampAlgorithm = ramp.WithDeviationStability(uploadRampAlgorithm)
+	}
+	if *latencyBudget > 0 {
+		downloadRampAlgorithm = ramp.WithLatencyBudget(downloadRampAlgorithm, *latencyBudget)
+		uploadRampAlgorithm = ramp.WithLatencyBudget(uploadRampAlgorithm, *latencyBudget)
+	}
+
+	// The connections of each direction share a pacer that keeps their aggregate rate
+	// at (or below) its ceiling. A target rate is also the ceiling.
+	if *maxRateMbps != "" && *targetRateMbps != "" {
+		fmt.Fprintf(os.Stderr, "Error: -max-rate-mbps and -target-rate-mbps cannot be combined.\n")
+		return
+	}
+	downloadRateCeiling, uploadRateCeiling := float64(0), float64(0)
+	if *maxRateMbps != "" {
+		if downloadRateCeiling, uploadRateCeiling, err = parseRateCeilings("-max-rate-mbps", *maxRateMbps); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return
+		}
+	}
+	if *targetRateMbps != "" {
+		if downloadRateCeiling, uploadRateCeiling, err = parseRateCeilings("-target-rate-mbps", *targetRateMbps); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return
+		}
+		downloadRampAlgorithm = ramp.WithTargetRate(downloadRampAlgorithm, downloadRateCeiling)
+		uploadRampAlgorithm = ramp.WithTargetRate(uploadRampAlgorithm, uploadRateCeiling)
+	}
+	downloadPacer, uploadPacer := lgc.NewPacer(downloadRateCeiling), lgc.NewPacer(uploadRateCeiling)
+
+	uploadPayload, err := lgc.LoadUploadPayload(*uploadSource)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	if *downloadSize < 0 {
+		fmt.Fprintf(os.Stderr, "Error: The download size cannot be negative.\n")
+		return
+	}
+	if *uploadChunkSize < 0 {
+		fmt.Fprintf(os.Stderr, "Error: The upload chunk size cannot be negative.\n")
+		return
+	}
+
+	if *dataLoggerFormat != "csv" && *dataLoggerFormat != "jsonl" {
+		fmt.Fprintf(os.Stderr, "Error: Unknown data logger format %s (use csv or jsonl).\n", *dataLoggerFormat)
+		return
+	}
+
+	// The clock by which the probers, the data collection and the timeouts keep time. A
+	// simulation keeps time by a fake clock that runs as fast as the test keeps up with
+	// it, so that it neither waits for the wall clock nor depends on how fast the
+	// client is.
+	testClock := clock.Clock(clock.Real{})
+	if *simulateProfile != "" {
+		fakeClock := clock.NewFake(time.Now())
+		drivingCtx, stopDriving := context.WithCancel(context.Background())
+		defer stopDriving()
+		go fakeClock.Drive(drivingCtx, constants.SimulatedClockStep, constants.SimulatedClockPause)
+		testClock = fakeClock
+	}
+	rpm.SetClock(testClock)
+	traceable.SetClock(testClock)
+
+	if *downloadDuration < 0 || *uploadDuration < 0 || *stableDuration < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -download-duration, -upload-duration and -stable-duration must not be negative.\n")
+		return
+	}
+	// Each direction has its own time to saturate; the test as a whole times out when
+	// the longer of them is over.
+	if *downloadDuration == 0 {
+		*downloadDuration = time.Second * time.Duration(*sattimeout)
+	}
+	if *uploadDuration == 0 {
+		*uploadDuration = time.Second * time.Duration(*sattimeout)
+	}
+	timeoutDuration := *downloadDuration
+	if *uploadDuration > timeoutDuration {
+		timeoutDuration = *uploadDuration
+	}
+	timeoutAbsoluteTime := testClock.Now().Add(timeoutDuration)
+	if *configAttempts < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -config-attempts must be at least 1.\n")
+		return
+	}
+	if *throughputOnly && *onlyRpm {
+		fmt.Fprintf(os.Stderr, "Error: There is no RPM to print (with -qq) when only the throughput is measured.\n")
+		return
+	}
+	if *spikeThreshold < 0 || *spikeFactor < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -spike-threshold and -spike-factor must not be negative.\n")
+		return
+	}
+	if *explainRamp && (*jsonOutput || *latencyOnly) {
+		fmt.Fprintf(os.Stderr, "Error: -explain only applies to the text output of a test with load (the JSON output has the ramp).\n")
+		return
+	}
+	if *latencyOnly && *throughputOnly {
+		fmt.Fprintf(os.Stderr, "Error: -latency-only and -throughput-only cannot be combined.\n")
+		return
+	}
+	if *rpmWindow < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -rpm-window must not be negative.\n")
+		return
+	}
+	if *maxSamples < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -max-samples must not be negative.\n")
+		return
+	}
+	if *maxSamples > 0 && *dataLoggerBaseFileName == "" {
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: Without -logger-filename, the data points beyond -max-samples are lost.\n",
+		)
+	}
+	rpm.SetSampleLimit(*maxSamples)
+	if *movingAverageWindow < 2 {
+		fmt.Fprintf(os.Stderr, "Error: -moving-average-window must be at least 2 (intervals).\n")
+		return
+	}
+	if *selfWeight < 0 || *selfWeight > 1 {
+		fmt.Fprintf(os.Stderr, "Error: -self-weight must be between 0 and 1.\n")
+		return
+	}
+	if err := outlier.Check(*outlierFilter); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return
+	}
+	if *outlierK <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -outlier-k must be positive.\n")
+		return
+	}
+	if *latencyOnly && *latencyDuration <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -latency-duration must be positive.\n")
+		return
+	}
+	// Everything that the run reports carries its identifier.
+	runId := runid.New()
+
+	// The URLs of the configuration to try in turn.
+	configUrls := make([]string, 0)
+	if flag.NArg() > 1 || (flag.NArg() == 1 && *configUrl != "") {
+		fmt.Fprintf(os.Stderr, "Error: Give the URL of the configuration only once.\n")
+		return
+	}
+	if flag.NArg() == 1 {
+		*configUrl = flag.Arg(0)
+	}
+	if *serverPreset != "" {
+		if *configUrl != "" || *discoverDomain != "" {
+			fmt.Fprintf(os.Stderr, "Error: -server cannot be combined with the URL of the configuration or -discover.\n")
+			return
+		}
+		if *configUrl, err = preset.Url(*serverPreset); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return
+		}
+	}
+	if *configUrl != "" && *discoverDomain != "" {
+		fmt.Fprintf(os.Stderr, "Error: The URL of the configuration cannot be combined with -discover.\n")
+		return
+	}
+	if *configUrl != "" {
+		configUrls = append(configUrls, *configUrl)
+	} else {
+		for _, host := range strings.Split(*configHost, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				configUrls = append(
+					configUrls,
+					config.Url(fmt.Sprintf("%s:%d", host, *configPort), *configPath),
+				)
+			}
+		}
+	}
+	if len(configUrls) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -config needs the name/IP of a configuration server.\n")
+		return
+	}
 
-	timeoutDuration := time.Second * time.Duration(*sattimeout)
-	timeoutAbsoluteTime := time.Now().Add(timeoutDuration)
-	configHostPort := fmt.Sprintf("%s:%d", *configHost, *configPort)
+	// A simulation stands in for the network and the server.
+	var simulation *simulate.Network = nil
+	if *simulateProfile != "" {
+		if *configUrl != "" || *discoverDomain != "" || *preflightCheck || *latencyOnly ||
+			*idleBaseline || *traceRoute || *udpProbe != "" || *icmpProbe || *pcapCapture != "" ||
+			*calculateExtendedStats || *maxRateMbps != "" || *targetRateMbps != "" {
+			fmt.Fprintf(os.Stderr, "Error: -simulate cannot be combined with a server or with options that need the network.\n")
+			return
+		}
+		profile, err := simulate.Load(*simulateProfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return
+		}
+		simulation = simulate.NewNetwork(profile, testClock)
+		// The simulated connections carry no requests.
+		*selfProbe = rpm.SelfProbePing
+	}
 
 	// This is the overall operating context of the program. All other
 	// contexts descend from this one. Canceling this one cancels all
@@ -102,6 +1769,11 @@ func main() {
 
 	//
 	lgDataCollectionCtx, cancelLGDataCollectionCtx := context.WithCancel(operatingCtx)
+	// Each direction stops collecting data on its own when its time to saturate is over.
+	downloadDataCollectionCtx, cancelDownloadDataCollectionCtx := context.WithCancel(lgDataCollectionCtx)
+	defer cancelDownloadDataCollectionCtx()
+	uploadDataCollectionCtx, cancelUploadDataCollectionCtx := context.WithCancel(lgDataCollectionCtx)
+	defer cancelUploadDataCollectionCtx()
 
 	// This context is used to control the load-generating network activity (i.e., all
 	// the connections that are open to do load generation).
@@ -110,23 +1782,84 @@ func main() {
 	// This context is used to control the activity of the foreign prober.
 	foreignProbertCtx, foreignProberCtxCancel := context.WithCancel(operatingCtx)
 	config := &config.Config{}
-	var debugLevel debug.DebugLevel = debug.Error
-
-	if *debugCliFlag {
-		debugLevel = debug.Debug
+	verbosity := debug.Normal
+	switch {
+	case (*quiet || *onlyRpm) && (*debugCliFlag || *verbose || *veryVerbose):
+		fmt.Fprintf(os.Stderr, "Error: -q and -qq cannot be combined with -v, -vv or -debug.\n")
+		return
+	case *onlyRpm:
+		verbosity = debug.OnlyRPM
+	case *quiet:
+		verbosity = debug.Quiet
+	case *veryVerbose:
+		verbosity = debug.VeryVerbose
+	case *debugCliFlag || *verbose:
+		verbosity = debug.Verbose
 	}
+	debugLevel := verbosity.Level()
+	if err := debug.SetFormat(*logFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return
+	}
+	if err := debug.SetTarget(*logTarget); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return
+	}
+	// The text output (all of it, unless quiet).
+	textOutput := !*jsonOutput && verbosity >= debug.Normal
+
+	// Everything that was detected during the test and that affects how its results
+	// should be read.
+	anomalies := anomaly.Set{}
 
 	if *calculateExtendedStats && !extendedstats.ExtendedStatsAvailable() {
 		*calculateExtendedStats = false
+		anomalies.Add(anomaly.ExtendedStatsUnavailable)
 		fmt.Printf(
 			"Warning: Calculation of extended statistics was requested but they are not supported on this platform.\n",
 		)
 	}
 
-	if err := config.Get(configHostPort, *configPath); err != nil {
+	otelExporter, err := otlp.NewExporterFromEnvironment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid OpenTelemetry configuration: %v\n", err)
+		return
+	}
+
+	// Where the time goes (reported in debug mode).
+	timings := stopwatch.Stopwatch{}
+
+	configFetchStartTime := time.Now()
+	if *discoverDomain != "" {
+		discovered, err := discover.Discover(*discoverDomain, *configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+			return
+		}
+		debug.Log(debugLevel, "config.discovered", "domain", *discoverDomain, "urls", discovered)
+		configUrls = discovered
+	}
+	if simulation != nil {
+		config.Source = "simulate:" + *simulateProfile
+		config.Urls = simulate.Urls
+	} else if err := config.GetWithRetry(
+		configUrls,
+		*configAttempts,
+		constants.ConfigRetryBackoff,
+		func(configUrl string, attempt int, err error) {
+			debug.Log(debugLevel, "config.fetch_failed", "url", configUrl, "attempt", attempt, "error", err)
+		},
+	); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		return
 	}
+	// The host (of the ones to try) that served the configuration.
+	configHostPort := ""
+	if simulation != nil {
+		configHostPort = "a network simulated by " + *simulateProfile
+	} else if source, err := url.Parse(config.Source); err == nil {
+		configHostPort = source.Host
+	}
 	if err := config.IsValid(); err != nil {
 		fmt.Fprintf(
 			os.Stderr,
@@ -136,26 +1869,135 @@ func main() {
 		)
 		return
 	}
-	if debug.IsDebug(debugLevel) {
-		fmt.Printf("Configuration: %s\n", config)
+	// Follow the redirects of the test URLs now so that the test does not measure them
+	// (a simulation has none).
+	resolved := []redirect.Hop{}
+	if simulation == nil {
+		resolved, err = config.ResolveRedirects()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v.\n", err)
+		return
+	}
+	redirects := append(config.Redirects, resolved...)
+	if err := config.IsValid(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid configuration after following the redirects: %v\n", err)
+		return
+	}
+	for _, hop := range redirects {
+		debug.Log(debugLevel, "config.redirect", "url", hop.Url, "location", hop.Location, "status", hop.Status, "duration", hop.Duration)
+	}
+	configFetchCompleteTime := timings.Since("config fetch", configFetchStartTime)
+	debug.Log(debugLevel, "config.loaded", "config", config)
+
+	// Before putting load on the resources, make sure that they can take it.
+	preflightResults := make([]preflight.Result, 0)
+	if *preflightCheck {
+		preflightResults = preflight.Check(config.Urls)
+		timings.Since("preflight", configFetchCompleteTime)
+		failed := false
+		for _, result := range preflightResults {
+			debug.Log(
+				debugLevel,
+				"preflight.result",
+				"name", result.Name,
+				"url", result.Url,
+				"status", result.Status,
+				"protocol", result.Protocol,
+				"size", result.Size,
+				"server", result.Server,
+				"error", result.Error,
+			)
+			if !result.Ok() {
+				fmt.Fprintf(os.Stderr, "Error: The preflight check of the %v.\n", result)
+				failed = true
+			} else if textOutput {
+				fmt.Printf("Preflight: %v.\n", result)
+			}
+		}
+		if failed {
+			return
+		}
+	}
+
+	// A captive portal or an intercepting proxy answers in the test server's place, so
+	// the results would measure it instead of the network.
+	middleboxFindings := []string{}
+	if simulation == nil {
+		middleboxFindings = middlebox.Check(config.Source, config.Urls.SmallUrl)
+	}
+	for _, finding := range middleboxFindings {
+		debug.Log(debugLevel, "middlebox.finding", "finding", finding)
+		anomalies.Add(anomaly.MiddleboxSuspected)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: %s: a captive portal or an intercepting proxy may be answering instead of the test server, so the results may not measure the network!\n",
+			finding,
+		)
+	}
+
+	shardedEndpoints := make([]string, 0)
+	for _, endpoint := range strings.Split(*shardEndpoints, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			shardedEndpoints = append(shardedEndpoints, endpoint)
+		}
+	}
+	shards, err := config.Shard(shardedEndpoints)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	// Sized probes get a part of the large resource.
+	probeUrl := config.Urls.SmallUrl
+	if *probeSize > 0 {
+		probeUrl = config.Urls.LargeUrl
+	}
+	shardProbeUrls := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		if *probeSize > 0 {
+			shardProbeUrls = append(shardProbeUrls, shard.LargeUrl)
+		} else {
+			shardProbeUrls = append(shardProbeUrls, shard.SmallUrl)
+		}
+	}
+	if len(shards) > 1 {
+		debug.Log(debugLevel, "config.sharded", "servers", len(shards), "shards", shards)
 	}
 
 	timeoutChannel := timeoutat.TimeoutAt(
 		operatingCtx,
 		timeoutAbsoluteTime,
+		testClock,
 		debugLevel,
 	)
-	if debug.IsDebug(debugLevel) {
-		fmt.Printf("Test will end earlier than %v\n", timeoutAbsoluteTime)
+	debug.Log(debugLevel, "test.deadline", "at", timeoutAbsoluteTime)
+
+	// Everything that the run reports (including the records of the data loggers) can
+	// be joined back to it.
+	runMetadata := metadata.Collect(runId, config.Source)
+	if err := datalogger.SetMetadata(runMetadata); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
+	debug.Log(debugLevel, "run.metadata", "metadata", runMetadata)
 
 	// print the banner
-	dt := time.Now().UTC()
-	fmt.Printf(
-		"%s UTC Go Responsiveness to %s...\n",
-		dt.Format("01-02-2006 15:04:05"),
-		configHostPort,
-	)
+	if textOutput {
+		dt := time.Now().UTC()
+		fmt.Printf(
+			"%s UTC Go Responsiveness to %s...\n",
+			dt.Format("01-02-2006 15:04:05"),
+			configHostPort,
+		)
+		fmt.Printf("Client: %s\n", runMetadata.Client)
+		fmt.Printf(
+			"Run: %s (%s, interface %s, configuration server %s at %s)\n",
+			runMetadata.RunId,
+			runMetadata.OS,
+			utilities.Conditional(runMetadata.Interface == "", "unknown", runMetadata.Interface),
+			runMetadata.ConfigServer,
+			utilities.Conditional(runMetadata.ConfigServerIP == "", "unknown", runMetadata.ConfigServerIP),
+		)
+	}
 
 	if len(*profile) != 0 {
 		f, err := os.Create(*profile)
@@ -171,6 +2013,43 @@ func main() {
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
+	if *blockProfile != "" {
+		runtime.SetBlockProfileRate(1)
+		defer writeProfile("block", *blockProfile)
+	}
+	if *mutexProfile != "" {
+		runtime.SetMutexProfileFraction(1)
+		defer writeProfile("mutex", *mutexProfile)
+	}
+	if *memProfile != "" {
+		defer writeProfile("heap", *memProfile)
+	}
+	if *goroutineProfile != "" {
+		defer writeProfile("goroutine", *goroutineProfile)
+	}
+	if *executionTrace != "" {
+		f, err := os.Create(*executionTrace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Could not create the execution trace %s: %v.\n", *executionTrace, err)
+			return
+		}
+		defer f.Close()
+		if err := trace.Start(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Could not start the execution trace: %v.\n", err)
+			return
+		}
+		defer trace.Stop()
+	}
+	if *pprofListen == "" {
+		*pprofListen = *profileHttp
+	}
+	if *pprofListen != "" {
+		go func() {
+			if err := http.ListenAndServe(*pprofListen, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Could not serve the runtime profiles: %v\n", err)
+			}
+		}()
+	}
 
 	var sslKeyFileConcurrentWriter *ccw.ConcurrentWriter = nil
 	if *sslKeyFileName != "" {
@@ -182,9 +2061,7 @@ func main() {
 				fmt.Printf("Could not seek to the end of the key file: %v!\n", err)
 				sslKeyFileConcurrentWriter = nil
 			} else {
-				if debug.IsDebug(debugLevel) {
-					fmt.Printf("Doing SSL key logging through file %v\n", *sslKeyFileName)
-				}
+				debug.Log(debugLevel, "test.key_logging", "file", *sslKeyFileName)
 				sslKeyFileConcurrentWriter = ccw.NewConcurrentFileWriter(sslKeyFileHandle)
 				defer sslKeyFileHandle.Close()
 			}
@@ -195,8 +2072,43 @@ func main() {
 	var foreignDataLogger datalogger.DataLogger[rpm.ProbeDataPoint] = nil
 	var downloadThroughputDataLogger datalogger.DataLogger[rpm.ThroughputDataPoint] = nil
 	var uploadThroughputDataLogger datalogger.DataLogger[rpm.ThroughputDataPoint] = nil
+	var rpmSeriesDataLogger datalogger.DataLogger[rpmseries.Point] = nil
 	// User wants to log data from each probe!
-	if *dataLoggerBaseFileName != "" {
+	if *dataLoggerBaseFileName == "-" {
+		// All the data loggers share standard output, so label their records.
+		if *dataLoggerCompress {
+			fmt.Fprintf(os.Stderr, "Warning: Data logger output to standard output is not compressed.\n")
+		}
+		if *plotScripts {
+			fmt.Fprintf(os.Stderr, "Warning: Plot scripts can only be written for data logger files.\n")
+		}
+		stdout := ccw.NewConcurrentFileWriter(os.Stdout)
+		selfDataLogger, _ = datalogger.CreateDataLoggerForWriter[rpm.ProbeDataPoint](
+			*dataLoggerFormat,
+			stdout,
+			"self",
+		)
+		foreignDataLogger, _ = datalogger.CreateDataLoggerForWriter[rpm.ProbeDataPoint](
+			*dataLoggerFormat,
+			stdout,
+			"foreign",
+		)
+		downloadThroughputDataLogger, _ = datalogger.CreateDataLoggerForWriter[rpm.ThroughputDataPoint](
+			*dataLoggerFormat,
+			stdout,
+			"download",
+		)
+		uploadThroughputDataLogger, _ = datalogger.CreateDataLoggerForWriter[rpm.ThroughputDataPoint](
+			*dataLoggerFormat,
+			stdout,
+			"upload",
+		)
+		rpmSeriesDataLogger, _ = datalogger.CreateDataLoggerForWriter[rpmseries.Point](
+			*dataLoggerFormat,
+			stdout,
+			"rpm",
+		)
+	} else if *dataLoggerBaseFileName != "" {
 		var err error = nil
 		unique := time.Now().UTC().Format("01-02-2006-15-04-05")
 
@@ -213,9 +2125,19 @@ func main() {
 			*dataLoggerBaseFileName,
 			"-throughput-upload"+unique,
 		)
+		dataLoggerRpmSeriesFilename := utilities.FilenameAppend(*dataLoggerBaseFileName, "-rpm-"+unique)
+		if *dataLoggerCompress {
+			dataLoggerSelfFilename += ".gz"
+			dataLoggerForeignFilename += ".gz"
+			dataLoggerDownloadThroughputFilename += ".gz"
+			dataLoggerUploadThroughputFilename += ".gz"
+			dataLoggerRpmSeriesFilename += ".gz"
+		}
 
-		selfDataLogger, err = datalogger.CreateCSVDataLogger[rpm.ProbeDataPoint](
+		selfDataLogger, err = datalogger.CreateDataLogger[rpm.ProbeDataPoint](
+			*dataLoggerFormat,
 			dataLoggerSelfFilename,
+			*dataLoggerCompress,
 		)
 		if err != nil {
 			fmt.Printf(
@@ -225,8 +2147,10 @@ func main() {
 			selfDataLogger = nil
 		}
 
-		foreignDataLogger, err = datalogger.CreateCSVDataLogger[rpm.ProbeDataPoint](
+		foreignDataLogger, err = datalogger.CreateDataLogger[rpm.ProbeDataPoint](
+			*dataLoggerFormat,
 			dataLoggerForeignFilename,
+			*dataLoggerCompress,
 		)
 		if err != nil {
 			fmt.Printf(
@@ -236,8 +2160,10 @@ func main() {
 			foreignDataLogger = nil
 		}
 
-		downloadThroughputDataLogger, err = datalogger.CreateCSVDataLogger[rpm.ThroughputDataPoint](
+		downloadThroughputDataLogger, err = datalogger.CreateDataLogger[rpm.ThroughputDataPoint](
+			*dataLoggerFormat,
 			dataLoggerDownloadThroughputFilename,
+			*dataLoggerCompress,
 		)
 		if err != nil {
 			fmt.Printf(
@@ -247,113 +2173,728 @@ func main() {
 			downloadThroughputDataLogger = nil
 		}
 
-		uploadThroughputDataLogger, err = datalogger.CreateCSVDataLogger[rpm.ThroughputDataPoint](
+		uploadThroughputDataLogger, err = datalogger.CreateDataLogger[rpm.ThroughputDataPoint](
+			*dataLoggerFormat,
 			dataLoggerUploadThroughputFilename,
+			*dataLoggerCompress,
 		)
 		if err != nil {
 			fmt.Printf(
 				"Warning: Could not create the file for storing upload throughput results (%s). Disabling functionality.\n",
 				dataLoggerUploadThroughputFilename,
 			)
-			uploadThroughputDataLogger = nil
+			uploadThroughputDataLogger = nil
+		}
+
+		rpmSeriesDataLogger, err = datalogger.CreateDataLogger[rpmseries.Point](
+			*dataLoggerFormat,
+			dataLoggerRpmSeriesFilename,
+			*dataLoggerCompress,
+		)
+		if err != nil {
+			fmt.Printf(
+				"Warning: Could not create the file for storing the RPM over time (%s). Disabling functionality.\n",
+				dataLoggerRpmSeriesFilename,
+			)
+			rpmSeriesDataLogger = nil
+		}
+
+		if *plotScripts && *dataLoggerFormat != "csv" {
+			fmt.Fprintf(os.Stderr, "Warning: Plot scripts can only be written for CSV data logger files.\n")
+		} else if *plotScripts {
+			plotScriptFilename := utilities.FilenameAppend(*dataLoggerBaseFileName, "-plot-"+unique)
+			plotScriptFilename = strings.TrimSuffix(
+				plotScriptFilename,
+				filepath.Ext(plotScriptFilename),
+			) + ".gp"
+			if err := plotscript.Write(plotScriptFilename, plotscript.Files{
+				Self:               dataLoggerSelfFilename,
+				Foreign:            dataLoggerForeignFilename,
+				DownloadThroughput: dataLoggerDownloadThroughputFilename,
+				UploadThroughput:   dataLoggerUploadThroughputFilename,
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+	// However the test ends, the data loggers write out what they logged.
+	closeDataLoggers := func() {
+		closeDataLogger("self", selfDataLogger, debugLevel)
+		closeDataLogger("foreign", foreignDataLogger, debugLevel)
+		closeDataLogger("download throughput", downloadThroughputDataLogger, debugLevel)
+		closeDataLogger("upload throughput", uploadThroughputDataLogger, debugLevel)
+		closeDataLogger("rpm series", rpmSeriesDataLogger, debugLevel)
+	}
+	defer closeDataLoggers()
+
+	/*
+	 * Create (and then, ironically, name) two anonymous functions that, when invoked,
+	 * will create load-generating connections for upload/download
+	 */
+	loadUrl := func(url string) string {
+		if *cacheBusting {
+			return utilities.AddCacheBuster(url)
+		}
+		return url
+	}
+	// The connections take turns using the shards. The first connection (which carries
+	// the self probes) always goes to the configured server.
+	generatedLgds := 0
+	generate_lgd := func() lgc.LoadGeneratingConnection {
+		if simulation != nil {
+			return simulation.NewConnection(false)
+		}
+		shard := shards[generatedLgds%len(shards)]
+		generatedLgds++
+		lgd := &lgc.LoadGeneratingConnectionDownload{
+			Path:        loadUrl(shard.LargeUrl),
+			KeyLogger:   sslKeyFileConcurrentWriter,
+			RangeSize:   *downloadSize,
+			SharedPacer: downloadPacer,
+		}
+		if *downloadSink != sink.Discard {
+			lgd.Sink, _ = sink.New(*downloadSink)
+		}
+		return lgd
+	}
+	generatedLgus := 0
+	generate_lgu := func() lgc.LoadGeneratingConnection {
+		if simulation != nil {
+			return simulation.NewConnection(true)
+		}
+		shard := shards[generatedLgus%len(shards)]
+		generatedLgus++
+		return &lgc.LoadGeneratingConnectionUpload{
+			Path:        loadUrl(shard.UploadUrl),
+			KeyLogger:   sslKeyFileConcurrentWriter,
+			Payload:     uploadPayload,
+			ChunkSize:   *uploadChunkSize,
+			SharedPacer: uploadPacer,
+		}
+	}
+
+	generateSelfProbeConfiguration := func() rpm.ProbeConfiguration {
+		return rpm.ProbeConfiguration{
+			URL:          probeUrl,
+			DataLogger:   selfDataLogger,
+			Interval:     testSpec.ProbeInterval,
+			CacheBusting: *cacheBusting,
+			Method:       *probeMethod,
+			Size:         *probeSize,
+			Mechanism:    *selfProbe,
+		}
+	}
+
+	generateForeignProbeConfiguration := func() rpm.ProbeConfiguration {
+		return rpm.ProbeConfiguration{
+			URL:          probeUrl,
+			DataLogger:   foreignDataLogger,
+			Interval:     testSpec.ProbeInterval,
+			CacheBusting: *cacheBusting,
+			ShardURLs:    shardProbeUrls,
+			Method:       *probeMethod,
+			Size:         *probeSize,
+
+			ResumptionFraction: *resumptionFraction,
+		}
+	}
+
+	var downloadDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "download")
+	var uploadDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "upload")
+	var foreignDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "foreign probe")
+
+	testServerHost := ""
+	if largeUrl, err := url.Parse(config.Urls.LargeUrl); err == nil {
+		testServerHost = largeUrl.Hostname()
+	}
+
+	var tracer *traceroute.Tracer = nil
+	idleHops, loadedHops := []traceroute.Hop{}, []traceroute.Hop{}
+	if *traceRoute {
+		if t, err := traceroute.NewTracer(testServerHost); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			defer t.Close()
+			idleHops = t.Trace(
+				operatingCtx,
+				constants.TracerouteMaxHops,
+				constants.TracerouteRounds,
+				constants.TracerouteTimeout,
+			)
+			if len(idleHops) == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: No hop on the path to %s answered the traceroute.\n", testServerHost)
+			} else {
+				tracer = t
+			}
+		}
+	}
+	// The path is traced again (no further than before) once the first direction
+	// saturated, i.e., under peak load.
+	var loadedHopsChannel chan []traceroute.Hop = nil
+	startLoadedTrace := func() {
+		if tracer == nil || loadedHopsChannel != nil {
+			return
+		}
+		loadedHopsChannel = make(chan []traceroute.Hop, 1)
+		go func() {
+			loadedHopsChannel <- tracer.Trace(
+				lgNetworkActivityCtx,
+				len(idleHops),
+				constants.TracerouteRounds,
+				constants.TracerouteTimeout,
+			)
+		}()
+	}
+
+	// The baseline uses the same probes as the foreign prober (but does not log them).
+	var idleProbeDataPoints []rpm.ProbeDataPoint = nil
+	if *idleBaseline {
+		idleCtx, cancelIdleCtx := context.WithTimeout(operatingCtx, constants.IdleBaselineDuration)
+		idleProbeDataPoints = utilities.ChannelToSlice(rpm.ForeignProber(
+			idleCtx,
+			func() rpm.ProbeConfiguration {
+				configuration := generateForeignProbeConfiguration()
+				configuration.DataLogger = nil
+				configuration.ResumptionFraction = 0
+				return configuration
+			},
+			sslKeyFileConcurrentWriter,
+			nil,
+			debug.NewDebugWithPrefix(debugLevel, "idle probe"),
+		))
+		cancelIdleCtx()
+	}
+
+	// Every test (with load or without) ends alike: the server is checked, the summary
+	// gets what every test reports, and the results are exported and printed. The
+	// _testSummary_ has what is particular to the test, whose _phases_ are the spans of
+	// its trace.
+	finishTest := func(
+		testSummary *summary.Summary,
+		phases []testPhase,
+		selfProbeDataPoints []rpm.ProbeDataPoint,
+		foreignProbeDataPoints []rpm.ProbeDataPoint,
+	) {
+		testCompleteTime := phases[len(phases)-1].end
+		resourceUsage := resourceusage.Collect()
+		debug.Log(debugLevel, "test.resource_usage", "usage", resourceUsage)
+
+		// With -preflight, see (now that the test is over and cannot be disturbed)
+		// whether the server lets caches store the resources that we measure with.
+		cacheChecksStartTime := time.Now()
+		cacheChecks := make([]cachecheck.Report, 0)
+		checkedResources := []struct {
+			method string
+			url    string
+		}{
+			{"GET", config.Urls.SmallUrl},
+			{"HEAD", config.Urls.LargeUrl},
+		}
+		// A simulation has no server to check.
+		if !*preflightCheck || simulation != nil {
+			checkedResources = nil
+		}
+		for _, resource := range checkedResources {
+			report, err := cachecheck.Check(resource.method, resource.url)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			if report.Cacheable {
+				anomalies.Add(anomaly.CacheDetected)
+				fmt.Fprintf(
+					os.Stderr,
+					"Warning: The server permits caching of a measurement resource: %v\n",
+					report,
+				)
+			} else {
+				debug.Log(debugLevel, "cachecheck.not_cacheable", "report", report)
+			}
+			cacheChecks = append(cacheChecks, report)
+		}
+		cacheChecksCompleteTime := timings.Since("cache checks", cacheChecksStartTime)
+
+		// When the server can describe itself, ask it (the answer is about how it was
+		// at the end of the test).
+		var serverMetadata *servermeta.Metadata = nil
+		if config.Urls.MetadataUrl != "" {
+			metadata, err := servermeta.Fetch(config.Urls.MetadataUrl)
+			timings.Since("server metadata", cacheChecksCompleteTime)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			} else {
+				serverMetadata = metadata
+			}
+		}
+
+		testSummary.Metadata = runMetadata
+		testSummary.Time = time.Now()
+		testSummary.ConfigSource = config.Source
+		testSummary.CacheBusting = *cacheBusting
+		testSummary.ProbeTiming = *probeTiming
+		testSummary.Spec = testSpec.Name
+		testSummary.Preflight = preflightResults
+		testSummary.Redirects = utilities.Fmap(redirects, func(hop redirect.Hop) summary.Redirect {
+			return summary.Redirect{
+				Url:      hop.Url,
+				Location: hop.Location,
+				Status:   hop.Status,
+				Seconds:  hop.Duration.Seconds(),
+			}
+		})
+		testSummary.Responsiveness = rpm.ClassifyResponsiveness(
+			testSummary.RPM,
+			*responsivenessMedium,
+			*responsivenessHigh,
+		)
+		testSummary.ResponsivenessMediumRPM = *responsivenessMedium
+		testSummary.ResponsivenessHighRPM = *responsivenessHigh
+		testSummary.SelfWeight = *selfWeight
+		testSummary.OutlierFilter = *outlierFilter
+		testSummary.MiddleboxFindings = middleboxFindings
+		testSummary.ServerMetadata = serverMetadata
+		testSummary.CacheChecks = cacheChecks
+		testSummary.ResourceUsage = resourceUsage
+		testSummary.Anomalies = anomalies.Codes()
+		// Whatever a test did not have is empty (rather than missing) in the JSON.
+		if testSummary.ProbeErrors == nil {
+			testSummary.ProbeErrors = make(map[string]int)
+		}
+		if testSummary.Connections == nil {
+			testSummary.Connections = make([]summary.Connection, 0)
+		}
+		if testSummary.Flows == nil {
+			testSummary.Flows = make([]summary.Flow, 0)
+		}
+		if testSummary.Ramp == nil {
+			testSummary.Ramp = make([]summary.RampStep, 0)
+		}
+		if testSummary.LatencySpikes == nil {
+			testSummary.LatencySpikes = make([]summary.Spike, 0)
+		}
+
+		if textOutput {
+			printPacketProbes(testSummary)
+			if serverMetadata != nil {
+				fmt.Printf("Server: %v\n", serverMetadata)
+			}
+			for _, hop := range redirects {
+				fmt.Printf(
+					"Redirect (not measured): %s -> %s (%d in %.3fs).\n",
+					hop.Url,
+					hop.Location,
+					hop.Status,
+					hop.Duration.Seconds(),
+				)
+			}
+		}
+
+		exportStartTime := time.Now()
+
+		var otelTrace *otlp.Trace = nil
+		if otelExporter != nil {
+			otelTrace = otlp.NewTrace()
+			testSpan := otelTrace.AddSpan(
+				"test",
+				otlp.SpanKindInternal,
+				nil,
+				phases[0].start,
+				testCompleteTime,
+				map[string]interface{}{
+					"config.source":  config.Source,
+					"rpm":            testSummary.RPM,
+					"responsiveness": testSummary.Responsiveness,
+				},
+			)
+			for _, phase := range phases {
+				otelTrace.AddSpan(phase.name, otlp.SpanKindInternal, testSpan, phase.start, phase.end, phase.attributes)
+			}
+			if *otelProbeSpans {
+				addProbeSpans(otelTrace, testSpan, rpm.Self, selfProbeDataPoints)
+				addProbeSpans(otelTrace, testSpan, rpm.Foreign, foreignProbeDataPoints)
+			}
+		}
+		exportResults(testSummary, otelExporter, otelTrace, debugLevel)
+
+		printResults(testSummary, verbosity)
+		if textOutput {
+			// Last of all, exactly like the summary of Apple's networkQuality (which
+			// counts a megabit as a million bits rather than as 2^20 bits like the rates
+			// above; see the README).
+			fmt.Printf("==== SUMMARY ====\n")
+			if !testSummary.LatencyOnly {
+				fmt.Printf("Uplink capacity: %.3f Mbps\n", testSummary.UploadRateBps*8/1e6)
+				fmt.Printf("Downlink capacity: %.3f Mbps\n", testSummary.DownloadRateBps*8/1e6)
+			}
+			if !testSummary.ThroughputOnly {
+				fmt.Printf("Responsiveness: %s (%.0f RPM)\n", testSummary.Responsiveness, testSummary.RPM)
+			}
+		}
+		closeDataLoggers()
+
+		timings.Since("export", exportStartTime)
+		for _, lap := range timings.Laps() {
+			debug.Log(debugLevel, "timing.lap", "name", lap.Name, "duration", lap.Duration)
+		}
+
+		cancelOperatingCtx()
+		if debug.IsDebug(debugLevel) {
+			debug.Log(debugLevel, "test.cooldown_start")
+			time.Sleep(constants.CooldownPeriod)
+			debug.Log(debugLevel, "test.cooldown_done")
+		}
+	}
+
+	// Without any load, the self probes get a connection of their own and the RPM is
+	// that of the idle network.
+	if *latencyOnly {
+		latencyStartTime := time.Now()
+		latencyCtx, cancelLatencyCtx := context.WithTimeout(operatingCtx, *latencyDuration)
+		clockWatcher := clockwatch.NewWatcher(latencyStartTime)
+		go clockWatcher.Run(latencyCtx, constants.ClockCheckInterval)
+		udpProber, icmpProber := startPacketProbers(latencyCtx, testServerHost)
+		selfProbesChannel := make(chan []rpm.ProbeDataPoint)
+		droppedSamplesChannel := make(chan int)
+		go func() {
+			selfProbes, dropped := utilities.ChannelToBoundedSlice(rpm.IdleSelfProber(
+				latencyCtx,
+				generateSelfProbeConfiguration(),
+				sslKeyFileConcurrentWriter,
+				nil,
+				debug.NewDebugWithPrefix(debugLevel, "idle"),
+			), *maxSamples)
+			droppedSamplesChannel <- dropped
+			selfProbesChannel <- selfProbes
+		}()
+		foreignProbeDataPoints, droppedSamples := utilities.ChannelToBoundedSlice(rpm.ForeignProber(
+			latencyCtx,
+			generateForeignProbeConfiguration,
+			sslKeyFileConcurrentWriter,
+			nil,
+			foreignDebugging,
+		), *maxSamples)
+		droppedSamples += <-droppedSamplesChannel
+		selfProbeDataPoints := <-selfProbesChannel
+		reportDroppedSamples(droppedSamples)
+		cancelLatencyCtx()
+		latencyCompleteTime := time.Now()
+		timings.Record("latency", latencyStartTime, latencyCompleteTime)
+
+		probeErrors := countProbeErrors(&anomalies, selfProbeDataPoints, foreignProbeDataPoints)
+		cachedProbes := countCachedProbes(&anomalies, selfProbeDataPoints, foreignProbeDataPoints)
+		clockJumps, suspended := checkClock(clockWatcher, &anomalies)
+		clockJumpProbes := 0
+		isUsable := usableProbes(clockJumps, &clockJumpProbes)
+		selfProbeRoundTripTimes, filteredSelfProbes := outlier.Filter(
+			utilities.Fmap(utilities.Filter(selfProbeDataPoints, isUsable), probeRoundTripTime),
+			*outlierFilter,
+			*outlierK,
+		)
+		// The foreign round trips count the usable foreign probes, like those of the full
+		// test do.
+		usableForeignProbeDataPoints := utilities.Filter(foreignProbeDataPoints, isUsable)
+		totalForeignRoundTrips := len(usableForeignProbeDataPoints)
+		foreignProbeRoundTripTimes, filteredForeignProbes := outlier.Filter(
+			utilities.Fmap(usableForeignProbeDataPoints, probeRoundTripTime),
+			*outlierFilter,
+			*outlierK,
+		)
+		filteredProbes := filteredSelfProbes + filteredForeignProbes
+		if len(selfProbeRoundTripTimes) == 0 || len(foreignProbeRoundTripTimes) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: There are not enough probes to calculate the RPM.\n")
+			exitStatus = 1
+			closeDataLoggers()
+			cancelOperatingCtx()
+			return
+		}
+		selfProbeRoundTripTimeP90 := p90(selfProbeRoundTripTimes)
+		foreignProbeRoundTripTimeP90 := p90(foreignProbeRoundTripTimes)
+		calculatedRpm := combinedRpm(selfProbeRoundTripTimes, foreignProbeRoundTripTimes, testSpec)
+		selfRpm, foreignRpm := componentRpms(selfProbeRoundTripTimes, foreignProbeRoundTripTimes, testSpec)
+
+		debug.Log(
+			debugLevel,
+			"test.probe_statistics",
+			"self_round_trips", len(selfProbeRoundTripTimes),
+			"foreign_round_trips", totalForeignRoundTrips,
+			"self_p90", selfProbeRoundTripTimeP90,
+			"foreign_p90", foreignProbeRoundTripTimeP90,
+			"cached_probes", cachedProbes,
+			"clock_jump_probes", clockJumpProbes,
+		)
+		timings.Since("statistics", latencyCompleteTime)
+
+		testSummary := &summary.Summary{
+			SelfProbeRoundTrips:    len(selfProbeRoundTripTimes),
+			ForeignProbeRoundTrips: totalForeignRoundTrips,
+			SelfProbeP90:           selfProbeRoundTripTimeP90,
+			ForeignProbeP90:        foreignProbeRoundTripTimeP90,
+			RPM:                    calculatedRpm,
+			CachedProbes:           cachedProbes,
+			ProbeErrors:            probeErrors,
+			ClockJumps:             len(clockJumps),
+			ClockJumpProbes:        clockJumpProbes,
+			Suspended:              suspended,
+			SelfProbeMechanism:     rpm.SelfProbeRequest,
+			LatencyOnly:            true,
+
+			SelfRPM:    selfRpm,
+			ForeignRPM: foreignRpm,
+
+			FilteredProbes: filteredProbes,
+
+			DroppedSamples: droppedSamples,
+		}
+		collectPacketProbes(testSummary, udpProber, icmpProber, latencyCompleteTime)
+
+		if textOutput {
+			fmt.Printf(
+				"Idle RPM: %.*f (%s; self probes: P90 %.3fs, foreign probes: P90 %.3fs)\n",
+				*rpmPrecision,
+				roundRpm(calculatedRpm),
+				rpm.ClassifyResponsiveness(calculatedRpm, *responsivenessMedium, *responsivenessHigh),
+				selfProbeRoundTripTimeP90,
+				foreignProbeRoundTripTimeP90,
+			)
+			printOutlierFilter(filteredProbes, len(selfProbeRoundTripTimes)+len(foreignProbeRoundTripTimes))
+			fmt.Printf(
+				"Foreign RPM: %.*f, self RPM: %.*f\n",
+				*rpmPrecision,
+				roundRpm(foreignRpm),
+				*rpmPrecision,
+				roundRpm(selfRpm),
+			)
+			printLatencyTable([]latencyRow{
+				{"Self probes", selfProbeRoundTripTimes},
+				{"Foreign probes", foreignProbeRoundTripTimes},
+			})
 		}
+
+		finishTest(
+			testSummary,
+			[]testPhase{{"latency", latencyStartTime, latencyCompleteTime, nil}},
+			selfProbeDataPoints,
+			foreignProbeDataPoints,
+		)
+		return
 	}
 
-	/*
-	 * Create (and then, ironically, name) two anonymous functions that, when invoked,
-	 * will create load-generating connections for upload/download
-	 */
-	generate_lgd := func() lgc.LoadGeneratingConnection {
-		return &lgc.LoadGeneratingConnectionDownload{
-			Path:      config.Urls.LargeUrl,
-			KeyLogger: sslKeyFileConcurrentWriter,
+	testStartTime := testClock.Now()
+
+	// The budget for each phase is the longest that it can take (the drain phase is
+	// skipped entirely when it is disabled).
+	progressEstimator := progress.NewEstimator(
+		testStartTime,
+		progress.Phase{Name: "saturation", Budget: timeoutDuration},
+		progress.Phase{Name: "collection", Budget: *stableDuration + time.Second*time.Duration(*rpmtimeout)},
+		progress.Phase{Name: "drain", Budget: time.Second * time.Duration(*drainTimeout)},
+	)
+	testRunningCtx, cancelTestRunningCtx := context.WithCancel(operatingCtx)
+
+	var capture *pcap.Capture = nil
+	if *pcapCapture != "" {
+		if iface, path, ok := strings.Cut(*pcapCapture, ":"); !ok {
+			fmt.Fprintf(os.Stderr, "Warning: The capture must be given as interface:file.\n")
+		} else if capture, err = pcap.Start(testRunningCtx, iface, path, serverEndpoints(shards)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			capture = nil
 		}
 	}
-	generate_lgu := func() lgc.LoadGeneratingConnection {
-		return &lgc.LoadGeneratingConnectionUpload{
-			Path:      config.Urls.UploadUrl,
-			KeyLogger: sslKeyFileConcurrentWriter,
+	// However the test ends, the capture is complete.
+	defer func() {
+		cancelTestRunningCtx()
+		if capture != nil {
+			capture.Wait()
 		}
+	}()
+	if *showProgress && *plainOutput {
+		go progress.ReportSteps(
+			testRunningCtx,
+			progressEstimator,
+			constants.ProgressInterval,
+			constants.PlainProgressStep,
+			os.Stderr,
+		)
+	} else if *showProgress {
+		go progress.Report(testRunningCtx, progressEstimator, constants.ProgressInterval, os.Stderr)
 	}
 
-	generateSelfProbeConfiguration := func() rpm.ProbeConfiguration {
-		return rpm.ProbeConfiguration{
-			URL:        config.Urls.SmallUrl,
-			DataLogger: selfDataLogger,
-			Interval:   100 * time.Millisecond,
-		}
+	// Everything that follows the test as it runs is an event handler.
+	eventHandlers := events.Multi{}
+	// The TUI redraws the screen, which plain output must not do.
+	if *tuiMode && *plainOutput {
+		*tuiMode = false
+		*liveOutput = true
 	}
+	if *liveOutput {
+		liveReporter := live.NewReporter(testStartTime)
+		liveReporter.SelfWeight = *selfWeight
+		eventHandlers = append(eventHandlers, liveReporter)
+		go liveReporter.Report(testRunningCtx, constants.LiveInterval, *jsonOutput, os.Stdout)
+	}
+	if *tuiMode {
+		screen := tui.NewScreen(testStartTime)
+		screen.SelfWeight = *selfWeight
+		eventHandlers = append(eventHandlers, screen)
+		go screen.Run(testRunningCtx, constants.LiveInterval, os.Stdout)
+	}
+	enterPhase := func(phase string, now time.Time) {
+		progressEstimator.EnterPhase(phase, now)
+		eventHandlers.OnPhaseChange(phase, progressEstimator.Percent(now))
+	}
+	enterPhase("saturation", testStartTime)
 
-	generateForeignProbeConfiguration := func() rpm.ProbeConfiguration {
-		return rpm.ProbeConfiguration{
-			URL:        config.Urls.SmallUrl,
-			DataLogger: foreignDataLogger,
-			Interval:   100 * time.Millisecond,
+	// The clock watcher watches the wall clock (whatever the clock of the test).
+	clockWatcher := clockwatch.NewWatcher(time.Now())
+	go clockWatcher.Run(testRunningCtx, constants.ClockCheckInterval)
+
+	cpuSampler := cpuload.NewSampler(time.Now())
+	go cpuSampler.Run(testRunningCtx, constants.CPUSampleInterval)
+
+	// With -abort-on-network-change, a change of the network stops everything; the test
+	// then ends (see aborted) the next time that it looks.
+	networkChangeAborted := atomic.Bool{}
+	networkWatcher := netwatch.NewWatcher(testServerHost)
+	go networkWatcher.Run(testRunningCtx, constants.NetworkCheckInterval, func(change netwatch.Change) {
+		debug.Log(debugLevel, "test.network_change", "change", change)
+		if *abortOnNetworkChange && networkChangeAborted.CompareAndSwap(false, true) {
+			// Whatever the test measured so far mixes two networks.
+			fmt.Fprintf(os.Stderr, "Error: The network changed during the test (%v); aborting.\n", change)
+			cancelOperatingCtx()
+		}
+	})
+	aborted := func() bool {
+		if networkChangeAborted.Load() {
+			exitStatus = 1
+			return true
 		}
+		return false
 	}
 
-	var downloadDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "download")
-	var uploadDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "upload")
-	var foreignDebugging *debug.DebugWithPrefix = debug.NewDebugWithPrefix(debugLevel, "foreign probe")
+	udpProber, icmpProber := startPacketProbers(testRunningCtx, testServerHost)
 
 	// TODO: Separate contexts for load generation and data collection. If we do that, if either of the two
 	// data collection go routines stops well before the other, they will continue to send probes and we can
 	// generate additional information!
 
+	// When only the throughput is measured, there are no probes at all.
+	lgSelfProbeConfigurationGenerator := generateSelfProbeConfiguration
+	if *throughputOnly {
+		lgSelfProbeConfigurationGenerator = nil
+	}
 	downloadSaturationComplete, downloadDataCollectionChannel := rpm.LGCollectData(
-		lgDataCollectionCtx,
+		downloadDataCollectionCtx,
 		lgNetworkActivityCtx,
 		operatingCtx,
 		generate_lgd,
-		generateSelfProbeConfiguration,
+		lgSelfProbeConfigurationGenerator,
 		downloadThroughputDataLogger,
+		downloadRampAlgorithm,
+		*movingAverageWindow,
+		events.NewEmitter(eventHandlers, events.Download),
 		downloadDebugging,
 	)
 	uploadSaturationComplete, uploadDataCollectionChannel := rpm.LGCollectData(
-		lgDataCollectionCtx,
+		uploadDataCollectionCtx,
 		lgNetworkActivityCtx,
 		operatingCtx,
 		generate_lgu,
-		generateSelfProbeConfiguration,
+		lgSelfProbeConfigurationGenerator,
 		uploadThroughputDataLogger,
+		uploadRampAlgorithm,
+		*movingAverageWindow,
+		events.NewEmitter(eventHandlers, events.Upload),
 		uploadDebugging,
 	)
 
-	foreignProbeDataPointsChannel := rpm.ForeignProber(
-		foreignProbertCtx,
-		generateForeignProbeConfiguration,
-		sslKeyFileConcurrentWriter,
-		foreignDebugging,
-	)
+	foreignProbeDataPointsChannel := make(chan rpm.ProbeDataPoint)
+	if *throughputOnly {
+		close(foreignProbeDataPointsChannel)
+	} else if simulation != nil {
+		foreignProbeDataPointsChannel = simulation.ForeignProber(
+			foreignProbertCtx,
+			generateForeignProbeConfiguration(),
+			events.NewEmitter(eventHandlers, events.Foreign),
+		)
+	} else {
+		foreignProbeDataPointsChannel = rpm.ForeignProber(
+			foreignProbertCtx,
+			generateForeignProbeConfiguration,
+			sslKeyFileConcurrentWriter,
+			events.NewEmitter(eventHandlers, events.Foreign),
+			foreignDebugging,
+		)
+	}
+	// Collect the foreign probes as they arrive (rather than once the test is done) so
+	// that no more of them than -max-samples are held in memory.
+	foreignProbeDataPoints, droppedForeignProbeDataPoints := []rpm.ProbeDataPoint(nil), 0
+	foreignProbesCollected := make(chan struct{})
+	go func() {
+		foreignProbeDataPoints, droppedForeignProbeDataPoints = utilities.ChannelToBoundedSlice(
+			foreignProbeDataPointsChannel,
+			*maxSamples,
+		)
+		close(foreignProbesCollected)
+	}()
 
 	dataCollectionTimeout := false
 	uploadDataGenerationComplete := false
 	downloadDataGenerationComplete := false
 	downloadDataCollectionResult := rpm.SelfDataCollectionResult{}
 	uploadDataCollectionResult := rpm.SelfDataCollectionResult{}
+	// How long each direction took to saturate (0 when it never did).
+	downloadSaturationDuration, uploadSaturationDuration := time.Duration(0), time.Duration(0)
+	// Only when the directions have different times to saturate does either time out
+	// before the test as a whole.
+	var downloadTimeoutChannel, uploadTimeoutChannel chan interface{} = nil, nil
+	if *downloadDuration != *uploadDuration {
+		downloadTimeoutChannel = timeoutat.TimeoutAt(operatingCtx, testClock.Now().Add(*downloadDuration), testClock, debugLevel)
+		uploadTimeoutChannel = timeoutat.TimeoutAt(operatingCtx, testClock.Now().Add(*uploadDuration), testClock, debugLevel)
+	}
 
 	for !(uploadDataGenerationComplete && downloadDataGenerationComplete) {
 		select {
+		case <-downloadTimeoutChannel:
+			// The direction reports its (provisional) data as it stops. One that is
+			// already saturated keeps probing until the other is done, too.
+			downloadTimeoutChannel = nil
+			if !downloadDataGenerationComplete {
+				debug.Log(debugLevel, "test.data_generation_timeout", "direction", "download")
+				cancelDownloadDataCollectionCtx()
+			}
+		case <-uploadTimeoutChannel:
+			uploadTimeoutChannel = nil
+			if !uploadDataGenerationComplete {
+				debug.Log(debugLevel, "test.data_generation_timeout", "direction", "upload")
+				cancelUploadDataCollectionCtx()
+			}
 		case fullyComplete := <-downloadSaturationComplete:
 			{
 				downloadDataGenerationComplete = true
-				if *debugCliFlag {
-					fmt.Printf(
-						"################# download load-generating data generation is %s complete!\n",
-						utilities.Conditional(fullyComplete, "", "(provisionally)"))
+				startLoadedTrace()
+				if !fullyComplete {
+					anomalies.Add(anomaly.Unsaturated)
+				} else {
+					downloadSaturationDuration = testClock.Now().Sub(testStartTime)
 				}
+				debug.Log(debugLevel, "test.data_generation_complete", "direction", "download", "provisional", !fullyComplete)
 			}
 		case fullyComplete := <-uploadSaturationComplete:
 			{
 				uploadDataGenerationComplete = true
-				if *debugCliFlag {
-					fmt.Printf(
-						"################# upload load-generating data generation is %s complete!\n",
-						utilities.Conditional(fullyComplete, "", "(provisionally)"))
+				startLoadedTrace()
+				if !fullyComplete {
+					anomalies.Add(anomaly.Unsaturated)
+				} else {
+					uploadSaturationDuration = testClock.Now().Sub(testStartTime)
 				}
+				debug.Log(debugLevel, "test.data_generation_complete", "direction", "upload", "provisional", !fullyComplete)
 			}
 		case <-timeoutChannel:
 			{
+				if aborted() {
+					return
+				}
 				if dataCollectionTimeout {
 					// We already timedout on data collection. This signal means that
 					// we are timedout on getting the provisional data collection. We
@@ -362,55 +2903,64 @@ func main() {
 						os.Stderr,
 						"Error: Load-Generating data collection could not be completed in time and no provisional data could be gathered. Test failed.\n",
 					)
+					exitStatus = 1
 					cancelOperatingCtx()
-					if *debugCliFlag {
+					if debug.IsDebug(debugLevel) {
 						time.Sleep(constants.CooldownPeriod)
 					}
 					return // Ends program
 				}
 				dataCollectionTimeout = true
+				anomalies.Add(anomaly.ProvisionalData)
 
 				// We timed out attempting to collect data about the link. So, we will
 				// shut down the generators
 				cancelLGDataCollectionCtx()
 				// and then we will give ourselves some additional time in order
 				// to see if we can get some provisional data.
-				timeoutAbsoluteTime = time.Now().
+				timeoutAbsoluteTime = testClock.Now().
 					Add(time.Second * time.Duration(*rpmtimeout))
 				timeoutChannel = timeoutat.TimeoutAt(
 					operatingCtx,
 					timeoutAbsoluteTime,
+					testClock,
 					debugLevel,
 				)
-				if *debugCliFlag {
-					fmt.Printf(
-						"################# timeout collecting load-generating data!\n",
-					)
-				}
+				debug.Log(debugLevel, "test.data_generation_timeout")
 			}
 		}
 	}
 
-	if *debugCliFlag {
-		fmt.Printf("Stopping all the load generating data generators.\n")
+	saturationTime := testClock.Now()
+	enterPhase("collection", saturationTime)
+
+	// Keep the network saturated (and the probes going) for the stable phase.
+	if *stableDuration > 0 && !dataCollectionTimeout {
+		debug.Log(debugLevel, "test.stable_phase", "duration", *stableDuration)
+		select {
+		case <-testClock.After(*stableDuration):
+		case <-operatingCtx.Done():
+		}
 	}
+
+	debug.Log(debugLevel, "test.stop_data_generation")
 	// Just cancel the data collection -- do *not* yet stop the actual load-generating
 	// network activity.
 	cancelLGDataCollectionCtx()
 
 	// Shutdown the foreign-connection prober!
-	if *debugCliFlag {
-		fmt.Printf("Stopping all foreign probers.\n")
-	}
+	debug.Log(debugLevel, "test.stop_foreign_probers")
+	probingStopTime := testClock.Now()
 	foreignProberCtxCancel()
 
 	// Now that we stopped generation, let's give ourselves some time to collect
 	// all the data from our data generators.
-	timeoutAbsoluteTime = time.Now().
+	timeoutAbsoluteTime = testClock.Now().
 		Add(time.Second * time.Duration(*rpmtimeout))
 	timeoutChannel = timeoutat.TimeoutAt(
 		operatingCtx,
 		timeoutAbsoluteTime,
+		testClock,
 		debugLevel,
 	)
 
@@ -422,38 +2972,135 @@ func main() {
 		case downloadDataCollectionResult = <-downloadDataCollectionChannel:
 			{
 				downloadDataCollectionComplete = true
-				if *debugCliFlag {
-					fmt.Printf(
-						"################# download load-generating data collection is complete (%fMBps, %d flows)!\n",
-						utilities.ToMBps(downloadDataCollectionResult.RateBps),
-						len(downloadDataCollectionResult.LGCs),
-					)
-				}
+				debug.Log(debugLevel, "test.data_collection_complete", "direction", "download", "mbps", utilities.ToMBps(downloadDataCollectionResult.RateBps), "flows", len(downloadDataCollectionResult.LGCs))
 			}
 		case uploadDataCollectionResult = <-uploadDataCollectionChannel:
 			{
 				uploadDataCollectionComplete = true
-				if *debugCliFlag {
-					fmt.Printf(
-						"################# upload load-generating data collection is complete (%fMBps, %d flows)!\n",
-						utilities.ToMBps(uploadDataCollectionResult.RateBps),
-						len(uploadDataCollectionResult.LGCs),
-					)
-				}
+				debug.Log(debugLevel, "test.data_collection_complete", "direction", "upload", "mbps", utilities.ToMBps(uploadDataCollectionResult.RateBps), "flows", len(uploadDataCollectionResult.LGCs))
 			}
 		case <-timeoutChannel:
 			{
+				if aborted() {
+					return
+				}
 				// This is just bad news -- we generated data but could not collect it. Let's just fail.
 
 				fmt.Fprint(
 					os.Stderr,
 					"Error: Load-Generating data collection could not be completed in time and no provisional data could be gathered. Test failed.\n",
 				)
+				exitStatus = 1
 				return // Ends program
 			}
 		}
 	}
 
+	if aborted() {
+		return
+	}
+	dataCollectionCompleteTime := testClock.Now()
+
+	// When one direction failed (e.g., because its URL is wrong), the other still
+	// produced results that are worth reporting.
+	if downloadDataCollectionResult.Error != nil && uploadDataCollectionResult.Error != nil {
+		fmt.Fprintf(
+			os.Stderr,
+			"Error: Neither download (%v) nor upload (%v) load could be generated. Test failed.\n",
+			downloadDataCollectionResult.Error,
+			uploadDataCollectionResult.Error,
+		)
+		exitStatus = 1
+		cancelOperatingCtx()
+		return // Ends program
+	}
+	downloadError, uploadError := "", ""
+	if downloadDataCollectionResult.Error != nil {
+		downloadError = downloadDataCollectionResult.Error.Error()
+		anomalies.Add(anomaly.DownloadFailed)
+		fmt.Fprintf(os.Stderr, "Warning: The download failed: %v\n", downloadError)
+	}
+	if uploadDataCollectionResult.Error != nil {
+		uploadError = uploadDataCollectionResult.Error.Error()
+		anomalies.Add(anomaly.UploadFailed)
+		fmt.Fprintf(os.Stderr, "Warning: The upload failed: %v\n", uploadError)
+	}
+	if len(downloadDataCollectionResult.LGCs) > 0 {
+		// All the connections use the same resolver, so the first lookup is the one
+		// that counts.
+		connectionStats := downloadDataCollectionResult.LGCs[0].Stats()
+		timings.Record("dns", connectionStats.DnsStartTime, connectionStats.DnsDoneTime)
+	}
+	timings.Record("ramp", testStartTime, saturationTime)
+	timings.Record("stable", saturationTime, dataCollectionCompleteTime)
+
+	// Take stock of the flows before draining them.
+	downloadFlows := flowSummaries("download", downloadDataCollectionResult.LGCs)
+	uploadFlows := flowSummaries("upload", uploadDataCollectionResult.LGCs)
+	loadConnections := connectionSummaries(downloadDataCollectionResult.LGCs)
+
+	if stalls := downloadDataCollectionResult.Stalls + uploadDataCollectionResult.Stalls; stalls > 0 {
+		anomalies.Add(anomaly.StalledFlows)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: %d load-generating connections stalled (they were replaced and are not part of the rates).\n",
+			stalls,
+		)
+	}
+	if *targetRateMbps != "" {
+		for _, direction := range []struct {
+			name   string
+			rate   float64
+			target float64
+		}{
+			{"download", downloadDataCollectionResult.RateBps, downloadRateCeiling},
+			{"upload", uploadDataCollectionResult.RateBps, uploadRateCeiling},
+		} {
+			if direction.rate < direction.target*(1-constants.TargetRateTolerance/100) {
+				fmt.Fprintf(
+					os.Stderr,
+					"Warning: The %s did not reach its target rate (%.3f of %.3f Mbps); the network was saturated below it.\n",
+					direction.name,
+					utilities.ToMbps(direction.rate),
+					utilities.ToMbps(direction.target),
+				)
+			}
+		}
+	}
+	goAways, streamResets := uint64(0), uint64(0)
+	for _, connection := range append(downloadDataCollectionResult.LGCs, uploadDataCollectionResult.LGCs...) {
+		connectionGoAways, connectionStreamResets := connection.Interruptions()
+		goAways += connectionGoAways
+		streamResets += connectionStreamResets
+	}
+	if goAways+streamResets > 0 {
+		anomalies.Add(anomaly.ServerInterruptions)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The server interrupted the load-generating connections %d times with a GOAWAY and %d times with a RST_STREAM (they reconnected).\n",
+			goAways,
+			streamResets,
+		)
+	}
+	if downloadDataCollectionResult.MissedDeadlines+uploadDataCollectionResult.MissedDeadlines > 0 {
+		anomalies.Add(anomaly.ClientCPUBound)
+	}
+	integrityFailures := 0
+	for _, connection := range downloadDataCollectionResult.LGCs {
+		if download, ok := connection.(*lgc.LoadGeneratingConnectionDownload); ok &&
+			download.IntegrityFailed() {
+			integrityFailures++
+		}
+	}
+	if integrityFailures > 0 {
+		anomalies.Add(anomaly.IntegrityMismatch)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The data of %d load-generating downloads failed the integrity check.\n",
+			integrityFailures,
+		)
+	}
+
 	// In the new version we are no longer going to wait to send probes until after
 	// saturation. When we get here we are now only going to compute the results
 	// and/or extended statistics!
@@ -479,25 +3126,201 @@ func main() {
 		}
 	}
 
-	// And only now, when we are done getting the extended stats from the connections, can
-	// we actually shut down the load-generating network activity!
+	if loadedHopsChannel != nil {
+		loadedHops = <-loadedHopsChannel
+	}
+
+	// Before shutting down the load-generating network activity, give the connections
+	// a chance to quiesce so that they can be closed cleanly (rather than reset).
+	drainedBytes := uint64(0)
+	drainStartTime := testClock.Now()
+	enterPhase("drain", drainStartTime)
+	if *drainTimeout > 0 {
+		drainedBytes = lgc.DrainAll(
+			append(downloadDataCollectionResult.LGCs, uploadDataCollectionResult.LGCs...),
+			time.Second*time.Duration(*drainTimeout),
+		)
+	}
+
+	// And only now, when we are done getting the extended stats from the connections
+	// (and draining them), can we actually shut down the load-generating network activity!
 	cancelLgNetworkActivityCtx()
+	drainCompleteTime := testClock.Now()
+	timings.Record("drain", drainStartTime, drainCompleteTime)
+	// The statistics take the time of the client (whatever the clock of the test).
+	statisticsStartTime := time.Now()
 
-	fmt.Printf(
-		"Download: %7.3f Mbps (%7.3f MBps), using %d parallel connections.\n",
-		utilities.ToMbps(downloadDataCollectionResult.RateBps),
-		utilities.ToMBps(downloadDataCollectionResult.RateBps),
-		len(downloadDataCollectionResult.LGCs),
+	cancelTestRunningCtx()
+	if capture != nil {
+		if packets, err := capture.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: The capture failed: %v\n", err)
+		} else {
+			debug.Log(debugLevel, "pcap.done", "packets", packets, "capture", *pcapCapture)
+		}
+	}
+	if aborted() {
+		return
+	}
+	progressEstimator.Complete()
+	eventHandlers.OnPhaseChange(progressEstimator.Phase(), 100)
+	if *showProgress {
+		fmt.Fprintf(os.Stderr, "Progress: 100%% (%s)\n", progressEstimator.Phase())
+	}
+
+	ipv6 := usesIPv6(downloadDataCollectionResult.LGCs)
+	downloadWireRateBps := wire.Rate(downloadDataCollectionResult.RateBps, ipv6)
+	uploadWireRateBps := wire.Rate(uploadDataCollectionResult.RateBps, ipv6)
+
+	if textOutput {
+		if downloadError != "" {
+			fmt.Printf("Download: failed (%s).\n", downloadError)
+		} else {
+			fmt.Printf(
+				"Download: %7.*f Mbps (%7.*f MBps), using %d parallel connections.\n",
+				*ratePrecision,
+				roundRate(utilities.ToMbps(downloadDataCollectionResult.RateBps)),
+				*ratePrecision,
+				roundRate(utilities.ToMBps(downloadDataCollectionResult.RateBps)),
+				len(downloadDataCollectionResult.LGCs),
+			)
+		}
+		if uploadError != "" {
+			fmt.Printf("Upload:   failed (%s).\n", uploadError)
+		} else {
+			fmt.Printf(
+				"Upload:   %7.*f Mbps (%7.*f MBps), using %d parallel connections.\n",
+				*ratePrecision,
+				roundRate(utilities.ToMbps(uploadDataCollectionResult.RateBps)),
+				*ratePrecision,
+				roundRate(utilities.ToMBps(uploadDataCollectionResult.RateBps)),
+				len(uploadDataCollectionResult.LGCs),
+			)
+		}
+		fmt.Printf(
+			"Estimated on the wire (with HTTP/2, TLS and TCP/IP overhead): %7.*f Mbps down, %7.*f Mbps up.\n",
+			*ratePrecision,
+			roundRate(utilities.ToMbps(downloadWireRateBps)),
+			*ratePrecision,
+			roundRate(utilities.ToMbps(uploadWireRateBps)),
+		)
+		for _, flows := range [][]summary.Flow{downloadFlows, uploadFlows} {
+			if len(flows) == 0 {
+				continue
+			}
+			rates := utilities.Fmap(flows, func(flow summary.Flow) string {
+				return fmt.Sprintf("%.*f", *ratePrecision, roundRate(utilities.ToMbps(flow.RateBps)))
+			})
+			fmt.Printf("Per-connection %s rates (Mbps): %s\n", flows[0].Direction, strings.Join(rates, " "))
+		}
+		for _, connection := range loadConnections {
+			fmt.Printf(
+				"Download connections to %s: %d (ALPN %s, %s, %s).\n",
+				connection.Address,
+				connection.Count,
+				utilities.Conditional(connection.ALPN != "", connection.ALPN, "none"),
+				utilities.Conditional(connection.TLSVersion != "", connection.TLSVersion, "no TLS"),
+				utilities.Conditional(connection.CipherSuite != "", connection.CipherSuite, "no cipher suite"),
+			)
+		}
+		if *rampAlgorithm != ramp.Default || *latencyBudget > 0 {
+			fmt.Printf("Connections were added with the %s ramp algorithm.\n", downloadRampAlgorithm.Name())
+		}
+		saturatedAfter := func(duration time.Duration) string {
+			return utilities.Conditional(duration == 0, "never", duration.Round(100*time.Millisecond).String())
+		}
+		fmt.Printf(
+			"Time to saturation: download %s, upload %s.\n",
+			saturatedAfter(downloadSaturationDuration),
+			saturatedAfter(uploadSaturationDuration),
+		)
+	}
+
+	<-foreignProbesCollected
+	droppedSamples := droppedForeignProbeDataPoints + downloadDataCollectionResult.Dropped +
+		uploadDataCollectionResult.Dropped
+	reportDroppedSamples(droppedSamples)
+
+	probeErrors := countProbeErrors(
+		&anomalies,
+		foreignProbeDataPoints,
+		downloadDataCollectionResult.ProbeDataPoints,
+		uploadDataCollectionResult.ProbeDataPoints,
 	)
-	fmt.Printf(
-		"Upload:   %7.3f Mbps (%7.3f MBps), using %d parallel connections.\n",
-		utilities.ToMbps(uploadDataCollectionResult.RateBps),
-		utilities.ToMBps(uploadDataCollectionResult.RateBps),
-		len(uploadDataCollectionResult.LGCs),
+
+	cachedProbes := countCachedProbes(
+		&anomalies,
+		foreignProbeDataPoints,
+		downloadDataCollectionResult.ProbeDataPoints,
+		uploadDataCollectionResult.ProbeDataPoints,
+	)
+	// Neither are probes that were in flight when the wall clock jumped (e.g., because
+	// the system was suspended).
+	clockJumps, suspended := checkClock(clockWatcher, &anomalies)
+	cpuSummary := cpuload.Summarize(cpuSampler.Samples())
+	if cpuSummary.Bound {
+		anomalies.Add(anomaly.ClientCPUBound)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The CPU was (about) all used for %d seconds of the test (peak: client %.0f%%, system %s); the rates may be the limit of this device's CPU rather than of the network.\n",
+			cpuSummary.BoundSamples*int(constants.CPUSampleInterval.Seconds()),
+			cpuSummary.ProcessPeakPercent,
+			utilities.Conditional(
+				cpuSummary.SystemPeakPercent < 0,
+				"unknown",
+				fmt.Sprintf("%.0f%%", cpuSummary.SystemPeakPercent),
+			),
+		)
+	}
+
+	networkChanges := networkWatcher.Changes()
+	for _, change := range networkChanges {
+		anomalies.Add(anomaly.NetworkChanged)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The network changed during the test (%v); its results mix the two networks.\n",
+			change,
+		)
+	}
+	clockJumpProbes := 0
+	isUsable := usableProbes(clockJumps, &clockJumpProbes)
+	// The specification's foreign probes do full handshakes; those that resumed a TLS
+	// session show how much of the cost of a new connection is due to TLS.
+	usableResumedForeignProbeDataPoints := utilities.Filter(
+		foreignProbeDataPoints,
+		func(dp rpm.ProbeDataPoint) bool { return dp.Resumed && isUsable(dp) },
+	)
+	usableForeignProbeDataPoints := utilities.Filter(
+		foreignProbeDataPoints,
+		func(dp rpm.ProbeDataPoint) bool { return !dp.Resumed && isUsable(dp) },
 	)
+	usableDownloadProbeDataPoints := utilities.Filter(
+		downloadDataCollectionResult.ProbeDataPoints,
+		isUsable,
+	)
+	usableUploadProbeDataPoints := utilities.Filter(
+		uploadDataCollectionResult.ProbeDataPoints,
+		isUsable,
+	)
+	// The self probes of a direction that failed did not measure a loaded connection.
+	if downloadError != "" {
+		usableDownloadProbeDataPoints = nil
+	}
+	if uploadError != "" {
+		usableUploadProbeDataPoints = nil
+	}
+	// With both self-probe mechanisms, the PINGs are only reported on their own.
+	isPing := func(dp rpm.ProbeDataPoint) bool { return dp.Ping }
+	usablePingProbeDataPoints := append(
+		utilities.Filter(usableDownloadProbeDataPoints, isPing),
+		utilities.Filter(usableUploadProbeDataPoints, isPing)...,
+	)
+	if *selfProbe == rpm.SelfProbeBoth {
+		isRequest := func(dp rpm.ProbeDataPoint) bool { return !dp.Ping }
+		usableDownloadProbeDataPoints = utilities.Filter(usableDownloadProbeDataPoints, isRequest)
+		usableUploadProbeDataPoints = utilities.Filter(usableUploadProbeDataPoints, isRequest)
+	}
 
-	foreignProbeDataPoints := utilities.ChannelToSlice(foreignProbeDataPointsChannel)
-	totalForeignRoundTrips := len(foreignProbeDataPoints)
+	totalForeignRoundTrips := len(usableForeignProbeDataPoints)
 	// The specification indicates that we want to calculate the foreign probes as such:
 	// 1/3*tcp_foreign + 1/3*tls_foreign + 1/3*http_foreign
 	// where tcp_foreign, tls_foreign, http_foreign are the P90 RTTs for the connection
@@ -508,78 +3331,452 @@ func main() {
 	// _foreign
 	// So, there's no need to divide by the number of RTTs defined in the ProbeDataPoints
 	// in the individual results.
-	foreignProbeRoundTripTimes := utilities.Fmap(
-		foreignProbeDataPoints,
-		func(dp rpm.ProbeDataPoint) float64 { return dp.Duration.Seconds() },
+	// The outliers (see -outliers) are filtered before any percentile is calculated.
+	filterOutliers := func(roundTripTimes []float64, filtered *int) []float64 {
+		kept, count := outlier.Filter(roundTripTimes, *outlierFilter, *outlierK)
+		*filtered += count
+		return kept
+	}
+	filteredProbes := 0
+	foreignProbeRoundTripTimes := filterOutliers(
+		utilities.Fmap(usableForeignProbeDataPoints, probeRoundTripTime),
+		&filteredProbes,
+	)
+	foreignProbeRoundTripTimeP90 := p90(foreignProbeRoundTripTimes)
+	resumedForeignProbeRoundTripTimeP90 := p90(
+		utilities.Fmap(usableResumedForeignProbeDataPoints, probeRoundTripTime),
 	)
-	foreignProbeRoundTripTimeP90 := utilities.CalculatePercentile(foreignProbeRoundTripTimes, 90)
 
-	downloadRoundTripTimes := utilities.Fmap(
-		downloadDataCollectionResult.ProbeDataPoints,
-		func(dcr rpm.ProbeDataPoint) float64 { return dcr.Duration.Seconds() },
+	downloadRoundTripTimes := filterOutliers(
+		utilities.Fmap(usableDownloadProbeDataPoints, probeRoundTripTime),
+		&filteredProbes,
 	)
-	uploadRoundTripTimes := utilities.Fmap(
-		uploadDataCollectionResult.ProbeDataPoints,
-		func(dcr rpm.ProbeDataPoint) float64 { return dcr.Duration.Seconds() },
+	uploadRoundTripTimes := filterOutliers(
+		utilities.Fmap(usableUploadProbeDataPoints, probeRoundTripTime),
+		&filteredProbes,
 	)
 	selfProbeRoundTripTimes := append(downloadRoundTripTimes, uploadRoundTripTimes...)
 	totalSelfRoundTrips := len(selfProbeRoundTripTimes)
-	selfProbeRoundTripTimeP90 := utilities.CalculatePercentile(selfProbeRoundTripTimes, 90)
+	selfProbeRoundTripTimeP90 := p90(selfProbeRoundTripTimes)
 
-	rpm := 60.0 / (float64(selfProbeRoundTripTimeP90+foreignProbeRoundTripTimeP90) / 2.0)
+	// The download and upload load-generating connections (and their self probes) run in
+	// parallel so the foreign probes cannot be attributed to either direction; only the
+	// self probes can be broken out by direction.
+	downloadSelfProbeRoundTripTimeP90 := p90(downloadRoundTripTimes)
+	uploadSelfProbeRoundTripTimeP90 := p90(uploadRoundTripTimes)
+	pingProbeRoundTripTimeP90 := p90(utilities.Fmap(usablePingProbeDataPoints, probeRoundTripTime))
 
-	if *debugCliFlag {
-		fmt.Printf(
-			"Total Load-Generating Round Trips: %d, Total New-Connection Round Trips: %d, P90 LG RTT: %f, P90 NC RTT: %f\n",
-			totalSelfRoundTrips,
-			totalForeignRoundTrips,
-			selfProbeRoundTripTimeP90,
-			foreignProbeRoundTripTimeP90,
-		)
+	// The latency spikes are found among all the probes (before any outliers are
+	// filtered) and put next to the throughput at the time.
+	latencySpikes := make([]summary.Spike, 0)
+	if *spikeThreshold > 0 || *spikeFactor > 0 {
+		spikeProbes := make([]spike.Probe, 0)
+		for _, probes := range [][]rpm.ProbeDataPoint{
+			usableForeignProbeDataPoints,
+			usableDownloadProbeDataPoints,
+			usableUploadProbeDataPoints,
+		} {
+			for _, dp := range probes {
+				spikeProbes = append(spikeProbes, spike.Probe{Time: dp.Time, Prober: dp.Prober, Seconds: probeRoundTripTime(dp)})
+			}
+		}
+		throughputAt := func(points []rpm.ThroughputDataPoint, at time.Time) float64 {
+			rate := float64(0)
+			for _, point := range points {
+				if point.Time.After(at) {
+					break
+				}
+				rate = point.Throughput
+			}
+			return rate
+		}
+		for _, detected := range spike.Detect(spikeProbes, spikeThreshold.Seconds(), *spikeFactor) {
+			latencySpike := summary.Spike{
+				Seconds:          detected.Time.Sub(testStartTime).Seconds(),
+				Prober:           detected.Prober,
+				RoundTripSeconds: detected.Seconds,
+				MedianSeconds:    detected.MedianSeconds,
+				DownloadRateBps:  throughputAt(downloadDataCollectionResult.Throughput, detected.Time),
+				UploadRateBps:    throughputAt(uploadDataCollectionResult.Throughput, detected.Time),
+			}
+			debug.Log(debugLevel, "test.latency_spike", "spike", latencySpike)
+			latencySpikes = append(latencySpikes, latencySpike)
+		}
+	}
+
+	// Each probe takes several round trips; the grade is about the latency of one.
+	perRoundTrip := func(dp rpm.ProbeDataPoint) time.Duration {
+		if dp.RoundTripCount == 0 {
+			return dp.Duration
+		}
+		return dp.Duration / time.Duration(dp.RoundTripCount)
+	}
+	usableIdleProbeDataPoints := utilities.Filter(idleProbeDataPoints, isUsable)
+	latencyInflation, bufferbloatGrade := time.Duration(0), ""
+	if inflation, ok := grade.Inflation(
+		utilities.Fmap(usableIdleProbeDataPoints, perRoundTrip),
+		utilities.Fmap(usableForeignProbeDataPoints, perRoundTrip),
+	); ok {
+		latencyInflation, bufferbloatGrade = inflation, grade.Bufferbloat(inflation)
+	} else if *idleBaseline {
+		fmt.Fprintf(os.Stderr, "Warning: There are not enough probes to grade the bufferbloat.\n")
 	}
 
-	fmt.Printf("RPM: %5.0f\n", rpm)
+	var traceHops []summary.Hop = nil
+	for i, idleHop := range idleHops {
+		hop := summary.Hop{TTL: idleHop.TTL, Address: idleHop.Address, IdleSeconds: idleHop.Median().Seconds()}
+		if i < len(loadedHops) {
+			hop.LoadedSeconds = loadedHops[i].Median().Seconds()
+			if hop.Address == "" {
+				hop.Address = loadedHops[i].Address
+			}
+		}
+		traceHops = append(traceHops, hop)
+	}
 
-	if *calculateExtendedStats {
-		fmt.Println(extendedStats.Repr())
+	// Probes whose responses arrived after their phase ended are still counted (in the
+	// phase in which they were sent) but we keep track of how many there were.
+	phaseCrossingProbes := 0
+	for _, probes := range [][]rpm.ProbeDataPoint{
+		foreignProbeDataPoints,
+		downloadDataCollectionResult.ProbeDataPoints,
+		uploadDataCollectionResult.ProbeDataPoints,
+	} {
+		for _, dp := range probes {
+			if dp.PhaseCrossing {
+				phaseCrossingProbes++
+			}
+		}
 	}
 
-	if !utilities.IsInterfaceNil(selfDataLogger) {
-		selfDataLogger.Export()
-		if *debugCliFlag {
-			fmt.Printf("Closing the self data logger.\n")
+	// The RPM of the round-trip times of the self and the foreign probes (of the whole
+	// test or of a window of it).
+	rpmOf := func(selfRoundTripTimes, foreignRoundTripTimes []float64) float64 {
+		return combinedRpm(selfRoundTripTimes, foreignRoundTripTimes, testSpec)
+	}
+
+	// Without probes (see -throughput-only), there is no RPM.
+	calculatedRpm, responsiveness := float64(0), ""
+	selfRpm, foreignRpm := float64(0), float64(0)
+	var rpmSeries []rpmseries.Point = nil
+	if !*throughputOnly {
+		selfRpm, foreignRpm = componentRpms(selfProbeRoundTripTimes, foreignProbeRoundTripTimes, testSpec)
+		calculatedRpm = rpmOf(selfProbeRoundTripTimes, foreignProbeRoundTripTimes)
+		responsiveness = rpm.ClassifyResponsiveness(calculatedRpm, *responsivenessMedium, *responsivenessHigh)
+
+		// The series is calculated from the usable probes (before any outliers are
+		// filtered, since that depends on all of them).
+		if *rpmWindow > 0 {
+			seriesProbes := make([]rpmseries.Probe, 0)
+			for _, probes := range []struct {
+				dataPoints []rpm.ProbeDataPoint
+				foreign    bool
+			}{
+				{usableForeignProbeDataPoints, true},
+				{usableDownloadProbeDataPoints, false},
+				{usableUploadProbeDataPoints, false},
+			} {
+				for _, dp := range probes.dataPoints {
+					seriesProbes = append(seriesProbes, rpmseries.Probe{
+						Time:    dp.Time,
+						Foreign: probes.foreign,
+						Seconds: probeRoundTripTime(dp),
+					})
+				}
+			}
+			rpmSeries = rpmseries.Calculate(
+				seriesProbes,
+				testStartTime,
+				dataCollectionCompleteTime,
+				*rpmWindow,
+				constants.RPMSeriesStep,
+				rpmOf,
+			)
+			if !utilities.IsInterfaceNil(rpmSeriesDataLogger) {
+				for _, point := range rpmSeries {
+					rpmSeriesDataLogger.LogRecord(point)
+				}
+			}
 		}
-		selfDataLogger.Close()
 	}
 
-	if !utilities.IsInterfaceNil(foreignDataLogger) {
-		foreignDataLogger.Export()
-		if *debugCliFlag {
-			fmt.Printf("Closing the foreign data logger.\n")
+	// On slow links, receiving (the body of) the response to a probe takes a significant
+	// part of the round-trip time, so the small object should be small.
+	largestProbeBody := uint64(0)
+	for _, probes := range [][]rpm.ProbeDataPoint{
+		usableForeignProbeDataPoints,
+		usableDownloadProbeDataPoints,
+		usableUploadProbeDataPoints,
+	} {
+		for _, dp := range probes {
+			largestProbeBody = utilities.Max(largestProbeBody, dp.BodySize)
+		}
+	}
+	if *probeSize == 0 && largestProbeBody > constants.MaximumSmallObjectSize {
+		anomalies.Add(anomaly.LargeSmallObject)
+		fmt.Fprintf(
+			os.Stderr,
+			"Warning: The small download resource is %d bytes; receiving it may inflate the probe round-trip times (see -compensate-serialization).\n",
+			largestProbeBody,
+		)
+	}
+	var compensatedSelfProbeRoundTripTimeP90, compensatedForeignProbeRoundTripTimeP90, compensatedRpm float64
+	if *compensateSerialization {
+		compensatedRoundTripTime := func(dp rpm.ProbeDataPoint) float64 {
+			roundTripTime := probeRoundTripTime(dp)
+			if *probeTiming == "total" && downloadDataCollectionResult.RateBps > 0 {
+				roundTripTime -= float64(dp.BodySize) / downloadDataCollectionResult.RateBps
+			}
+			return math.Max(roundTripTime, 0)
 		}
-		foreignDataLogger.Close()
+		compensatedSelfProbeRoundTripTimeP90 = p90(
+			append(
+				utilities.Fmap(usableDownloadProbeDataPoints, compensatedRoundTripTime),
+				utilities.Fmap(usableUploadProbeDataPoints, compensatedRoundTripTime)...,
+			),
+		)
+		compensatedForeignProbeRoundTripTimeP90 = p90(
+			utilities.Fmap(usableForeignProbeDataPoints, compensatedRoundTripTime),
+		)
+		compensatedRpm = rpm.Combine(
+			compensatedSelfProbeRoundTripTimeP90,
+			compensatedForeignProbeRoundTripTimeP90,
+			*selfWeight,
+		)
 	}
 
-	if !utilities.IsInterfaceNil(downloadThroughputDataLogger) {
-		downloadThroughputDataLogger.Export()
-		if *debugCliFlag {
-			fmt.Printf("Closing the download throughput data logger.\n")
+	debug.Log(
+		debugLevel,
+		"test.probe_statistics",
+		"self_round_trips", totalSelfRoundTrips,
+		"foreign_round_trips", totalForeignRoundTrips,
+		"self_p90", selfProbeRoundTripTimeP90,
+		"foreign_p90", foreignProbeRoundTripTimeP90,
+		"download_self_p90", downloadSelfProbeRoundTripTimeP90,
+		"upload_self_p90", uploadSelfProbeRoundTripTimeP90,
+		"phase_crossing_probes", phaseCrossingProbes,
+		"cached_probes", cachedProbes,
+		"clock_jump_probes", clockJumpProbes,
+	)
+
+	if textOutput && !*throughputOnly {
+		fmt.Printf("RPM: %5.*f (%s)\n", *rpmPrecision, roundRpm(calculatedRpm), responsiveness)
+		printOutlierFilter(filteredProbes, len(selfProbeRoundTripTimes)+len(foreignProbeRoundTripTimes))
+		if len(rpmSeries) > 0 {
+			lowest := rpmSeries[0].RPM
+			for _, point := range rpmSeries {
+				lowest = math.Min(lowest, point.RPM)
+			}
+			fmt.Printf(
+				"RPM over %v windows: first %.*f, lowest %.*f, last %.*f\n",
+				*rpmWindow,
+				*rpmPrecision, roundRpm(rpmSeries[0].RPM),
+				*rpmPrecision, roundRpm(lowest),
+				*rpmPrecision, roundRpm(rpmSeries[len(rpmSeries)-1].RPM),
+			)
 		}
-		downloadThroughputDataLogger.Close()
+		if *spikeThreshold > 0 || *spikeFactor > 0 {
+			fmt.Printf("Latency spikes: %d\n", len(latencySpikes))
+			for _, latencySpike := range latencySpikes {
+				fmt.Printf(
+					"  %6.1fs  %s probe: %.3fs (median %.3fs) at %.3f Mbps down, %.3f Mbps up\n",
+					latencySpike.Seconds,
+					latencySpike.Prober,
+					latencySpike.RoundTripSeconds,
+					latencySpike.MedianSeconds,
+					utilities.ToMbps(latencySpike.DownloadRateBps),
+					utilities.ToMbps(latencySpike.UploadRateBps),
+				)
+			}
+		}
+		fmt.Printf(
+			"Foreign RPM: %5.*f, self RPM: %5.*f\n",
+			*rpmPrecision,
+			roundRpm(foreignRpm),
+			*rpmPrecision,
+			roundRpm(selfRpm),
+		)
+		printLatencyTable([]latencyRow{
+			{"Self probes", selfProbeRoundTripTimes},
+			{"Foreign probes", foreignProbeRoundTripTimes},
+			{"Idle probes", utilities.Fmap(usableIdleProbeDataPoints, probeRoundTripTime)},
+		})
 	}
+	if textOutput {
+		if bufferbloatGrade != "" {
+			fmt.Printf(
+				"Bufferbloat grade: %s (under load, the latency of a round trip grew by %v).\n",
+				bufferbloatGrade,
+				latencyInflation.Round(time.Millisecond),
+			)
+		}
+		if len(traceHops) > 0 {
+			fmt.Printf("Traceroute to %s (median RTT before the test and under load):\n", testServerHost)
+			seconds := func(value float64) string {
+				return utilities.Conditional(value == 0, "      *", fmt.Sprintf("%6.3fs", value))
+			}
+			for _, hop := range traceHops {
+				inflation := ""
+				if hop.IdleSeconds != 0 && hop.LoadedSeconds != 0 {
+					inflation = fmt.Sprintf(" %+.3fs", hop.LoadedSeconds-hop.IdleSeconds)
+				}
+				fmt.Printf(
+					"%3d  %-39s %s %s%s\n",
+					hop.TTL,
+					utilities.Conditional(hop.Address == "", "*", hop.Address),
+					seconds(hop.IdleSeconds),
+					seconds(hop.LoadedSeconds),
+					inflation,
+				)
+			}
+		}
+		if *resumptionFraction > 0 {
+			fmt.Printf(
+				"Foreign probes: P90 %.3fs with a full TLS handshake, %.3fs with a resumed TLS session (%d probes).\n",
+				foreignProbeRoundTripTimeP90,
+				resumedForeignProbeRoundTripTimeP90,
+				len(usableResumedForeignProbeDataPoints),
+			)
+		}
+		if *compensateSerialization {
+			fmt.Printf(
+				"RPM (compensated for the serialization of the probes): %5.*f\n",
+				*rpmPrecision,
+				roundRpm(compensatedRpm),
+			)
+		}
 
-	if !utilities.IsInterfaceNil(uploadThroughputDataLogger) {
-		uploadThroughputDataLogger.Export()
-		if *debugCliFlag {
-			fmt.Printf("Closing the upload throughput data logger.\n")
+		if *drainTimeout > 0 {
+			fmt.Printf("Drain: discarded %d bytes while closing the connections.\n", drainedBytes)
 		}
-		uploadThroughputDataLogger.Close()
 	}
 
-	cancelOperatingCtx()
-	if *debugCliFlag {
-		fmt.Printf("In debugging mode, we will cool down.\n")
-		time.Sleep(constants.CooldownPeriod)
-		fmt.Printf("Done cooling down.\n")
+	timings.Since("statistics", statisticsStartTime)
+
+	testSummary := &summary.Summary{
+		RampAlgorithm:          *rampAlgorithm,
+		LatencyBudget:          latencyBudget.Seconds(),
+		DownloadRateBps:        downloadDataCollectionResult.RateBps,
+		DownloadWireRateBps:    downloadWireRateBps,
+		DownloadFlows:          len(downloadDataCollectionResult.LGCs),
+		UploadRateBps:          uploadDataCollectionResult.RateBps,
+		UploadWireRateBps:      uploadWireRateBps,
+		UploadFlows:            len(uploadDataCollectionResult.LGCs),
+		SelfProbeRoundTrips:    totalSelfRoundTrips,
+		ForeignProbeRoundTrips: totalForeignRoundTrips,
+		SelfProbeP90:           selfProbeRoundTripTimeP90,
+		DownloadSelfProbeP90:   downloadSelfProbeRoundTripTimeP90,
+		UploadSelfProbeP90:     uploadSelfProbeRoundTripTimeP90,
+		ForeignProbeP90:        foreignProbeRoundTripTimeP90,
+		ResumedForeignProbes:   len(usableResumedForeignProbeDataPoints),
+		ResumedForeignProbeP90: resumedForeignProbeRoundTripTimeP90,
+		RPM:                    calculatedRpm,
+		PhaseCrossingProbes:    phaseCrossingProbes,
+		CachedProbes:           cachedProbes,
+		ProbeErrors:            probeErrors,
+		ClockJumps:             len(clockJumps),
+		ClockJumpProbes:        clockJumpProbes,
+		Suspended:              suspended,
+		DrainedBytes:           drainedBytes,
+
+		CompensatedSelfProbeP90:    compensatedSelfProbeRoundTripTimeP90,
+		CompensatedForeignProbeP90: compensatedForeignProbeRoundTripTimeP90,
+		CompensatedRPM:             compensatedRpm,
+
+		DownloadError: downloadError,
+		UploadError:   uploadError,
+
+		DownloadStalls: downloadDataCollectionResult.Stalls,
+		UploadStalls:   uploadDataCollectionResult.Stalls,
+
+		SelfProbeMechanism: *selfProbe,
+		PingProbes:         len(usablePingProbeDataPoints),
+		PingProbeP90:       pingProbeRoundTripTimeP90,
+
+		GoAways:      goAways,
+		StreamResets: streamResets,
+
+		Traceroute: traceHops,
+
+		IdleProbes:       len(usableIdleProbeDataPoints),
+		LatencyInflation: latencyInflation.Seconds(),
+		BufferbloatGrade: bufferbloatGrade,
+
+		ThroughputOnly: *throughputOnly,
+
+		Connections: loadConnections,
+
+		DownloadSaturationSeconds: downloadSaturationDuration.Seconds(),
+		UploadSaturationSeconds:   uploadSaturationDuration.Seconds(),
+
+		Ramp: append(
+			rampSummaries("download", downloadDataCollectionResult.Ramp, testStartTime),
+			rampSummaries("upload", uploadDataCollectionResult.Ramp, testStartTime)...,
+		),
+
+		SelfRPM:    selfRpm,
+		ForeignRPM: foreignRpm,
+
+		MovingAverageWindow: *movingAverageWindow,
+
+		DownloadDuration: downloadDuration.Seconds(),
+		UploadDuration:   uploadDuration.Seconds(),
+		StableDuration:   stableDuration.Seconds(),
+
+		DownloadRateCeilingBps: downloadRateCeiling,
+		UploadRateCeilingBps:   uploadRateCeiling,
+		TargetRate:             *targetRateMbps != "",
+
+		FilteredProbes: filteredProbes,
+
+		DroppedSamples: droppedSamples,
+
+		LatencySpikes: latencySpikes,
+
+		NetworkChanges: utilities.Fmap(networkChanges, netwatch.Change.String),
+
+		CPU: &cpuSummary,
+
+		RPMSeries: rpmSeries,
+
+		Flows: append(downloadFlows, uploadFlows...),
+	}
+	collectPacketProbes(testSummary, udpProber, icmpProber, probingStopTime)
+
+	if textOutput && *calculateExtendedStats {
+		fmt.Println(extendedStats.Repr())
+	}
+	if textOutput && *explainRamp {
+		fmt.Printf("How the test reached the working conditions:\n")
+		for _, direction := range []struct {
+			name      string
+			saturated bool
+		}{
+			{"download", downloadSaturationDuration > 0},
+			{"upload", uploadSaturationDuration > 0},
+		} {
+			steps := utilities.Filter(testSummary.Ramp, func(step summary.RampStep) bool {
+				return step.Direction == direction.name
+			})
+			for _, line := range explain.Narrate(direction.name, steps, direction.saturated) {
+				fmt.Println(line)
+			}
+		}
 	}
+
+	finishTest(
+		testSummary,
+		[]testPhase{
+			{"ramp", testStartTime, saturationTime, nil},
+			{"stable", saturationTime, dataCollectionCompleteTime, nil},
+			{
+				"drain",
+				drainStartTime,
+				drainCompleteTime,
+				map[string]interface{}{"drain.discarded_bytes": drainedBytes},
+			},
+		},
+		append(downloadDataCollectionResult.ProbeDataPoints, uploadDataCollectionResult.ProbeDataPoints...),
+		foreignProbeDataPoints,
+	)
 }
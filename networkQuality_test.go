@@ -0,0 +1,72 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/spec"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+func TestParseRateCeilings(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		download float64
+		upload   float64
+	}{
+		{"100", 100, 100},
+		{"400,40", 400, 40},
+		{" 12.5 , 2 ", 12.5, 2},
+	} {
+		download, upload, err := parseRateCeilings("-max-rate-mbps", test.value)
+		if err != nil {
+			t.Fatalf("Could not parse %q: %v", test.value, err)
+		}
+		// A run that is held at its ceilings reports them as they were given.
+		if utilities.ToMbps(download) != test.download || utilities.ToMbps(upload) != test.upload {
+			t.Fatalf(
+				"Expected %q to be reported as %v,%v Mbps but got %v,%v",
+				test.value,
+				test.download,
+				test.upload,
+				utilities.ToMbps(download),
+				utilities.ToMbps(upload),
+			)
+		}
+	}
+	for _, value := range []string{"", "0", "-1", "1,2,3", "fast"} {
+		if _, _, err := parseRateCeilings("-max-rate-mbps", value); err == nil {
+			t.Fatalf("Expected %q to be rejected but it was not", value)
+		}
+	}
+}
+
+func TestRpmRoundTripTimes(t *testing.T) {
+	self := []float64{0.5, 0.5, 0.5}
+	foreign := []float64{1.5, 1.5, 1.5}
+	for _, name := range spec.Names {
+		profile, _ := spec.Get(name)
+		selfRoundTripTime, foreignRoundTripTime := rpmRoundTripTimes(self, foreign, profile)
+		if selfRoundTripTime != 0.5 || foreignRoundTripTime != 1.5/float64(profile.ForeignRoundTrips) {
+			t.Fatalf("Expected 0.5 and 1.5 per foreign round trip with %s but got %v and %v", name, selfRoundTripTime, foreignRoundTripTime)
+		}
+		// The component RPMs are calculated just like the RPM.
+		selfRpm, foreignRpm := componentRpms(self, foreign, profile)
+		if selfRpm != 60.0/selfRoundTripTime || foreignRpm != 60.0/foreignRoundTripTime {
+			t.Fatalf("Expected the component RPMs of the round-trip times of the RPM with %s but got %v and %v", name, selfRpm, foreignRpm)
+		}
+	}
+}
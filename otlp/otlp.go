@@ -0,0 +1,346 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package otlp exports the results of a test as OpenTelemetry traces and metrics. It
+// speaks OTLP/HTTP with JSON encoding (so that there is no need for the OpenTelemetry
+// SDK and its dependencies) and is configured with the standard OTEL_* environment
+// variables.
+package otlp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/summary"
+	"github.com/network-quality/goresponsiveness/useragent"
+)
+
+const (
+	defaultServiceName = "goresponsiveness"
+	scopeName          = "github.com/network-quality/goresponsiveness"
+	defaultTimeout     = 10 * time.Second
+)
+
+type SpanKind int
+
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+type Span struct {
+	spanId     string
+	parent     *Span
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+}
+
+type Trace struct {
+	traceId string
+	spans   []*Span
+}
+
+func randomId(length int) string {
+	id := make([]byte, length)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func NewTrace() *Trace {
+	return &Trace{traceId: randomId(16), spans: make([]*Span, 0)}
+}
+
+// AddSpan adds a (completed) span to the trace. The span is a root span when _parent_
+// is nil.
+func (t *Trace) AddSpan(
+	name string,
+	kind SpanKind,
+	parent *Span,
+	start time.Time,
+	end time.Time,
+	attributes map[string]interface{},
+) *Span {
+	span := &Span{
+		spanId:     randomId(8),
+		parent:     parent,
+		Name:       name,
+		Kind:       kind,
+		Start:      start,
+		End:        end,
+		Attributes: attributes,
+	}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+type Exporter struct {
+	tracesEndpoint  string
+	metricsEndpoint string
+	headers         map[string]string
+	timeout         time.Duration
+	resource        map[string]interface{}
+}
+
+// parseKeyValues parses the comma-separated list of (URL-encoded) key=value pairs
+// that is the format of OTEL_EXPORTER_OTLP_HEADERS and OTEL_RESOURCE_ATTRIBUTES.
+func parseKeyValues(list string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		pieces := strings.SplitN(pair, "=", 2)
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("Malformed key/value pair: %s", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(pieces[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Malformed value for key %s: %v", pieces[0], err)
+		}
+		result[strings.TrimSpace(pieces[0])] = value
+	}
+	return result, nil
+}
+
+// signalEndpoint determines the URL to which to send a particular signal (traces or
+// metrics), preferring the signal-specific environment variable (which is used as-is)
+// over the generic one (to which the signal's path is appended).
+func signalEndpoint(signal string) string {
+	signalVariable := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT"
+	if endpoint := os.Getenv(signalVariable); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/" + signal
+	}
+	return ""
+}
+
+// NewExporterFromEnvironment configures an exporter from the standard OTEL_* environment
+// variables. Unlike the OpenTelemetry SDKs, exporting is opt in: when there is no OTLP
+// endpoint configured (or OTEL_SDK_DISABLED is true) the result is nil.
+func NewExporterFromEnvironment() (*Exporter, error) {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil, nil
+	}
+	exporter := &Exporter{
+		tracesEndpoint:  signalEndpoint("traces"),
+		metricsEndpoint: signalEndpoint("metrics"),
+		timeout:         defaultTimeout,
+		resource:        map[string]interface{}{"service.name": defaultServiceName},
+	}
+	if exporter.tracesEndpoint == "" && exporter.metricsEndpoint == "" {
+		return nil, nil
+	}
+
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" &&
+		protocol != "http/json" {
+		return nil, fmt.Errorf(
+			"Unsupported OTLP protocol %s (only http/json is supported)",
+			protocol,
+		)
+	}
+
+	headers, err := parseKeyValues(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse OTEL_EXPORTER_OTLP_HEADERS: %v", err)
+	}
+	exporter.headers = headers
+
+	if timeout := os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); timeout != "" {
+		milliseconds, err := strconv.Atoi(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse OTEL_EXPORTER_OTLP_TIMEOUT: %v", err)
+		}
+		exporter.timeout = time.Duration(milliseconds) * time.Millisecond
+	}
+
+	attributes, err := parseKeyValues(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse OTEL_RESOURCE_ATTRIBUTES: %v", err)
+	}
+	for key, value := range attributes {
+		exporter.resource[key] = value
+	}
+	if serviceName := os.Getenv("OTEL_SERVICE_NAME"); serviceName != "" {
+		exporter.resource["service.name"] = serviceName
+	}
+	return exporter, nil
+}
+
+func encodeTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]interface{}, 0, len(attributes))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint64:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": value})
+	}
+	return encoded
+}
+
+func (e *Exporter) post(endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	useragent.Apply(request)
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: e.timeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not export to %s: %v", endpoint, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("Exporting to %s failed: %s", endpoint, response.Status)
+	}
+	return nil
+}
+
+func (e *Exporter) ExportTrace(t *Trace) error {
+	if e.tracesEndpoint == "" {
+		return nil
+	}
+	spans := make([]interface{}, 0, len(t.spans))
+	for _, span := range t.spans {
+		encoded := map[string]interface{}{
+			"traceId":           t.traceId,
+			"spanId":            span.spanId,
+			"name":              span.Name,
+			"kind":              span.Kind,
+			"startTimeUnixNano": encodeTime(span.Start),
+			"endTimeUnixNano":   encodeTime(span.End),
+			"attributes":        encodeAttributes(span.Attributes),
+		}
+		if span.parent != nil {
+			encoded["parentSpanId"] = span.parent.spanId
+		}
+		spans = append(spans, encoded)
+	}
+	return e.post(e.tracesEndpoint, map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": encodeAttributes(e.resource)},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": scopeName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+}
+
+// ExportSummary exports the values in _s_ as OpenTelemetry gauges.
+func (e *Exporter) ExportSummary(s *summary.Summary) error {
+	if e.metricsEndpoint == "" {
+		return nil
+	}
+	gauges := []struct {
+		name       string
+		unit       string
+		value      float64
+		attributes map[string]interface{}
+	}{
+		{"networkquality.download.rate", "By/s", s.DownloadRateBps, nil},
+		{"networkquality.download.flows", "{connection}", float64(s.DownloadFlows), nil},
+		{"networkquality.upload.rate", "By/s", s.UploadRateBps, nil},
+		{"networkquality.upload.flows", "{connection}", float64(s.UploadFlows), nil},
+		{"networkquality.probe.self.p90", "s", s.SelfProbeP90, nil},
+		{"networkquality.probe.foreign.p90", "s", s.ForeignProbeP90, nil},
+		{
+			"networkquality.rpm",
+			"{round_trip}/min",
+			s.RPM,
+			map[string]interface{}{"responsiveness": s.Responsiveness},
+		},
+	}
+	metrics := make([]interface{}, 0, len(gauges))
+	for _, gauge := range gauges {
+		dataPoint := map[string]interface{}{
+			"timeUnixNano": encodeTime(s.Time),
+			"asDouble":     gauge.value,
+		}
+		if gauge.attributes != nil {
+			dataPoint["attributes"] = encodeAttributes(gauge.attributes)
+		}
+		metrics = append(metrics, map[string]interface{}{
+			"name": gauge.name,
+			"unit": gauge.unit,
+			"gauge": map[string]interface{}{
+				"dataPoints": []interface{}{dataPoint},
+			},
+		})
+	}
+	return e.post(e.metricsEndpoint, map[string]interface{}{
+		"resourceMetrics": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": encodeAttributes(e.resource)},
+				"scopeMetrics": []interface{}{
+					map[string]interface{}{
+						"scope":   map[string]interface{}{"name": scopeName},
+						"metrics": metrics,
+					},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,141 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+// collector records what was exported to it (by path).
+func collector(t *testing.T, received map[string]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Api-Key") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payload := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("The payload of %s is not JSON: %v", r.URL.Path, err)
+		}
+		received[r.URL.Path] = payload
+	}))
+}
+
+// field follows _path_ (of keys and indices) through the decoded JSON _value_.
+func field(value interface{}, path ...interface{}) interface{} {
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			object, _ := value.(map[string]interface{})
+			value = object[key]
+		case int:
+			array, _ := value.([]interface{})
+			if key >= len(array) {
+				return nil
+			}
+			value = array[key]
+		}
+	}
+	return value
+}
+
+func TestExport(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	server := collector(t, received)
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Api-Key=secret")
+	t.Setenv("OTEL_SERVICE_NAME", "test")
+
+	exporter, err := NewExporterFromEnvironment()
+	if err != nil || exporter == nil {
+		t.Fatalf("Expected an exporter but got %v", err)
+	}
+
+	start := time.Unix(100, 0)
+	trace := NewTrace()
+	test := trace.AddSpan("test", SpanKindInternal, nil, start, start.Add(time.Second), map[string]interface{}{"rpm": 1234.0})
+	trace.AddSpan("probe", SpanKindClient, test, start, start.Add(time.Millisecond), map[string]interface{}{"probe.cached": false})
+	if err := exporter.ExportTrace(trace); err != nil {
+		t.Fatalf("Could not export the trace: %v", err)
+	}
+	scopeSpans := field(received["/v1/traces"], "resourceSpans", 0, "scopeSpans", 0)
+	if field(scopeSpans, "scope", "name") != scopeName {
+		t.Fatalf("Expected the scope %s but got %v", scopeName, scopeSpans)
+	}
+	testSpan, probeSpan := field(scopeSpans, "spans", 0), field(scopeSpans, "spans", 1)
+	if field(testSpan, "name") != "test" ||
+		field(testSpan, "startTimeUnixNano") != "100000000000" ||
+		field(testSpan, "attributes", 0, "value", "doubleValue") != 1234.0 {
+		t.Fatalf("Unexpected span of the test: %v", testSpan)
+	}
+	if field(probeSpan, "parentSpanId") != field(testSpan, "spanId") ||
+		field(probeSpan, "traceId") != field(testSpan, "traceId") ||
+		field(probeSpan, "kind") != float64(SpanKindClient) ||
+		field(probeSpan, "attributes", 0, "value", "boolValue") != false {
+		t.Fatalf("Unexpected span of the probe: %v", probeSpan)
+	}
+	resource := field(received["/v1/traces"], "resourceSpans", 0, "resource", "attributes", 0)
+	if field(resource, "key") != "service.name" || field(resource, "value", "stringValue") != "test" {
+		t.Fatalf("Expected the service name test but got %v", resource)
+	}
+
+	s := &summary.Summary{Time: start, RPM: 1234, Responsiveness: "High"}
+	if err := exporter.ExportSummary(s); err != nil {
+		t.Fatalf("Could not export the summary: %v", err)
+	}
+	metrics := field(received["/v1/metrics"], "resourceMetrics", 0, "scopeMetrics", 0, "metrics").([]interface{})
+	rpm := field(metrics, len(metrics)-1)
+	if field(rpm, "name") != "networkquality.rpm" ||
+		field(rpm, "gauge", "dataPoints", 0, "asDouble") != 1234.0 ||
+		field(rpm, "gauge", "dataPoints", 0, "timeUnixNano") != "100000000000" ||
+		field(rpm, "gauge", "dataPoints", 0, "attributes", 0, "value", "stringValue") != "High" {
+		t.Fatalf("Unexpected RPM gauge: %v", rpm)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "")
+	exporter, _ = NewExporterFromEnvironment()
+	if err := exporter.ExportSummary(s); err == nil {
+		t.Fatalf("Expected the collector's refusal to be an error")
+	}
+}
+
+func TestNewExporterFromEnvironment(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if exporter, err := NewExporterFromEnvironment(); exporter != nil || err != nil {
+		t.Fatalf("Expected no exporter without an endpoint but got %v (%v)", exporter, err)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4318/traces")
+	for _, protocol := range []string{"grpc", "http/protobuf"} {
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", protocol)
+		if exporter, err := NewExporterFromEnvironment(); exporter != nil || err == nil {
+			t.Fatalf("Expected %s not to be supported but got %v", protocol, exporter)
+		}
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if exporter, err := NewExporterFromEnvironment(); exporter != nil || err != nil {
+		t.Fatalf("Expected no exporter when the SDK is disabled but got %v (%v)", exporter, err)
+	}
+}
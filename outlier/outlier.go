@@ -0,0 +1,91 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package outlier filters the round-trip times of the probes with robust statistics
+// before their percentiles are calculated, so that a rare hiccup (e.g., a DNS lookup
+// or a scheduling delay that takes seconds) does not move the headline numbers.
+package outlier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// Keep every value (the default).
+	None = "none"
+	// Leave out the values that are outliers.
+	Discard = "discard"
+	// Replace the values that are outliers with the limit beyond which they are.
+	Winsorize = "winsorize"
+)
+
+var Names = []string{None, Discard, Winsorize}
+
+// The factor that scales the median absolute deviation of normally distributed values
+// to their standard deviation (so that k MADs are roughly k standard deviations).
+const madScale = 1.4826
+
+// Check makes sure that _method_ is one of Names.
+func Check(method string) error {
+	for _, name := range Names {
+		if method == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown outlier filter %s (use one of %s)", method, strings.Join(Names, ", "))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// Filter applies _method_ to the values that are more than _k_ (scaled) median
+// absolute deviations away from the median of _values_. It returns the values that are
+// left (without changing _values_) and how many were discarded or winsorized. When
+// (more than) half the values are the same, nothing counts as an outlier.
+func Filter(values []float64, method string, k float64) ([]float64, int) {
+	if method == None || len(values) == 0 {
+		return values, 0
+	}
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - center)
+	}
+	limit := k * madScale * median(deviations)
+	if limit == 0 {
+		return values, 0
+	}
+	filtered, count := make([]float64, 0, len(values)), 0
+	for _, value := range values {
+		if math.Abs(value-center) <= limit {
+			filtered = append(filtered, value)
+			continue
+		}
+		count++
+		if method == Winsorize {
+			filtered = append(filtered, math.Max(center-limit, math.Min(value, center+limit)))
+		}
+	}
+	return filtered, count
+}
@@ -0,0 +1,43 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package outlier
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	values := []float64{0.010, 0.011, 0.012, 0.010, 0.011, 0.013, 0.012, 3.0}
+	if filtered, count := Filter(values, Discard, 3); count != 1 || len(filtered) != 7 {
+		t.Fatalf("Expected to discard 1 value but discarded %d (%v).", count, filtered)
+	}
+	filtered, count := Filter(values, Winsorize, 3)
+	if count != 1 || len(filtered) != len(values) {
+		t.Fatalf("Expected to winsorize 1 value but winsorized %d (%v).", count, filtered)
+	}
+	if filtered[7] >= 0.1 || values[7] != 3.0 {
+		t.Fatalf("Expected the outlier to be winsorized (without changing the values) but got %v.", filtered[7])
+	}
+	if _, count := Filter(values, None, 3); count != 0 {
+		t.Fatalf("Expected no filtering but filtered %d values.", count)
+	}
+	if _, count := Filter([]float64{1, 1, 1, 5}, Discard, 3); count != 0 {
+		t.Fatalf("Expected no filtering without deviation but filtered %d values.", count)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check("trim"); err == nil {
+		t.Fatalf("Accepted an unknown outlier filter.")
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pcap
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// packetSource reads the packets of an interface from a (cooked) packet socket, so
+// that they start at their IP header whatever the interface's link type.
+type packetSource struct {
+	fd       int
+	loopback bool
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
+
+func openSource(iface string) (source, error) {
+	netInterface, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, err
+	}
+	address := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: netInterface.Index}
+	if err := unix.Bind(fd, address); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	// Wake up regularly to notice the end of the capture.
+	timeout := unix.NsecToTimeval(int64(readTimeout))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &packetSource{fd: fd, loopback: netInterface.Flags&net.FlagLoopback != 0}, nil
+}
+
+func (s *packetSource) read(packet []byte) (int, error) {
+	length, from, err := unix.Recvfrom(s.fd, packet, unix.MSG_TRUNC)
+	if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// On the loopback interface, every packet is seen leaving and arriving.
+	if linkLayer, ok := from.(*unix.SockaddrLinklayer); ok && s.loopback && linkLayer.Pkttype == unix.PACKET_OUTGOING {
+		return 0, nil
+	}
+	return length, nil
+}
+
+func (s *packetSource) close() error {
+	return unix.Close(s.fd)
+}
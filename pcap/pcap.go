@@ -0,0 +1,188 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package pcap captures the packets of a test's own connections to a pcap file (so
+// that debugging does not need a separately coordinated tcpdump). The packets are
+// stamped with the same clock as the data loggers' records.
+package pcap
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// The file is in the pcap format with nanosecond time stamps and every packet starts
+// at its IP header (LINKTYPE_RAW).
+const (
+	magic    = 0xa1b23c4d
+	linkType = 101
+)
+
+// How long reading a packet may block before the end of the capture is noticed.
+const readTimeout = 100 * time.Millisecond
+
+// source is where the packets come from (see openSource).
+type source interface {
+	// read reads the next packet into _packet_ and returns its length (which may be
+	// larger than _packet_) or 0 (and no error) when nothing arrived for a while.
+	read(packet []byte) (int, error)
+	close() error
+}
+
+type Capture struct {
+	source    source
+	file      *os.File
+	writer    *bufio.Writer
+	endpoints map[netip.AddrPort]bool
+	packets   int
+	err       error
+	done      chan struct{}
+}
+
+// Start captures the packets on _iface_ from or to any of _endpoints_ (the servers'
+// addresses and ports) into the file at _path_ until _ctx_ is canceled.
+func Start(ctx context.Context, iface string, path string, endpoints []netip.AddrPort) (*Capture, error) {
+	source, err := openSource(iface)
+	if err != nil {
+		return nil, fmt.Errorf("Could not capture on %s: %v", iface, err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		source.close()
+		return nil, fmt.Errorf("Could not create the capture file %s: %v", path, err)
+	}
+	capture := &Capture{
+		source:    source,
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		endpoints: make(map[netip.AddrPort]bool),
+		done:      make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		capture.endpoints[endpoint] = true
+	}
+
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], magic)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], uint32(constants.PcapSnapLength))
+	binary.LittleEndian.PutUint32(header[20:], linkType)
+	capture.writer.Write(header)
+
+	go capture.run(ctx)
+	return capture, nil
+}
+
+// endpoints returns the source and the destination of the TCP or UDP _packet_ (which
+// starts at its IP header).
+func endpoints(packet []byte) (netip.AddrPort, netip.AddrPort, bool) {
+	if len(packet) < 1 {
+		return netip.AddrPort{}, netip.AddrPort{}, false
+	}
+	var source, destination netip.Addr
+	var protocol byte
+	var transport []byte
+	switch packet[0] >> 4 {
+	case 4:
+		headerLength := int(packet[0]&0x0f) * 4
+		if len(packet) < 20 || len(packet) < headerLength {
+			return netip.AddrPort{}, netip.AddrPort{}, false
+		}
+		source, _ = netip.AddrFromSlice(packet[12:16])
+		destination, _ = netip.AddrFromSlice(packet[16:20])
+		protocol, transport = packet[9], packet[headerLength:]
+	case 6:
+		// Extension headers are not followed (the test's connections do not use them).
+		if len(packet) < 40 {
+			return netip.AddrPort{}, netip.AddrPort{}, false
+		}
+		source, _ = netip.AddrFromSlice(packet[8:24])
+		destination, _ = netip.AddrFromSlice(packet[24:40])
+		protocol, transport = packet[6], packet[40:]
+	default:
+		return netip.AddrPort{}, netip.AddrPort{}, false
+	}
+	if (protocol != 6 && protocol != 17) || len(transport) < 4 {
+		return netip.AddrPort{}, netip.AddrPort{}, false
+	}
+	return netip.AddrPortFrom(source, binary.BigEndian.Uint16(transport[0:])),
+		netip.AddrPortFrom(destination, binary.BigEndian.Uint16(transport[2:])),
+		true
+}
+
+func (c *Capture) matches(packet []byte) bool {
+	source, destination, ok := endpoints(packet)
+	return ok && (c.endpoints[source] || c.endpoints[destination])
+}
+
+func (c *Capture) write(packet []byte, length int, now time.Time) {
+	captured := length
+	if captured > len(packet) {
+		captured = len(packet)
+	}
+	if captured > constants.PcapSnapLength {
+		captured = constants.PcapSnapLength
+	}
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(now.Nanosecond()))
+	binary.LittleEndian.PutUint32(record[8:], uint32(captured))
+	binary.LittleEndian.PutUint32(record[12:], uint32(length))
+	c.writer.Write(record)
+	c.writer.Write(packet[:captured])
+	c.packets++
+}
+
+func (c *Capture) run(ctx context.Context) {
+	defer close(c.done)
+	packet := make([]byte, 65536)
+	for ctx.Err() == nil {
+		length, err := c.source.read(packet)
+		if err != nil {
+			c.err = err
+			break
+		}
+		// The length is that of the whole packet, which may be longer than the buffer
+		// (e.g., with GRO or BIG TCP).
+		received := length
+		if received > len(packet) {
+			received = len(packet)
+		}
+		if length > 0 && c.matches(packet[:received]) {
+			c.write(packet, length, time.Now())
+		}
+	}
+	c.source.close()
+	if err := c.writer.Flush(); err != nil && c.err == nil {
+		c.err = err
+	}
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// Wait waits for the capture to end and returns the number of packets that it
+// captured.
+func (c *Capture) Wait() (int, error) {
+	<-c.done
+	return c.packets, c.err
+}
@@ -0,0 +1,87 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pcap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	// A TCP segment from 192.0.2.1:443 to 198.51.100.7:50000.
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = 6
+	copy(packet[12:], []byte{192, 0, 2, 1})
+	copy(packet[16:], []byte{198, 51, 100, 7})
+	copy(packet[20:], []byte{0x01, 0xbb, 0xc3, 0x50})
+
+	source, destination, ok := endpoints(packet)
+	if !ok || source != netip.MustParseAddrPort("192.0.2.1:443") ||
+		destination != netip.MustParseAddrPort("198.51.100.7:50000") {
+		t.Fatalf("Expected 192.0.2.1:443 and 198.51.100.7:50000 but got %v and %v (%v).", source, destination, ok)
+	}
+
+	// ICMP has no ports.
+	packet[9] = 1
+	if _, _, ok := endpoints(packet); ok {
+		t.Fatalf("Expected no endpoints for an ICMP packet.")
+	}
+}
+
+// oversized is a source of one packet that is longer than the buffer (as with GRO).
+type oversized struct {
+	reads int
+}
+
+func (o *oversized) read(packet []byte) (int, error) {
+	o.reads++
+	if o.reads > 1 {
+		return 0, errors.New("done")
+	}
+	packet[0] = 0x45
+	packet[9] = 6
+	copy(packet[12:], []byte{192, 0, 2, 1})
+	copy(packet[16:], []byte{198, 51, 100, 7})
+	copy(packet[20:], []byte{0x01, 0xbb, 0xc3, 0x50})
+	return len(packet) + 1000, nil
+}
+
+func (o *oversized) close() error {
+	return nil
+}
+
+func TestRunOversizedPacket(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "test.pcap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture := &Capture{
+		source:    &oversized{},
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		endpoints: map[netip.AddrPort]bool{netip.MustParseAddrPort("192.0.2.1:443"): true},
+		done:      make(chan struct{}),
+	}
+	capture.run(context.Background())
+	if packets, _ := capture.Wait(); packets != 1 {
+		t.Fatalf("Expected the oversized packet to be captured but got %d packets.", packets)
+	}
+}
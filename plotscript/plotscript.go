@@ -0,0 +1,92 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package plotscript writes gnuplot scripts that plot the data in the files written by
+// the data loggers.
+package plotscript
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// The files written by the data loggers. The script refers to them by their base names
+// so it has to be kept in the same directory. Files whose names end in .gz are
+// decompressed (with gzip) as they are plotted.
+type Files struct {
+	Self               string
+	Foreign            string
+	DownloadThroughput string
+	UploadThroughput   string
+}
+
+var script = template.Must(template.New("plot").Parse(`# Generated by Go Responsiveness. Run with: gnuplot {{.Script}}
+set terminal pngcairo size 1200,900
+set output '{{.Output}}'
+
+set datafile separator ','
+set xdata time
+set timefmt '%m-%d-%Y-%H-%M-%S'
+set format x '%H:%M:%S'
+set grid
+set key outside top right
+
+set multiplot layout 2,1 title 'Go Responsiveness'
+
+set title 'Throughput'
+set ylabel 'Mbps'
+plot '{{.DownloadThroughput}}' every ::1 using 1:($2*8/1000000) with lines title 'Download', \
+     '{{.UploadThroughput}}' every ::1 using 1:($2*8/1000000) with lines title 'Upload'
+
+set title 'Probe latency'
+set ylabel 'ms'
+plot '{{.Self}}' every ::1 using 1:($3*1000) with points pointtype 7 pointsize 0.5 title 'Self (load-generating connections)', \
+     '{{.Foreign}}' every ::1 using 1:($3*1000) with points pointtype 7 pointsize 0.5 title 'Foreign (new connections)'
+
+unset multiplot
+`))
+
+// Write writes a script to _filename_ that plots the throughput and the probe latency
+// over time into a PNG (named like the script).
+func Write(filename string, files Files) error {
+	output := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".png"
+	dataSource := func(dataFilename string) string {
+		base := filepath.Base(dataFilename)
+		if strings.HasSuffix(base, ".gz") {
+			return "< gzip -dc " + base
+		}
+		return base
+	}
+	parameters := map[string]string{
+		"Script":             filepath.Base(filename),
+		"Output":             filepath.Base(output),
+		"Self":               dataSource(files.Self),
+		"Foreign":            dataSource(files.Foreign),
+		"DownloadThroughput": dataSource(files.DownloadThroughput),
+		"UploadThroughput":   dataSource(files.UploadThroughput),
+	}
+
+	destination, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("Could not create the plot script %s: %v", filename, err)
+	}
+	defer destination.Close()
+	if err := script.Execute(destination, parameters); err != nil {
+		return fmt.Errorf("Could not write the plot script %s: %v", filename, err)
+	}
+	return nil
+}
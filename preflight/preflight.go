@@ -0,0 +1,103 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package preflight quickly checks the resources of the test server before the test
+// puts load on them, so that a server that is unreachable or misconfigured fails the
+// test right away with a clear message (rather than with a test of zero rates).
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// Result is what the check of a single resource found.
+type Result struct {
+	Name     string `json:"name"`
+	Url      string `json:"url"`
+	Status   int    `json:"status"`
+	Protocol string `json:"protocol"`
+	// The size of the resource in bytes (-1 when the server does not tell).
+	Size   int64  `json:"size"`
+	Server string `json:"server,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Ok returns whether the resource can be used for the test.
+func (r Result) Ok() bool {
+	return r.Error == ""
+}
+
+func (r Result) String() string {
+	if !r.Ok() {
+		return fmt.Sprintf("%s (%s): %s", r.Name, r.Url, r.Error)
+	}
+	description := fmt.Sprintf("%s (%s): %s", r.Name, r.Url, r.Protocol)
+	if r.Size >= 0 {
+		description += fmt.Sprintf(", %d bytes", r.Size)
+	}
+	if r.Server != "" {
+		description += ", server " + r.Server
+	}
+	return description
+}
+
+// Check checks the small, large and upload resources of _urls_.
+func Check(urls config.ConfigUrls) []Result {
+	return []Result{
+		check("small", "GET", urls.SmallUrl),
+		check("large", "GET", urls.LargeUrl),
+		check("upload", "POST", urls.UploadUrl),
+	}
+}
+
+// check requests the resource at _rawUrl_ with _method_ and only reads the headers of
+// the response (the large resource may be large).
+func check(name string, method string, rawUrl string) Result {
+	result := Result{Name: name, Url: rawUrl, Size: -1}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		result.Error = fmt.Sprintf("Invalid URL: %v", err)
+		return result
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), constants.PreflightTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, method, rawUrl, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	config.Prepare(request)
+	request.Header.Set("Accept-Encoding", "identity")
+	response, err := config.NewClient(parsed.Scheme).Do(request)
+	if err != nil {
+		result.Error = fmt.Sprintf("Unreachable: %v", err)
+		return result
+	}
+	defer response.Body.Close()
+	result.Status = response.StatusCode
+	result.Protocol = response.Proto
+	result.Size = response.ContentLength
+	result.Server = strings.TrimSpace(response.Header.Get("Server"))
+	if response.StatusCode/100 != 2 {
+		result.Error = fmt.Sprintf("The server responded with %s", response.Status)
+	}
+	return result
+}
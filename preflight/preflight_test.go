@@ -0,0 +1,50 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package preflight
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/config"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/upload" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Server", "test/1.0")
+		fmt.Fprint(w, "1234")
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	results := Check(config.ConfigUrls{
+		SmallUrl:  server.URL + "/small",
+		LargeUrl:  server.URL + "/large",
+		UploadUrl: server.URL + "/upload",
+	})
+	if small := results[0]; !small.Ok() || small.Protocol != "HTTP/2.0" || small.Size != 4 || small.Server != "test/1.0" {
+		t.Fatalf("Expected a usable small resource but got %v", small)
+	}
+	if upload := results[2]; upload.Ok() || upload.Status != http.StatusNotFound {
+		t.Fatalf("Expected an unusable upload resource but got %v", upload)
+	}
+}
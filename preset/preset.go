@@ -0,0 +1,41 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package preset names the public servers whose configuration URLs are well known, so
+// that nobody has to look them up (or type them) to run a test against them.
+package preset
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	Apple = "apple"
+)
+
+var Names = []string{Apple}
+
+// Url returns the URL of the configuration of the server called _name_.
+func Url(name string) (string, error) {
+	switch name {
+	case Apple:
+		return "https://mensura.cdn-apple.com/api/v1/gm/config", nil
+	}
+	return "", fmt.Errorf(
+		"Unknown server %s (use one of %s)",
+		name,
+		strings.Join(Names, ", "),
+	)
+}
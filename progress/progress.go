@@ -0,0 +1,149 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// A Phase of a test and the maximum amount of time that it is budgeted to take.
+type Phase struct {
+	Name   string
+	Budget time.Duration
+}
+
+// Estimator estimates the overall progress of a test from the time budgets of its
+// phases. Within a phase, progress is proportional to the share of the phase's budget
+// that has elapsed; when a phase ends early, progress jumps to the end of that phase.
+type Estimator struct {
+	lock       sync.Mutex
+	phases     []Phase
+	current    int
+	phaseStart time.Time
+	complete   bool
+}
+
+func NewEstimator(start time.Time, phases ...Phase) *Estimator {
+	return &Estimator{phases: phases, current: 0, phaseStart: start}
+}
+
+// EnterPhase marks the beginning of the phase named _name_ (and the end of all the
+// phases before it). Entering an unknown phase does nothing.
+func (e *Estimator) EnterPhase(name string, now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for i, phase := range e.phases {
+		if phase.Name == name && i >= e.current {
+			e.current = i
+			e.phaseStart = now
+			return
+		}
+	}
+}
+
+// Complete marks the end of the test.
+func (e *Estimator) Complete() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.complete = true
+}
+
+// Phase returns the name of the current phase.
+func (e *Estimator) Phase() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.complete || len(e.phases) == 0 {
+		return "complete"
+	}
+	return e.phases[e.current].Name
+}
+
+// Percent returns the estimated progress (between 0 and 100) at _now_. It only reaches
+// 100 once the test is complete.
+func (e *Estimator) Percent(now time.Time) float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.complete {
+		return 100
+	}
+
+	total := time.Duration(0)
+	elapsed := time.Duration(0)
+	for i, phase := range e.phases {
+		total += phase.Budget
+		if i < e.current {
+			elapsed += phase.Budget
+		} else if i == e.current {
+			inPhase := now.Sub(e.phaseStart)
+			if inPhase > phase.Budget {
+				inPhase = phase.Budget
+			}
+			if inPhase > 0 {
+				elapsed += inPhase
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	percent := 100 * elapsed.Seconds() / total.Seconds()
+	if percent > 99 {
+		percent = 99
+	}
+	return percent
+}
+
+// Report writes the estimated progress to _w_ every _interval_ until _ctx_ is
+// canceled. Each report is on its own line (e.g., "Progress: 42% (saturation)") so
+// that it is easy for wrappers to parse.
+func Report(ctx context.Context, e *Estimator, interval time.Duration, w io.Writer) {
+	ReportSteps(ctx, e, interval, 0, w)
+}
+
+// ReportSteps is like Report but only reports when the progress has reached another
+// multiple of _step_ percent (or the phase has changed) since the last report, which
+// keeps the output brief (e.g., for screen readers). Every check is reported when _step_
+// is 0.
+func ReportSteps(
+	ctx context.Context,
+	e *Estimator,
+	interval time.Duration,
+	step float64,
+	w io.Writer,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastStep, lastPhase := -1.0, ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			percent, phase := e.Percent(now), e.Phase()
+			if step > 0 {
+				if math.Floor(percent/step) == lastStep && phase == lastPhase {
+					continue
+				}
+				lastStep, lastPhase = math.Floor(percent/step), phase
+			}
+			fmt.Fprintf(w, "Progress: %.0f%% (%s)\n", percent, phase)
+		}
+	}
+}
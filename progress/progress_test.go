@@ -0,0 +1,56 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimatorPhases(t *testing.T) {
+	start := time.Now()
+	estimator := NewEstimator(
+		start,
+		Phase{"saturation", 20 * time.Second},
+		Phase{"collection", 10 * time.Second},
+		Phase{"drain", 10 * time.Second},
+	)
+
+	if percent := estimator.Percent(start.Add(10 * time.Second)); percent != 25 {
+		t.Fatalf("Expected 25%% progress halfway through the first phase but got %v.", percent)
+	}
+	// Overrunning a phase's budget does not count for more than the budget.
+	if percent := estimator.Percent(start.Add(30 * time.Second)); percent != 50 {
+		t.Fatalf("Expected 50%% progress after overrunning the first phase but got %v.", percent)
+	}
+
+	// Ending a phase early jumps to the end of that phase.
+	estimator.EnterPhase("drain", start.Add(5*time.Second))
+	if percent := estimator.Percent(start.Add(5 * time.Second)); percent != 75 {
+		t.Fatalf("Expected 75%% progress at the start of the last phase but got %v.", percent)
+	}
+	if estimator.Phase() != "drain" {
+		t.Fatalf("Expected to be in the drain phase but in %s.", estimator.Phase())
+	}
+
+	// Never 100% until complete.
+	if percent := estimator.Percent(start.Add(time.Minute)); percent != 99 {
+		t.Fatalf("Expected 99%% progress before completion but got %v.", percent)
+	}
+	estimator.Complete()
+	if percent := estimator.Percent(start.Add(time.Minute)); percent != 100 {
+		t.Fatalf("Expected 100%% progress after completion but got %v.", percent)
+	}
+}
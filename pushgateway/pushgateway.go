@@ -0,0 +1,130 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pushgateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/summary"
+	"github.com/network-quality/goresponsiveness/useragent"
+)
+
+// The text exposition format is understood by the Prometheus Pushgateway. The
+// metrics are only gauges and the output is terminated with "# EOF" so that it is
+// also valid OpenMetrics (to a Pushgateway, the terminator is just a comment).
+const ContentType = "text/plain; version=0.0.4"
+
+type metric struct {
+	name   string
+	labels string
+	help   string
+	value  float64
+}
+
+func metrics(s *summary.Summary) []metric {
+	return []metric{
+		{
+			"networkquality_download_bytes_per_second",
+			"",
+			"Download goodput.",
+			s.DownloadRateBps,
+		},
+		{
+			"networkquality_download_flows",
+			"",
+			"Load-generating download connections.",
+			float64(s.DownloadFlows),
+		},
+		{
+			"networkquality_upload_bytes_per_second",
+			"",
+			"Upload goodput.",
+			s.UploadRateBps,
+		},
+		{
+			"networkquality_upload_flows",
+			"",
+			"Load-generating upload connections.",
+			float64(s.UploadFlows),
+		},
+		{
+			"networkquality_self_probe_p90_seconds",
+			"",
+			"P90 of the self-probe round-trip times.",
+			s.SelfProbeP90,
+		},
+		{
+			"networkquality_foreign_probe_p90_seconds",
+			"",
+			"P90 of the foreign-probe round-trip times.",
+			s.ForeignProbeP90,
+		},
+		{
+			"networkquality_rpm",
+			"",
+			"Responsiveness (round trips per minute).",
+			s.RPM,
+		},
+		{
+			// Like an info metric: the class is in the label.
+			"networkquality_responsiveness",
+			fmt.Sprintf("{class=%q}", s.Responsiveness),
+			"Class of the responsiveness (Low, Medium or High).",
+			1,
+		},
+		{
+			"networkquality_last_run_timestamp_seconds",
+			"",
+			"Time at which the run finished.",
+			float64(s.Time.UnixNano()) / 1e9,
+		},
+	}
+}
+
+// Format renders _s_ in the text exposition format.
+func Format(s *summary.Summary) string {
+	var builder strings.Builder
+	for _, m := range metrics(s) {
+		fmt.Fprintf(&builder, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&builder, "# TYPE %s gauge\n", m.name)
+		fmt.Fprintf(&builder, "%s%s %v\n", m.name, m.labels, m.value)
+	}
+	builder.WriteString("# EOF\n")
+	return builder.String()
+}
+
+// Push sends the metrics for _s_ to _url_. For a Pushgateway, _url_ should include
+// the grouping key (e.g., http://pushgateway:9091/metrics/job/networkquality).
+func Push(url string, s *summary.Summary) error {
+	client := &http.Client{Timeout: constants.PushTimeout}
+	request, err := http.NewRequest("POST", url, strings.NewReader(Format(s)))
+	if err != nil {
+		return fmt.Errorf("Could not create the push request for %s: %v", url, err)
+	}
+	request.Header.Set("Content-Type", ContentType)
+	useragent.Apply(request)
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not push metrics to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Pushing metrics to %s failed: %s", url, resp.Status)
+	}
+	return nil
+}
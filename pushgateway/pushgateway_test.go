@@ -0,0 +1,61 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pushgateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+func TestFormat(t *testing.T) {
+	s := &summary.Summary{Time: time.Unix(10, 0), RPM: 1234, Responsiveness: "High", DownloadFlows: 8}
+	formatted := Format(s)
+	for _, expected := range []string{
+		"# TYPE networkquality_rpm gauge\nnetworkquality_rpm 1234\n",
+		"networkquality_download_flows 8\n",
+		"networkquality_responsiveness{class=\"High\"} 1\n",
+		"networkquality_last_run_timestamp_seconds 10\n",
+	} {
+		if !strings.Contains(formatted, expected) {
+			t.Fatalf("Formatted metrics do not contain %q:\n%s", expected, formatted)
+		}
+	}
+	if !strings.HasSuffix(formatted, "# EOF\n") {
+		t.Fatalf("Formatted metrics are not terminated by # EOF.")
+	}
+}
+
+func TestPush(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}))
+	defer server.Close()
+
+	s := &summary.Summary{RPM: 42}
+	if err := Push(server.URL+"/metrics/job/test", s); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if received != Format(s) {
+		t.Fatalf("Pushed %q but expected %q", received, Format(s))
+	}
+}
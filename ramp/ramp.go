@@ -0,0 +1,231 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package ramp holds the policies that decide when to add load-generating connections
+// and when the network is saturated, so that alternatives can be compared in the same
+// harness.
+package ramp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+const (
+	Default      = "default"
+	BinarySearch = "binary-search"
+	AdditiveHold = "additive-hold"
+)
+
+var Names = []string{Default, BinarySearch, AdditiveHold}
+
+// Observation is what an algorithm learns about the connections at the end of every
+// (one-second) interval.
+type Observation struct {
+	Connections int
+	// The moving average of the throughput (bytes per second).
+	Throughput float64
+	// The percent change of the moving average since the previous interval.
+	MovingAverageDelta float64
+	// The number of intervals since connections were last added.
+	IntervalsSinceIncrease uint64
+	// Whether the recent moving averages are consistent with each other.
+	Stable bool
+	// Whether the standard deviation of the recent moving averages is small compared
+	// to their average.
+	LowDeviation bool
+	// The P90 of the round-trip times of the recent self probes (0 when there were
+	// none).
+	Latency time.Duration
+}
+
+type Decision struct {
+	// The number of connections to add.
+	Add uint64
+	// Whether the network is saturated (at which point the algorithm is not consulted
+	// again).
+	Saturated bool
+}
+
+type Algorithm interface {
+	Name() string
+	// The number of connections with which to start.
+	Initial() uint64
+	Decide(o Observation) Decision
+}
+
+// New creates (a fresh instance of) the algorithm called _name_.
+func New(name string) (Algorithm, error) {
+	switch name {
+	case Default:
+		return &defaultAlgorithm{}, nil
+	case BinarySearch:
+		return &holdAndCompare{
+			name: BinarySearch,
+			next: func(connections int) uint64 { return uint64(connections) },
+		}, nil
+	case AdditiveHold:
+		return &holdAndCompare{
+			name: AdditiveHold,
+			next: func(int) uint64 { return 1 },
+		}, nil
+	}
+	return nil, fmt.Errorf(
+		"Unknown ramp algorithm %s (use one of %s)",
+		name,
+		strings.Join(Names, ", "),
+	)
+}
+
+// latencyBudget stops another algorithm from adding connections once the loaded latency
+// exceeds the budget: the network counts as saturated at that point, which makes the
+// throughput of the test the throughput that is achievable while staying (about) within
+// the budget. Because connections cannot be closed during a test, the count is the one
+// at which the budget was first exceeded.
+type latencyBudget struct {
+	Algorithm
+	budget time.Duration
+}
+
+// WithLatencyBudget limits _algorithm_ to adding connections while the loaded latency
+// is within _budget_.
+func WithLatencyBudget(algorithm Algorithm, budget time.Duration) Algorithm {
+	return &latencyBudget{algorithm, budget}
+}
+
+func (l *latencyBudget) Name() string {
+	return fmt.Sprintf("%s (latency budget %v)", l.Algorithm.Name(), l.budget)
+}
+
+func (l *latencyBudget) Decide(o Observation) Decision {
+	if o.Latency > l.budget {
+		return Decision{Saturated: true}
+	}
+	return l.Algorithm.Decide(o)
+}
+
+// targetRate stops another algorithm from adding connections once the throughput is
+// (about) at a target: the connections are paced to the target, so more of them would
+// not raise it. The network counts as saturated once the throughput is there and
+// stable, which starts the measurement of the responsiveness at the target rather than
+// at the capacity of the network. Below the target, the other algorithm decides (so
+// that a network that cannot reach the target is still saturated).
+type targetRate struct {
+	Algorithm
+	target float64
+}
+
+// WithTargetRate makes _algorithm_ hold the connections once the throughput is within
+// constants.TargetRateTolerance (percent) of _target_ (bytes per second).
+func WithTargetRate(algorithm Algorithm, target float64) Algorithm {
+	return &targetRate{algorithm, target}
+}
+
+func (t *targetRate) Name() string {
+	return fmt.Sprintf("%s (target %.3f Mbps)", t.Algorithm.Name(), t.target*8/1e6)
+}
+
+func (t *targetRate) Decide(o Observation) Decision {
+	if o.Throughput >= t.target*(1-constants.TargetRateTolerance/100) {
+		return Decision{Saturated: o.Stable}
+	}
+	return t.Algorithm.Decide(o)
+}
+
+// deviationStability makes another algorithm judge the stability of the throughput by
+// the standard deviation of the recent moving averages (see spec.Draft02).
+type deviationStability struct {
+	Algorithm
+}
+
+// WithDeviationStability makes _algorithm_ take the throughput as stable when the
+// standard deviation of the recent moving averages is small.
+func WithDeviationStability(algorithm Algorithm) Algorithm {
+	return &deviationStability{algorithm}
+}
+
+func (d *deviationStability) Decide(o Observation) Decision {
+	o.Stable = o.LowDeviation
+	return d.Algorithm.Decide(o)
+}
+
+// defaultAlgorithm adds a fixed number of connections whenever the throughput is still
+// growing (but not more often than the moving averages can settle) and declares
+// saturation when connections were added recently and the throughput is stable anyway.
+type defaultAlgorithm struct{}
+
+func (*defaultAlgorithm) Name() string {
+	return Default
+}
+
+func (*defaultAlgorithm) Initial() uint64 {
+	return constants.StartingNumberOfLoadGeneratingConnections
+}
+
+func (*defaultAlgorithm) Decide(o Observation) Decision {
+	if o.MovingAverageDelta > constants.InstabilityDelta {
+		if o.IntervalsSinceIncrease > constants.MovingAverageStabilitySpan {
+			return Decision{Add: constants.AdditiveNumberOfLoadGeneratingConnections}
+		}
+		return Decision{}
+	}
+	if o.IntervalsSinceIncrease < constants.MovingAverageStabilitySpan && o.Stable {
+		return Decision{Saturated: true}
+	}
+	return Decision{Add: constants.AdditiveNumberOfLoadGeneratingConnections}
+}
+
+// holdAndCompare adds connections (as many as _next_ says) and then holds the count
+// long enough for the moving averages to settle. When the throughput has grown since
+// the last addition, it adds more; otherwise, once the throughput is stable, the
+// network is saturated.
+//
+// Adding as many connections as there are (i.e., doubling) is the search for the upper
+// bound of a binary search. Because connections cannot be closed during a test, the
+// search cannot go on to narrow down the count between the bounds: it settles on the
+// count at which doubling stopped helping.
+type holdAndCompare struct {
+	name                 string
+	next                 func(connections int) uint64
+	throughputAtIncrease float64
+}
+
+func (h *holdAndCompare) Name() string {
+	return h.name
+}
+
+func (h *holdAndCompare) Initial() uint64 {
+	return constants.StartingNumberOfLoadGeneratingConnections
+}
+
+func (h *holdAndCompare) Decide(o Observation) Decision {
+	if o.IntervalsSinceIncrease < constants.MovingAverageStabilitySpan {
+		return Decision{}
+	}
+	if utilities.SignedPercentDifference(
+		o.Throughput,
+		h.throughputAtIncrease,
+	) > constants.InstabilityDelta {
+		h.throughputAtIncrease = o.Throughput
+		return Decision{Add: h.next(o.Connections)}
+	}
+	if o.Stable {
+		return Decision{Saturated: true}
+	}
+	return Decision{}
+}
@@ -0,0 +1,101 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ramp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBinarySearchDoubles(t *testing.T) {
+	algorithm, err := New(BinarySearch)
+	if err != nil {
+		t.Fatalf("Could not create the algorithm: %v", err)
+	}
+	if decision := algorithm.Decide(Observation{Connections: 4, Throughput: 100}); decision.Add != 0 {
+		t.Fatalf("Added connections before the hold time was over: %v", decision)
+	}
+	settled := Observation{Connections: 4, Throughput: 100, IntervalsSinceIncrease: 4}
+	if decision := algorithm.Decide(settled); decision.Add != 4 {
+		t.Fatalf("Expected to double the connections but got %v", decision)
+	}
+	settled.Connections = 8
+	if decision := algorithm.Decide(settled); decision.Add != 0 || decision.Saturated {
+		t.Fatalf("Expected to wait for stability but got %v", decision)
+	}
+	settled.Stable = true
+	if decision := algorithm.Decide(settled); !decision.Saturated {
+		t.Fatalf("Expected saturation but got %v", decision)
+	}
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	if _, err := New("fastest"); err == nil {
+		t.Fatalf("Created an unknown algorithm.")
+	}
+}
+
+func TestLatencyBudget(t *testing.T) {
+	algorithm, _ := New(Default)
+	algorithm = WithLatencyBudget(algorithm, 100*time.Millisecond)
+	growing := Observation{
+		Connections:            4,
+		MovingAverageDelta:     50,
+		IntervalsSinceIncrease: 5,
+		Latency:                50 * time.Millisecond,
+	}
+	if decision := algorithm.Decide(growing); decision.Add == 0 {
+		t.Fatalf("Expected to add connections within the budget but got %v", decision)
+	}
+	growing.Latency = 150 * time.Millisecond
+	if decision := algorithm.Decide(growing); !decision.Saturated {
+		t.Fatalf("Expected saturation beyond the budget but got %v", decision)
+	}
+}
+
+func TestTargetRate(t *testing.T) {
+	algorithm, _ := New(Default)
+	algorithm = WithTargetRate(algorithm, 1e6)
+	below := Observation{
+		Connections:            4,
+		Throughput:             5e5,
+		MovingAverageDelta:     50,
+		IntervalsSinceIncrease: 5,
+	}
+	if decision := algorithm.Decide(below); decision.Add == 0 {
+		t.Fatalf("Expected to add connections below the target but got %v", decision)
+	}
+	atTarget := Observation{Connections: 8, Throughput: 9.8e5, MovingAverageDelta: 50, IntervalsSinceIncrease: 5}
+	if decision := algorithm.Decide(atTarget); decision.Add != 0 || decision.Saturated {
+		t.Fatalf("Expected to hold the connections at the target but got %v", decision)
+	}
+	atTarget.Stable = true
+	if decision := algorithm.Decide(atTarget); !decision.Saturated {
+		t.Fatalf("Expected saturation at the stable target but got %v", decision)
+	}
+}
+
+func TestDeviationStability(t *testing.T) {
+	algorithm, _ := New(Default)
+	algorithm = WithDeviationStability(algorithm)
+	settled := Observation{Connections: 4, IntervalsSinceIncrease: 1, Stable: true}
+	if decision := algorithm.Decide(settled); decision.Saturated {
+		t.Fatalf("Expected to ignore the sequential increases but got %v", decision)
+	}
+	settled.LowDeviation = true
+	if decision := algorithm.Decide(settled); !decision.Saturated {
+		t.Fatalf("Expected saturation but got %v", decision)
+	}
+}
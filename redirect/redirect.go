@@ -0,0 +1,181 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package redirect follows the redirects of the test server itself rather than leaving
+// them to the HTTP client: it limits how many there may be and records each of them
+// (with how long it took), so that they can be reported rather than silently followed
+// (or failed on).
+package redirect
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// How many redirects a request may take.
+var limit = constants.DefaultMaxRedirects
+
+// SetLimit sets how many redirects a request may take (0 for none).
+func SetLimit(redirects int) {
+	limit = redirects
+}
+
+// The servers (host:port) that are known to be foreign (true) or to be the test
+// server (false) (see MarkForeign and MarkOrigin).
+var (
+	foreign     = map[string]bool{}
+	foreignLock sync.RWMutex
+)
+
+// Address returns the server (host:port) of _u_: its port is that of its scheme unless
+// it has one.
+func Address(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// MarkForeign marks the server of _u_ as one that a server redirected to from another
+// server: what is meant for the test server only (the credentials, the Host override
+// and the server name override) must not go to it. A server marked with MarkOrigin
+// stays the test server.
+func MarkForeign(u *url.URL) {
+	foreignLock.Lock()
+	defer foreignLock.Unlock()
+	addr := Address(u)
+	if _, known := foreign[addr]; !known {
+		foreign[addr] = true
+	}
+}
+
+// MarkOrigin marks the server of _u_ as the test server (that of a test URL as
+// configured), even if it was marked with MarkForeign.
+func MarkOrigin(u *url.URL) {
+	foreignLock.Lock()
+	defer foreignLock.Unlock()
+	foreign[Address(u)] = false
+}
+
+// IsForeign returns whether the server at _addr_ (host:port) was marked with
+// MarkForeign (and not with MarkOrigin).
+func IsForeign(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	foreignLock.RLock()
+	defer foreignLock.RUnlock()
+	return foreign[net.JoinHostPort(strings.ToLower(host), port)]
+}
+
+// A Hop is a single redirect.
+type Hop struct {
+	Url      string
+	Location string
+	Status   int
+	Duration time.Duration
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Follow requests _rawUrl_ (with _method_) through _client_ and follows the redirects
+// (up to the limit). _prepare_ (if not nil) prepares each of the requests (e.g., adds
+// headers). Like net/http's own client, it does not send the Authorization header (or
+// an overridden Host) to any host other than that of _rawUrl_ and, after a 301, 302 or
+// 303, it turns any method but GET and HEAD into a GET. It marks every other server
+// that it is redirected to with MarkForeign before it requests it. It returns the final
+// response, whose body the caller must close, and the redirects that led to it.
+func Follow(
+	client *http.Client,
+	method string,
+	rawUrl string,
+	prepare func(*http.Request),
+) (*http.Response, []Hop, error) {
+	manual := *client
+	manual.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	hops := make([]Hop, 0)
+	origin := ""
+	originAddr := ""
+	for {
+		request, err := http.NewRequest(method, rawUrl, nil)
+		if err != nil {
+			return nil, hops, err
+		}
+		if len(hops) == 0 {
+			origin = request.URL.Host
+			originAddr = Address(request.URL)
+		} else if Address(request.URL) != originAddr {
+			MarkForeign(request.URL)
+		}
+		if prepare != nil {
+			prepare(request)
+		}
+		if !strings.EqualFold(request.URL.Host, origin) {
+			// The credentials and the Host override are for the test server only.
+			request.Header.Del("Authorization")
+			request.Host = ""
+		}
+		start := time.Now()
+		response, err := manual.Do(request)
+		if err != nil {
+			return nil, hops, err
+		}
+		location := response.Header.Get("Location")
+		if !isRedirect(response.StatusCode) || location == "" {
+			return response, hops, nil
+		}
+		response.Body.Close()
+		next, err := request.URL.Parse(location)
+		if err != nil {
+			return nil, hops, fmt.Errorf("%s redirects to an invalid URL (%s): %v", rawUrl, location, err)
+		}
+		hops = append(hops, Hop{
+			Url:      rawUrl,
+			Location: next.String(),
+			Status:   response.StatusCode,
+			Duration: time.Since(start),
+		})
+		if len(hops) > limit {
+			return nil, hops, fmt.Errorf("%s redirects more than %d times", hops[0].Url, limit)
+		}
+		rawUrl = next.String()
+		switch response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+			if method != "GET" && method != "HEAD" {
+				method = "GET"
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package redirect
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+func TestFollow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusTemporaryRedirect)
+		default:
+			fmt.Fprint(w, "done")
+		}
+	}))
+	defer server.Close()
+
+	response, hops, err := Follow(server.Client(), "GET", server.URL+"/a", nil)
+	if err != nil {
+		t.Fatalf("Could not follow the redirects: %v", err)
+	}
+	response.Body.Close()
+	if len(hops) != 2 || hops[1].Location != server.URL+"/c" || hops[1].Status != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected two redirects to %s/c but got %v", server.URL, hops)
+	}
+
+	SetLimit(1)
+	defer SetLimit(constants.DefaultMaxRedirects)
+	if _, _, err := Follow(server.Client(), "GET", server.URL+"/a", nil); err == nil {
+		t.Fatalf("Followed more redirects than the limit")
+	}
+}
+
+func TestFollowToAnotherHost(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.Host == "override" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" || r.Host != "override" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, other.URL+"/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	prepare := func(request *http.Request) {
+		request.Header.Set("Authorization", "Bearer secret")
+		request.Host = "override"
+	}
+	response, hops, err := Follow(server.Client(), "GET", server.URL+"/", prepare)
+	if err != nil {
+		t.Fatalf("Could not follow the redirects: %v", err)
+	}
+	response.Body.Close()
+	if len(hops) != 1 || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the credentials only at the original host but got %d after %v", response.StatusCode, hops)
+	}
+}
+
+func TestMarkForeign(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	response, _, err := Follow(server.Client(), "GET", server.URL+"/", nil)
+	if err != nil {
+		t.Fatalf("Could not follow the redirects: %v", err)
+	}
+	response.Body.Close()
+	otherUrl, _ := url.Parse(other.URL)
+	serverUrl, _ := url.Parse(server.URL)
+	if !IsForeign(Address(otherUrl)) || IsForeign(Address(serverUrl)) {
+		t.Fatalf("Expected only the server that was redirected to to be foreign")
+	}
+
+	MarkOrigin(otherUrl)
+	MarkForeign(otherUrl)
+	if IsForeign(Address(otherUrl)) {
+		t.Fatalf("Marked the test server as foreign")
+	}
+}
+
+func TestFollowPostAsGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			http.Redirect(w, r, "/target", http.StatusFound)
+		case "/temporary":
+			http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+		default:
+			fmt.Fprint(w, r.Method)
+		}
+	}))
+	defer server.Close()
+
+	for path, method := range map[string]string{"/found": "GET", "/temporary": "POST"} {
+		response, _, err := Follow(server.Client(), "POST", server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("Could not follow the redirects of %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != method {
+			t.Fatalf("Expected a POST to %s to end as a %s but it ended as a %s", path, method, body)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package resourceusage
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// ResourceUsage describes the resources that the client itself consumed. Knowing
+// them helps to tell whether a measurement was limited by the client's hardware
+// rather than by the network.
+type ResourceUsage struct {
+	// Whether the platform could report peak RSS and CPU time.
+	Available        bool    `json:"available"`
+	PeakRSSBytes     uint64  `json:"peak_rss_bytes"`
+	UserCPUSeconds   float64 `json:"user_cpu_seconds"`
+	SystemCPUSeconds float64 `json:"system_cpu_seconds"`
+	GCPauseSeconds   float64 `json:"gc_pause_seconds"`
+	GCCount          uint32  `json:"gc_count"`
+}
+
+// Collect gathers the resource usage of the process up to now.
+func Collect() ResourceUsage {
+	usage := ResourceUsage{}
+	collectPlatformUsage(&usage)
+
+	memStats := runtime.MemStats{}
+	runtime.ReadMemStats(&memStats)
+	usage.GCPauseSeconds = time.Duration(memStats.PauseTotalNs).Seconds()
+	usage.GCCount = memStats.NumGC
+	return usage
+}
+
+// ProcessCPUSeconds returns the CPU time that the client has used so far (and whether
+// the platform could report it) without the cost of collecting the rest (reading the
+// memory statistics stops the world).
+func ProcessCPUSeconds() (float64, bool) {
+	usage := ResourceUsage{}
+	collectPlatformUsage(&usage)
+	return usage.CPUSeconds(), usage.Available
+}
+
+func (usage ResourceUsage) CPUSeconds() float64 {
+	return usage.UserCPUSeconds + usage.SystemCPUSeconds
+}
+
+func (usage ResourceUsage) String() string {
+	platformUsage := "peak RSS and CPU time unavailable on this platform"
+	if usage.Available {
+		platformUsage = fmt.Sprintf(
+			"peak RSS %.1f MB, CPU %.2f s (%.2f s user, %.2f s system)",
+			float64(usage.PeakRSSBytes)/float64(1024*1024),
+			usage.CPUSeconds(),
+			usage.UserCPUSeconds,
+			usage.SystemCPUSeconds,
+		)
+	}
+	return fmt.Sprintf(
+		"%s, GC pauses %.3f s over %d collections",
+		platformUsage,
+		usage.GCPauseSeconds,
+		usage.GCCount,
+	)
+}
@@ -0,0 +1,40 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package resourceusage
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func collectPlatformUsage(usage *ResourceUsage) {
+	rusage := unix.Rusage{}
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &rusage); err != nil {
+		return
+	}
+	usage.Available = true
+	// Everyone but Darwin reports the maximum RSS in kilobytes.
+	usage.PeakRSSBytes = uint64(rusage.Maxrss)
+	if runtime.GOOS != "darwin" {
+		usage.PeakRSSBytes *= 1024
+	}
+	usage.UserCPUSeconds = time.Duration(rusage.Utime.Nano()).Seconds()
+	usage.SystemCPUSeconds = time.Duration(rusage.Stime.Nano()).Seconds()
+}
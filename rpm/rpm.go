@@ -16,31 +16,63 @@ package rpm
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/auth"
+	"github.com/network-quality/goresponsiveness/clock"
 	"github.com/network-quality/goresponsiveness/constants"
 	"github.com/network-quality/goresponsiveness/datalogger"
 	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/events"
 	"github.com/network-quality/goresponsiveness/extendedstats"
+	"github.com/network-quality/goresponsiveness/hostoverride"
 	"github.com/network-quality/goresponsiveness/lgc"
 	"github.com/network-quality/goresponsiveness/ma"
+	"github.com/network-quality/goresponsiveness/ramp"
 	"github.com/network-quality/goresponsiveness/stats"
 	"github.com/network-quality/goresponsiveness/traceable"
+	"github.com/network-quality/goresponsiveness/useragent"
 	"github.com/network-quality/goresponsiveness/utilities"
 	"golang.org/x/net/http2"
 )
 
+// The clock by which the probers and the data collection keep time.
+var clk clock.Clock = clock.Real{}
+
+// SetClock makes the probers and the data collection keep time by _c_ (e.g., a fake
+// clock in tests and simulations).
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// How many probe and throughput data points of each kind the data collection keeps in
+// memory (0 for all of them).
+var sampleLimit = 0
+
+// SetSampleLimit makes the data collection keep only the most recent _limit_ probe and
+// throughput data points of each kind in memory (0 for all of them). The older ones are
+// dropped, which bounds the memory of long tests; they remain in the data logs.
+func SetSampleLimit(limit int) {
+	sampleLimit = limit
+}
+
 func addFlows(
 	ctx context.Context,
 	toAdd uint64,
 	lgcs *[]lgc.LoadGeneratingConnection,
 	lgcGenerator func() lgc.LoadGeneratingConnection,
+	emitter *events.Emitter,
 	debug debug.DebugLevel,
 ) {
 	for i := uint64(0); i < toAdd; i++ {
@@ -52,6 +84,7 @@ func addFlows(
 			)
 			return
 		}
+		emitter.ConnectionAdded((*lgcs)[len(*lgcs)-1].ClientId())
 	}
 }
 
@@ -59,19 +92,106 @@ type ProbeConfiguration struct {
 	URL        string
 	DataLogger datalogger.DataLogger[ProbeDataPoint]
 	Interval   time.Duration
+	// When set, every probe's URL gets a unique query parameter (see
+	// utilities.AddCacheBuster).
+	CacheBusting bool
+	// When set, the probes take turns using these URLs (e.g., the URL on each of the
+	// servers across which the test is sharded) instead of URL.
+	ShardURLs []string
+	// The method of the probes' requests: GET (the default when empty) or HEAD.
+	Method string
+	// When positive, the probes request (with a Range header) only this many bytes of
+	// the resource at the URL.
+	Size int64
+	// The fraction (between 0 and 1) of the foreign probes that try to resume the TLS
+	// session of an earlier foreign probe rather than doing a full handshake. (Go's TLS
+	// client does not support 0-RTT, so resumed probes do not send early data.)
+	ResumptionFraction float64
+	// How self probes measure the latency (one of the SelfProbe* mechanisms; the
+	// default when empty is SelfProbeRequest).
+	Mechanism string
+}
+
+// The mechanisms with which self probes can measure the latency on the load-generating
+// connections.
+const (
+	// A request for the small object (as in the specification).
+	SelfProbeRequest = "request"
+	// An HTTP/2 PING, which the server's HTTP/2 stack answers without involving its
+	// application.
+	SelfProbePing = "ping"
+	// Both of the above (the RPM is calculated with the requests).
+	SelfProbeBoth = "both"
+)
+
+// resumes determines whether the probeCount-th foreign probe tries to resume a TLS
+// session. The probes that do are spread evenly.
+func (pc *ProbeConfiguration) resumes(probeCount int) bool {
+	return int(float64(probeCount+1)*pc.ResumptionFraction) >
+		int(float64(probeCount)*pc.ResumptionFraction)
+}
+
+// probeUrl determines the URL to use for the probeCount-th probe.
+func (pc *ProbeConfiguration) probeUrl(probeCount int) string {
+	url := pc.URL
+	if len(pc.ShardURLs) != 0 {
+		url = pc.ShardURLs[probeCount%len(pc.ShardURLs)]
+	}
+	if pc.CacheBusting {
+		return utilities.AddCacheBuster(url)
+	}
+	return url
 }
 
 type ProbeDataPoint struct {
-	Time           time.Time     `Description:"Time of the generation of the data point."                    Formatter:"Format"  FormatterArgument:"01-02-2006-15-04-05.000"`
-	RoundTripCount uint64        `Description:"The number of round trips measured by this data point."`
-	Duration       time.Duration `Description:"The duration for this measurement."                           Formatter:"Seconds"`
-	TCPRtt         time.Duration `Description:"The underlying connection's RTT at probe time."               Formatter:"Seconds"`
-	TCPCwnd        uint32        `Description:"The underlying connection's congestion window at probe time."`
+	Time              time.Time     `Description:"Time of the generation of the data point."                    Formatter:"Format"  FormatterArgument:"01-02-2006-15-04-05.000"`
+	RoundTripCount    uint64        `Description:"The number of round trips measured by this data point."`
+	Duration          time.Duration `Description:"The duration for this measurement."                           Formatter:"Seconds"`
+	FirstByteDuration time.Duration `Description:"The duration until the first byte of the response."           Formatter:"Seconds"`
+	BodySize          uint64        `Description:"The size of the body of the response (bytes)."`
+	TCPRtt            time.Duration `Description:"The underlying connection's RTT at probe time."               Formatter:"Seconds"`
+	TCPCwnd           uint32        `Description:"The underlying connection's congestion window at probe time."`
+	PhaseCrossing     bool          `Description:"Whether the response arrived after the phase in which the probe was sent ended."`
+	Cached            bool          `Description:"Whether the response appears to have come from a cache rather than the server."`
+	Resumed           bool          `Description:"Whether the probe's connection resumed the TLS session of an earlier connection."`
+	Error             string        `Description:"Why the probe failed (see ClassifyProbeError); empty when it succeeded."`
+	Ping              bool          `Description:"Whether the probe was an HTTP/2 PING rather than a request."`
+	Prober            string        `Description:"The prober that sent the probe (foreign, download, upload or self)."`
+	Sequence          uint64        `Description:"The number of the probe among those that its prober sent (from 1)."`
 }
 
 type ThroughputDataPoint struct {
-	Time       time.Time `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
-	Throughput float64   `Description:"Instantaneous throughput (b/s)."`
+	Time        time.Time `Description:"Time of the generation of the data point." Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Throughput  float64   `Description:"Instantaneous throughput (b/s)."`
+	Connections int       `Description:"The number of active (neither failed nor stalled) load-generating connections."`
+}
+
+// The reasons for which the ramp (see RampStep) changes the load-generating connections.
+const (
+	// The connections with which the ramp starts.
+	RampInitial = "initial"
+	// The ramp algorithm decided to add connections.
+	RampAdd = "add"
+	// Connections stalled and were replaced.
+	RampStall = "stall"
+	// The ramp algorithm decided that the network is saturated (nothing is added).
+	RampSaturated = "saturated"
+)
+
+// RampStep is a point on the timeline of the ramp: when connections were added (or
+// saturation was declared), why, and what the ramp algorithm observed at the time.
+type RampStep struct {
+	Time   time.Time
+	Reason string
+	// The number of connections before the step and the number that it added.
+	Connections int
+	Added       uint64
+	// What the ramp algorithm observed (see ramp.Observation); zero for the initial
+	// connections and for stalls.
+	Throughput         float64
+	MovingAverageDelta float64
+	Stable             bool
+	Latency            time.Duration
 }
 
 type SelfDataCollectionResult struct {
@@ -79,6 +199,19 @@ type SelfDataCollectionResult struct {
 	LGCs                []lgc.LoadGeneratingConnection
 	ProbeDataPoints     []ProbeDataPoint
 	LoggingContinuation func()
+	// Why the load could not be generated (nil when it could be).
+	Error error
+	// The number of times that the throughput could not be sampled on time.
+	MissedDeadlines int
+	// The number of load-generating connections that stalled (and were replaced).
+	Stalls int
+	// The timeline of the ramp.
+	Ramp []RampStep
+	// The (moving average of the) throughput every second.
+	Throughput []ThroughputDataPoint
+	// The number of (the oldest) probe and throughput data points that were dropped to
+	// stay within the sample limit.
+	Dropped int
 }
 
 type ProbeType int64
@@ -88,6 +221,22 @@ const (
 	Foreign
 )
 
+// IsCachedResponse determines whether the headers of a probe's response show that it
+// was served from a cache (e.g., a CDN) rather than by the server itself.
+func IsCachedResponse(header http.Header) bool {
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+		return true
+	}
+	for _, cacheHeader := range []string{"X-Cache", "X-Cache-Status", "CF-Cache-Status"} {
+		for _, value := range header.Values(cacheHeader) {
+			if strings.Contains(strings.ToUpper(value), "HIT") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (pt ProbeType) Value() string {
 	if pt == Self {
 		return "Self"
@@ -95,19 +244,82 @@ func (pt ProbeType) Value() string {
 	return "Foreign"
 }
 
+// The reasons for which a probe may fail (besides http-4xx and http-5xx, for error
+// responses from the server).
+const (
+	ProbeErrorDNS            = "dns"
+	ProbeErrorConnect        = "connect"
+	ProbeErrorConnectTimeout = "connect-timeout"
+	ProbeErrorTLS            = "tls"
+	ProbeErrorReadTimeout    = "read-timeout"
+	ProbeErrorCanceled       = "canceled"
+	ProbeErrorCompression    = "compression"
+	ProbeErrorNewConnection  = "new-connection"
+	ProbeErrorOther          = "other"
+)
+
+// ClassifyProbeError determines why a probe failed from the error that its request
+// returned. _connected_ tells whether the probe had a connection at the time (which
+// distinguishes connect timeouts from read timeouts).
+func ClassifyProbeError(err error, connected bool) string {
+	var dnsError *net.DNSError
+	var opError *net.OpError
+	var recordHeaderError tls.RecordHeaderError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+	var timeout interface{ Timeout() bool }
+	switch {
+	case errors.As(err, &dnsError):
+		return ProbeErrorDNS
+	case errors.Is(err, context.Canceled):
+		return ProbeErrorCanceled
+	case errors.As(err, &recordHeaderError),
+		errors.As(err, &unknownAuthorityError),
+		errors.As(err, &hostnameError),
+		errors.As(err, &certificateInvalidError),
+		strings.Contains(err.Error(), "tls:"):
+		return ProbeErrorTLS
+	case errors.As(err, &opError) && opError.Op == "dial":
+		if opError.Timeout() {
+			return ProbeErrorConnectTimeout
+		}
+		return ProbeErrorConnect
+	case errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &timeout) && timeout.Timeout()):
+		if connected {
+			return ProbeErrorReadTimeout
+		}
+		return ProbeErrorConnectTimeout
+	}
+	return ProbeErrorOther
+}
+
+// Probe sends a single probe. The probe's request is bound to _parentProbeCtx_ while
+// _phaseCtx_ delimits the phase in which the probe was sent: when the response arrives
+// after _phaseCtx_ is done, the probe is still attributed to that phase (its data point
+// carries the time at which it was sent) but it is flagged as crossing a phase boundary.
+// When _waitGroup_ is not nil, the caller must have already added this probe to it. A
+// positive _size_ limits the probe to that many bytes of the resource. The probe's data
+// point is labeled with the name of its _prober_ and its _sequence_ number.
 func Probe(
 	parentProbeCtx context.Context,
+	phaseCtx context.Context,
 	waitGroup *sync.WaitGroup,
 	logger datalogger.DataLogger[ProbeDataPoint],
+	emitter *events.Emitter,
 	client *http.Client,
 	probeUrl string,
+	method string,
+	size int64,
 	probeType ProbeType,
+	prober string,
+	sequence uint64,
 	result *chan ProbeDataPoint,
 	debugging *debug.DebugWithPrefix,
 ) error {
 
 	if waitGroup != nil {
-		waitGroup.Add(1)
 		defer waitGroup.Done()
 	}
 
@@ -116,11 +328,14 @@ func Probe(
 	}
 
 	probeId := utilities.GenerateUniqueId()
+	if method == "" {
+		method = "GET"
+	}
 	probeTracer := NewProbeTracer(client, probeType, probeId, debugging)
-	time_before_probe := time.Now()
+	time_before_probe := clk.Now()
 	probe_req, err := http.NewRequestWithContext(
 		httptrace.WithClientTrace(parentProbeCtx, probeTracer.trace),
-		"GET",
+		method,
 		probeUrl,
 		nil,
 	)
@@ -130,23 +345,74 @@ func Probe(
 
 	// Used to disable compression
 	probe_req.Header.Set("Accept-Encoding", "identity")
+	useragent.Apply(probe_req)
+	auth.Apply(probe_req)
+	hostoverride.Apply(probe_req)
+	if size > 0 {
+		probe_req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", size-1))
+	}
+
+	roundTripCount := uint64(1)
+	if probeType == Foreign {
+		roundTripCount = 3
+	}
+	// A failed probe is recorded too (so that failures do not just silently reduce the
+	// number of probes) but it only counts as a failure.
+	fail := func(err error, category string) error {
+		failure := ProbeDataPoint{
+			Time:           time_before_probe,
+			RoundTripCount: roundTripCount,
+			Duration:       clk.Now().Sub(time_before_probe),
+			PhaseCrossing:  phaseCtx.Err() != nil,
+			Error:          category,
+			Prober:         prober,
+			Sequence:       sequence,
+		}
+		debugging.Log("rpm.probe_failed", "type", probeType.Value(), "id", probeId, "category", category, "error", err)
+		if !utilities.IsInterfaceNil(logger) {
+			logger.LogRecord(failure)
+		}
+		*result <- failure
+		return err
+	}
 
 	probe_resp, err := client.Do(probe_req)
 	if err != nil {
-		return err
+		connected := !probeTracer.stats.GetConnectionDoneTime.IsZero()
+		return fail(err, ClassifyProbeError(err, connected))
+	}
+
+	if probe_resp.StatusCode >= 400 {
+		probe_resp.Body.Close()
+		return fail(
+			fmt.Errorf("The server responded with %s", probe_resp.Status),
+			fmt.Sprintf("http-%dxx", probe_resp.StatusCode/100),
+		)
 	}
 
 	// Header.Get returns "" when not set
 	if probe_resp.Header.Get("Content-Encoding") != "" {
-		return fmt.Errorf("Content-Encoding header was set (compression not allowed)")
+		probe_resp.Body.Close()
+		return fail(
+			fmt.Errorf("Content-Encoding header was set (compression not allowed)"),
+			ProbeErrorCompression,
+		)
 	}
 
 	// TODO: Make this interruptable somehow by using _ctx_.
-	_, err = io.ReadAll(probe_resp.Body)
+	var body io.Reader = probe_resp.Body
+	if size > 0 {
+		// In case the server ignores the Range header.
+		body = io.LimitReader(body, size)
+	}
+	received, err := io.ReadAll(body)
 	if err != nil {
-		return err
+		probe_resp.Body.Close()
+		return fail(err, ClassifyProbeError(err, true))
 	}
-	time_after_probe := time.Now()
+	time_after_probe := clk.Now()
+	phaseCrossing := phaseCtx.Err() != nil
+	resumed := probe_resp.TLS != nil && probe_resp.TLS.DidResume
 
 	// Depending on whether we think that Close() requires another RTT (via TCP), we
 	// may need to move this before/after capturing the after time.
@@ -160,45 +426,32 @@ func Probe(
 	// of the values will be 0 (or very small where the time that go takes for delivering callbacks
 	// and doing context switches pokes through). When it is !isSelfProbe then the values will
 	// be significant and we want to add them regardless!
-	totalDelay := probeTracer.GetTLSAndHttpHeaderDelta() + probeTracer.GetHttpDownloadDelta(
-		time_after_probe,
-	) + probeTracer.GetTCPDelta()
+	firstByteDelay := probeTracer.GetTLSAndHttpHeaderDelta() + probeTracer.GetTCPDelta()
+	totalDelay := firstByteDelay + probeTracer.GetHttpDownloadDelta(time_after_probe)
 
-	// We must have reused the connection if we are a self probe!
+	// A self probe must have reused the connection. When the connection went away (a
+	// server may send a GOAWAY at any time, e.g., after a number of requests), the
+	// probe dialed a new one and measured that.
 	if probeType == Self && !probeTracer.stats.ConnectionReused {
-		panic(!probeTracer.stats.ConnectionReused)
+		return fail(
+			fmt.Errorf("The self probe was not sent on an established connection"),
+			ProbeErrorNewConnection,
+		)
 	}
 
-	if debug.IsDebug(debugging.Level) {
-		fmt.Printf(
-			"(%s) (%s Probe %v) sanity vs total: %v vs %v\n",
-			debugging.Prefix,
-			probeType.Value(),
-			probeId,
-			sanity,
-			totalDelay,
-		)
+	debugging.Log("rpm.probe_sanity", "type", probeType.Value(), "id", probeId, "sanity", sanity, "total", totalDelay)
+	// A response that came from a cache measures the distance to the cache and not the
+	// responsiveness of the path to the server. So does one that arrived faster than
+	// physically possible.
+	cached := IsCachedResponse(probe_resp.Header) ||
+		totalDelay < time.Duration(roundTripCount)*constants.MinimumProbeRoundTripDuration
+	if cached {
+		// There is one warning for all of them, once the test is over.
+		debugging.Log("rpm.probe_cached", "type", probeType.Value(), "id", probeId)
 	}
-	roundTripCount := uint64(1)
-	if probeType == Foreign {
-		roundTripCount = 3
+	if phaseCrossing {
+		debugging.Log("rpm.probe_phase_crossing", "type", probeType.Value(), "id", probeId)
 	}
-	// TODO: Careful!!! It's possible that this channel has been closed because the Prober that
-	// started it has been stopped. Writing to a closed channel will cause a panic. It might not
-	// matter because a panic just stops the go thread containing the paniced code and we are in
-	// a go thread that executes only this function.
-	defer func() {
-		isThreadPanicing := recover()
-		if isThreadPanicing != nil && debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) (%s Probe %v) Probe attempted to write to the result channel after its invoker ended (official reason: %v).\n",
-				debugging.Prefix,
-				probeType.Value(),
-				probeId,
-				isThreadPanicing,
-			)
-		}
-	}()
 	tcpRtt := time.Duration(0 * time.Second)
 	tcpCwnd := uint32(0)
 	if extendedstats.ExtendedStatsAvailable() {
@@ -211,23 +464,183 @@ func Probe(
 		}
 	}
 	dataPoint := ProbeDataPoint{
-		Time:           time_before_probe,
-		RoundTripCount: roundTripCount,
-		Duration:       totalDelay,
-		TCPRtt:         tcpRtt,
-		TCPCwnd:        tcpCwnd,
+		Time:              time_before_probe,
+		RoundTripCount:    roundTripCount,
+		Duration:          totalDelay,
+		FirstByteDuration: firstByteDelay,
+		BodySize:          uint64(len(received)),
+		TCPRtt:            tcpRtt,
+		TCPCwnd:           tcpCwnd,
+		PhaseCrossing:     phaseCrossing,
+		Cached:            cached,
+		Resumed:           resumed,
+		Prober:            prober,
+		Sequence:          sequence,
 	}
 	if !utilities.IsInterfaceNil(logger) {
 		logger.LogRecord(dataPoint)
 	}
+	emitter.ProbeResult(events.ProbeResult{
+		Time:           dataPoint.Time,
+		Foreign:        probeType == Foreign,
+		Duration:       dataPoint.Duration,
+		RoundTripCount: dataPoint.RoundTripCount,
+		PhaseCrossing:  dataPoint.PhaseCrossing,
+		Cached:         dataPoint.Cached,
+	})
 	*result <- dataPoint
 	return nil
 }
 
+// PingProbe is a self probe that measures the round-trip time of an HTTP/2 PING on
+// _connection_. Unlike the response to a request, the acknowledgement of a PING does
+// not depend on how fast the server's application is. Its data point is labeled like
+// that of a probe (see Probe).
+func PingProbe(
+	parentProbeCtx context.Context,
+	phaseCtx context.Context,
+	waitGroup *sync.WaitGroup,
+	logger datalogger.DataLogger[ProbeDataPoint],
+	emitter *events.Emitter,
+	connection lgc.LoadGeneratingConnection,
+	prober string,
+	sequence uint64,
+	result *chan ProbeDataPoint,
+	debugging *debug.DebugWithPrefix,
+) error {
+	if waitGroup != nil {
+		defer waitGroup.Done()
+	}
+
+	time_before_probe := clk.Now()
+	duration, err := connection.Ping(parentProbeCtx)
+	dataPoint := ProbeDataPoint{
+		Time:              time_before_probe,
+		RoundTripCount:    1,
+		Duration:          duration,
+		FirstByteDuration: duration,
+		PhaseCrossing:     phaseCtx.Err() != nil,
+		Ping:              true,
+		Prober:            prober,
+		Sequence:          sequence,
+	}
+	if err != nil {
+		dataPoint.Duration = clk.Now().Sub(time_before_probe)
+		dataPoint.FirstByteDuration = 0
+		dataPoint.Error = ClassifyProbeError(err, true)
+		debugging.Log("rpm.ping_failed", "category", dataPoint.Error, "error", err)
+	}
+	if !utilities.IsInterfaceNil(logger) {
+		logger.LogRecord(dataPoint)
+	}
+	if err == nil {
+		emitter.ProbeResult(events.ProbeResult{
+			Time:           dataPoint.Time,
+			Foreign:        false,
+			Duration:       dataPoint.Duration,
+			RoundTripCount: dataPoint.RoundTripCount,
+			PhaseCrossing:  dataPoint.PhaseCrossing,
+		})
+	}
+	*result <- dataPoint
+	return err
+}
+
+// The classes of responsiveness (as Apple's networkQuality reports them).
+const (
+	ResponsivenessLow    = "Low"
+	ResponsivenessMedium = "Medium"
+	ResponsivenessHigh   = "High"
+)
+
+// Combine calculates the RPM from the round-trip time (in seconds) of the self probes
+// and that of the foreign probes, which weigh _selfWeight_ and 1 - _selfWeight_.
+func Combine(selfSeconds float64, foreignSeconds float64, selfWeight float64) float64 {
+	return 60.0 / (selfWeight*selfSeconds + (1-selfWeight)*foreignSeconds)
+}
+
+// ClassifyResponsiveness classifies _rpm_ as low (below _mediumThreshold_), medium or
+// high (from _highThreshold_).
+func ClassifyResponsiveness(rpm float64, mediumThreshold float64, highThreshold float64) string {
+	if rpm >= highThreshold {
+		return ResponsivenessHigh
+	}
+	if rpm >= mediumThreshold {
+		return ResponsivenessMedium
+	}
+	return ResponsivenessLow
+}
+
+// awaitLateProbes implements the policy for probes that are still in flight when their
+// prober is stopped: they are given constants.LateProbeGracePeriod to complete (and
+// are recorded, flagged as crossing a phase boundary, when they do) and are canceled
+// after that. It returns only once every probe is done so that the caller can safely
+// close the channel to which the probes send their results.
+func awaitLateProbes(wg *sync.WaitGroup, cancelProbeRequests context.CancelFunc) {
+	utilities.OrTimeout(func() { wg.Wait() }, constants.LateProbeGracePeriod)
+	cancelProbeRequests()
+	wg.Wait()
+}
+
+// probeCollector gathers the data points that a prober sends so that they are available
+// while the prober is still running.
+type probeCollector struct {
+	lock       sync.Mutex
+	dataPoints []ProbeDataPoint
+	// The number of data points that were dropped to stay within the sample limit.
+	dropped int
+	done    chan struct{}
+}
+
+func collectProbes(points chan ProbeDataPoint) *probeCollector {
+	collector := &probeCollector{dataPoints: make([]ProbeDataPoint, 0), done: make(chan struct{})}
+	go func() {
+		for dataPoint := range points {
+			collector.lock.Lock()
+			var dropped int
+			collector.dataPoints, dropped = utilities.AppendBounded(collector.dataPoints, dataPoint, sampleLimit)
+			collector.dropped += dropped
+			collector.lock.Unlock()
+		}
+		close(collector.done)
+	}()
+	return collector
+}
+
+// latencyP90 is the P90 (like the one that is reported; see
+// utilities.CalculatePercentile) of the round-trip times of the (uncached) probes that
+// were sent since _since_. It is 0 when there are none.
+func (c *probeCollector) latencyP90(since time.Time) time.Duration {
+	c.lock.Lock()
+	roundTrips := make([]int64, 0)
+	for _, dataPoint := range c.dataPoints {
+		if dataPoint.Time.Before(since) || dataPoint.Cached || dataPoint.Error != "" ||
+			dataPoint.RoundTripCount == 0 {
+			continue
+		}
+		roundTrips = append(
+			roundTrips,
+			int64(dataPoint.Duration/time.Duration(dataPoint.RoundTripCount)),
+		)
+	}
+	c.lock.Unlock()
+	if len(roundTrips) == 0 {
+		return 0
+	}
+	return time.Duration(utilities.CalculatePercentile(roundTrips, 90))
+}
+
+// all returns every data point once the prober is done.
+func (c *probeCollector) all() []ProbeDataPoint {
+	<-c.done
+	return c.dataPoints
+}
+
 func ForeignProber(
 	proberCtx context.Context,
 	foreignProbeConfigurationGenerator func() ProbeConfiguration,
 	keyLogger io.Writer,
+	emitter *events.Emitter,
 	debugging *debug.DebugWithPrefix,
 ) (points chan ProbeDataPoint) {
 	points = make(chan ProbeDataPoint)
@@ -237,123 +650,234 @@ func ForeignProber(
 	go func() {
 		wg := sync.WaitGroup{}
 		probeCount := 0
+		// The probes' requests outlive _proberCtx_ so that late-arriving responses are
+		// not simply dropped (see awaitLateProbes).
+		probeRequestCtx, cancelProbeRequests := context.WithCancel(context.Background())
+		// Where the probes that resume TLS sessions find them.
+		sessionCache := tls.NewLRUClientSessionCache(0)
 
 		for proberCtx.Err() == nil {
-			time.Sleep(foreignProbeConfiguration.Interval)
+			<-clk.After(foreignProbeConfiguration.Interval)
+
+			debugging.Trace("rpm.foreign_probe_start", "number", probeCount)
+			resumes := foreignProbeConfiguration.resumes(probeCount)
+			newTransport := func() *http2.Transport {
+				transport := &http2.Transport{}
+				transport.TLSClientConfig = &tls.Config{}
+				transport.DisableCompression = true
+
+				if !utilities.IsInterfaceNil(keyLogger) {
+					debugging.Trace("rpm.key_logger")
+
+					// The presence of a custom TLSClientConfig in a *generic* `transport`
+					// means that go will default to HTTP/1.1 and cowardly avoid HTTP/2:
+					// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L278
+					// Also, it would appear that the API's choice of HTTP vs HTTP2 can
+					// depend on whether the url contains
+					// https:// or http://:
+					// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L74
+					transport.TLSClientConfig.KeyLogWriter = keyLogger
+				}
+				transport.TLSClientConfig.InsecureSkipVerify = true
+				if resumes {
+					transport.TLSClientConfig.ClientSessionCache = sessionCache
+				}
+				lgc.SetTransportDeadlines(transport)
+				return transport
+			}
+
+			client := &http.Client{Transport: &hostoverride.Transport{New: newTransport}}
 
-			if debug.IsDebug(debugging.Level) {
-				fmt.Printf(
-					"(%s) About to start foreign probe number %d!\n",
-					debugging.Prefix,
-					probeCount,
+			probeCount++
+			sequence := uint64(probeCount)
+			url := foreignProbeConfiguration.probeUrl(probeCount)
+			wg.Add(1)
+			go func() {
+				Probe(
+					probeRequestCtx,
+					proberCtx,
+					&wg,
+					foreignProbeConfiguration.DataLogger,
+					emitter,
+					client,
+					url,
+					foreignProbeConfiguration.Method,
+					foreignProbeConfiguration.Size,
+					Foreign,
+					events.Foreign,
+					sequence,
+					&points,
+					debugging,
 				)
-			}
-			transport := http2.Transport{}
-			transport.TLSClientConfig = &tls.Config{}
-
-			if !utilities.IsInterfaceNil(keyLogger) {
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf(
-						"Using an SSL Key Logger for this foreign probe.\n",
-					)
-				}
+				// Every foreign probe gets its own connection; do not let it
+				// linger once the probe is done with it.
+				client.CloseIdleConnections()
+			}()
+		}
+		debugging.Log("rpm.foreign_prober_waiting")
+		awaitLateProbes(&wg, cancelProbeRequests)
+		debugging.Log("rpm.foreign_prober_done")
+		close(points)
+	}()
+	return
+}
 
-				// The presence of a custom TLSClientConfig in a *generic* `transport`
-				// means that go will default to HTTP/1.1 and cowardly avoid HTTP/2:
-				// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L278
-				// Also, it would appear that the API's choice of HTTP vs HTTP2 can
-				// depend on whether the url contains
-				// https:// or http://:
-				// https://github.com/golang/go/blob/7ca6902c171b336d98adbb103d701a013229c806/src/net/http/transport.go#L74
-				transport.TLSClientConfig.KeyLogWriter = keyLogger
-			}
-			transport.TLSClientConfig.InsecureSkipVerify = true
+// SelfProber sends self probes over the load-generating connection that _connection_
+// returns at the time of each probe.
+func SelfProber(
+	proberCtx context.Context,
+	connection func() lgc.LoadGeneratingConnection,
+	selfProbeConfiguration ProbeConfiguration,
+	emitter *events.Emitter,
+	debugging *debug.DebugWithPrefix,
+) (points chan ProbeDataPoint) {
+	points = make(chan ProbeDataPoint)
 
-			client := &http.Client{Transport: &transport}
+	debugging = debug.NewDebugWithPrefix(debugging.Level, debugging.Prefix+" self probe")
+	// The self probers are named after the direction of their load-generating
+	// connections.
+	prober := emitter.Source()
+	if prober == "" {
+		prober = "self"
+	}
 
+	go func() {
+		wg := sync.WaitGroup{}
+		probeCount := 0
+		// With both mechanisms, a PING and a request are sent each time; each of them
+		// gets its own number.
+		sequence := uint64(0)
+		probeRequestCtx, cancelProbeRequests := context.WithCancel(context.Background())
+		for proberCtx.Err() == nil {
+			<-clk.After(selfProbeConfiguration.Interval)
+			debugging.Trace("rpm.self_probe_start", "number", probeCount)
 			probeCount++
+			// The connection to probe changes when the one that was probed goes away (see
+			// LGCollectData).
+			probed := connection()
+			if selfProbeConfiguration.Mechanism == SelfProbePing ||
+				selfProbeConfiguration.Mechanism == SelfProbeBoth {
+				sequence++
+				wg.Add(1)
+				go PingProbe(
+					probeRequestCtx,
+					proberCtx,
+					&wg,
+					selfProbeConfiguration.DataLogger,
+					emitter,
+					probed,
+					prober,
+					sequence,
+					&points,
+					debugging,
+				)
+			}
+			if selfProbeConfiguration.Mechanism == SelfProbePing {
+				continue
+			}
+			sequence++
+			wg.Add(1)
 			go Probe(
+				probeRequestCtx,
 				proberCtx,
 				&wg,
-				foreignProbeConfiguration.DataLogger,
-				client,
-				foreignProbeConfiguration.URL,
-				Foreign,
+				selfProbeConfiguration.DataLogger,
+				emitter,
+				probed.Client(),
+				selfProbeConfiguration.probeUrl(probeCount),
+				selfProbeConfiguration.Method,
+				selfProbeConfiguration.Size,
+				Self,
+				prober,
+				sequence,
 				&points,
 				debugging,
 			)
 		}
-		if debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) Foreign probe driver is going to start waiting for its probes to finish.\n",
-				debugging.Prefix,
-			)
-		}
-		utilities.OrTimeout(func() { wg.Wait() }, 2*time.Second)
-		if debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) Foreign probe driver is done waiting for its probes to finish.\n",
-				debugging.Prefix,
-			)
-		}
+		debugging.Log("rpm.self_prober_waiting")
+		awaitLateProbes(&wg, cancelProbeRequests)
+		debugging.Log("rpm.self_prober_done", "probes", probeCount)
 		close(points)
 	}()
 	return
 }
 
-func SelfProber(
+// IdleSelfProber sends self probes like SelfProber but over a connection of its own
+// that carries no load (e.g., to measure the idle network). The connection is opened
+// (with a request that is not measured) before the first probe; the mechanism of
+// _selfProbeConfiguration_ is ignored because there are only requests. When the server
+// closes the connection (e.g., when it is idle for too long or has carried too many
+// requests), the next probe opens a new one and fails (see ProbeErrorNewConnection).
+func IdleSelfProber(
 	proberCtx context.Context,
-	defaultConnection lgc.LoadGeneratingConnection,
-	altConnections *[]lgc.LoadGeneratingConnection,
 	selfProbeConfiguration ProbeConfiguration,
+	keyLogger io.Writer,
+	emitter *events.Emitter,
 	debugging *debug.DebugWithPrefix,
 ) (points chan ProbeDataPoint) {
 	points = make(chan ProbeDataPoint)
 
 	debugging = debug.NewDebugWithPrefix(debugging.Level, debugging.Prefix+" self probe")
 
+	client := &http.Client{Transport: &hostoverride.Transport{New: func() *http2.Transport {
+		transport := &http2.Transport{}
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		transport.DisableCompression = true
+		if !utilities.IsInterfaceNil(keyLogger) {
+			transport.TLSClientConfig.KeyLogWriter = keyLogger
+		}
+		lgc.SetTransportDeadlines(transport)
+		return transport
+	}}}
+
 	go func() {
+		defer client.CloseIdleConnections()
+
+		warmup, err := http.NewRequestWithContext(proberCtx, "GET", selfProbeConfiguration.URL, nil)
+		if err == nil {
+			useragent.Apply(warmup)
+			auth.Apply(warmup)
+			hostoverride.Apply(warmup)
+			var response *http.Response
+			if response, err = client.Do(warmup); err == nil {
+				_, err = io.Copy(io.Discard, response.Body)
+				response.Body.Close()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not open the connection for the self probes: %v\n", err)
+			close(points)
+			return
+		}
+
 		wg := sync.WaitGroup{}
 		probeCount := 0
+		probeRequestCtx, cancelProbeRequests := context.WithCancel(context.Background())
 		for proberCtx.Err() == nil {
-			time.Sleep(selfProbeConfiguration.Interval)
-			if debug.IsDebug(debugging.Level) {
-				fmt.Printf(
-					"(%s) About to start self probe number %d!\n",
-					debugging.Prefix,
-					probeCount,
-				)
-			}
+			<-clk.After(selfProbeConfiguration.Interval)
+			debugging.Trace("rpm.self_probe_start", "number", probeCount)
 			probeCount++
-			// TODO: We do not yet take in to account that the load-generating connection that we were given
-			// on which to perform measurements might go away during testing. We have access to all the open
-			// load-generating connections (altConnections) to handle this case, but we just aren't using them
-			// yet.
+			wg.Add(1)
 			go Probe(
+				probeRequestCtx,
 				proberCtx,
 				&wg,
 				selfProbeConfiguration.DataLogger,
-				defaultConnection.Client(),
-				selfProbeConfiguration.URL,
+				emitter,
+				client,
+				selfProbeConfiguration.probeUrl(probeCount),
+				selfProbeConfiguration.Method,
+				selfProbeConfiguration.Size,
 				Self,
+				"self",
+				uint64(probeCount),
 				&points,
 				debugging,
 			)
 		}
-		if debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) Self probe driver is going to start waiting for its probes to finish.\n",
-				debugging.Prefix,
-			)
-		}
-		utilities.OrTimeout(func() { wg.Wait() }, 2*time.Second)
-		if debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) Self probe driver is stopping after sending %d probes.\n",
-				debugging.Prefix,
-				probeCount,
-			)
-		}
+		debugging.Log("rpm.self_prober_waiting")
+		awaitLateProbes(&wg, cancelProbeRequests)
+		debugging.Log("rpm.self_prober_done", "probes", probeCount)
 		close(points)
 	}()
 	return
@@ -366,6 +890,9 @@ func LGCollectData(
 	lgcGenerator func() lgc.LoadGeneratingConnection,
 	selfProbeConfigurationGenerator func() ProbeConfiguration,
 	throughputDataLogger datalogger.DataLogger[ThroughputDataPoint],
+	rampAlgorithm ramp.Algorithm,
+	movingAverageWindow int,
+	emitter *events.Emitter,
 	debugging *debug.DebugWithPrefix,
 ) (saturated chan bool, resulted chan SelfDataCollectionResult) {
 	resulted = make(chan SelfDataCollectionResult)
@@ -376,93 +903,130 @@ func LGCollectData(
 
 		lgcs := make([]lgc.LoadGeneratingConnection, 0)
 
+		rampSteps := []RampStep{{Time: clk.Now(), Reason: RampInitial, Added: rampAlgorithm.Initial()}}
+		throughputDataPoints := make([]ThroughputDataPoint, 0)
+		droppedThroughputDataPoints := 0
 		addFlows(
 			networkActivityCtx,
-			constants.StartingNumberOfLoadGeneratingConnections,
+			rampAlgorithm.Initial(),
 			&lgcs,
 			lgcGenerator,
+			emitter,
 			debugging.Level,
 		)
 
 		selfProbeCtx, selfProbeCtxCancel := context.WithCancel(saturationCtx)
-		probeDataPointsChannel := SelfProber(selfProbeCtx,
-			lgcs[0],
-			&lgcs,
-			selfProbeConfigurationGenerator(),
-			debugging,
-		)
+		// Without a configuration for them, there are no self probes (e.g., when only
+		// the throughput is measured).
+		selfProbePoints := make(chan ProbeDataPoint)
+		// The self probes go over the first connection until it goes away (see below).
+		probedLock := sync.Mutex{}
+		probed := lgcs[0]
+		probedConnection := func() lgc.LoadGeneratingConnection {
+			probedLock.Lock()
+			defer probedLock.Unlock()
+			return probed
+		}
+		if selfProbeConfigurationGenerator != nil {
+			selfProbePoints = SelfProber(selfProbeCtx,
+				probedConnection,
+				selfProbeConfigurationGenerator(),
+				emitter,
+				debugging,
+			)
+		} else {
+			close(selfProbePoints)
+		}
+		selfProbes := collectProbes(selfProbePoints)
 
 		previousFlowIncreaseInterval := uint64(0)
 		previousMovingAverage := float64(0)
 
 		// The moving average will contain the average for the last
-		// constants.MovingAverageIntervalCount throughputs.
-		// ie, ma[i] = (throughput[i-3] + throughput[i-2] + throughput[i-1] + throughput[i])/4
+		// movingAverageWindow throughputs.
+		// ie, with the default window of 4,
+		// ma[i] = (throughput[i-3] + throughput[i-2] + throughput[i-1] + throughput[i])/4
 		movingAverage := ma.NewMovingAverage(
-			constants.MovingAverageIntervalCount,
+			movingAverageWindow,
 		)
 
 		// The moving average average will be the average of the last
-		// constants.MovingAverageIntervalCount moving averages.
-		// ie, maa[i] = (ma[i-3] + ma[i-2] + ma[i-1] + ma[i])/4
+		// movingAverageWindow moving averages.
+		// ie, with the default window of 4,
+		// maa[i] = (ma[i-3] + ma[i-2] + ma[i-1] + ma[i])/4
 		movingAverageAverage := ma.NewMovingAverage(
-			constants.MovingAverageIntervalCount,
+			movingAverageWindow,
 		)
 
-		nextSampleStartTime := time.Now().Add(time.Second)
+		nextSampleStartTime := clk.Now().Add(time.Second)
+		var failure error
+		missedDeadlines := 0
+
+		// A connection that stops making progress once the network is saturated would
+		// silently drag the rate down, so it is stopped, left out and replaced.
+		idleIntervals := make(map[uint64]int)
+		stalled := make(map[uint64]bool)
 
 		for currentInterval := uint64(0); true; currentInterval++ {
 
 			// Stop if the client has reached saturation on both sides (up and down)
 			if saturationCtx.Err() != nil {
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf("%v: Stopping data-collection/saturation loop at %v because both sides are saturated.", debugging, time.Now())
+				debugging.Log("rpm.saturation_loop_stop")
+				// Unless we timed out before saturating, in which case the controller
+				// is still waiting to hear that the data is (only) provisional.
+				if !isSaturated {
+					saturated <- false
 				}
 				break
 			}
 
 			// Stop if we timed out! Send back false to indicate that we are returning under duress.
 			if controlCtx.Err() != nil {
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf("%v: Stopping data-collection/saturation loop at %v because our controller told us to do so.", debugging, time.Now())
-				}
+				debugging.Log("rpm.saturation_loop_cancel")
 				saturated <- false
 				break
 			}
 
-			now := time.Now()
+			now := clk.Now()
 			// At each 1-second interval
 			if nextSampleStartTime.Sub(now) > 0 {
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf(
-						"%v: Sleeping until %v\n",
-						debugging,
-						nextSampleStartTime,
-					)
-				}
-				time.Sleep(nextSampleStartTime.Sub(now))
+				debugging.Log("rpm.sleep", "until", nextSampleStartTime)
+				<-clk.After(nextSampleStartTime.Sub(now))
 			} else {
 				fmt.Fprintf(os.Stderr, "Warning: Missed a one-second deadline.\n")
+				missedDeadlines++
 			}
-			nextSampleStartTime = time.Now().Add(time.Second)
+			nextSampleStartTime = clk.Now().Add(time.Second)
 
 			// Compute "instantaneous aggregate" goodput which is the number of
 			// bytes transferred within the last second.
 			var totalTransfer float64 = 0
+			activeConnections := 0
 			allInvalid := true
+			replacements := uint64(0)
 			for i := range lgcs {
+				if stalled[lgcs[i].ClientId()] {
+					continue
+				}
 				if !lgcs[i].IsValid() {
-					if debug.IsDebug(debugging.Level) {
-						fmt.Printf(
-							"%v: Load-generating connection with id %d is invalid ... skipping.\n",
-							debugging,
-							lgcs[i].ClientId(),
-						)
-					}
+					debugging.Log("rpm.lgc_invalid", "id", lgcs[i].ClientId())
 					continue
 				}
 				allInvalid = false
+				activeConnections++
 				currentTransferred, currentInterval := lgcs[i].TransferredInInterval()
+				if currentTransferred == 0 {
+					idleIntervals[lgcs[i].ClientId()]++
+				} else {
+					idleIntervals[lgcs[i].ClientId()] = 0
+				}
+				if isSaturated && idleIntervals[lgcs[i].ClientId()] >= constants.StallIntervalCount {
+					debugging.Log("rpm.lgc_stalled", "id", lgcs[i].ClientId())
+					stalled[lgcs[i].ClientId()] = true
+					replacements++
+					// Should it recover, it would load the link without being counted.
+					go lgcs[i].Drain(0)
+				}
 				// normalize to a second-long interval!
 				instantaneousTransferred := float64(
 					currentTransferred,
@@ -472,20 +1036,51 @@ func LGCollectData(
 				totalTransfer += instantaneousTransferred
 			}
 
+			if replacements > 0 {
+				rampSteps = append(rampSteps, RampStep{
+					Time:        clk.Now(),
+					Reason:      RampStall,
+					Connections: len(lgcs),
+					Added:       replacements,
+				})
+				addFlows(networkActivityCtx, replacements, &lgcs, lgcGenerator, emitter, debugging.Level)
+			}
+
+			// The self probes move on from a connection that stalled or failed to the
+			// first one that is still live.
+			probedLock.Lock()
+			if stalled[probed.ClientId()] || !probed.IsValid() {
+				for i := range lgcs {
+					if !stalled[lgcs[i].ClientId()] && lgcs[i].IsValid() {
+						debugging.Log("rpm.self_probe_moved", "from", probed.ClientId(), "to", lgcs[i].ClientId())
+						probed = lgcs[i]
+						break
+					}
+				}
+			}
+			probedLock.Unlock()
+
 			// For some reason, all the lgcs are invalid. This likely means that
 			// the network/server went away.
 			if allInvalid {
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf(
-						"%v: All lgcs were invalid. Assuming that network/server went away.\n",
-						debugging,
-					)
+				debugging.Log("rpm.all_lgcs_invalid")
+				failure = fmt.Errorf("All load-generating connections failed")
+				for i := range lgcs {
+					if lgcs[i].Failure() != nil {
+						failure = fmt.Errorf("All load-generating connections failed: %v", lgcs[i].Failure())
+						break
+					}
+				}
+				// Let the controller get on with the other direction rather than wait
+				// for a saturation that will never come.
+				if !isSaturated {
+					saturated <- false
 				}
 				break
 			}
 
 			// Compute a moving average of the last
-			// constants.MovingAverageIntervalCount "instantaneous aggregate
+			// movingAverageWindow "instantaneous aggregate
 			// goodput" measurements
 			movingAverage.AddMeasurement(float64(totalTransfer))
 			currentMovingAverage := movingAverage.CalculateAverage()
@@ -495,34 +1090,26 @@ func LGCollectData(
 				previousMovingAverage,
 			)
 
+			throughputDataPoint := ThroughputDataPoint{clk.Now(), currentMovingAverage, activeConnections}
+			var dropped int
+			throughputDataPoints, dropped = utilities.AppendBounded(throughputDataPoints, throughputDataPoint, sampleLimit)
+			droppedThroughputDataPoints += dropped
 			if !utilities.IsInterfaceNil(throughputDataLogger) {
-				throughputDataLogger.LogRecord(
-					ThroughputDataPoint{time.Now(), currentMovingAverage},
-				)
-			}
-
-			if debug.IsDebug(debugging.Level) {
-				fmt.Printf(
-					"%v: Instantaneous goodput: %f MB.\n",
-					debugging,
-					utilities.ToMBps(float64(totalTransfer)),
-				)
-				fmt.Printf(
-					"%v: Previous moving average: %f MB.\n",
-					debugging,
-					utilities.ToMBps(previousMovingAverage),
-				)
-				fmt.Printf(
-					"%v: Current moving average: %f MB.\n",
-					debugging,
-					utilities.ToMBps(currentMovingAverage),
-				)
-				fmt.Printf(
-					"%v: Moving average delta: %f.\n",
-					debugging,
-					movingAverageDelta,
-				)
+				throughputDataLogger.LogRecord(throughputDataPoint)
 			}
+			emitter.ThroughputSample(events.ThroughputSample{
+				Time:           clk.Now(),
+				BytesPerSecond: currentMovingAverage,
+				Connections:    len(lgcs),
+			})
+
+			debugging.Log(
+				"rpm.goodput",
+				"instantaneous_mb", utilities.ToMBps(float64(totalTransfer)),
+				"previous_moving_average_mb", utilities.ToMBps(previousMovingAverage),
+				"moving_average_mb", utilities.ToMBps(currentMovingAverage),
+				"moving_average_delta", movingAverageDelta,
+			)
 
 			previousMovingAverage = currentMovingAverage
 
@@ -538,54 +1125,51 @@ func LGCollectData(
 				continue
 			}
 
-			// If moving average > "previous" moving average + InstabilityDelta:
-			if movingAverageDelta > constants.InstabilityDelta {
-				// Network did not yet reach saturation. If no flows added
-				// within the last 4 seconds, add 4 more flows
-				if intervalsSinceLastFlowIncrease > constants.MovingAverageStabilitySpan {
-					if debug.IsDebug(debugging.Level) {
-						fmt.Printf(
-							"%v: Adding flows because we are unsaturated and waited a while.\n",
-							debugging,
-						)
-					}
-					addFlows(
-						networkActivityCtx,
-						constants.AdditiveNumberOfLoadGeneratingConnections,
-						&lgcs,
-						lgcGenerator,
-						debugging.Level,
-					)
-					previousFlowIncreaseInterval = currentInterval
-				} else {
-					if debug.IsDebug(debugging.Level) {
-						fmt.Printf("%v: We are unsaturated, but it still too early to add anything.\n", debugging)
-					}
-				}
-			} else { // Else, network reached saturation for the current flow count.
-				if debug.IsDebug(debugging.Level) {
-					fmt.Printf("%v: Network reached saturation with current flow count.\n", debugging)
-				}
-				// If new flows added and for 4 seconds the moving average
-				// throughput did not change: network reached stable saturation
-				if intervalsSinceLastFlowIncrease < constants.MovingAverageStabilitySpan && movingAverageAverage.AllSequentialIncreasesLessThan(constants.InstabilityDelta) {
-					if debug.IsDebug(debugging.Level) {
-						fmt.Printf("%v: New flows added within the last four seconds and the moving-average average is consistent!\n", debugging)
-					}
-					// Do not break -- we want to continue looping so that we can continue to log.
-					// See comment at the beginning of the loop for its terminating condition.
-					isSaturated = true
-
-					// But, we do send back a flare that says we are saturated (and happily so)!
-					saturated <- true
-				} else {
-					// Else, add four more flows
-					if debug.IsDebug(debugging.Level) {
-						fmt.Printf("%v: New flows to add to try to increase our saturation!\n", debugging)
-					}
-					addFlows(networkActivityCtx, constants.AdditiveNumberOfLoadGeneratingConnections, &lgcs, lgcGenerator, debugging.Level)
-					previousFlowIncreaseInterval = currentInterval
-				}
+			observation := ramp.Observation{
+				Connections:            len(lgcs),
+				Throughput:             currentMovingAverage,
+				MovingAverageDelta:     movingAverageDelta,
+				IntervalsSinceIncrease: intervalsSinceLastFlowIncrease,
+				Stable: movingAverageAverage.AllSequentialIncreasesLessThan(
+					constants.InstabilityDelta,
+				),
+				LowDeviation: movingAverageAverage.StandardDeviationWithin(
+					constants.InstabilityDelta,
+				),
+				Latency: selfProbes.latencyP90(
+					clk.Now().Add(
+						-time.Duration(movingAverageWindow) * time.Second,
+					),
+				),
+			}
+			decision := rampAlgorithm.Decide(observation)
+			step := RampStep{
+				Time:               clk.Now(),
+				Connections:        len(lgcs),
+				Added:              decision.Add,
+				Throughput:         observation.Throughput,
+				MovingAverageDelta: observation.MovingAverageDelta,
+				Stable:             observation.Stable,
+				Latency:            observation.Latency,
+			}
+			if decision.Saturated {
+				step.Reason, step.Added = RampSaturated, 0
+				rampSteps = append(rampSteps, step)
+				debugging.Log("rpm.ramp_saturated", "algorithm", rampAlgorithm.Name())
+				// Do not break -- we want to continue looping so that we can continue to log.
+				// See comment at the beginning of the loop for its terminating condition.
+				isSaturated = true
+
+				// But, we do send back a flare that says we are saturated (and happily so)!
+				saturated <- true
+			} else if decision.Add > 0 {
+				step.Reason = RampAdd
+				rampSteps = append(rampSteps, step)
+				debugging.Log("rpm.ramp_add_flows", "algorithm", rampAlgorithm.Name(), "flows", decision.Add)
+				addFlows(networkActivityCtx, decision.Add, &lgcs, lgcGenerator, emitter, debugging.Level)
+				previousFlowIncreaseInterval = currentInterval
+			} else {
+				debugging.Log("rpm.ramp_wait", "algorithm", rampAlgorithm.Name())
 			}
 		}
 		// For whatever reason, we are done. Let's report our results.
@@ -596,18 +1180,24 @@ func LGCollectData(
 		// calls to a cancel function are a-okay.
 		selfProbeCtxCancel()
 
-		selfProbeDataPoints := make([]ProbeDataPoint, 0)
-		for dataPoint := range probeDataPointsChannel {
-			selfProbeDataPoints = append(selfProbeDataPoints, dataPoint)
+		selfProbeDataPoints := selfProbes.all()
+		debugging.Log("rpm.self_data_points", "count", len(selfProbeDataPoints))
+		// A direction that failed has no meaningful rate (and maybe no samples at all).
+		rate := float64(0)
+		if failure == nil {
+			rate = movingAverage.CalculateAverage()
 		}
-		if debug.IsDebug(debugging.Level) {
-			fmt.Printf(
-				"(%s) Collected %d self data points\n",
-				debugging.Prefix,
-				len(selfProbeDataPoints),
-			)
+		resulted <- SelfDataCollectionResult{
+			RateBps:         rate,
+			LGCs:            lgcs,
+			ProbeDataPoints: selfProbeDataPoints,
+			Error:           failure,
+			MissedDeadlines: missedDeadlines,
+			Stalls:          len(stalled),
+			Ramp:            rampSteps,
+			Throughput:      throughputDataPoints,
+			Dropped:         selfProbes.dropped + droppedThroughputDataPoints,
 		}
-		resulted <- SelfDataCollectionResult{RateBps: movingAverage.CalculateAverage(), LGCs: lgcs, ProbeDataPoints: selfProbeDataPoints}
 	}()
 	return
 }
@@ -638,9 +1228,7 @@ func (p *ProbeTracer) GetDnsDelta() time.Duration {
 		return time.Duration(0)
 	}
 	delta := p.stats.DnsDoneTime.Sub(p.stats.DnsStartTime)
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): DNS Time: %v\n", p.probeid, delta)
-	}
+	debug.LogTrace(p.debug, "rpm.probe_dns_time", "id", p.probeid, "duration", delta)
 	return delta
 }
 
@@ -649,9 +1237,7 @@ func (p *ProbeTracer) GetTCPDelta() time.Duration {
 		return time.Duration(0)
 	}
 	delta := p.stats.ConnectDoneTime.Sub(p.stats.ConnectStartTime)
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): TCP Connection Time: %v\n", p.probeid, delta)
-	}
+	debug.LogTrace(p.debug, "rpm.probe_tcp_time", "id", p.probeid, "duration", delta)
 	return delta
 }
 
@@ -660,9 +1246,7 @@ func (p *ProbeTracer) GetTLSDelta() time.Duration {
 		panic("There should not be TLS information, but there is.")
 	}
 	delta := time.Duration(0)
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): TLS Time: %v\n", p.probeid, delta)
-	}
+	debug.LogTrace(p.debug, "rpm.probe_tls_time", "id", p.probeid, "duration", delta)
 	return delta
 }
 
@@ -682,9 +1266,7 @@ func (p *ProbeTracer) GetTLSAndHttpHeaderDelta() time.Duration {
 		before = p.stats.GetConnectionDoneTime
 	}
 	delta := p.stats.HttpResponseReadyTime.Sub(before)
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): Http TLS and Header Time: %v\n", p.probeid, delta)
-	}
+	debug.LogTrace(p.debug, "rpm.probe_tls_and_header_time", "id", p.probeid, "duration", delta)
 	return delta
 }
 
@@ -692,18 +1274,11 @@ func (p *ProbeTracer) GetHttpHeaderDelta() time.Duration {
 	panic(
 		"Unusable until TLS tracing support is enabled! Use GetTLSAndHttpHeaderDelta() instead.\n",
 	)
-	delta := p.stats.HttpResponseReadyTime.Sub(utilities.GetSome(p.stats.TLSDoneTime))
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): Http Header Time: %v\n", p.probeid, delta)
-	}
-	return delta
 }
 
 func (p *ProbeTracer) GetHttpDownloadDelta(httpDoneTime time.Time) time.Duration {
 	delta := httpDoneTime.Sub(p.stats.HttpResponseReadyTime)
-	if debug.IsDebug(p.debug) {
-		fmt.Printf("(Probe %v): Http Download Time: %v\n", p.probeid, delta)
-	}
+	debug.LogTrace(p.debug, "rpm.probe_download_time", "id", p.probeid, "duration", delta)
 	return delta
 }
 
@@ -733,14 +1308,7 @@ func (probe *ProbeTracer) SetDnsStartTimeInfo(
 ) {
 	probe.stats.DnsStartTime = now
 	probe.stats.DnsStart = dnsStartInfo
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) DNS Start for Probe %v: %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			dnsStartInfo,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_dns_start", "type", probe.probeType.Value(), "id", probe.ProbeId(), "info", dnsStartInfo)
 }
 
 func (probe *ProbeTracer) SetDnsDoneTimeInfo(
@@ -749,28 +1317,14 @@ func (probe *ProbeTracer) SetDnsDoneTimeInfo(
 ) {
 	probe.stats.DnsDoneTime = now
 	probe.stats.DnsDone = dnsDoneInfo
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) DNS Done for Probe %v: %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.DnsDone,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_dns_done", "type", probe.probeType.Value(), "id", probe.ProbeId(), "info", probe.stats.DnsDone)
 }
 
 func (probe *ProbeTracer) SetConnectStartTime(
 	now time.Time,
 ) {
 	probe.stats.ConnectStartTime = now
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) TCP Start for Probe %v at %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.ConnectStartTime,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_tcp_start", "type", probe.probeType.Value(), "id", probe.ProbeId())
 }
 
 func (probe *ProbeTracer) SetConnectDoneTimeError(
@@ -779,27 +1333,12 @@ func (probe *ProbeTracer) SetConnectDoneTimeError(
 ) {
 	probe.stats.ConnectDoneTime = now
 	probe.stats.ConnectDoneError = err
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) TCP Done for Probe %v (with error %v) @ %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.ConnectDoneError,
-			probe.stats.ConnectDoneTime,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_tcp_done", "type", probe.probeType.Value(), "id", probe.ProbeId(), "error", probe.stats.ConnectDoneError)
 }
 
 func (probe *ProbeTracer) SetGetConnTime(now time.Time) {
 	probe.stats.GetConnectionStartTime = now
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Started getting connection for Probe %v @ %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.GetConnectionStartTime,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_get_conn", "type", probe.probeType.Value(), "id", probe.ProbeId())
 }
 
 func (probe *ProbeTracer) SetGotConnTimeInfo(
@@ -814,32 +1353,17 @@ func (probe *ProbeTracer) SetGotConnTimeInfo(
 			os.Stderr,
 			"A self probe sent used a new connection!\n",
 		)
-	} else if debug.IsDebug(probe.debug) {
-		fmt.Printf("Properly reused a connection when doing a self probe!\n")
-	}
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Got a reused connection for Probe %v at %v with info %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.GetConnectionDoneTime,
-			probe.stats.ConnInfo,
-		)
+	} else if probe.probeType == Self {
+		debug.LogTrace(probe.debug, "rpm.probe_reused_conn", "id", probe.ProbeId())
 	}
+	debug.LogTrace(probe.debug, "rpm.probe_got_conn", "type", probe.probeType.Value(), "id", probe.ProbeId(), "info", probe.stats.ConnInfo)
 }
 
 func (probe *ProbeTracer) SetTLSHandshakeStartTime(
 	now time.Time,
 ) {
 	probe.stats.TLSStartTime = utilities.Some(now)
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Started TLS Handshake for Probe %v @ %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.TLSStartTime,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_tls_start", "type", probe.probeType.Value(), "id", probe.ProbeId())
 }
 
 func (probe *ProbeTracer) SetTLSHandshakeDoneTimeState(
@@ -848,15 +1372,7 @@ func (probe *ProbeTracer) SetTLSHandshakeDoneTimeState(
 ) {
 	probe.stats.TLSDoneTime = utilities.Some(now)
 	probe.stats.TLSConnInfo = connectionState
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Completed TLS handshake for Probe %v at %v with info %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.TLSDoneTime,
-			probe.stats.TLSConnInfo,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_tls_done", "type", probe.probeType.Value(), "id", probe.ProbeId(), "info", probe.stats.TLSConnInfo)
 }
 
 func (probe *ProbeTracer) SetHttpWroteRequestTimeInfo(
@@ -865,27 +1381,12 @@ func (probe *ProbeTracer) SetHttpWroteRequestTimeInfo(
 ) {
 	probe.stats.HttpWroteRequestTime = now
 	probe.stats.HttpInfo = info
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Http finished writing request for Probe %v at %v with info %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.HttpWroteRequestTime,
-			probe.stats.HttpInfo,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_wrote_request", "type", probe.probeType.Value(), "id", probe.ProbeId(), "info", probe.stats.HttpInfo)
 }
 
 func (probe *ProbeTracer) SetHttpResponseReadyTime(
 	now time.Time,
 ) {
 	probe.stats.HttpResponseReadyTime = now
-	if debug.IsDebug(probe.debug) {
-		fmt.Printf(
-			"(%s Probe) Http response is ready for Probe %v at %v\n",
-			probe.probeType.Value(),
-			probe.ProbeId(),
-			probe.stats.HttpResponseReadyTime,
-		)
-	}
+	debug.LogTrace(probe.debug, "rpm.probe_first_response_byte", "type", probe.probeType.Value(), "id", probe.ProbeId())
 }
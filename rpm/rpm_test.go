@@ -0,0 +1,336 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpm
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/clock"
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/ramp"
+	"github.com/network-quality/goresponsiveness/stats"
+	"golang.org/x/net/http2"
+)
+
+func TestIsCachedResponse(t *testing.T) {
+	cases := []struct {
+		header http.Header
+		cached bool
+	}{
+		{http.Header{}, false},
+		{http.Header{"Age": []string{"0"}}, false},
+		{http.Header{"Age": []string{"12"}}, true},
+		{http.Header{"X-Cache": []string{"Miss from cloudfront"}}, false},
+		{http.Header{"X-Cache": []string{"Hit from cloudfront"}}, true},
+		{http.Header{"X-Cache": []string{"MISS, HIT"}}, true},
+		{http.Header{"Cf-Cache-Status": []string{"DYNAMIC"}}, false},
+		{http.Header{"Cf-Cache-Status": []string{"HIT"}}, true},
+	}
+	for _, c := range cases {
+		if IsCachedResponse(c.header) != c.cached {
+			t.Fatalf("Expected %v to be cached: %v.", c.header, c.cached)
+		}
+	}
+}
+
+func TestProbeCollectorLatencyP90(t *testing.T) {
+	start := time.Now()
+	points := make(chan ProbeDataPoint)
+	collector := collectProbes(points)
+	points <- ProbeDataPoint{Time: start.Add(-time.Minute), RoundTripCount: 1, Duration: time.Second}
+	for i := 1; i <= 10; i++ {
+		points <- ProbeDataPoint{
+			Time:           start,
+			RoundTripCount: 3,
+			Duration:       time.Duration(i*3) * time.Millisecond,
+		}
+	}
+	close(points)
+	if all := collector.all(); len(all) != 11 {
+		t.Fatalf("Expected 11 data points but got %d.", len(all))
+	}
+	if p90 := collector.latencyP90(start); p90 != 9*time.Millisecond {
+		t.Fatalf("Expected a P90 of 9ms but got %v.", p90)
+	}
+
+	// The P90 is the one that is reported (the nearest rank): that of 5 round trips is
+	// the slowest of them.
+	points = make(chan ProbeDataPoint)
+	collector = collectProbes(points)
+	for i := 1; i <= 5; i++ {
+		points <- ProbeDataPoint{Time: start, RoundTripCount: 1, Duration: time.Duration(i) * time.Millisecond}
+	}
+	close(points)
+	collector.all()
+	if p90 := collector.latencyP90(start); p90 != 5*time.Millisecond {
+		t.Fatalf("Expected a P90 of 5ms but got %v.", p90)
+	}
+}
+
+func TestResumptionFraction(t *testing.T) {
+	configuration := ProbeConfiguration{ResumptionFraction: 0.25}
+	resuming := 0
+	for probeCount := 0; probeCount < 100; probeCount++ {
+		if configuration.resumes(probeCount) {
+			resuming++
+		}
+	}
+	if resuming != 25 {
+		t.Fatalf("Expected 25 of 100 probes to resume but %d did.", resuming)
+	}
+	if (&ProbeConfiguration{}).resumes(0) {
+		t.Fatalf("A probe resumed without a resumption fraction.")
+	}
+}
+
+func TestClassifyProbeError(t *testing.T) {
+	cases := []struct {
+		err       error
+		connected bool
+		category  string
+	}{
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, false, ProbeErrorDNS},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, false, ProbeErrorConnect},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, false, ProbeErrorConnectTimeout},
+		{fmt.Errorf("remote error: tls: handshake failure"), false, ProbeErrorTLS},
+		{fmt.Errorf("Get: %w", context.DeadlineExceeded), false, ProbeErrorConnectTimeout},
+		{fmt.Errorf("Get: %w", context.DeadlineExceeded), true, ProbeErrorReadTimeout},
+		{&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, true, ProbeErrorReadTimeout},
+		{fmt.Errorf("Get: %w", context.Canceled), true, ProbeErrorCanceled},
+		{errors.New("unexpected EOF"), true, ProbeErrorOther},
+	}
+	for _, c := range cases {
+		if category := ClassifyProbeError(c.err, c.connected); category != c.category {
+			t.Fatalf("Expected %v to be classified as %s but got %s.", c.err, c.category, category)
+		}
+	}
+}
+
+func TestClassifyResponsiveness(t *testing.T) {
+	cases := []struct {
+		rpm   float64
+		class string
+	}{
+		{0, ResponsivenessLow},
+		{299.9, ResponsivenessLow},
+		{300, ResponsivenessMedium},
+		{999, ResponsivenessMedium},
+		{1000, ResponsivenessHigh},
+	}
+	for _, c := range cases {
+		if class := ClassifyResponsiveness(c.rpm, 300, 1000); class != c.class {
+			t.Fatalf("Expected %v RPM to be classified as %s but got %s.", c.rpm, c.class, class)
+		}
+	}
+}
+
+// newGoAwayServer starts an HTTP/2 server that sends a GOAWAY (and closes the
+// connection) once a connection has been idle for a little while, like a server that
+// limits the lifetime of its connections.
+func newGoAwayServer() *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "x")
+	}))
+	server.EnableHTTP2 = true
+	server.Config.IdleTimeout = 50 * time.Millisecond
+	server.StartTLS()
+	return server
+}
+
+func TestSelfProbeAfterGoAway(t *testing.T) {
+	server := newGoAwayServer()
+	defer server.Close()
+	client := &http.Client{
+		Transport: &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	defer client.CloseIdleConnections()
+	debugging := debug.NewDebugWithPrefix(debug.NoDebug, "test")
+
+	// Open the connection on which the self probes are sent.
+	if response, err := client.Get(server.URL); err != nil {
+		t.Fatalf("Could not open the connection: %v", err)
+	} else {
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+
+	points := make(chan ProbeDataPoint, 2)
+	for i, expected := range []string{"", ProbeErrorNewConnection} {
+		if i > 0 {
+			// Let the server send its GOAWAY.
+			time.Sleep(200 * time.Millisecond)
+		}
+		Probe(
+			context.Background(),
+			context.Background(),
+			nil,
+			nil,
+			nil,
+			client,
+			server.URL,
+			"GET",
+			0,
+			Self,
+			"self",
+			uint64(i),
+			&points,
+			debugging,
+		)
+		if point := <-points; point.Error != expected {
+			t.Fatalf("Expected self probe %d to fail with %q but got %q.", i, expected, point.Error)
+		}
+	}
+}
+
+func TestIdleSelfProberAfterGoAway(t *testing.T) {
+	server := newGoAwayServer()
+	defer server.Close()
+	debugging := debug.NewDebugWithPrefix(debug.NoDebug, "test")
+
+	// Every probe comes after the server gave up on the connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	points := IdleSelfProber(
+		ctx,
+		ProbeConfiguration{URL: server.URL, Interval: 100 * time.Millisecond},
+		nil,
+		nil,
+		debugging,
+	)
+	probes := 0
+	for point := range points {
+		if point.Error != ProbeErrorNewConnection {
+			t.Fatalf("Expected the self probe to fail with %q but got %q.", ProbeErrorNewConnection, point.Error)
+		}
+		probes++
+	}
+	if probes == 0 {
+		t.Fatalf("Expected self probes but got none.")
+	}
+}
+
+// A stallingConnection transfers a megabyte every interval until it stalls after
+// _stallAfter_ intervals (never when 0).
+type stallingConnection struct {
+	id         uint64
+	stallAfter int
+	intervals  int
+	stats      stats.TraceStats
+	pings      atomic.Int64
+	drained    atomic.Bool
+}
+
+func (c *stallingConnection) Start(context.Context, debug.DebugLevel) bool { return true }
+func (c *stallingConnection) TransferredInInterval() (uint64, time.Duration) {
+	c.intervals++
+	if c.stallAfter > 0 && c.intervals > c.stallAfter {
+		return 0, time.Second
+	}
+	return 1000 * 1000, time.Second
+}
+func (c *stallingConnection) Transferred() (uint64, time.Duration) { return 0, 0 }
+func (c *stallingConnection) Client() *http.Client                 { return nil }
+func (c *stallingConnection) IsValid() bool                        { return true }
+func (c *stallingConnection) Failure() error                       { return nil }
+func (c *stallingConnection) ClientId() uint64                     { return c.id }
+func (c *stallingConnection) Interruptions() (uint64, uint64)      { return 0, 0 }
+func (c *stallingConnection) Stats() *stats.TraceStats             { return &c.stats }
+func (c *stallingConnection) Ping(context.Context) (time.Duration, error) {
+	c.pings.Add(1)
+	return time.Millisecond, nil
+}
+func (c *stallingConnection) Drain(time.Duration) uint64 {
+	c.drained.Store(true)
+	return 0
+}
+
+// saturatedRamp is saturated as soon as it is asked.
+type saturatedRamp struct{}
+
+func (saturatedRamp) Name() string                          { return "saturated" }
+func (saturatedRamp) Initial() uint64                       { return 2 }
+func (saturatedRamp) Decide(ramp.Observation) ramp.Decision { return ramp.Decision{Saturated: true} }
+
+func TestStalledConnectionReplaced(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	SetClock(fake)
+	defer SetClock(clock.Real{})
+	drivingCtx, stopDriving := context.WithCancel(context.Background())
+	defer stopDriving()
+	go fake.Drive(drivingCtx, 10*time.Millisecond, time.Millisecond)
+
+	connectionsLock := sync.Mutex{}
+	connections := make([]*stallingConnection, 0)
+	generator := func() lgc.LoadGeneratingConnection {
+		connectionsLock.Lock()
+		defer connectionsLock.Unlock()
+		// The first connection (the one that is probed) stalls once the network is
+		// saturated.
+		connection := &stallingConnection{id: uint64(len(connections))}
+		if len(connections) == 0 {
+			connection.stallAfter = 3
+		}
+		connections = append(connections, connection)
+		return connection
+	}
+
+	saturationCtx, cancelSaturation := context.WithCancel(context.Background())
+	saturated, resulted := LGCollectData(
+		saturationCtx,
+		context.Background(),
+		context.Background(),
+		generator,
+		func() ProbeConfiguration {
+			return ProbeConfiguration{Interval: 100 * time.Millisecond, Mechanism: SelfProbePing}
+		},
+		nil,
+		saturatedRamp{},
+		4,
+		nil,
+		debug.NewDebugWithPrefix(debug.Error, "test"),
+	)
+	if !<-saturated {
+		t.Fatalf("Expected the connections to saturate.")
+	}
+	// Give the first connection the time to stall and the probes the time to move on.
+	<-fake.After(10 * time.Second)
+	cancelSaturation()
+	result := <-resulted
+
+	connectionsLock.Lock()
+	defer connectionsLock.Unlock()
+	if len(connections) != 3 || len(result.LGCs) != 3 {
+		t.Fatalf("Expected the stalled connection to be replaced but there are %d connections.", len(connections))
+	}
+	if !connections[0].drained.Load() || connections[1].drained.Load() {
+		t.Fatalf("Expected (only) the stalled connection to be stopped.")
+	}
+	if connections[1].pings.Load() == 0 {
+		t.Fatalf("Expected the self probes to move on from the stalled connection.")
+	}
+}
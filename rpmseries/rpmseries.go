@@ -0,0 +1,88 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package rpmseries calculates the RPM over sliding windows of a test. Unlike the one
+// RPM of the whole test, the series shows whether the responsiveness degrades as the
+// test goes on (e.g., as the buffers fill).
+package rpmseries
+
+import (
+	"sort"
+	"time"
+)
+
+// Probe is a probe that was answered.
+type Probe struct {
+	Time    time.Time
+	Foreign bool
+	Seconds float64
+}
+
+// Point is the RPM of the probes that were sent in the window that ended at Time.
+type Point struct {
+	Time          time.Time `json:"-"              Description:"The end of the window."                                Formatter:"Format" FormatterArgument:"01-02-2006-15-04-05.000"`
+	Seconds       float64   `json:"seconds"        Description:"The end of the window (seconds since the start of the test)."`
+	SelfProbes    int       `json:"self_probes"    Description:"The number of self probes in the window."`
+	ForeignProbes int       `json:"foreign_probes" Description:"The number of foreign probes in the window."`
+	RPM           float64   `json:"rpm"            Description:"The RPM of the probes in the window."`
+}
+
+// Calculate calculates the RPM (with _rpm_, from the round-trip times of the self and
+// the foreign probes) of the _probes_ in every _window_ from _start_ to _end_, one every
+// _step_. The windows without self or foreign probes are left out. When the test was
+// shorter than a window, there is one from _start_ to _end_.
+func Calculate(
+	probes []Probe,
+	start time.Time,
+	end time.Time,
+	window time.Duration,
+	step time.Duration,
+	rpm func(self []float64, foreign []float64) float64,
+) []Point {
+	sorted := append([]Probe(nil), probes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	points := make([]Point, 0)
+	first := start.Add(window)
+	if first.After(end) {
+		first = end
+	}
+	for windowEnd := first; !windowEnd.After(end); windowEnd = windowEnd.Add(step) {
+		windowStart := windowEnd.Add(-window)
+		self, foreign := make([]float64, 0), make([]float64, 0)
+		for _, probe := range sorted {
+			if !probe.Time.After(windowStart) {
+				continue
+			}
+			if probe.Time.After(windowEnd) {
+				break
+			}
+			if probe.Foreign {
+				foreign = append(foreign, probe.Seconds)
+			} else {
+				self = append(self, probe.Seconds)
+			}
+		}
+		if len(self) > 0 && len(foreign) > 0 {
+			points = append(points, Point{
+				Time:          windowEnd,
+				Seconds:       windowEnd.Sub(start).Seconds(),
+				SelfProbes:    len(self),
+				ForeignProbes: len(foreign),
+				RPM:           rpm(self, foreign),
+			})
+		}
+	}
+	return points
+}
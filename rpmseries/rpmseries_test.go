@@ -0,0 +1,55 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package rpmseries
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculate(t *testing.T) {
+	start := time.Now()
+	probes := make([]Probe, 0)
+	// The round-trip times grow by 10ms every second (as if a buffer filled).
+	for i := 0; i < 20; i++ {
+		at := start.Add(time.Duration(i)*500*time.Millisecond + time.Millisecond)
+		seconds := 0.01 * float64(1+i/2)
+		probes = append(probes, Probe{Time: at, Seconds: seconds}, Probe{Time: at, Foreign: true, Seconds: seconds})
+	}
+	mean := func(self []float64, foreign []float64) float64 {
+		total := 0.0
+		for _, seconds := range append(self, foreign...) {
+			total += seconds
+		}
+		return 60 / (total / float64(len(self)+len(foreign)))
+	}
+	points := Calculate(probes, start, start.Add(10*time.Second), 2*time.Second, time.Second, mean)
+	if len(points) != 9 {
+		t.Fatalf("There are %d points (expected one every second from 2s to 10s).", len(points))
+	}
+	if points[0].Seconds != 2 || points[0].SelfProbes != 4 || points[0].ForeignProbes != 4 {
+		t.Fatalf("The first point is %+v.", points[0])
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].RPM >= points[i-1].RPM {
+			t.Fatalf("The RPM did not fall as the round-trip times grew: %+v.", points)
+		}
+	}
+
+	short := Calculate(probes[:4], start, start.Add(time.Second), 2*time.Second, time.Second, mean)
+	if len(short) != 1 || short[0].Seconds != 1 {
+		t.Fatalf("A test shorter than the window gave %+v.", short)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package selftest runs the client against a server of its own on localhost, so that the
+// whole pipeline (fetching the configuration, generating load, probing and logging) can
+// be checked end-to-end without a test server (e.g., as a smoke test of a package or to
+// go with a bug report).
+package selftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+// The size of the large resource (more than any self-test downloads).
+const largeSize = 64 * 1024 * 1024 * 1024
+
+// The number of data logs of a test: one for each of the self probes, the foreign
+// probes, the download, the upload and the RPM over the windows of the test.
+const dataLogs = 5
+
+// zeros is a large resource that takes no memory.
+type zeros struct{}
+
+func (zeros) ReadAt(p []byte, off int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// NewServer starts a minimal test server on localhost: over HTTP/2 (with a self-signed
+// certificate), it serves a configuration, a small and a large resource and accepts
+// uploads. Its configuration is at /config.
+func NewServer() *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewUnstartedServer(mux)
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(
+			w,
+			`{"version": 1, "urls": {"small_https_download_url": "%[1]s/small", "large_https_download_url": "%[1]s/large", "https_upload_url": "%[1]s/upload"}}`,
+			server.URL,
+		)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("x"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeContent(w, r, "large", time.Time{}, io.NewSectionReader(zeros{}, 0, largeSize))
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		io.Copy(io.Discard, r.Body)
+	})
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+// Check returns the results in _output_ (of a test with -json against the server) and
+// what is wrong with them and with the records in the files at _logs_ (of its data
+// loggers, in CSV): nothing when every part of the pipeline did its job.
+func Check(output []byte, logs []string) (summary.Summary, []string) {
+	results := summary.Summary{}
+	if err := json.Unmarshal(output, &results); err != nil {
+		return results, []string{fmt.Sprintf("The results are not JSON: %v", err)}
+	}
+	problems := make([]string, 0)
+	if results.DownloadRateBps <= 0 {
+		problems = append(problems, "No data was downloaded")
+	}
+	if results.UploadRateBps <= 0 {
+		problems = append(problems, "No data was uploaded")
+	}
+	if results.SelfProbeRoundTrips == 0 {
+		problems = append(problems, "No self probe succeeded")
+	}
+	if results.ForeignProbeRoundTrips == 0 {
+		problems = append(problems, "No foreign probe succeeded")
+	}
+	if results.RPM <= 0 {
+		problems = append(problems, "There is no RPM")
+	}
+	if len(logs) != dataLogs {
+		problems = append(problems, fmt.Sprintf("Expected %d data logs but found %d", dataLogs, len(logs)))
+	}
+	for _, log := range logs {
+		content, err := os.ReadFile(log)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Could not read the data log %s: %v", filepath.Base(log), err))
+		} else if bytes.Count(content, []byte("\n")) < 2 {
+			// There is a header before the records.
+			problems = append(problems, fmt.Sprintf("The data log %s has no records", filepath.Base(log)))
+		}
+	}
+	return results, problems
+}
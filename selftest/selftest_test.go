@@ -0,0 +1,64 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package selftest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/config"
+)
+
+func TestNewServer(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	configuration := &config.Config{}
+	if err := configuration.GetUrl(server.URL + "/config"); err != nil {
+		t.Fatalf("Expected a configuration but got %v", err)
+	}
+	if err := configuration.IsValid(); err != nil {
+		t.Fatalf("Expected a valid configuration but got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	directory := t.TempDir()
+	logs := make([]string, 0)
+	for _, name := range []string{"self", "foreign", "download", "upload", "rpm"} {
+		log := filepath.Join(directory, name+".csv")
+		if err := os.WriteFile(log, []byte("header\nrecord\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, log)
+	}
+	output := []byte(`{"download_bytes_per_second": 1, "upload_bytes_per_second": 1, "self_probe_round_trips": 1, "foreign_probe_round_trips": 1, "rpm": 1}`)
+	if _, problems := Check(output, logs); len(problems) != 0 {
+		t.Fatalf("Expected no problems but got %v", problems)
+	}
+
+	// The logs of a test without the RPM over the windows of the test are not complete.
+	if _, problems := Check(output, logs[:4]); len(problems) != 1 {
+		t.Fatalf("Expected a missing data log but got %v", problems)
+	}
+
+	os.WriteFile(logs[0], []byte("header\n"), 0o644)
+	if _, problems := Check([]byte(`{"rpm": 1}`), logs[:3]); len(problems) != 6 {
+		t.Fatalf("Expected 6 problems but got %v", problems)
+	}
+	if _, problems := Check([]byte("Error"), logs); len(problems) != 1 {
+		t.Fatalf("Expected the results not to be JSON but got %v", problems)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package servercheck checks whether a server is suitable for responsiveness testing
+// (i.e., whether it complies with the specification) without running a full test.
+package servercheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/cachecheck"
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/useragent"
+	"golang.org/x/net/http2"
+)
+
+type Result struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+func (r *Report) add(name string, passed bool, detail string, arguments ...interface{}) {
+	r.Results = append(r.Results, Result{name, passed, fmt.Sprintf(detail, arguments...)})
+}
+
+// Passed is true when every check passed.
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	var builder strings.Builder
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&builder, "%s  %-18s %s\n", status, result.Name, result.Detail)
+	}
+	return builder.String()
+}
+
+// The test requires HTTP/2 so every request is made over HTTP/2 (and fails when the
+// server does not support it).
+func newClient() *http.Client {
+	transport := &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	return &http.Client{Transport: transport, Timeout: constants.ServerCheckTimeout}
+}
+
+// checkDownload checks that _downloadUrl_ can be downloaded. When _minimumBytes_ is not
+// 0, the check stops after that many bytes (and fails if there are not that many).
+func checkDownload(report *Report, name string, downloadUrl string, minimumBytes int64) {
+	client := newClient()
+	defer client.CloseIdleConnections()
+
+	// There is no need to download all of a large object to know that it is large.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, "GET", downloadUrl, nil)
+	if err != nil {
+		report.add(name, false, "%v", err)
+		return
+	}
+	request.Header.Set("Accept-Encoding", "identity")
+	useragent.Apply(request)
+	response, err := client.Do(request)
+	if err != nil {
+		report.add(name, false, "Could not download %s over HTTP/2: %v", downloadUrl, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		report.add(name, false, "Downloading %s failed: %s", downloadUrl, response.Status)
+		return
+	}
+	if response.Header.Get("Content-Encoding") != "" {
+		report.add(name, false, "The response is compressed (Content-Encoding: %s)", response.Header.Get("Content-Encoding"))
+		return
+	}
+	var body io.Reader = response.Body
+	if minimumBytes != 0 {
+		body = io.LimitReader(response.Body, minimumBytes)
+	}
+	read, err := io.Copy(io.Discard, body)
+	if err != nil || read < minimumBytes {
+		report.add(name, false, "Only %d bytes could be downloaded from %s (%v)", read, downloadUrl, err)
+		return
+	}
+	report.add(name, true, "%s (%s)", response.Status, response.Proto)
+}
+
+func checkUpload(report *Report, uploadUrl string) {
+	client := newClient()
+	defer client.CloseIdleConnections()
+
+	// Like the load-generating connections, send a body of unknown length (so that it
+	// is streamed rather than sent with a Content-Length).
+	body := io.NopCloser(io.LimitReader(zeroReader{}, constants.ServerCheckUploadSize))
+	request, err := http.NewRequest("POST", uploadUrl, body)
+	if err != nil {
+		report.add("Upload", false, "%v", err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	useragent.Apply(request)
+	response, err := client.Do(request)
+	if err != nil {
+		report.add("Upload", false, "Could not upload to %s over HTTP/2: %v", uploadUrl, err)
+		return
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		report.add("Upload", false, "Uploading to %s failed: %s", uploadUrl, response.Status)
+		return
+	}
+	report.add("Upload", true, "%d bytes of unknown length accepted: %s", constants.ServerCheckUploadSize, response.Status)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// Check runs all the checks against the server whose configuration is at _configUrl_.
+func Check(configUrl string) *Report {
+	report := &Report{}
+
+	parsed, err := url.Parse(configUrl)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		report.add("Configuration", false, "%s is not an https URL", configUrl)
+		return report
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host += ":443"
+	}
+	c := config.Config{}
+	if err := c.Get(host, parsed.RequestURI()); err != nil {
+		report.add("Configuration", false, "%v", strings.TrimSpace(err.Error()))
+		return report
+	}
+	if err := c.IsValid(); err != nil {
+		report.add("Configuration", false, "%v", err)
+		return report
+	}
+	report.add("Configuration", true, "Valid (version %d)", c.Version)
+
+	checkDownload(report, "Small download", c.Urls.SmallUrl, 0)
+	checkDownload(report, "Large download", c.Urls.LargeUrl, constants.ServerCheckDownloadSize)
+	checkUpload(report, c.Urls.UploadUrl)
+
+	for _, resource := range []struct {
+		name   string
+		method string
+		url    string
+	}{
+		{"Caching (small)", "GET", c.Urls.SmallUrl},
+		{"Caching (large)", "HEAD", c.Urls.LargeUrl},
+	} {
+		cacheReport, err := cachecheck.Check(resource.method, resource.url)
+		if err != nil {
+			report.add(resource.name, false, "%v", err)
+			continue
+		}
+		report.add(resource.name, !cacheReport.Cacheable, "%s", cacheReport.Reason)
+	}
+	return report
+}
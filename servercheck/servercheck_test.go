@@ -0,0 +1,94 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package servercheck
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// newServer starts a test server over HTTP/2 that complies with the specification or,
+// when _broken_, breaks it in every way that Check checks (other than the configuration).
+func newServer(broken bool) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewUnstartedServer(mux)
+	cacheControl := "no-store"
+	if broken {
+		cacheControl = "max-age=3600"
+	}
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(
+			w,
+			`{"version": 1, "urls": {"small_https_download_url": "%[1]s/small", "large_https_download_url": "%[1]s/large", "https_upload_url": "%[1]s/upload"}}`,
+			server.URL,
+		)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheControl)
+		if broken {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write([]byte("x"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheControl)
+		size := 2 * constants.ServerCheckDownloadSize
+		if broken {
+			size = constants.ServerCheckDownloadSize / 2
+		}
+		http.ServeContent(w, r, "large", time.Time{}, bytes.NewReader(make([]byte, size)))
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if broken {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+func TestCheck(t *testing.T) {
+	server := newServer(false)
+	defer server.Close()
+	report := Check(server.URL + "/config")
+	if !report.Passed() || len(report.Results) != 6 {
+		t.Fatalf("Expected all 6 checks to pass but got:\n%v", report)
+	}
+
+	broken := newServer(true)
+	defer broken.Close()
+	report = Check(broken.URL + "/config")
+	if report.Passed() || len(report.Results) != 6 {
+		t.Fatalf("Expected 6 checks but got:\n%v", report)
+	}
+	for _, result := range report.Results {
+		if result.Passed != (result.Name == "Configuration") {
+			t.Fatalf("Expected only the configuration to pass but got:\n%v", report)
+		}
+	}
+
+	if report := Check("http://example.com/config"); report.Passed() {
+		t.Fatalf("Expected a plain-HTTP configuration to fail but got:\n%v", report)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package servermeta fetches a test server's own view of itself (what software it
+// runs, which instance and region answered and how busy it is) from the metadata
+// endpoint that a server may advertise in its configuration. Results from anycast
+// servers are hard to interpret without it.
+package servermeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// Metadata is what the server reports about itself. Every field is optional.
+type Metadata struct {
+	Software string   `json:"software,omitempty"`
+	Instance string   `json:"instance,omitempty"`
+	Region   string   `json:"region,omitempty"`
+	Load     *float64 `json:"load,omitempty"`
+}
+
+func (m Metadata) String() string {
+	pieces := make([]string, 0)
+	for _, piece := range []struct {
+		name  string
+		value string
+	}{
+		{"software", m.Software},
+		{"instance", m.Instance},
+		{"region", m.Region},
+	} {
+		if piece.value != "" {
+			pieces = append(pieces, fmt.Sprintf("%s: %s", piece.name, piece.value))
+		}
+	}
+	if m.Load != nil {
+		pieces = append(pieces, fmt.Sprintf("load: %v", *m.Load))
+	}
+	return strings.Join(pieces, ", ")
+}
+
+// Fetch gets the metadata from _url_.
+func Fetch(url string) (*Metadata, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch the server metadata from %s: %v", url, err)
+	}
+	config.Prepare(request)
+	client := config.NewClient(request.URL.Scheme)
+	client.Timeout = constants.ServerMetadataTimeout
+	defer client.CloseIdleConnections()
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch the server metadata from %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"Could not fetch the server metadata from %s: %s",
+			url,
+			response.Status,
+		)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the server metadata from %s: %v", url, err)
+	}
+	metadata := &Metadata{}
+	if err := json.Unmarshal(body, metadata); err != nil {
+		return nil, fmt.Errorf("Could not parse the server metadata from %s: %v", url, err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,54 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package servermeta
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newServer starts a server that, like a test server, speaks HTTP/2.
+func newServer(handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	return server
+}
+
+func TestFetch(t *testing.T) {
+	server := newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"software": "test/1.0", "region": "earth", "load": 0.25, "other": true}`)
+	}))
+	defer server.Close()
+
+	metadata, err := Fetch(server.URL)
+	if err != nil {
+		t.Fatalf("Could not fetch the metadata: %v", err)
+	}
+	if expected := "software: test/1.0, region: earth, load: 0.25"; metadata.String() != expected {
+		t.Fatalf("Expected %q but got %q.", expected, metadata.String())
+	}
+}
+
+func TestFetchUnsupported(t *testing.T) {
+	server := newServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := Fetch(server.URL); err == nil {
+		t.Fatalf("Fetching metadata from a server without the endpoint succeeded.")
+	}
+}
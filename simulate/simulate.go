@@ -0,0 +1,413 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package simulate replaces the network and the test server with a model of a link
+// whose capacity, latency, buffer and loss follow a scripted profile. Its connections
+// are load-generating connections (see package lgc) and its foreign prober sends probes
+// like rpm.ForeignProber does, so that the saturation and RPM algorithms can be
+// regression-tested and demonstrated without a network.
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/clock"
+	"github.com/network-quality/goresponsiveness/config"
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/events"
+	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/rpm"
+	"github.com/network-quality/goresponsiveness/stats"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+// Urls stand in for the URLs of the test server in a simulation (nothing requests them).
+var Urls = config.ConfigUrls{
+	SmallUrl:  "https://simulated.invalid/small",
+	LargeUrl:  "https://simulated.invalid/large",
+	UploadUrl: "https://simulated.invalid/upload",
+}
+
+// A Step is how the link behaves from a point in the simulation on (until the next
+// step).
+type Step struct {
+	AtSeconds    float64 `json:"at_seconds"`
+	DownloadMbps float64 `json:"download_mbps"`
+	UploadMbps   float64 `json:"upload_mbps"`
+	// How fast a single connection can go (0 for as fast as the link), so that it takes
+	// more connections to saturate a link that is faster.
+	FlowMbps float64 `json:"flow_mbps"`
+	// The round-trip time of the idle link.
+	LatencyMs float64 `json:"latency_ms"`
+	// The delay that the queue of each direction adds when it is full (i.e., when the
+	// connections could send more than the link carries).
+	BufferMs float64 `json:"buffer_ms"`
+	// The probability (between 0 and 1) that a packet is lost.
+	Loss float64 `json:"loss"`
+}
+
+// A Profile scripts how the link behaves over the course of a simulation.
+type Profile struct {
+	// The seed of the losses (the same seed loses the same packets).
+	Seed  int64  `json:"seed"`
+	Steps []Step `json:"steps"`
+}
+
+// Load reads the profile in the JSON file at _path_.
+func Load(path string) (Profile, error) {
+	profile := Profile{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return profile, fmt.Errorf("Could not read the simulation profile: %v", err)
+	}
+	if err := json.Unmarshal(content, &profile); err != nil {
+		return profile, fmt.Errorf("Could not parse the simulation profile %s: %v", path, err)
+	}
+	return profile, profile.Check()
+}
+
+// Check returns why the profile cannot be simulated (nil when it can).
+func (p Profile) Check() error {
+	if len(p.Steps) == 0 || p.Steps[0].AtSeconds != 0 {
+		return fmt.Errorf("The simulation profile must have a step at 0 seconds")
+	}
+	for i, step := range p.Steps {
+		if i > 0 && step.AtSeconds <= p.Steps[i-1].AtSeconds {
+			return fmt.Errorf("The steps of the simulation profile must be in order of at_seconds")
+		}
+		if step.DownloadMbps <= 0 || step.UploadMbps <= 0 || step.FlowMbps < 0 ||
+			step.LatencyMs < 0 || step.BufferMs < 0 || step.Loss < 0 || step.Loss >= 1 {
+			return fmt.Errorf("The step at %v seconds of the simulation profile is out of range", step.AtSeconds)
+		}
+	}
+	return nil
+}
+
+// A Network is a link that behaves as a profile scripts (from when it was created on).
+type Network struct {
+	profile Profile
+	clock   clock.Clock
+	start   time.Time
+
+	lock   sync.Mutex
+	random *rand.Rand
+	// Up to when the transfers of the connections are accounted for.
+	settled     time.Time
+	connections map[bool][]*Connection
+}
+
+// NewNetwork creates a network that behaves as _profile_ scripts and keeps time by
+// _clk_.
+func NewNetwork(profile Profile, clk clock.Clock) *Network {
+	now := clk.Now()
+	return &Network{
+		profile:     profile,
+		clock:       clk,
+		start:       now,
+		random:      rand.New(rand.NewSource(profile.Seed)),
+		settled:     now,
+		connections: make(map[bool][]*Connection),
+	}
+}
+
+// stepAt returns the index of the step that is in effect at _at_.
+func (n *Network) stepAt(at time.Time) int {
+	elapsed := at.Sub(n.start).Seconds()
+	current := 0
+	for i, step := range n.profile.Steps {
+		if step.AtSeconds <= elapsed {
+			current = i
+		}
+	}
+	return current
+}
+
+// flowRate is how many bytes per second a single connection can carry during _step_.
+func flowRate(step Step) float64 {
+	rate := math.Inf(1)
+	if step.FlowMbps > 0 {
+		rate = step.FlowMbps * 1000 * 1000 / 8
+	}
+	// With losses, a TCP connection cannot go faster than (about) MSS/(RTT*sqrt(p))
+	// (see Mathis et al., "The macroscopic behavior of the TCP congestion avoidance
+	// algorithm").
+	if step.Loss > 0 && step.LatencyMs > 0 {
+		rate = math.Min(rate, 1.22*1460/(step.LatencyMs/1000*math.Sqrt(step.Loss)))
+	}
+	return rate
+}
+
+// rates returns how many bytes per second each of _count_ connections in a direction
+// carries during _step_ and how much of the link they would use (more than 1 when they
+// could send more than it carries).
+func rates(step Step, upload bool, count int) (float64, float64) {
+	capacity := step.DownloadMbps * 1000 * 1000 / 8
+	if upload {
+		capacity = step.UploadMbps * 1000 * 1000 / 8
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	offered := float64(count) * flowRate(step)
+	return math.Min(capacity, offered) / float64(count), offered / capacity
+}
+
+// settle accounts for what the connections transferred up to _now_ (with the lock held).
+func (n *Network) settle(now time.Time) {
+	for n.settled.Before(now) {
+		current := n.stepAt(n.settled)
+		until := now
+		if current+1 < len(n.profile.Steps) {
+			next := n.start.Add(time.Duration(n.profile.Steps[current+1].AtSeconds * float64(time.Second)))
+			if next.Before(until) {
+				until = next
+			}
+		}
+		elapsed := until.Sub(n.settled).Seconds()
+		for _, upload := range []bool{false, true} {
+			rate, _ := rates(n.profile.Steps[current], upload, len(n.connections[upload]))
+			for _, connection := range n.connections[upload] {
+				connection.transferred += rate * elapsed
+				connection.inInterval += rate * elapsed
+			}
+		}
+		n.settled = until
+	}
+}
+
+// roundTripTime returns the round-trip time over the link at _now_ (with the lock
+// held): that of the idle link plus the delays of the queues of both directions.
+func (n *Network) roundTripTime(now time.Time) time.Duration {
+	step := n.profile.Steps[n.stepAt(now)]
+	delay := step.LatencyMs
+	for _, upload := range []bool{false, true} {
+		_, utilization := rates(step, upload, len(n.connections[upload]))
+		// The queue stays short until the link is nearly fully used.
+		delay += step.BufferMs * math.Pow(math.Min(utilization, 1), 4)
+	}
+	return time.Duration(delay * float64(time.Millisecond))
+}
+
+// roundTrips returns how long _count_ round trips take at _now_ (with their packets
+// lost, and retransmitted, as the profile scripts).
+func (n *Network) roundTrips(now time.Time, count int) time.Duration {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	roundTripTime := n.roundTripTime(now)
+	loss := n.profile.Steps[n.stepAt(now)].Loss
+	total := time.Duration(0)
+	for i := 0; i < count; i++ {
+		total += roundTripTime
+		if n.random.Float64() < loss {
+			total += time.Duration(math.Max(
+				float64(constants.SimulatedRetransmissionTimeout),
+				float64(2*roundTripTime),
+			))
+		}
+	}
+	return total
+}
+
+// wait waits for _d_ to pass (by the clock of the network) or for _ctx_ to be done.
+func (n *Network) wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-n.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewConnection creates a (not yet started) load-generating connection that
+// downloads or, when _upload_, uploads over the network.
+func (n *Network) NewConnection(upload bool) *Connection {
+	return &Connection{network: n, upload: upload, clientId: utilities.GenerateUniqueId()}
+}
+
+// ForeignProber sends foreign probes like rpm.ForeignProber, every interval of
+// _configuration_, over the network: each takes three round trips (for the TCP and
+// TLS handshakes and the request) through the queues of the loaded link.
+func (n *Network) ForeignProber(
+	proberCtx context.Context,
+	configuration rpm.ProbeConfiguration,
+	emitter *events.Emitter,
+) (points chan rpm.ProbeDataPoint) {
+	points = make(chan rpm.ProbeDataPoint)
+	go func() {
+		wg := sync.WaitGroup{}
+		sequence := uint64(0)
+		for n.wait(proberCtx, configuration.Interval) == nil {
+			sequence++
+			wg.Add(1)
+			go func(sequence uint64) {
+				defer wg.Done()
+				sent := n.clock.Now()
+				duration := n.roundTrips(sent, 3)
+				n.wait(context.Background(), duration)
+				dataPoint := rpm.ProbeDataPoint{
+					Time:              sent,
+					RoundTripCount:    3,
+					Duration:          duration,
+					FirstByteDuration: duration,
+					PhaseCrossing:     proberCtx.Err() != nil,
+					Prober:            events.Foreign,
+					Sequence:          sequence,
+				}
+				if !utilities.IsInterfaceNil(configuration.DataLogger) {
+					configuration.DataLogger.LogRecord(dataPoint)
+				}
+				emitter.ProbeResult(events.ProbeResult{
+					Time:           dataPoint.Time,
+					Foreign:        true,
+					Duration:       dataPoint.Duration,
+					RoundTripCount: dataPoint.RoundTripCount,
+					PhaseCrossing:  dataPoint.PhaseCrossing,
+				})
+				points <- dataPoint
+			}(sequence)
+		}
+		wg.Wait()
+		close(points)
+	}()
+	return
+}
+
+// A Connection is a load-generating connection over a simulated network. It carries
+// no requests (so its self probes must be PINGs; see rpm.SelfProbePing).
+type Connection struct {
+	network  *Network
+	upload   bool
+	clientId uint64
+	stats    stats.TraceStats
+
+	// Guarded by the lock of the network.
+	started         time.Time
+	stopped         time.Time
+	lastIntervalEnd time.Time
+	transferred     float64
+	inInterval      float64
+}
+
+var _ lgc.LoadGeneratingConnection = &Connection{}
+
+// Start starts transferring (until _ctx_ is done).
+func (c *Connection) Start(ctx context.Context, debugLevel debug.DebugLevel) bool {
+	n := c.network
+	n.lock.Lock()
+	now := n.clock.Now()
+	n.settle(now)
+	c.started, c.lastIntervalEnd = now, now
+	n.connections[c.upload] = append(n.connections[c.upload], c)
+	n.lock.Unlock()
+	debug.Log(debugLevel, "simulate.connection_started", "id", c.clientId, "upload", c.upload)
+	go func() {
+		<-ctx.Done()
+		c.stop()
+	}()
+	return true
+}
+
+// stop stops transferring.
+func (c *Connection) stop() {
+	n := c.network
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if !c.stopped.IsZero() {
+		return
+	}
+	c.stopped = n.clock.Now()
+	n.settle(c.stopped)
+	remaining := make([]*Connection, 0)
+	for _, connection := range n.connections[c.upload] {
+		if connection != c {
+			remaining = append(remaining, connection)
+		}
+	}
+	n.connections[c.upload] = remaining
+}
+
+func (c *Connection) TransferredInInterval() (uint64, time.Duration) {
+	n := c.network
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	now := n.clock.Now()
+	n.settle(now)
+	transferred := uint64(c.inInterval)
+	c.inInterval -= float64(transferred)
+	interval := now.Sub(c.lastIntervalEnd)
+	c.lastIntervalEnd = now
+	return transferred, interval
+}
+
+func (c *Connection) Transferred() (uint64, time.Duration) {
+	n := c.network
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	end := c.stopped
+	if end.IsZero() {
+		end = n.clock.Now()
+		n.settle(end)
+	}
+	return uint64(c.transferred), end.Sub(c.started)
+}
+
+// Client returns nil: the connection carries no requests.
+func (c *Connection) Client() *http.Client {
+	return nil
+}
+
+func (c *Connection) IsValid() bool {
+	return true
+}
+
+func (c *Connection) Failure() error {
+	return nil
+}
+
+func (c *Connection) ClientId() uint64 {
+	return c.clientId
+}
+
+func (c *Connection) Interruptions() (uint64, uint64) {
+	return 0, 0
+}
+
+// Stats returns empty statistics (there is no actual connection to trace).
+func (c *Connection) Stats() *stats.TraceStats {
+	return &c.stats
+}
+
+// Ping waits for a round trip through the queues of the link.
+func (c *Connection) Ping(ctx context.Context) (time.Duration, error) {
+	duration := c.network.roundTrips(c.network.clock.Now(), 1)
+	if err := c.network.wait(ctx, duration); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+// Drain stops transferring right away (nothing is in flight to settle).
+func (c *Connection) Drain(time.Duration) uint64 {
+	c.stop()
+	return 0
+}
@@ -0,0 +1,111 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package simulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/clock"
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/debug"
+	"github.com/network-quality/goresponsiveness/lgc"
+	"github.com/network-quality/goresponsiveness/ramp"
+	"github.com/network-quality/goresponsiveness/rpm"
+)
+
+func TestTransferred(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	network := NewNetwork(Profile{Steps: []Step{
+		{AtSeconds: 0, DownloadMbps: 8, UploadMbps: 8},
+		{AtSeconds: 1, DownloadMbps: 16, UploadMbps: 8},
+	}}, fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, second := network.NewConnection(false), network.NewConnection(false)
+	first.Start(ctx, debug.Error)
+	second.Start(ctx, debug.Error)
+	fake.Advance(2 * time.Second)
+	// The connections share 1 MB in the first second and 2 MB in the second.
+	if transferred, duration := first.Transferred(); transferred != 1500*1000 || duration != 2*time.Second {
+		t.Fatalf("Expected 1.5 MB in 2s but got %d bytes in %v.", transferred, duration)
+	}
+	second.Drain(0)
+	fake.Advance(time.Second)
+	if transferred, interval := first.TransferredInInterval(); transferred != 3500*1000 || interval != 3*time.Second {
+		t.Fatalf("Expected 3.5 MB in 3s but got %d bytes in %v.", transferred, interval)
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	rpm.SetClock(fake)
+	defer rpm.SetClock(clock.Real{})
+	// It takes 10 connections to saturate the link, whose queue then adds 200ms.
+	network := NewNetwork(Profile{Steps: []Step{
+		{DownloadMbps: 100, UploadMbps: 20, FlowMbps: 10, LatencyMs: 20, BufferMs: 200},
+	}}, fake)
+
+	saturationCtx, cancelSaturation := context.WithCancel(context.Background())
+	networkCtx, cancelNetwork := context.WithCancel(context.Background())
+	defer cancelNetwork()
+	algorithm, _ := ramp.New(ramp.Default)
+	saturated, resulted := rpm.LGCollectData(
+		saturationCtx,
+		networkCtx,
+		context.Background(),
+		func() lgc.LoadGeneratingConnection { return network.NewConnection(false) },
+		func() rpm.ProbeConfiguration {
+			return rpm.ProbeConfiguration{Interval: 100 * time.Millisecond, Mechanism: rpm.SelfProbePing}
+		},
+		nil,
+		algorithm,
+		constants.MovingAverageIntervalCount,
+		nil,
+		debug.NewDebugWithPrefix(debug.Error, "download"),
+	)
+
+	// Let the simulated time pass as fast as the collection keeps up with it.
+	drivingCtx, stopDriving := context.WithCancel(context.Background())
+	defer stopDriving()
+	go fake.Drive(drivingCtx, constants.SimulatedClockStep, constants.SimulatedClockPause)
+
+	if !<-saturated {
+		t.Fatalf("Expected the simulated link to saturate.")
+	}
+	cancelSaturation()
+	result := <-resulted
+
+	if result.RateBps < 0.95*12.5e6 || result.RateBps > 1.05*12.5e6 {
+		t.Fatalf("Expected a rate of (about) 12.5 MB/s but got %v.", result.RateBps)
+	}
+	if len(result.LGCs) < 10 {
+		t.Fatalf("Expected at least 10 connections but got %d.", len(result.LGCs))
+	}
+	saturatedAt := result.Ramp[len(result.Ramp)-1].Time
+	loaded := 0
+	for _, probe := range result.ProbeDataPoints {
+		if probe.Time.After(saturatedAt.Add(-time.Second)) {
+			loaded++
+			if probe.Duration != 220*time.Millisecond {
+				t.Fatalf("Expected the loaded round trips to take 220ms but one took %v.", probe.Duration)
+			}
+		}
+	}
+	if loaded == 0 {
+		t.Fatalf("Expected self probes of the saturated link.")
+	}
+}
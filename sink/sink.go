@@ -0,0 +1,144 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package sink provides the destinations for the bodies of load-generating downloads.
+// They range from doing nothing with the data (the fastest) to checking every byte (the
+// strictest).
+package sink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+)
+
+// A Sink consumes the body of a download.
+type Sink interface {
+	// Begin is called with the headers of the response before any of its body is written.
+	Begin(header http.Header)
+	Write(p []byte) (int, error)
+	// End is called when the download stops (_complete_ is true when the whole body was
+	// received) and returns any problem with the integrity of the data.
+	End(complete bool) error
+}
+
+// The kinds of sinks that New can create.
+const (
+	Discard  = "discard"
+	Checksum = "checksum"
+	Pattern  = "pattern"
+)
+
+// New creates a sink of the given kind.
+func New(kind string) (Sink, error) {
+	switch kind {
+	case Discard:
+		return discardSink{}, nil
+	case Checksum:
+		return &checksumSink{}, nil
+	case Pattern:
+		return &patternSink{expected: 0}, nil
+	}
+	return nil, fmt.Errorf("Unknown download sink: %s (use %s, %s or %s)", kind, Discard, Checksum, Pattern)
+}
+
+type discardSink struct{}
+
+func (discardSink) Begin(http.Header)           {}
+func (discardSink) Write(p []byte) (int, error) { return len(p), nil }
+func (discardSink) End(bool) error              { return nil }
+
+// checksumSink calculates the SHA-256 of the body as it arrives and, when the server
+// provides a digest (in a Content-Digest or Digest header) and the whole body was
+// received, compares the two.
+type checksumSink struct {
+	hash     hash.Hash
+	expected []byte
+}
+
+var (
+	contentDigest = regexp.MustCompile(`(?i)sha-256=:([A-Za-z0-9+/=]+):`)
+	legacyDigest  = regexp.MustCompile(`(?i)sha-256=([A-Za-z0-9+/=]+)`)
+)
+
+func (c *checksumSink) Begin(header http.Header) {
+	c.hash = sha256.New()
+	c.expected = nil
+	var encoded []string
+	if digest := header.Get("Content-Digest"); digest != "" {
+		encoded = contentDigest.FindStringSubmatch(digest)
+	} else if digest := header.Get("Digest"); digest != "" {
+		encoded = legacyDigest.FindStringSubmatch(digest)
+	}
+	if len(encoded) == 2 {
+		if expected, err := base64.StdEncoding.DecodeString(encoded[1]); err == nil {
+			c.expected = expected
+		}
+	}
+}
+
+func (c *checksumSink) Write(p []byte) (int, error) {
+	return c.hash.Write(p)
+}
+
+func (c *checksumSink) End(complete bool) error {
+	if !complete || c.expected == nil {
+		return nil
+	}
+	if actual := c.hash.Sum(nil); !bytes.Equal(actual, c.expected) {
+		return fmt.Errorf(
+			"The SHA-256 of the download (%s) does not match the server's digest (%s)",
+			base64.StdEncoding.EncodeToString(actual),
+			base64.StdEncoding.EncodeToString(c.expected),
+		)
+	}
+	return nil
+}
+
+// patternSink verifies that every byte of the body is the expected one (the reference
+// server sends zeros).
+type patternSink struct {
+	expected byte
+	offset   uint64
+	mismatch error
+}
+
+func (p *patternSink) Begin(http.Header) {
+	p.offset = 0
+	p.mismatch = nil
+}
+
+func (p *patternSink) Write(data []byte) (int, error) {
+	if p.mismatch == nil {
+		for index, b := range data {
+			if b != p.expected {
+				p.mismatch = fmt.Errorf(
+					"The download does not match the expected pattern at offset %d",
+					p.offset+uint64(index),
+				)
+				break
+			}
+		}
+	}
+	p.offset += uint64(len(data))
+	return len(data), nil
+}
+
+func (p *patternSink) End(bool) error {
+	return p.mismatch
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package sink
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	body := []byte("responsiveness")
+	digest := sha256.Sum256(body)
+	header := http.Header{
+		"Content-Digest": []string{"sha-256=:" + base64.StdEncoding.EncodeToString(digest[:]) + ":"},
+	}
+
+	s, _ := New(Checksum)
+	s.Begin(header)
+	s.Write(body)
+	if err := s.End(true); err != nil {
+		t.Fatalf("Checksum of a correct body failed: %v", err)
+	}
+
+	s.Begin(header)
+	s.Write([]byte("responsivenesS"))
+	if err := s.End(true); err == nil {
+		t.Fatalf("Checksum of an incorrect body succeeded.")
+	}
+	// An incomplete body cannot be checked.
+	s.Begin(header)
+	s.Write(body[:4])
+	if err := s.End(false); err != nil {
+		t.Fatalf("Checksum of an incomplete body failed: %v", err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	s, _ := New(Pattern)
+	s.Begin(http.Header{})
+	s.Write(make([]byte, 10))
+	if err := s.End(true); err != nil {
+		t.Fatalf("Pattern check of zeros failed: %v", err)
+	}
+	s.Begin(http.Header{})
+	s.Write(make([]byte, 10))
+	s.Write([]byte{0, 0, 1})
+	if err := s.End(false); err == nil || err.Error() != "The download does not match the expected pattern at offset 12" {
+		t.Fatalf("Pattern check of a mismatch gave an unexpected result: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package spec holds how the revisions of the responsiveness draft
+// (draft-ietf-ippm-responsiveness) measure and aggregate, so that results can be
+// compared with those of other clients that follow a particular revision.
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	Draft01 = "draft-ietf-ippm-responsiveness-01"
+	Draft02 = "draft-ietf-ippm-responsiveness-02"
+	Default = Draft01
+)
+
+var Names = []string{Draft01, Draft02}
+
+type Profile struct {
+	Name string
+	// The interval between the probes of each prober.
+	ProbeInterval time.Duration
+	// Whether the RPM is calculated from the trimmed means of the round-trip times
+	// (rather than from their P90s).
+	TrimmedMean bool
+	// How many round trips a foreign probe stands for in the RPM (e.g., 3 for its TCP,
+	// TLS and HTTP round trips, which then share the weight of the foreign probes).
+	ForeignRoundTrips int
+	// Whether the throughput counts as stable when the standard deviation of the
+	// recent moving averages is small (rather than when none of them grew much).
+	DeviationStability bool
+}
+
+// Get returns the profile of the revision called _name_.
+func Get(name string) (Profile, error) {
+	switch name {
+	case Draft01:
+		return Profile{Name: Draft01, ProbeInterval: 100 * time.Millisecond, ForeignRoundTrips: 1}, nil
+	case Draft02:
+		return Profile{
+			Name:               Draft02,
+			ProbeInterval:      100 * time.Millisecond,
+			TrimmedMean:        true,
+			ForeignRoundTrips:  3,
+			DeviationStability: true,
+		}, nil
+	}
+	return Profile{}, fmt.Errorf(
+		"Unknown specification %s (use one of %s)",
+		name,
+		strings.Join(Names, ", "),
+	)
+}
+
+// TrimmedMean is the mean of the smallest _percentile_ percent of _values_ (0 when
+// there are none).
+func TrimmedMean(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	kept := int(float64(len(sorted))*percentile/100 + 0.5)
+	if kept < 1 {
+		kept = 1
+	}
+	total := 0.0
+	for _, value := range sorted[:kept] {
+		total += value
+	}
+	return total / float64(kept)
+}
@@ -0,0 +1,38 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package spec
+
+import "testing"
+
+func TestTrimmedMean(t *testing.T) {
+	values := make([]float64, 0, 20)
+	for i := 1; i <= 19; i++ {
+		values = append(values, 1)
+	}
+	// The outlier is trimmed.
+	values = append(values, 100)
+	if mean := TrimmedMean(values, 95); mean != 1 {
+		t.Fatalf("Expected a trimmed mean of 1 but got %v.", mean)
+	}
+	if mean := TrimmedMean(nil, 95); mean != 0 {
+		t.Fatalf("Expected a trimmed mean of 0 without values but got %v.", mean)
+	}
+}
+
+func TestUnknownSpecification(t *testing.T) {
+	if _, err := Get("draft-ietf-ippm-responsiveness-99"); err == nil {
+		t.Fatalf("Got an unknown specification.")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package spike finds the probes whose round-trip times stand out from those around
+// them (latency spikes), which periodic interference (e.g., the scans of a Wi-Fi
+// adapter) leaves behind.
+package spike
+
+import (
+	"sort"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/constants"
+)
+
+// Probe is a probe that was answered.
+type Probe struct {
+	Time    time.Time
+	Prober  string
+	Seconds float64
+}
+
+// Spike is a probe whose round-trip time exceeded the threshold (or the running median
+// of its prober's previous probes by the factor).
+type Spike struct {
+	Probe
+	// The median of the round-trip times of the previous probes of the same prober (0
+	// when there were too few of them).
+	MedianSeconds float64
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// Detect finds the spikes among _probes_: those whose round-trip time is longer than
+// _thresholdSeconds_ or _factor_ times the median of the (up to
+// constants.SpikeMedianWindow) previous probes of the same prober. A zero threshold
+// or factor disables that criterion. The spikes are in the order in which they were
+// sent.
+func Detect(probes []Probe, thresholdSeconds float64, factor float64) []Spike {
+	sorted := append([]Probe(nil), probes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	histories := make(map[string][]float64)
+	spikes := make([]Spike, 0)
+	for _, probe := range sorted {
+		history := histories[probe.Prober]
+		runningMedian := float64(0)
+		if len(history) >= constants.SpikeMinimumHistory {
+			runningMedian = median(history)
+		}
+		if (thresholdSeconds > 0 && probe.Seconds > thresholdSeconds) ||
+			(factor > 0 && runningMedian > 0 && probe.Seconds > factor*runningMedian) {
+			spikes = append(spikes, Spike{Probe: probe, MedianSeconds: runningMedian})
+		}
+		history = append(history, probe.Seconds)
+		if len(history) > constants.SpikeMedianWindow {
+			history = history[1:]
+		}
+		histories[probe.Prober] = history
+	}
+	return spikes
+}
@@ -0,0 +1,45 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package spike
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	start := time.Now()
+	probes := make([]Probe, 0)
+	for i := 0; i < 10; i++ {
+		seconds := 0.010
+		if i == 7 {
+			seconds = 0.500
+		}
+		probes = append(probes, Probe{Time: start.Add(time.Duration(i) * time.Second), Prober: "download", Seconds: seconds})
+	}
+	// A slow probe of another prober is not compared with those of the first.
+	probes = append(probes, Probe{Time: start, Prober: "foreign", Seconds: 0.400})
+
+	spikes := Detect(probes, 0, 5)
+	if len(spikes) != 1 || spikes[0].Prober != "download" || spikes[0].MedianSeconds != 0.010 {
+		t.Fatalf("Expected the one spike of the download prober but got %v.", spikes)
+	}
+	if spikes := Detect(probes, 0.300, 0); len(spikes) != 2 || spikes[0].Prober != "foreign" {
+		t.Fatalf("Expected the two probes above the threshold (in order) but got %v.", spikes)
+	}
+	if spikes := Detect(probes, 0, 0); len(spikes) != 0 {
+		t.Fatalf("Expected no spikes without criteria but got %v.", spikes)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package stopwatch keeps track of how long the parts of a run take so that it is easy
+// to see where the time goes (without having to profile the client).
+package stopwatch
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Lap is the time that one part of a run took.
+type Lap struct {
+	Name     string
+	Duration time.Duration
+}
+
+// A Stopwatch records laps in the order in which they are recorded.
+type Stopwatch struct {
+	laps []Lap
+}
+
+// Record records that the part of the run called _name_ went from _start_ to _end_.
+// Parts that did not happen (_start_ or _end_ is the zero time) are not recorded.
+func (s *Stopwatch) Record(name string, start time.Time, end time.Time) {
+	if start.IsZero() || end.IsZero() {
+		return
+	}
+	s.laps = append(s.laps, Lap{Name: name, Duration: end.Sub(start)})
+}
+
+// Since records that the part of the run called _name_ went from _start_ until now and
+// returns now (so that it can be the start of the next part).
+func (s *Stopwatch) Since(name string, start time.Time) time.Time {
+	now := time.Now()
+	s.Record(name, start, now)
+	return now
+}
+
+// Laps returns the laps that were recorded.
+func (s *Stopwatch) Laps() []Lap {
+	return s.laps
+}
+
+// Report writes a line for every lap to _w_.
+func (s *Stopwatch) Report(w io.Writer) {
+	for _, lap := range s.laps {
+		fmt.Fprintf(w, "%-20s %v\n", lap.Name+":", lap.Duration.Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package stopwatch
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStopwatch(t *testing.T) {
+	timings := Stopwatch{}
+	start := time.Now()
+	timings.Record("first", start, start.Add(2*time.Second))
+	timings.Record("skipped", time.Time{}, start)
+	timings.Record("second", start, start.Add(time.Millisecond))
+
+	laps := timings.Laps()
+	if len(laps) != 2 || laps[0].Name != "first" || laps[1].Duration != time.Millisecond {
+		t.Fatalf("The stopwatch recorded the wrong laps: %v", laps)
+	}
+
+	report := bytes.Buffer{}
+	timings.Report(&report)
+	expected := "first:               2s\nsecond:              1ms\n"
+	if report.String() != expected {
+		t.Fatalf("The report is %q rather than %q", report.String(), expected)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package submit uploads the results of a run to a collection service (e.g., for a
+// community measurement campaign), without what could identify the client's network.
+package submit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/summary"
+	"github.com/network-quality/goresponsiveness/useragent"
+)
+
+// Anonymize returns a copy of _s_ without the addresses of the hops on the path to the
+// test server (which belong to the client's network). The client's own addresses are
+// never part of the results.
+func Anonymize(s *summary.Summary) summary.Summary {
+	anonymized := *s
+	anonymized.Traceroute = make([]summary.Hop, len(s.Traceroute))
+	for i, hop := range s.Traceroute {
+		hop.Address = ""
+		anonymized.Traceroute[i] = hop
+	}
+	return anonymized
+}
+
+// Submit POSTs _s_ (anonymized, see Anonymize) as JSON to _url_.
+func Submit(url string, s *summary.Summary) error {
+	body, err := json.Marshal(Anonymize(s))
+	if err != nil {
+		return fmt.Errorf("Could not format the results for submission: %v", err)
+	}
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Could not create the submission request for %s: %v", url, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	useragent.Apply(request)
+
+	client := &http.Client{Timeout: constants.SubmitTimeout}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not submit the results to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Submitting the results to %s failed: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package submit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/metadata"
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+func TestSubmit(t *testing.T) {
+	var received summary.Summary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	s := &summary.Summary{
+		Metadata:   metadata.Metadata{RunId: "0b5e3c7a-8f3a-4c2e-9d1e-1f2a3b4c5d6e"},
+		RPM:        42,
+		Traceroute: []summary.Hop{{TTL: 1, Address: "192.168.1.1", IdleSeconds: 0.001}},
+	}
+	if err := Submit(server.URL, s); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if received.Metadata.RunId != s.Metadata.RunId || received.RPM != s.RPM {
+		t.Fatalf("Submitted %+v but expected the run %s with an RPM of %v", received, s.Metadata.RunId, s.RPM)
+	}
+	if len(received.Traceroute) != 1 || received.Traceroute[0].Address != "" {
+		t.Fatalf("The submitted traceroute %+v is not anonymized", received.Traceroute)
+	}
+	if s.Traceroute[0].Address == "" {
+		t.Fatalf("Submitting anonymized the results themselves")
+	}
+}
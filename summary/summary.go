@@ -0,0 +1,274 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package summary
+
+import (
+	"time"
+
+	"github.com/network-quality/goresponsiveness/cachecheck"
+	"github.com/network-quality/goresponsiveness/cpuload"
+	"github.com/network-quality/goresponsiveness/metadata"
+	"github.com/network-quality/goresponsiveness/preflight"
+	"github.com/network-quality/goresponsiveness/resourceusage"
+	"github.com/network-quality/goresponsiveness/rpmseries"
+	"github.com/network-quality/goresponsiveness/servermeta"
+)
+
+// Summary holds the final results of a single test run. It is the single source
+// for everything that is reported about a run once it is over (other than the
+// granular information that goes to the data loggers).
+type Summary struct {
+	// The identifier of the run, the client that ran it, where and against which
+	// server (see package metadata).
+	Metadata metadata.Metadata `json:"metadata"`
+
+	Time                   time.Time `json:"time"`
+	ConfigSource           string    `json:"config_source"`
+	CacheBusting           bool      `json:"cache_busting"`
+	RampAlgorithm          string    `json:"ramp_algorithm"`
+	ProbeTiming            string    `json:"probe_timing"`
+	LatencyBudget          float64   `json:"latency_budget_seconds,omitempty"`
+	DownloadRateBps        float64   `json:"download_bytes_per_second"`
+	DownloadWireRateBps    float64   `json:"download_wire_bytes_per_second"`
+	DownloadFlows          int       `json:"download_flows"`
+	UploadRateBps          float64   `json:"upload_bytes_per_second"`
+	UploadWireRateBps      float64   `json:"upload_wire_bytes_per_second"`
+	UploadFlows            int       `json:"upload_flows"`
+	SelfProbeRoundTrips    int       `json:"self_probe_round_trips"`
+	ForeignProbeRoundTrips int       `json:"foreign_probe_round_trips"`
+	SelfProbeP90           float64   `json:"self_probe_p90_seconds"`
+	ForeignProbeP90        float64   `json:"foreign_probe_p90_seconds"`
+	ResumedForeignProbes   int       `json:"resumed_foreign_probes"`
+	ResumedForeignProbeP90 float64   `json:"resumed_foreign_probe_p90_seconds"`
+	DownloadSelfProbeP90   float64   `json:"download_self_probe_p90_seconds"`
+	UploadSelfProbeP90     float64   `json:"upload_self_probe_p90_seconds"`
+	RPM                    float64   `json:"rpm"`
+	PhaseCrossingProbes    int       `json:"phase_crossing_probes"`
+	CachedProbes           int       `json:"cached_probes"`
+	ClockJumps             int       `json:"clock_jumps"`
+	ClockJumpProbes        int       `json:"clock_jump_probes"`
+	Suspended              bool      `json:"suspended"`
+	DrainedBytes           uint64    `json:"drain_discarded_bytes"`
+
+	// Only with -compensate-serialization.
+	CompensatedSelfProbeP90    float64 `json:"compensated_self_probe_p90_seconds,omitempty"`
+	CompensatedForeignProbeP90 float64 `json:"compensated_foreign_probe_p90_seconds,omitempty"`
+	CompensatedRPM             float64 `json:"compensated_rpm,omitempty"`
+
+	// Why the load could not be generated in a direction (which is then left out).
+	DownloadError string `json:"download_error,omitempty"`
+	UploadError   string `json:"upload_error,omitempty"`
+
+	// The number of load-generating connections that stalled (and were replaced).
+	DownloadStalls int `json:"download_stalls"`
+	UploadStalls   int `json:"upload_stalls"`
+
+	// The self probes that were HTTP/2 PINGs (see rpm.SelfProbePing).
+	SelfProbeMechanism string  `json:"self_probe_mechanism"`
+	PingProbes         int     `json:"ping_probes"`
+	PingProbeP90       float64 `json:"ping_probe_p90_seconds"`
+
+	// How many times the server interrupted the load-generating connections.
+	GoAways      uint64 `json:"goaways"`
+	StreamResets uint64 `json:"stream_resets"`
+
+	// The UDP probes that were sent while the HTTP probes ran (see package udpprobe).
+	UDPProbes     int     `json:"udp_probes"`
+	UDPProbesLost int     `json:"udp_probes_lost"`
+	UDPProbeP90   float64 `json:"udp_probe_p90_seconds"`
+
+	// The ICMP echo requests that were sent while the HTTP probes ran (see package
+	// icmpprobe).
+	ICMPProbes     int     `json:"icmp_probes"`
+	ICMPProbesLost int     `json:"icmp_probes_lost"`
+	ICMPProbeP90   float64 `json:"icmp_probe_p90_seconds"`
+
+	// The path to the test server before the test and under load (see package
+	// traceroute).
+	Traceroute []Hop `json:"traceroute,omitempty"`
+
+	// The probes of the idle network before the test, by how much the latency per
+	// round trip grew under load and the resulting grade (see package grade).
+	IdleProbes       int     `json:"idle_probes"`
+	LatencyInflation float64 `json:"latency_inflation_seconds"`
+	BufferbloatGrade string  `json:"bufferbloat_grade,omitempty"`
+
+	// The revision of the draft whose measurement and aggregation the test followed.
+	Spec string `json:"spec"`
+
+	// What the checks of the resources before the test found (only with -preflight).
+	Preflight []preflight.Result `json:"preflight,omitempty"`
+
+	// Whether the test only measured the latency of the idle network (with
+	// -latency-only), in which case there are no rates and flows.
+	LatencyOnly bool `json:"latency_only,omitempty"`
+
+	// Whether the test only measured the throughput (with -throughput-only), in which
+	// case there are no probes and no RPM.
+	ThroughputOnly bool `json:"throughput_only,omitempty"`
+
+	// The redirects of the configuration and the test URLs (which are not measured).
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// The class of the RPM (see rpm.ClassifyResponsiveness) and the RPMs from which
+	// it counts as medium and as high.
+	Responsiveness          string  `json:"responsiveness"`
+	ResponsivenessMediumRPM float64 `json:"responsiveness_medium_rpm"`
+	ResponsivenessHighRPM   float64 `json:"responsiveness_high_rpm"`
+
+	// The RPMs of the self probes and of the foreign probes on their own (of which
+	// the RPM is the harmonic mean, in which the self probes weigh SelfWeight). The
+	// gap between them shows whether the latency comes from setting up new
+	// connections or from queues on the loaded ones.
+	SelfRPM    float64 `json:"self_rpm"`
+	ForeignRPM float64 `json:"foreign_rpm"`
+	SelfWeight float64 `json:"self_weight"`
+
+	// The number of intervals over which the throughput was averaged to judge whether
+	// it was stable (none without load).
+	MovingAverageWindow int `json:"moving_average_window,omitempty"`
+
+	// The longest that each direction could take to saturate and how long the network
+	// was kept saturated afterwards (see -stable-duration), in seconds.
+	DownloadDuration float64 `json:"download_duration_seconds"`
+	UploadDuration   float64 `json:"upload_duration_seconds"`
+	StableDuration   float64 `json:"stable_duration_seconds,omitempty"`
+
+	// The ceilings of the aggregate rates of the load-generating connections (bytes per
+	// second; none without -max-rate-mbps or -target-rate-mbps) and whether they were
+	// targets at which the load was held (rather than saturating the network).
+	DownloadRateCeilingBps float64 `json:"download_rate_ceiling_bytes_per_second,omitempty"`
+	UploadRateCeilingBps   float64 `json:"upload_rate_ceiling_bytes_per_second,omitempty"`
+	TargetRate             bool    `json:"target_rate,omitempty"`
+
+	// How the outliers among the round-trip times of the probes were filtered (see
+	// package outlier) and how many were discarded or winsorized.
+	OutlierFilter  string `json:"outlier_filter"`
+	FilteredProbes int    `json:"filtered_probes"`
+
+	// How long each direction took to saturate from the start of the test (0 when it
+	// never did, e.g., because the test timed out first).
+	DownloadSaturationSeconds float64 `json:"download_saturation_seconds"`
+	UploadSaturationSeconds   float64 `json:"upload_saturation_seconds"`
+
+	// When (in seconds from the start of the test) the ramp of each direction added
+	// connections or declared the network saturated, and why.
+	Ramp []RampStep `json:"ramp"`
+
+	// The probes whose round-trip times stood out (see package spike; only with
+	// -spike-threshold or -spike-factor).
+	LatencySpikes []Spike `json:"latency_spikes"`
+
+	// How the network changed during the test (see package netwatch).
+	NetworkChanges []string `json:"network_changes,omitempty"`
+
+	// The number of (the oldest) probe and throughput data points that were dropped
+	// to stay within -max-samples (the results are calculated from the others).
+	DroppedSamples int `json:"dropped_samples,omitempty"`
+
+	// The CPU usage of the client and of the whole system during the test (see
+	// package cpuload; none without load).
+	CPU *cpuload.Summary `json:"cpu,omitempty"`
+
+	// The RPM over sliding windows of the test (see -rpm-window and package
+	// rpmseries).
+	RPMSeries []rpmseries.Point `json:"rpm_series,omitempty"`
+
+	// What suggested a captive portal or an intercepting proxy before the test (see
+	// package middlebox).
+	MiddleboxFindings []string `json:"middlebox_findings,omitempty"`
+
+	// Where the download load-generating connections went and what they negotiated
+	// (the connections that have all of that in common are counted together). The
+	// upload connections keep no statistics of their own.
+	Connections []Connection `json:"connections"`
+
+	// Every load-generating connection on its own.
+	Flows []Flow `json:"flows"`
+
+	// The codes (see package anomaly) of the anomalies that were detected.
+	Anomalies []string `json:"anomalies"`
+
+	// The number of failed probes by the reason for which they failed.
+	ProbeErrors    map[string]int              `json:"probe_errors"`
+	ServerMetadata *servermeta.Metadata        `json:"server_metadata,omitempty"`
+	CacheChecks    []cachecheck.Report         `json:"cache_checks"`
+	ResourceUsage  resourceusage.ResourceUsage `json:"resource_usage"`
+}
+
+// Hop holds the median round-trip times to a hop of the path to the test server
+// before the test and under load (0 when the hop did not answer).
+type Hop struct {
+	TTL           int     `json:"ttl"`
+	Address       string  `json:"address"`
+	IdleSeconds   float64 `json:"idle_seconds"`
+	LoadedSeconds float64 `json:"loaded_seconds"`
+}
+
+// Redirect holds a single redirect of a request to the test server.
+type Redirect struct {
+	Url      string  `json:"url"`
+	Location string  `json:"location"`
+	Status   int     `json:"status"`
+	Seconds  float64 `json:"seconds"`
+}
+
+// Connection holds the address of the server to which load-generating connections
+// went, the protocol that ALPN selected and the TLS version and cipher suite that
+// they negotiated.
+type Connection struct {
+	Address     string `json:"address"`
+	ALPN        string `json:"alpn"`
+	TLSVersion  string `json:"tls_version"`
+	CipherSuite string `json:"cipher_suite"`
+	Count       int    `json:"count"`
+}
+
+// RampStep holds a point on the timeline of the ramp of a direction (see rpm.RampStep):
+// why connections were added (initial, add or stall) or saturation was declared
+// (saturated), and what the ramp algorithm observed at the time.
+type RampStep struct {
+	Direction                 string  `json:"direction"`
+	Seconds                   float64 `json:"seconds"`
+	Reason                    string  `json:"reason"`
+	Connections               int     `json:"connections"`
+	Added                     uint64  `json:"added"`
+	RateBps                   float64 `json:"bytes_per_second"`
+	MovingAverageDeltaPercent float64 `json:"moving_average_delta_percent"`
+	Stable                    bool    `json:"stable"`
+	LatencySeconds            float64 `json:"latency_seconds"`
+}
+
+// Spike holds a probe whose round-trip time stood out (see package spike): when it was
+// sent (in seconds from the start of the test), by which prober, the median of the
+// previous probes of that prober and the throughput at the time.
+type Spike struct {
+	Seconds          float64 `json:"seconds"`
+	Prober           string  `json:"prober"`
+	RoundTripSeconds float64 `json:"round_trip_seconds"`
+	MedianSeconds    float64 `json:"median_seconds"`
+	DownloadRateBps  float64 `json:"download_bytes_per_second"`
+	UploadRateBps    float64 `json:"upload_bytes_per_second"`
+}
+
+// Flow holds the results of a single load-generating connection. Comparing the flows
+// shows whether the network treats them unfairly (or whether some of them stalled).
+type Flow struct {
+	Direction string  `json:"direction"`
+	Id        uint64  `json:"id"`
+	Bytes     uint64  `json:"bytes"`
+	Seconds   float64 `json:"seconds"`
+	RateBps   float64 `json:"bytes_per_second"`
+}
@@ -16,31 +16,30 @@ package timeoutat
 
 import (
 	"context"
-	"fmt"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/clock"
 	"github.com/network-quality/goresponsiveness/debug"
 )
 
+// TimeoutAt sends on the returned channel once _when_ has come by _clk_ (or _ctx_ is
+// done).
 func TimeoutAt(
 	ctx context.Context,
 	when time.Time,
+	clk clock.Clock,
 	debugLevel debug.DebugLevel,
 ) (response chan interface{}) {
 	response = make(chan interface{})
 	go func(ctx context.Context) {
 		go func() {
-			if debug.IsDebug(debugLevel) {
-				fmt.Printf("Timeout expected to end at %v\n", when)
-			}
+			debug.Log(debugLevel, "timeout.scheduled", "at", when)
 			select {
-			case <-time.After(when.Sub(time.Now())):
+			case <-clk.After(when.Sub(clk.Now())):
 			case <-ctx.Done():
 			}
 			response <- struct{}{}
-			if debug.IsDebug(debugLevel) {
-				fmt.Printf("Timeout ended at %v\n", time.Now())
-			}
+			debug.Log(debugLevel, "timeout.ended")
 		}()
 	}(ctx)
 	return
@@ -0,0 +1,44 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package timeoutat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/clock"
+	"github.com/network-quality/goresponsiveness/debug"
+)
+
+func TestTimeoutAt(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	timeout := TimeoutAt(context.Background(), fake.Now().Add(time.Minute), fake, debug.Error)
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(59 * time.Second)
+	select {
+	case <-timeout:
+		t.Fatalf("Expected no timeout before the deadline.")
+	case <-time.After(10 * time.Millisecond):
+	}
+	fake.Advance(time.Second)
+	select {
+	case <-timeout:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a timeout at the deadline.")
+	}
+}
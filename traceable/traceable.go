@@ -19,9 +19,19 @@ import (
 	"net/http/httptrace"
 	"time"
 
+	"github.com/network-quality/goresponsiveness/clock"
 	"github.com/network-quality/goresponsiveness/debug"
 )
 
+// The clock by which the tracers time the requests.
+var clk clock.Clock = clock.Real{}
+
+// SetClock makes the tracers time the requests by _c_ (that by which the probes are
+// timed; see rpm.SetClock).
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
 type Traceable interface {
 	SetDnsStartTimeInfo(time.Time, httptrace.DNSStartInfo)
 	SetDnsDoneTimeInfo(time.Time, httptrace.DNSDoneInfo)
@@ -41,34 +51,34 @@ func GenerateHttpTimingTracer(
 ) *httptrace.ClientTrace {
 	tracer := httptrace.ClientTrace{
 		DNSStart: func(dnsStartInfo httptrace.DNSStartInfo) {
-			traceable.SetDnsStartTimeInfo(time.Now(), dnsStartInfo)
+			traceable.SetDnsStartTimeInfo(clk.Now(), dnsStartInfo)
 		},
 		DNSDone: func(dnsDoneInfo httptrace.DNSDoneInfo) {
-			traceable.SetDnsDoneTimeInfo(time.Now(), dnsDoneInfo)
+			traceable.SetDnsDoneTimeInfo(clk.Now(), dnsDoneInfo)
 		},
 		ConnectStart: func(network, address string) {
-			traceable.SetConnectStartTime(time.Now())
+			traceable.SetConnectStartTime(clk.Now())
 		},
 		ConnectDone: func(network, address string, err error) {
-			traceable.SetConnectDoneTimeError(time.Now(), err)
+			traceable.SetConnectDoneTimeError(clk.Now(), err)
 		},
 		GetConn: func(hostPort string) {
-			traceable.SetGetConnTime(time.Now())
+			traceable.SetGetConnTime(clk.Now())
 		},
 		GotConn: func(connInfo httptrace.GotConnInfo) {
-			traceable.SetGotConnTimeInfo(time.Now(), connInfo)
+			traceable.SetGotConnTimeInfo(clk.Now(), connInfo)
 		},
 		TLSHandshakeStart: func() {
-			traceable.SetTLSHandshakeStartTime(time.Now())
+			traceable.SetTLSHandshakeStartTime(clk.Now())
 		},
 		TLSHandshakeDone: func(tlsConnState tls.ConnectionState, err error) {
-			traceable.SetTLSHandshakeDoneTimeState(time.Now(), tlsConnState)
+			traceable.SetTLSHandshakeDoneTimeState(clk.Now(), tlsConnState)
 		},
 		WroteRequest: func(wroteRequest httptrace.WroteRequestInfo) {
-			traceable.SetHttpWroteRequestTimeInfo(time.Now(), wroteRequest)
+			traceable.SetHttpWroteRequestTimeInfo(clk.Now(), wroteRequest)
 		},
 		GotFirstResponseByte: func() {
-			traceable.SetHttpResponseReadyTime(time.Now())
+			traceable.SetHttpResponseReadyTime(clk.Now())
 		},
 	}
 	return &tracer
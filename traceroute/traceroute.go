@@ -0,0 +1,216 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package traceroute traces the path to a host with ICMP echo requests of increasing
+// TTL. Like Paris traceroute, it keeps the fields that load balancers hash on (the
+// identifier and the checksum) the same for every probe, so that all of them follow
+// the same path, and comparing a trace of the idle network with one under load shows
+// at which hop the latency grows.
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+// The types of the ICMP (and ICMPv6) messages that matter to a trace.
+const (
+	echoRequest    = 8
+	echoReply      = 0
+	timeExceeded   = 11
+	echoRequestV6  = 128
+	echoReplyV6    = 129
+	timeExceededV6 = 3
+)
+
+// Hop is what was learned about the hop at TTL. Address is empty when nothing
+// answered.
+type Hop struct {
+	TTL            int
+	Address        string
+	RoundTripTimes []time.Duration
+}
+
+// Median returns the median of the round-trip times to the hop (or 0 when it did not
+// answer).
+func (h Hop) Median() time.Duration {
+	if len(h.RoundTripTimes) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), h.RoundTripTimes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+type probe struct {
+	ttl  int
+	sent time.Time
+}
+
+type Tracer struct {
+	conn        net.PacketConn
+	destination *net.IPAddr
+	v6          bool
+	id          int
+	sequence    int
+	probes      map[int]probe
+}
+
+// NewTracer prepares tracing the path to _host_. It needs a raw socket (and,
+// therefore, privileges).
+func NewTracer(host string) (*Tracer, error) {
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve %s for the traceroute: %v", host, err)
+	}
+	tracer := &Tracer{destination: addr, id: os.Getpid() & 0xffff}
+	network := "ip4:icmp"
+	if addr.IP.To4() == nil {
+		tracer.v6, network = true, "ip6:ipv6-icmp"
+	}
+	if tracer.conn, err = net.ListenPacket(network, ""); err != nil {
+		return nil, fmt.Errorf("Could not open a raw socket for the traceroute (it needs privileges): %v", err)
+	}
+	return tracer, nil
+}
+
+func (t *Tracer) Close() error {
+	return t.conn.Close()
+}
+
+// marshalEcho builds the echo request with _sequence_. The first two bytes of the
+// payload are the complement of the sequence number, so the checksum is the same for
+// every probe. (The kernel fills in the checksum of ICMPv6, which also covers the
+// addresses, but those stay the same, too.)
+func marshalEcho(request byte, id int, sequence int) []byte {
+	message := make([]byte, 8+2)
+	message[0] = request
+	binary.BigEndian.PutUint16(message[4:], uint16(id))
+	binary.BigEndian.PutUint16(message[6:], uint16(sequence))
+	binary.BigEndian.PutUint16(message[8:], ^uint16(sequence))
+	if request == echoRequest {
+		binary.BigEndian.PutUint16(message[2:], checksum(message))
+	}
+	return message
+}
+
+func checksum(message []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(message); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(message[i:]))
+	}
+	if len(message)%2 == 1 {
+		sum += uint32(message[len(message)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// parseReply returns the identifier and sequence number of the echo request that
+// _packet_ answers and whether it came from the destination (rather than from a hop
+// on the way, in which case the request is quoted after the hop's IP header).
+func parseReply(v6 bool, packet []byte) (id int, sequence int, fromDestination bool, ok bool) {
+	reply, exceeded, request := byte(echoReply), byte(timeExceeded), byte(echoRequest)
+	if v6 {
+		reply, exceeded, request = echoReplyV6, timeExceededV6, echoRequestV6
+	}
+	if len(packet) < 8 {
+		return 0, 0, false, false
+	}
+	switch packet[0] {
+	case reply:
+		return int(binary.BigEndian.Uint16(packet[4:])), int(binary.BigEndian.Uint16(packet[6:])), true, true
+	case exceeded:
+		quoted := packet[8:]
+		headerLength := 40
+		if !v6 {
+			if len(quoted) < 1 {
+				return 0, 0, false, false
+			}
+			headerLength = int(quoted[0]&0x0f) * 4
+		}
+		if len(quoted) < headerLength+8 || quoted[headerLength] != request {
+			return 0, 0, false, false
+		}
+		quoted = quoted[headerLength:]
+		return int(binary.BigEndian.Uint16(quoted[4:])), int(binary.BigEndian.Uint16(quoted[6:])), false, true
+	}
+	return 0, 0, false, false
+}
+
+// Trace sends _rounds_ rounds of probes with TTLs from 1 to _maxHops_ and waits up to
+// _timeout_ for the answers to each round. The trace ends at the first hop that is
+// the destination (or, when the destination did not answer, at the last hop that
+// did).
+func (t *Tracer) Trace(ctx context.Context, maxHops int, rounds int, timeout time.Duration) []Hop {
+	hops := make([]Hop, maxHops)
+	for i := range hops {
+		hops[i].TTL = i + 1
+	}
+	// The smallest TTL at which the destination answered.
+	pathLength := maxHops
+	packet := make([]byte, 1500)
+
+	for round := 0; round < rounds && ctx.Err() == nil; round++ {
+		t.probes = make(map[int]probe)
+		for ttl := 1; ttl <= pathLength; ttl++ {
+			if err := setTTL(t.conn, t.v6, ttl); err != nil {
+				continue
+			}
+			sequence := t.sequence & 0xffff
+			t.sequence++
+			request := byte(echoRequest)
+			if t.v6 {
+				request = echoRequestV6
+			}
+			t.probes[sequence] = probe{ttl: ttl, sent: time.Now()}
+			t.conn.WriteTo(marshalEcho(request, t.id, sequence), t.destination)
+		}
+
+		t.conn.SetReadDeadline(time.Now().Add(timeout))
+		for len(t.probes) > 0 && ctx.Err() == nil {
+			n, from, err := t.conn.ReadFrom(packet)
+			if err != nil {
+				break
+			}
+			now := time.Now()
+			id, sequence, fromDestination, ok := parseReply(t.v6, packet[:n])
+			if !ok || id != t.id {
+				continue
+			}
+			sent, ok := t.probes[sequence]
+			if !ok {
+				continue
+			}
+			delete(t.probes, sequence)
+			hop := &hops[sent.ttl-1]
+			hop.Address = from.String()
+			hop.RoundTripTimes = append(hop.RoundTripTimes, now.Sub(sent.sent))
+			if fromDestination && sent.ttl < pathLength {
+				pathLength = sent.ttl
+			}
+		}
+	}
+	for pathLength > 0 && hops[pathLength-1].Address == "" {
+		pathLength--
+	}
+	return hops[:pathLength]
+}
@@ -0,0 +1,54 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package traceroute
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConstantChecksum(t *testing.T) {
+	first := marshalEcho(echoRequest, 0x1234, 1)
+	for sequence := 2; sequence < 100; sequence++ {
+		request := marshalEcho(echoRequest, 0x1234, sequence)
+		if checksum(request) != 0 {
+			t.Fatalf("The checksum of %v is wrong.", request)
+		}
+		if request[2] != first[2] || request[3] != first[3] {
+			t.Fatalf("The checksum changed with sequence number %d.", sequence)
+		}
+	}
+}
+
+func TestParseTimeExceeded(t *testing.T) {
+	request := marshalEcho(echoRequest, 0x1234, 7)
+	// The time exceeded message quotes the IP header (of 20 bytes) and the request.
+	packet := append([]byte{timeExceeded, 0, 0, 0, 0, 0, 0, 0, 0x45}, make([]byte, 19)...)
+	packet = append(packet, request...)
+	id, sequence, fromDestination, ok := parseReply(false, packet)
+	if !ok || fromDestination || id != 0x1234 || sequence != 7 {
+		t.Fatalf("Expected a hop's answer to 0x1234/7 but got %x/%d (%v, %v).", id, sequence, fromDestination, ok)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	hop := Hop{RoundTripTimes: []time.Duration{3 * time.Second, time.Second, 2 * time.Second}}
+	if hop.Median() != 2*time.Second {
+		t.Fatalf("Expected a median of 2s but got %v.", hop.Median())
+	}
+	if (Hop{}).Median() != 0 {
+		t.Fatalf("Expected a median of 0 for a silent hop.")
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package tui renders the state of a test as it runs on a (ANSI) terminal: sparklines of
+// throughput, probe latency and flow counts and a big RPM figure.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/network-quality/goresponsiveness/events"
+	"github.com/network-quality/goresponsiveness/live"
+	"github.com/network-quality/goresponsiveness/utilities"
+)
+
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+
+	// The number of samples (one per refresh) shown in the sparklines.
+	historyLength = 60
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders _values_ as a sparkline scaled between 0 and the largest value.
+func Sparkline(values []float64) string {
+	maximum := 0.0
+	for _, value := range values {
+		maximum = math.Max(maximum, value)
+	}
+	var builder strings.Builder
+	for _, value := range values {
+		index := 0
+		if maximum > 0 {
+			index = int(value / maximum * float64(len(sparks)-1))
+		}
+		builder.WriteRune(sparks[index])
+	}
+	return builder.String()
+}
+
+// Each digit is 5 rows of 3 columns.
+var bigDigits = [10][5]string{
+	{"███", "█ █", "█ █", "█ █", "███"},
+	{"  █", "  █", "  █", "  █", "  █"},
+	{"███", "  █", "███", "█  ", "███"},
+	{"███", "  █", "███", "  █", "███"},
+	{"█ █", "█ █", "███", "  █", "  █"},
+	{"███", "█  ", "███", "  █", "███"},
+	{"███", "█  ", "███", "█ █", "███"},
+	{"███", "  █", "  █", "  █", "  █"},
+	{"███", "█ █", "███", "█ █", "███"},
+	{"███", "█ █", "███", "  █", "███"},
+}
+
+// BigNumber renders _n_ in (5-line high) big digits.
+func BigNumber(n uint64) string {
+	digits := fmt.Sprintf("%d", n)
+	rows := make([]string, 5)
+	for _, digit := range digits {
+		for row := range rows {
+			rows[row] += bigDigits[digit-'0'][row] + " "
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+type history struct {
+	values []float64
+}
+
+func (h *history) add(value float64) {
+	h.values = append(h.values, value)
+	if len(h.values) > historyLength {
+		h.values = h.values[len(h.values)-historyLength:]
+	}
+}
+
+// Screen is an events.Handler that keeps the history of a test so that it can draw it.
+// The overall state comes from a live.Reporter; the probe latency sparklines show the
+// largest probe duration during each refresh interval.
+type Screen struct {
+	*live.Reporter
+	lock            sync.Mutex
+	intervalSelf    float64
+	intervalForeign float64
+
+	download, upload, flows, self, foreign history
+}
+
+func NewScreen(start time.Time) *Screen {
+	return &Screen{Reporter: live.NewReporter(start)}
+}
+
+func (s *Screen) OnProbeResult(source string, result events.ProbeResult) {
+	s.Reporter.OnProbeResult(source, result)
+	if result.Cached {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if result.Foreign {
+		s.intervalForeign = math.Max(s.intervalForeign, result.Duration.Seconds())
+	} else {
+		s.intervalSelf = math.Max(s.intervalSelf, result.Duration.Seconds())
+	}
+}
+
+func (s *Screen) render(now time.Time) string {
+	interim := s.Reporter.Interim(now)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.download.add(utilities.ToMbps(interim.DownloadRateBps))
+	s.upload.add(utilities.ToMbps(interim.UploadRateBps))
+	s.flows.add(float64(interim.DownloadFlows + interim.UploadFlows))
+	s.self.add(s.intervalSelf)
+	s.foreign.add(s.intervalForeign)
+	s.intervalSelf, s.intervalForeign = 0, 0
+
+	var builder strings.Builder
+	builder.WriteString(clearScreen)
+	fmt.Fprintf(&builder, "%sGo Responsiveness%s  %5.1fs  (%s)\n\n", bold, reset, interim.Elapsed, interim.Phase)
+	builder.WriteString(BigNumber(uint64(math.Round(interim.RPM))))
+	builder.WriteString("  RPM\n\n")
+	fmt.Fprintf(&builder, "Download %9.3f Mbps  %s\n", utilities.ToMbps(interim.DownloadRateBps), Sparkline(s.download.values))
+	fmt.Fprintf(&builder, "Upload   %9.3f Mbps  %s\n", utilities.ToMbps(interim.UploadRateBps), Sparkline(s.upload.values))
+	fmt.Fprintf(&builder, "Flows    %9d       %s\n", interim.DownloadFlows+interim.UploadFlows, Sparkline(s.flows.values))
+	fmt.Fprintf(&builder, "LG RTT   %9.3f s     %s\n", interim.SelfProbeP90, Sparkline(s.self.values))
+	fmt.Fprintf(&builder, "NC RTT   %9.3f s     %s\n", interim.ForeignProbeP90, Sparkline(s.foreign.values))
+	builder.WriteString("\n(RTTs are the P90s so far; the sparklines show the slowest probe of each interval.)\n")
+	return builder.String()
+}
+
+// Run redraws the screen on _w_ every _interval_ until _ctx_ is canceled.
+func (s *Screen) Run(ctx context.Context, interval time.Duration, w io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			io.WriteString(w, s.render(now))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package tui
+
+import (
+	"testing"
+)
+
+func TestSparkline(t *testing.T) {
+	if sparkline := Sparkline([]float64{0, 1, 2, 7}); sparkline != "▁▂▃█" {
+		t.Fatalf("Unexpected sparkline: %s", sparkline)
+	}
+	if sparkline := Sparkline([]float64{0, 0}); sparkline != "▁▁" {
+		t.Fatalf("Unexpected sparkline for all zeros: %s", sparkline)
+	}
+}
+
+func TestBigNumber(t *testing.T) {
+	expected := "  █ ███ \n  █   █ \n  █ ███ \n  █ █   \n  █ ███ "
+	if big := BigNumber(12); big != expected {
+		t.Fatalf("Unexpected big number:\n%s", big)
+	}
+}
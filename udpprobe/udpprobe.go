@@ -0,0 +1,179 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package udpprobe measures the latency of UDP next to the HTTP probes (much like
+// irtt): the client sends small, numbered packets to an echo server (see Serve), which
+// sends each of them straight back.
+package udpprobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Every packet is the magic followed by its (big-endian) sequence number.
+var magic = []byte("NQUP")
+
+const packetLength = 4 + 8
+
+// Sample is the round trip of the packet that was sent at Time (or its loss).
+type Sample struct {
+	Time     time.Time
+	Duration time.Duration
+	Lost     bool
+}
+
+type Prober struct {
+	lock     sync.Mutex
+	conn     net.Conn
+	sequence uint64
+	sent     map[uint64]time.Time
+	samples  []Sample
+	done     chan struct{}
+}
+
+func NewProber(address string) (*Prober, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not set up the UDP probes to %s: %v", address, err)
+	}
+	return &Prober{conn: conn, sent: make(map[uint64]time.Time), done: make(chan struct{})}, nil
+}
+
+func (p *Prober) send(now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	packet := make([]byte, packetLength)
+	copy(packet, magic)
+	binary.BigEndian.PutUint64(packet[len(magic):], p.sequence)
+	p.sent[p.sequence] = now
+	p.sequence++
+	// A packet that could not be sent eventually counts as lost.
+	p.conn.Write(packet)
+}
+
+func (p *Prober) receive(packet []byte, now time.Time) {
+	if len(packet) != packetLength || !bytes.HasPrefix(packet, magic) {
+		return
+	}
+	sequence := binary.BigEndian.Uint64(packet[len(magic):])
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// Late (already counted as lost) and duplicated echos are ignored.
+	if sentTime, ok := p.sent[sequence]; ok {
+		delete(p.sent, sequence)
+		p.samples = append(p.samples, Sample{Time: sentTime, Duration: now.Sub(sentTime)})
+	}
+}
+
+func (p *Prober) outstanding() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.sent)
+}
+
+// expire counts the packets that were sent more than _timeout_ before _now_ and whose
+// echo has not arrived as lost.
+func (p *Prober) expire(now time.Time, timeout time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for sequence, sentTime := range p.sent {
+		if now.Sub(sentTime) > timeout {
+			delete(p.sent, sequence)
+			p.samples = append(p.samples, Sample{Time: sentTime, Lost: true})
+		}
+	}
+}
+
+// Run sends a packet every _interval_ until _ctx_ is canceled and then waits for the
+// echos of the packets still in flight. A packet whose echo does not arrive within
+// _timeout_ counts as lost.
+func (p *Prober) Run(ctx context.Context, interval time.Duration, timeout time.Duration) {
+	defer close(p.done)
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		packet := make([]byte, packetLength+1)
+		for {
+			n, err := p.conn.Read(packet)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// E.g., an ICMP port unreachable; the packet simply counts as lost.
+				continue
+			}
+			p.receive(packet[:n], time.Now())
+			if ctx.Err() != nil && p.outstanding() == 0 {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.Now()
+			if p.outstanding() > 0 {
+				deadline = deadline.Add(timeout)
+			}
+			p.conn.SetReadDeadline(deadline)
+			<-received
+			p.conn.Close()
+			p.expire(time.Now(), 0)
+			return
+		case now := <-ticker.C:
+			p.expire(now, timeout)
+			p.send(now)
+		}
+	}
+}
+
+// Wait waits for Run to finish.
+func (p *Prober) Wait() {
+	<-p.done
+}
+
+// Samples returns the round trips (and losses) so far. Packets that are still in
+// flight are not included.
+func (p *Prober) Samples() []Sample {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]Sample(nil), p.samples...)
+}
+
+// Serve echoes every probe packet that arrives on _conn_ back to its sender until
+// reading from _conn_ fails.
+func Serve(conn net.PacketConn) error {
+	packet := make([]byte, packetLength+1)
+	for {
+		n, address, err := conn.ReadFrom(packet)
+		if err != nil {
+			return err
+		}
+		if n != packetLength || !bytes.HasPrefix(packet, magic) {
+			continue
+		}
+		if _, err := conn.WriteTo(packet[:n], address); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package udpprobe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRoundTrips(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	defer conn.Close()
+	go Serve(conn)
+
+	prober, err := NewProber(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	prober.Run(ctx, 10*time.Millisecond, time.Second)
+
+	samples := prober.Samples()
+	if len(samples) < 10 {
+		t.Fatalf("Expected at least 10 samples but got %d.", len(samples))
+	}
+	for _, sample := range samples {
+		if sample.Lost || sample.Duration <= 0 {
+			t.Fatalf("Expected only round trips on the loopback but got %v.", sample)
+		}
+	}
+}
+
+func TestLoss(t *testing.T) {
+	prober := &Prober{sent: make(map[uint64]time.Time), done: make(chan struct{})}
+	start := time.Now()
+	prober.sent[0] = start
+	prober.sent[1] = start.Add(time.Second)
+	prober.expire(start.Add(1500*time.Millisecond), time.Second)
+	prober.receive(append(append([]byte(nil), magic...), 0, 0, 0, 0, 0, 0, 0, 0), start.Add(2*time.Second))
+	prober.receive(append(append([]byte(nil), magic...), 0, 0, 0, 0, 0, 0, 0, 1), start.Add(2*time.Second))
+
+	samples := prober.Samples()
+	if len(samples) != 2 || !samples[0].Lost || samples[1].Lost || samples[1].Duration != time.Second {
+		t.Fatalf("Expected the first packet to be lost and the second to take 1s but got %v.", samples)
+	}
+}
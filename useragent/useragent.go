@@ -0,0 +1,50 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package useragent identifies the client to the servers: every request that it sends
+// carries the same User-Agent header, so that server operators can tell its requests
+// apart in their logs (and route or rate-limit them).
+package useragent
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/network-quality/goresponsiveness/version"
+)
+
+var userAgent = Default()
+
+// Default returns the User-Agent that identifies this build of the client.
+func Default() string {
+	return fmt.Sprintf(
+		"goresponsiveness/%s (+https://github.com/network-quality/goresponsiveness)",
+		version.Get().Version,
+	)
+}
+
+// Set sets the User-Agent of all requests to _value_.
+func Set(value string) {
+	userAgent = value
+}
+
+// Get returns the User-Agent of all requests.
+func Get() string {
+	return userAgent
+}
+
+// Apply sets the User-Agent header of _request_.
+func Apply(request *http.Request) {
+	request.Header.Set("User-Agent", userAgent)
+}
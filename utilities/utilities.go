@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/url"
 	"os"
 	"reflect"
 	"sort"
@@ -67,6 +68,11 @@ func ToMBps(bytes float64) float64 {
 	return float64(bytes) / float64(1024*1024)
 }
 
+// FromMbps converts a rate in Mbps (as ToMbps reports them) into bytes per second.
+func FromMbps(mbps float64) float64 {
+	return mbps / float64(8) * float64(1024*1024)
+}
+
 type MeasurementResult struct {
 	Delay            time.Duration
 	MeasurementCount uint16
@@ -125,6 +131,33 @@ func RandBetween(max int) int {
 	return rand.New(rand.NewSource(int64(time.Now().Nanosecond()))).Int() % max
 }
 
+// The policies with which Round can round.
+const (
+	RoundNearest = "nearest"
+	RoundEven    = "even"
+	RoundDown    = "down"
+	RoundUp      = "up"
+)
+
+// Round rounds _value_ to _digits_ decimal places according to _policy_: to the nearest
+// value (halves away from zero), to the nearest even value (halves to the even
+// neighbor), down or up.
+func Round(value float64, digits int, policy string) float64 {
+	scale := math.Pow(10, float64(digits))
+	scaled := value * scale
+	switch policy {
+	case RoundEven:
+		scaled = math.RoundToEven(scaled)
+	case RoundDown:
+		scaled = math.Floor(scaled)
+	case RoundUp:
+		scaled = math.Ceil(scaled)
+	default:
+		scaled = math.Round(scaled)
+	}
+	return scaled / scale
+}
+
 func Max(x, y uint64) uint64 {
 	if x > y {
 		return x
@@ -140,6 +173,35 @@ func ChannelToSlice[S any](channel <-chan S) (slice []S) {
 	return
 }
 
+// AppendBounded appends _element_ to _elements_ but keeps at most _limit_ (when it is
+// positive) of them: once the limit is reached, the oldest quarter is dropped (so that
+// dropping is not done on every append). It returns the elements and how many of them
+// it dropped.
+func AppendBounded[S any](elements []S, element S, limit int) ([]S, int) {
+	dropped := 0
+	if limit > 0 && len(elements) >= limit {
+		dropped = len(elements) - limit + 1
+		if quarter := limit / 4; dropped < quarter {
+			dropped = quarter
+		}
+		elements = elements[:copy(elements, elements[dropped:])]
+	}
+	return append(elements, element), dropped
+}
+
+// ChannelToBoundedSlice is ChannelToSlice but it keeps at most _limit_ (when it is
+// positive) of the most recent elements (see AppendBounded). It returns the elements and
+// how many of them it dropped.
+func ChannelToBoundedSlice[S any](channel <-chan S, limit int) (slice []S, dropped int) {
+	slice = make([]S, 0)
+	for element := range channel {
+		var justDropped int
+		slice, justDropped = AppendBounded(slice, element, limit)
+		dropped += justDropped
+	}
+	return
+}
+
 func Fmap[S any, F any](elements []S, mapper func(S) F) []F {
 	result := make([]F, 0)
 	for _, s := range elements {
@@ -148,10 +210,25 @@ func Fmap[S any, F any](elements []S, mapper func(S) F) []F {
 	return result
 }
 
+func Filter[S any](elements []S, keep func(S) bool) []S {
+	result := make([]S, 0)
+	for _, s := range elements {
+		if keep(s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// CalculatePercentile determines the _percentile_th percentile (by nearest rank) of
+// _elements_ (which it sorts).
 func CalculatePercentile[S float32 | int32 | float64 | int64](elements []S, percentile int) S {
 	sort.Slice(elements, func(a, b int) bool { return elements[a] < elements[b] })
 	elementsCount := len(elements)
-	percentileIdx := elementsCount * (percentile / 100)
+	percentileIdx := (elementsCount*percentile+99)/100 - 1
+	if percentileIdx < 0 {
+		percentileIdx = 0
+	}
 	return elements[percentileIdx]
 }
 
@@ -176,6 +253,26 @@ func OrTimeout(f func(), timeout time.Duration) {
 	}
 }
 
+// The name of the query parameter that AddCacheBuster adds.
+const CacheBusterParameter = "nq_cache_buster"
+
+// AddCacheBuster adds a unique query parameter to _rawUrl_ so that caches between the
+// client and the server cannot answer a request for it. If _rawUrl_ cannot be parsed,
+// it is returned unmodified.
+func AddCacheBuster(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := parsed.Query()
+	query.Set(
+		CacheBusterParameter,
+		fmt.Sprintf("%x-%x", time.Now().UnixNano(), GenerateUniqueId()),
+	)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
 func FilenameAppend(filename, appendage string) string {
 	pieces := strings.SplitN(filename, ".", 2)
 	result := pieces[0] + appendage
@@ -184,3 +281,111 @@ func FilenameAppend(filename, appendage string) string {
 	}
 	return result
 }
+
+// StreamingPercentile estimates a percentile of a stream of values without keeping
+// them: it implements the P² algorithm (Jain and Chlamtac, "The P² Algorithm for Dynamic
+// Calculation of Quantiles and Histograms Without Storing Observations"), which tracks
+// five markers (the minimum, the maximum, the percentile and two between) and adjusts
+// their heights with a piecewise-parabolic fit as values arrive. Until there are five
+// values, the percentile is exact (by nearest rank, like CalculatePercentile).
+type StreamingPercentile struct {
+	percentile int
+	count      int
+	heights    [5]float64
+	positions  [5]float64
+	desired    [5]float64
+	increments [5]float64
+}
+
+// NewStreamingPercentile creates an estimator of the _percentile_th percentile.
+func NewStreamingPercentile(percentile int) *StreamingPercentile {
+	p := float64(percentile) / 100
+	return &StreamingPercentile{
+		percentile: percentile,
+		positions:  [5]float64{1, 2, 3, 4, 5},
+		desired:    [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		increments: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+// Count is the number of values added so far.
+func (s *StreamingPercentile) Count() int {
+	return s.count
+}
+
+// Add adds _value_ to the stream.
+func (s *StreamingPercentile) Add(value float64) {
+	if s.count < len(s.heights) {
+		s.heights[s.count] = value
+		s.count++
+		if s.count == len(s.heights) {
+			sort.Float64s(s.heights[:])
+		}
+		return
+	}
+	s.count++
+
+	// Find the cell of the value (extending the extremes when it is outside them) and
+	// move the markers above it.
+	var cell int
+	switch {
+	case value < s.heights[0]:
+		s.heights[0] = value
+		cell = 0
+	case value >= s.heights[4]:
+		s.heights[4] = value
+		cell = 3
+	default:
+		for cell = 0; cell < 3 && value >= s.heights[cell+1]; cell++ {
+		}
+	}
+	for i := cell + 1; i < len(s.positions); i++ {
+		s.positions[i]++
+	}
+	for i := range s.desired {
+		s.desired[i] += s.increments[i]
+	}
+
+	// Move the middle markers (by one position) that are off their desired positions.
+	for i := 1; i <= 3; i++ {
+		delta := s.desired[i] - s.positions[i]
+		if (delta >= 1 && s.positions[i+1]-s.positions[i] > 1) ||
+			(delta <= -1 && s.positions[i-1]-s.positions[i] < -1) {
+			direction := 1.0
+			if delta < 0 {
+				direction = -1.0
+			}
+			height := s.parabolic(i, direction)
+			if s.heights[i-1] < height && height < s.heights[i+1] {
+				s.heights[i] = height
+			} else {
+				s.heights[i] = s.linear(i, direction)
+			}
+			s.positions[i] += direction
+		}
+	}
+}
+
+func (s *StreamingPercentile) parabolic(i int, direction float64) float64 {
+	n, h := s.positions, s.heights
+	return h[i] + direction/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+direction)*(h[i+1]-h[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-direction)*(h[i]-h[i-1])/(n[i]-n[i-1]))
+}
+
+func (s *StreamingPercentile) linear(i int, direction float64) float64 {
+	j := i + int(direction)
+	return s.heights[i] + direction*(s.heights[j]-s.heights[i])/(s.positions[j]-s.positions[i])
+}
+
+// Value is the (estimated) percentile of the values added so far (0 when there are
+// none).
+func (s *StreamingPercentile) Value() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if s.count < len(s.heights) {
+		return CalculatePercentile(append([]float64(nil), s.heights[:s.count]...), s.percentile)
+	}
+	return s.heights[2]
+}
@@ -14,6 +14,8 @@
 package utilities
 
 import (
+	"math/rand"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -73,3 +75,115 @@ func TestFilenameAppend(t *testing.T) {
 		t.Fatalf("%s != %s for FilenameAppend.", expected, result)
 	}
 }
+
+func TestAddCacheBuster(t *testing.T) {
+	busted := AddCacheBuster("https://example.com/small?size=1")
+	parsed, err := url.Parse(busted)
+	if err != nil {
+		t.Fatalf("Could not parse the cache-busted URL %s: %v", busted, err)
+	}
+	if parsed.Query().Get("size") != "1" || parsed.Query().Get(CacheBusterParameter) == "" {
+		t.Fatalf("Cache busting did not preserve the query or add a cache buster: %s", busted)
+	}
+	if busted == AddCacheBuster("https://example.com/small?size=1") {
+		t.Fatalf("Cache busting the same URL twice gave the same result: %s", busted)
+	}
+}
+
+func TestRound(t *testing.T) {
+	for _, test := range []struct {
+		value    float64
+		digits   int
+		policy   string
+		expected float64
+	}{
+		{1234.5, 0, RoundNearest, 1235},
+		{1234.5, 0, RoundEven, 1234},
+		{1234.9, 0, RoundDown, 1234},
+		{1234.1, 0, RoundUp, 1235},
+		{12.3456, 2, RoundNearest, 12.35},
+		{12.3456, 2, RoundDown, 12.34},
+	} {
+		if rounded := Round(test.value, test.digits, test.policy); rounded != test.expected {
+			t.Fatalf("Rounding %v to %d digits (%s) gave %v rather than %v", test.value, test.digits, test.policy, rounded, test.expected)
+		}
+	}
+}
+
+func TestFromMbps(t *testing.T) {
+	for _, mbps := range []float64{0, 1, 40, 100, 400} {
+		if converted := ToMbps(FromMbps(mbps)); converted != mbps {
+			t.Fatalf("Expected %v Mbps but got %v", mbps, converted)
+		}
+	}
+}
+
+func TestCalculatePercentile(t *testing.T) {
+	elements := []float64{10, 1, 9, 2, 8, 3, 7, 4, 6, 5}
+	for _, test := range []struct {
+		percentile int
+		expected   float64
+	}{
+		{0, 1},
+		{50, 5},
+		{90, 9},
+		{95, 10},
+		{100, 10},
+	} {
+		if actual := CalculatePercentile(elements, test.percentile); actual != test.expected {
+			t.Fatalf("The %dth percentile is %v (expected %v).", test.percentile, actual, test.expected)
+		}
+	}
+}
+
+func TestAppendBounded(t *testing.T) {
+	elements, dropped := make([]int, 0), 0
+	for i := 0; i < 100; i++ {
+		var justDropped int
+		elements, justDropped = AppendBounded(elements, i, 8)
+		dropped += justDropped
+		if len(elements) > 8 {
+			t.Fatalf("There are %d elements (expected at most 8).", len(elements))
+		}
+	}
+	if elements[len(elements)-1] != 99 || dropped+len(elements) != 100 {
+		t.Fatalf("Kept %v and dropped %d of 100 elements.", elements, dropped)
+	}
+	for i := 1; i < len(elements); i++ {
+		if elements[i] != elements[i-1]+1 {
+			t.Fatalf("Kept %v rather than the most recent elements.", elements)
+		}
+	}
+	if unbounded, dropped := AppendBounded([]int{1, 2}, 3, 0); len(unbounded) != 3 || dropped != 0 {
+		t.Fatalf("Without a limit, appending gave %v (and dropped %d).", unbounded, dropped)
+	}
+}
+
+func TestStreamingPercentile(t *testing.T) {
+	few := NewStreamingPercentile(90)
+	for _, element := range []float64{3, 1, 2} {
+		few.Add(element)
+	}
+	if actual := few.Value(); actual != 3 {
+		t.Fatalf("The 90th percentile of 3 values is %v (expected 3).", actual)
+	}
+
+	generator := rand.New(rand.NewSource(1))
+	for _, percentile := range []int{50, 90, 99} {
+		streaming := NewStreamingPercentile(percentile)
+		elements := make([]float64, 0, 100000)
+		for i := 0; i < cap(elements); i++ {
+			// Round-trip times are skewed: mostly short with a long tail.
+			element := 0.02 + generator.ExpFloat64()*0.05
+			streaming.Add(element)
+			elements = append(elements, element)
+		}
+		expected := CalculatePercentile(elements, percentile)
+		if actual := streaming.Value(); AbsPercentDifference(actual, expected) > 2 {
+			t.Fatalf("The estimated %dth percentile is %v (expected about %v).", percentile, actual, expected)
+		}
+		if streaming.Count() != len(elements) {
+			t.Fatalf("The estimator counted %d values (expected %d).", streaming.Count(), len(elements))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package version identifies the build of the client, so that results can be traced
+// back to the client that produced them. Builds can set the identification with the
+// linker, e.g.:
+//
+//	go build -ldflags "-X github.com/network-quality/goresponsiveness/version.Version=v1.0.0 \
+//	  -X github.com/network-quality/goresponsiveness/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/network-quality/goresponsiveness/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Otherwise, it comes from what the Go toolchain recorded in the binary (if anything).
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set by the linker (see above).
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// What is reported when a part of the identification is not known.
+const unknown = "unknown"
+
+// Info identifies a build of the client.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the identification of the running client.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+			info.Version = buildInfo.Main.Version
+		}
+		modified := false
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	for _, part := range []*string{&info.Version, &info.Commit, &info.Date} {
+		if *part == "" {
+			*part = unknown
+		}
+	}
+	return info
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf(
+		"goresponsiveness %s (commit %s, built %s with %s)",
+		i.Version,
+		i.Commit,
+		i.Date,
+		i.GoVersion,
+	)
+}
@@ -0,0 +1,30 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package version
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	Version, Commit, Date = "v1.2.3", "abcdef", "2022-06-01T00:00:00Z"
+	defer func() { Version, Commit, Date = "", "", "" }()
+
+	info := Get()
+	if info.Version != Version || info.Commit != Commit || info.Date != Date {
+		t.Fatalf("Expected the identification from the linker but got %v", info)
+	}
+	if info.GoVersion == "" {
+		t.Fatalf("Expected the version of Go but got none")
+	}
+}
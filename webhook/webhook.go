@@ -0,0 +1,69 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/network-quality/goresponsiveness/constants"
+	"github.com/network-quality/goresponsiveness/summary"
+	"github.com/network-quality/goresponsiveness/useragent"
+)
+
+// The header that carries the signature of the body when a secret is configured. Its
+// value is "sha256=" followed by the hex-encoded HMAC-SHA256 of the body.
+const SignatureHeader = "X-Signature-256"
+
+// Sign calculates the value of the signature header for _body_ using _secret_.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify POSTs _s_ (as JSON) to _url_. When _secret_ is not empty, the request carries
+// a signature so that the receiver can verify where it came from.
+func Notify(url string, secret string, s *summary.Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("Could not format the results for the webhook: %v", err)
+	}
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Could not create the webhook request for %s: %v", url, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	useragent.Apply(request)
+	if secret != "" {
+		request.Header.Set(SignatureHeader, Sign(body, secret))
+	}
+
+	client := &http.Client{Timeout: constants.WebhookTimeout}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not notify webhook %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Notifying webhook %s failed: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/network-quality/goresponsiveness/summary"
+)
+
+func TestSign(t *testing.T) {
+	// Test case 2 of RFC 4231.
+	expected := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if signature := Sign([]byte("what do ya want for nothing?"), "Jefe"); signature != expected {
+		t.Fatalf("Expected the signature %s but got %s", expected, signature)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var body []byte
+	signature, contentType := "", ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	if err := Notify(server.URL, "Jefe", &summary.Summary{RPM: 42}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if contentType != "application/json" || signature != Sign(body, "Jefe") {
+		t.Fatalf("Expected signed JSON but got %s signed with %q", contentType, signature)
+	}
+
+	if err := Notify(server.URL, "", &summary.Summary{RPM: 42}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if signature != "" {
+		t.Fatalf("Expected no signature without a secret but got %s", signature)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package wire estimates how many bytes cross the network for the application data
+// (goodput) of a test: the HTTP/2 framing, TLS records and TCP/IP headers around it
+// add up to several percent, which matters when comparing with measurements taken at
+// the packet level.
+package wire
+
+import "github.com/network-quality/goresponsiveness/constants"
+
+const (
+	// The largest payload of an HTTP/2 DATA frame (by default) and its header.
+	http2MaxFramePayload = 16384
+	http2FrameHeader     = 9
+	// The largest plaintext of a TLS record and the record's overhead (the header,
+	// the AEAD tag and, for TLS 1.3, the inner content type).
+	tlsMaxRecordPlaintext = 16384
+	tlsRecordOverhead     = 5 + 16 + 1
+	// The headers of a TCP segment (with the timestamp option) and of the IP packet
+	// that carries it.
+	tcpHeader  = 32
+	ipv4Header = 20
+	ipv6Header = 40
+)
+
+// Overhead is the ratio of the bytes on the wire (IP packets) to the goodput, assuming
+// full-sized frames, records and segments.
+func Overhead(ipv6 bool) float64 {
+	ipHeader := ipv4Header
+	if ipv6 {
+		ipHeader = ipv6Header
+	}
+	segmentPayload := constants.WireMTU - ipHeader - tcpHeader
+	return (float64(http2MaxFramePayload+http2FrameHeader) / http2MaxFramePayload) *
+		(float64(tlsMaxRecordPlaintext+tlsRecordOverhead) / tlsMaxRecordPlaintext) *
+		(float64(constants.WireMTU) / float64(segmentPayload))
+}
+
+// Rate estimates the rate on the wire that corresponds to _goodput_ (in the same unit).
+func Rate(goodput float64, ipv6 bool) float64 {
+	return goodput * Overhead(ipv6)
+}
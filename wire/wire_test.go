@@ -0,0 +1,31 @@
+/*
+ * This file is part of Go Responsiveness.
+ *
+ * Go Responsiveness is free software: you can redistribute it and/or modify it under
+ * the terms of the GNU General Public License as published by the Free Software Foundation,
+ * either version 2 of the License, or (at your option) any later version.
+ * Go Responsiveness is distributed in the hope that it will be useful, but WITHOUT ANY
+ * WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A
+ * PARTICULAR PURPOSE. See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along
+ * with Go Responsiveness. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package wire
+
+import "testing"
+
+func TestOverhead(t *testing.T) {
+	ipv4 := Overhead(false)
+	ipv6 := Overhead(true)
+	if ipv4 < 1.03 || ipv4 > 1.05 {
+		t.Fatalf("The IPv4 overhead of %v is implausible.", ipv4)
+	}
+	if ipv6 <= ipv4 {
+		t.Fatalf("The IPv6 overhead (%v) is not larger than the IPv4 one (%v).", ipv6, ipv4)
+	}
+	if rate := Rate(1000, false); rate != 1000*ipv4 {
+		t.Fatalf("Expected a wire rate of %v but got %v.", 1000*ipv4, rate)
+	}
+}